@@ -0,0 +1,70 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/canonical/lxd/lxd/response"
+	"github.com/canonical/lxd/shared/logger"
+	"github.com/canonical/microcluster/rest"
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/sunbeam"
+)
+
+// accessLogged wraps the actions of the given endpoints so that every
+// request is logged with its method, path, status, duration and client,
+// unless the api.access-log config key is "false".
+func accessLogged(endpoints []rest.Endpoint) []rest.Endpoint {
+	for i := range endpoints {
+		for _, action := range []*rest.EndpointAction{&endpoints[i].Get, &endpoints[i].Put, &endpoints[i].Post, &endpoints[i].Delete, &endpoints[i].Patch} {
+			if action.Handler == nil {
+				continue
+			}
+
+			handler := action.Handler
+			action.Handler = func(s *state.State, r *http.Request) response.Response {
+				if !sunbeam.AccessLogEnabled(s) {
+					return handler(s, r)
+				}
+
+				start := time.Now()
+
+				return &accessLogResponse{Response: handler(s, r), r: r, start: start}
+			}
+		}
+	}
+
+	return endpoints
+}
+
+// accessLogResponse logs the request once the wrapped response has been
+// rendered.
+type accessLogResponse struct {
+	response.Response
+	r     *http.Request
+	start time.Time
+}
+
+// Render renders the wrapped response and logs the request.
+func (a *accessLogResponse) Render(w http.ResponseWriter) error {
+	recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+	err := a.Response.Render(recorder)
+
+	ctx := logger.Ctx{
+		"method":   a.r.Method,
+		"path":     a.r.URL.Path,
+		"status":   recorder.status,
+		"duration": time.Since(a.start).String(),
+		"client":   requestActor(a.r),
+	}
+
+	if err != nil {
+		ctx["err"] = err
+	}
+
+	logger.Info("API request", ctx)
+
+	return err
+}
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/canonical/lxd/lxd/response"
+	"github.com/canonical/lxd/shared/logger"
+	"github.com/canonical/microcluster/rest"
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/internal/testutil"
+)
+
+// testLogger records the messages logged at info level, with their context.
+type testLogger struct {
+	messages []string
+	contexts []logger.Ctx
+}
+
+func (l *testLogger) Panic(msg string, args ...logger.Ctx) {}
+func (l *testLogger) Fatal(msg string, args ...logger.Ctx) {}
+func (l *testLogger) Error(msg string, args ...logger.Ctx) {}
+func (l *testLogger) Warn(msg string, args ...logger.Ctx)  {}
+func (l *testLogger) Debug(msg string, args ...logger.Ctx) {}
+func (l *testLogger) Trace(msg string, args ...logger.Ctx) {}
+
+func (l *testLogger) Info(msg string, args ...logger.Ctx) {
+	ctx := logger.Ctx{}
+	for _, arg := range args {
+		for key, value := range arg {
+			ctx[key] = value
+		}
+	}
+
+	l.messages = append(l.messages, msg)
+	l.contexts = append(l.contexts, ctx)
+}
+
+func (l *testLogger) AddContext(logger.Ctx) logger.Logger {
+	return l
+}
+
+func TestAccessLogged(t *testing.T) {
+	s := testutil.NewState(t)
+	issue := loadTestClientCA(t, false)
+
+	restore := logger.Log
+	t.Cleanup(func() { logger.Log = restore })
+
+	log := &testLogger{}
+	logger.Log = log
+
+	endpoints := accessLogged([]rest.Endpoint{{
+		Path: "config/{key}",
+		Put: rest.EndpointAction{Handler: func(s *state.State, r *http.Request) response.Response {
+			return response.EmptySyncResponse
+		}},
+		Delete: rest.EndpointAction{Handler: func(s *state.State, r *http.Request) response.Response {
+			return response.NotFound(nil)
+		}},
+	}})
+
+	tests := []struct {
+		action rest.EndpointAction
+		method string
+		remote string
+		certs  []*x509.Certificate
+		status int
+		client string
+	}{
+		{action: endpoints[0].Put, method: http.MethodPut, remote: "@", status: http.StatusOK, client: "local"},
+		{action: endpoints[0].Delete, method: http.MethodDelete, remote: "10.0.0.9:4321", certs: []*x509.Certificate{issue("operator").Leaf}, status: http.StatusNotFound, client: "CN=operator"},
+	}
+
+	for i, test := range tests {
+		r := httptest.NewRequest(test.method, "/1.0/config/region", nil)
+		r.RemoteAddr = test.remote
+		if test.certs != nil {
+			r.TLS = &tls.ConnectionState{PeerCertificates: test.certs}
+		}
+
+		err := test.action.Handler(s, r).Render(httptest.NewRecorder())
+		if err != nil {
+			t.Fatalf("Failed to render response: %v", err)
+		}
+
+		if len(log.messages) != i+1 || log.messages[i] != "API request" {
+			t.Fatalf("Logged %v, expected a line for each request", log.messages)
+		}
+
+		ctx := log.contexts[i]
+		if ctx["method"] != test.method || ctx["path"] != "/1.0/config/region" || ctx["status"] != test.status || ctx["client"] != test.client {
+			t.Errorf("Logged %s request with %v, expected status %d and client %q", test.method, ctx, test.status, test.client)
+		}
+
+		duration, ok := ctx["duration"].(string)
+		if !ok || duration == "" {
+			t.Errorf("Logged %s request without its duration: %v", test.method, ctx)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"github.com/canonical/lxd/lxd/response"
+	"github.com/canonical/microcluster/rest"
+	"github.com/canonical/microcluster/state"
+	"github.com/gorilla/mux"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/api/types"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/sunbeam"
+)
+
+// /1.0/allowlist endpoint.
+// Lists the system_ids that are approved to join the cluster.
+var allowlistCmd = rest.Endpoint{
+	Path: "allowlist",
+
+	Get:  rest.EndpointAction{Handler: cmdAllowlistGetAll, ProxyTarget: true},
+	Post: rest.EndpointAction{Handler: cmdAllowlistPost, ProxyTarget: true},
+}
+
+// /1.0/allowlist/<systemid> endpoint.
+var allowlistEntryCmd = rest.Endpoint{
+	Path: "allowlist/{systemid}",
+
+	Delete: rest.EndpointAction{Handler: cmdAllowlistDelete, ProxyTarget: true},
+}
+
+func cmdAllowlistGetAll(s *state.State, _ *http.Request) response.Response {
+	systemIDs, err := sunbeam.ListAllowedSystemIDs(s)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, systemIDs)
+}
+
+func cmdAllowlistPost(s *state.State, r *http.Request) response.Response {
+	var req types.AllowedSystemID
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	err = sunbeam.AddAllowedSystemID(s, req.SystemID)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.EmptySyncResponse
+}
+
+func cmdAllowlistDelete(s *state.State, r *http.Request) response.Response {
+	systemID, err := url.PathUnescape(mux.Vars(r)["systemid"])
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	err = sunbeam.DeleteAllowedSystemID(s, systemID)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.EmptySyncResponse
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/canonical/lxd/lxd/response"
+	"github.com/canonical/microcluster/rest"
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/api/types"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/sunbeam"
+)
+
+// /1.0/apply endpoint.
+// Records a manifest and its accompanying config changes atomically.
+var applyCmd = rest.Endpoint{
+	Path: "apply",
+
+	Post: rest.EndpointAction{Handler: cmdApplyPost, ProxyTarget: true, AllowUntrusted: true},
+}
+
+func cmdApplyPost(s *state.State, r *http.Request) response.Response {
+	var req types.Apply
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	warnings := sunbeam.ManifestWarnings(req.Manifest.Data)
+	for key, value := range req.Config {
+		warnings = append(warnings, sunbeam.ConfigWarnings(key, value)...)
+	}
+
+	err = sunbeam.Apply(s, req.Manifest.ManifestID, req.Manifest.Data, req.Config)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return warningsResponse("manifests/"+req.Manifest.ManifestID, warnings)
+}
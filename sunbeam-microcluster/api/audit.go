@@ -0,0 +1,218 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/canonical/lxd/lxd/response"
+	"github.com/canonical/lxd/shared"
+	"github.com/canonical/lxd/shared/logger"
+	"github.com/canonical/microcluster/rest"
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/database"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/sunbeam"
+)
+
+const (
+	// defaultAuditLimit is the page size used when no limit is requested.
+	defaultAuditLimit = 100
+	// maxAuditLimit bounds the number of audit entries returned in one page.
+	maxAuditLimit = 1000
+)
+
+// /1.0/audit endpoint.
+// Returns the audit log, filtered by target, action, actor and time range.
+// Pages are requested by passing the ID of the last entry seen as "after".
+var auditCmd = rest.Endpoint{
+	Path: "audit",
+
+	Get: rest.EndpointAction{Handler: cmdAuditGet, ProxyTarget: true},
+}
+
+// /1.0/audit/export endpoint.
+// Streams the audit log as JSON Lines, taking the same filters as
+// /1.0/audit. All matching entries are returned unless a limit is given.
+var auditExportCmd = rest.Endpoint{
+	Path: "audit/export",
+
+	Get: rest.EndpointAction{Handler: cmdAuditExportGet, ProxyTarget: true},
+}
+
+func cmdAuditGet(s *state.State, r *http.Request) response.Response {
+	filter, err := parseAuditFilter(r, defaultAuditLimit)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	entries, err := sunbeam.ListAudit(s, filter)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, entries)
+}
+
+func cmdAuditExportGet(s *state.State, r *http.Request) response.Response {
+	filter, err := parseAuditFilter(r, 0)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	return jsonlResponse(func(w http.ResponseWriter) error {
+		return sunbeam.ExportAuditJSONL(s, w, filter)
+	})
+}
+
+// parseAuditFilter builds an audit log filter from the request query.
+func parseAuditFilter(r *http.Request, limit int) (database.AuditEntryFilter, error) {
+	var err error
+
+	query := r.URL.Query()
+	filter := database.AuditEntryFilter{Limit: limit}
+
+	for name, field := range map[string]**string{"target": &filter.Target, "action": &filter.Action, "actor": &filter.Actor} {
+		if query.Has(name) {
+			value := query.Get(name)
+			*field = &value
+		}
+	}
+
+	for name, field := range map[string]**time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if query.Has(name) {
+			t, err := time.Parse(time.RFC3339, query.Get(name))
+			if err != nil {
+				return filter, fmt.Errorf("Invalid %s value: %w", name, err)
+			}
+
+			*field = &t
+		}
+	}
+
+	if query.Has("after") {
+		filter.After, err = strconv.ParseInt(query.Get("after"), 10, 64)
+		if err != nil {
+			return filter, fmt.Errorf("Invalid after value: %w", err)
+		}
+	}
+
+	if query.Has("limit") {
+		filter.Limit, err = strconv.Atoi(query.Get("limit"))
+		if err != nil || filter.Limit <= 0 {
+			return filter, fmt.Errorf("Invalid limit value %q", query.Get("limit"))
+		}
+
+		if limit > 0 && filter.Limit > maxAuditLimit {
+			filter.Limit = maxAuditLimit
+		}
+	}
+
+	return filter, nil
+}
+
+// audited wraps the mutating actions of the given endpoints so that every
+// successful request is recorded in the audit log.
+func audited(endpoints []rest.Endpoint) []rest.Endpoint {
+	for i := range endpoints {
+		for _, action := range []*rest.EndpointAction{&endpoints[i].Put, &endpoints[i].Post, &endpoints[i].Delete, &endpoints[i].Patch} {
+			if action.Handler == nil {
+				continue
+			}
+
+			handler := action.Handler
+			action.Handler = func(s *state.State, r *http.Request) response.Response {
+				return &auditResponse{Response: handler(s, r), s: s, r: r}
+			}
+		}
+	}
+
+	return endpoints
+}
+
+// auditResponse records the request in the audit log once the wrapped
+// response has been rendered without an error status.
+type auditResponse struct {
+	response.Response
+	s *state.State
+	r *http.Request
+}
+
+// Render renders the wrapped response and records the audit entry.
+func (a *auditResponse) Render(w http.ResponseWriter) error {
+	recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+	err := a.Response.Render(recorder)
+	if err != nil || recorder.status >= http.StatusBadRequest {
+		return err
+	}
+
+	target := strings.TrimPrefix(a.r.URL.Path, "/1.0/")
+	err = sunbeam.RecordAudit(a.s, requestActor(a.r), auditAction(a.r.Method), target)
+	if err != nil {
+		logger.Warn("Failed to record audit entry", logger.Ctx{"target": target, "err": err})
+	}
+
+	return nil
+}
+
+// statusRecorder remembers the status code written to the response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader records the status code and writes it to the response.
+func (w *statusRecorder) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Flush sends buffered data to the client, so that streamed responses keep
+// working when recorded.
+func (w *statusRecorder) Flush() {
+	flusher, ok := w.ResponseWriter.(http.Flusher)
+	if ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap returns the recorded response, so that http.ResponseController
+// reaches the connection.
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// auditAction maps the HTTP method of a request to the audit action.
+func auditAction(method string) string {
+	switch method {
+	case http.MethodPost:
+		return database.ChangeCreate
+	case http.MethodDelete:
+		return database.ChangeDelete
+	default:
+		return database.ChangeUpdate
+	}
+}
+
+// requestActor identifies who made a request: the identity of a client
+// certificate issued by the client CA, the fingerprint of any other client
+// certificate, "local" for the unix socket, or the remote address.
+func requestActor(r *http.Request) string {
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		identity, ok := sunbeam.CertificateIdentity(r.TLS.PeerCertificates)
+		if ok {
+			return identity
+		}
+
+		return shared.CertFingerprint(r.TLS.PeerCertificates[0])
+	}
+
+	if r.RemoteAddr == "" || r.RemoteAddr == "@" {
+		return "local"
+	}
+
+	return r.RemoteAddr
+}
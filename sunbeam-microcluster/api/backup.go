@@ -0,0 +1,63 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/canonical/lxd/lxd/response"
+	"github.com/canonical/microcluster/rest"
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/api/types"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/sunbeam"
+)
+
+// /1.0/import endpoint.
+// Restores a snapshot returned by the export endpoint. The "mode" query is
+// "merge", the default, or "replace" to delete the rows missing from the
+// snapshot. The snapshot is read as YAML with a YAML Content-Type.
+var importCmd = rest.Endpoint{
+	Path: "import",
+
+	Post: rest.EndpointAction{Handler: cmdImportPost, ProxyTarget: true},
+}
+
+// /1.0/export endpoint.
+// Returns a snapshot of the cluster state for backups. Juju user tokens are
+// only included with the "include-secrets=true" query. The snapshot is
+// returned as a bare YAML document if the Accept header asks for YAML.
+var exportCmd = rest.Endpoint{
+	Path: "export",
+
+	Get: rest.EndpointAction{Handler: cmdExportGet, ProxyTarget: true},
+}
+
+func cmdExportGet(s *state.State, r *http.Request) response.Response {
+	includeSecrets := r.URL.Query().Get("include-secrets") == "true"
+
+	export, err := sunbeam.ExportCluster(s, includeSecrets)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	if acceptsYAML(r) {
+		return yamlResponse(export)
+	}
+
+	return response.SyncResponse(true, export)
+}
+
+func cmdImportPost(s *state.State, r *http.Request) response.Response {
+	var req types.ClusterExport
+
+	err := decodeRequest(r, &req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	err = sunbeam.ImportCluster(s, req, r.URL.Query().Get("mode"))
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.EmptySyncResponse
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/canonical/lxd/shared/api"
+	"gopkg.in/yaml.v2"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/api/types"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/internal/testutil"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/sunbeam"
+)
+
+func TestExportImportYAML(t *testing.T) {
+	s := testutil.NewState(t)
+
+	config := map[string]string{"region": "RegionOne", "ceph.osds": "3"}
+	err := sunbeam.SetConfigBatch(s, config)
+	if err != nil {
+		t.Fatalf("Failed to set config: %v", err)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/1.0/export", nil)
+	r.Header.Set("Accept", "application/yaml")
+	w := httptest.NewRecorder()
+
+	err = cmdExportGet(s, r).Render(w)
+	if err != nil {
+		t.Fatalf("Failed to render export: %v", err)
+	}
+
+	if w.Header().Get("Content-Type") != "application/yaml" {
+		t.Fatalf("Export is returned as %q, expected application/yaml", w.Header().Get("Content-Type"))
+	}
+
+	exported := w.Body.Bytes()
+
+	var export types.ClusterExport
+	err = yaml.UnmarshalStrict(exported, &export)
+	if err != nil {
+		t.Fatalf("Failed to parse YAML export: %v", err)
+	}
+
+	for key, value := range config {
+		if export.Config[key] != value {
+			t.Errorf("YAML export has config key %q set to %q, expected %q", key, export.Config[key], value)
+		}
+	}
+
+	err = sunbeam.SetConfigBatch(s, map[string]string{"region": "RegionTwo", "stray": "value"})
+	if err != nil {
+		t.Fatalf("Failed to change config: %v", err)
+	}
+
+	importYAML := func(body []byte) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/1.0/import?mode=replace", bytes.NewReader(body))
+		r.Header.Set("Content-Type", "application/yaml")
+		w := httptest.NewRecorder()
+
+		err := cmdImportPost(s, r).Render(w)
+		if err != nil {
+			t.Fatalf("Failed to render import: %v", err)
+		}
+
+		return w
+	}
+
+	w = importYAML(exported)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Failed to import YAML export: %d %s", w.Code, w.Body.String())
+	}
+
+	for key, value := range config {
+		got, err := sunbeam.GetConfig(s, key)
+		if err != nil || got != value {
+			t.Errorf("Config key %q is %q (%v) after the import, expected %q", key, got, err, value)
+		}
+	}
+
+	_, err = sunbeam.GetConfig(s, "stray")
+	if !api.StatusErrorCheck(err, http.StatusNotFound) {
+		t.Errorf("Expected a key missing from the export to be deleted by the import, got %v", err)
+	}
+
+	w = importYAML([]byte(strings.Replace(string(exported), "config:", "confg:", 1)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected a YAML document with an unknown field to be rejected with 400, got %d", w.Code)
+	}
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/canonical/lxd/lxd/response"
+	"github.com/canonical/microcluster/rest"
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/sunbeam"
+)
+
+const (
+	// defaultChangesLimit is the page size used when no limit is requested.
+	defaultChangesLimit = 100
+	// maxChangesLimit bounds the number of changes returned in one page.
+	maxChangesLimit = 1000
+)
+
+// /1.0/changes endpoint.
+// Returns the changes recorded after the "since" sequence, clients
+// checkpoint the last sequence they processed and pass it on the next call.
+var changesCmd = rest.Endpoint{
+	Path: "changes",
+
+	Get: rest.EndpointAction{Handler: cmdChangesGet, ProxyTarget: true, AllowUntrusted: true},
+}
+
+// /1.0/changes/export endpoint.
+// Streams all the changes recorded after the "since" sequence as JSON Lines.
+var changesExportCmd = rest.Endpoint{
+	Path: "changes/export",
+
+	Get: rest.EndpointAction{Handler: cmdChangesExportGet, ProxyTarget: true, AllowUntrusted: true},
+}
+
+func cmdChangesGet(s *state.State, r *http.Request) response.Response {
+	since, err := parseChangesSince(r)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	limit := defaultChangesLimit
+	if r.URL.Query().Has("limit") {
+		limit, err = strconv.Atoi(r.URL.Query().Get("limit"))
+		if err != nil || limit <= 0 {
+			return response.BadRequest(fmt.Errorf("Invalid limit value %q", r.URL.Query().Get("limit")))
+		}
+
+		if limit > maxChangesLimit {
+			limit = maxChangesLimit
+		}
+	}
+
+	changes, err := sunbeam.ListChanges(s, since, limit)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, changes)
+}
+
+func cmdChangesExportGet(s *state.State, r *http.Request) response.Response {
+	since, err := parseChangesSince(r)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	return jsonlResponse(func(w http.ResponseWriter) error {
+		return sunbeam.ExportChangesJSONL(s, w, since)
+	})
+}
+
+// parseChangesSince returns the sequence given in the "since" query, 0 if unset.
+func parseChangesSince(r *http.Request) (int64, error) {
+	if !r.URL.Query().Has("since") {
+		return 0, nil
+	}
+
+	since, err := strconv.ParseInt(r.URL.Query().Get("since"), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid since value: %w", err)
+	}
+
+	return since, nil
+}
+
+// jsonlResponse returns a response streaming JSON Lines written by render.
+func jsonlResponse(render func(w http.ResponseWriter) error) response.Response {
+	return response.ManualResponse(func(w http.ResponseWriter) error {
+		clearWriteDeadline(w)
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.WriteHeader(http.StatusOK)
+
+		return render(w)
+	})
+}
+
+// clearWriteDeadline lifts the write timeout of the server for a streamed
+// response, which may legitimately take longer. Servers without a write
+// timeout, such as the one on the unix socket, are not affected.
+func clearWriteDeadline(w http.ResponseWriter) {
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+}
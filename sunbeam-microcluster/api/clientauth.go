@@ -0,0 +1,89 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/canonical/lxd/lxd/response"
+	"github.com/canonical/lxd/shared"
+	"github.com/canonical/microcluster/rest"
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/sunbeam"
+)
+
+// certifiedPaths are the endpoint paths, and the paths below them, whose
+// mutating actions require a client certificate when the daemon is
+// configured to require one.
+var certifiedPaths = []string{"config", "nodes"}
+
+// certified wraps the mutating actions of the config and nodes endpoints so
+// that they are rejected without a client certificate issued by the client
+// CA, if the daemon requires one. Read actions are left open.
+func certified(endpoints []rest.Endpoint) []rest.Endpoint {
+	for i := range endpoints {
+		if !certifiedPath(endpoints[i].Path) {
+			continue
+		}
+
+		for _, action := range []*rest.EndpointAction{&endpoints[i].Put, &endpoints[i].Post, &endpoints[i].Delete, &endpoints[i].Patch} {
+			if action.Handler == nil {
+				continue
+			}
+
+			handler := action.Handler
+			action.Handler = func(s *state.State, r *http.Request) response.Response {
+				err := verifyClientCertificate(s, r)
+				if err != nil {
+					return response.Forbidden(err)
+				}
+
+				return handler(s, r)
+			}
+		}
+	}
+
+	return endpoints
+}
+
+// certifiedPath returns whether the endpoint path is one of certifiedPaths or
+// below one of them.
+func certifiedPath(path string) bool {
+	for _, certified := range certifiedPaths {
+		if path == certified || strings.HasPrefix(path, certified+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// verifyClientCertificate checks that a mutating request presents a client
+// certificate issued by the client CA, if one is required. Requests on the
+// unix socket, whose access is governed by the socket group, and requests
+// forwarded by other cluster members are not checked.
+func verifyClientCertificate(s *state.State, r *http.Request) error {
+	if !sunbeam.ClientCertRequired() {
+		return nil
+	}
+
+	if r.RemoteAddr == "" || r.RemoteAddr == "@" {
+		return nil
+	}
+
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return fmt.Errorf("A client certificate issued by the client CA is required to modify %q", strings.TrimPrefix(r.URL.Path, "/1.0/"))
+	}
+
+	_, ok := sunbeam.CertificateIdentity(r.TLS.PeerCertificates)
+	if ok {
+		return nil
+	}
+
+	if s.Remotes().RemoteByCertificateFingerprint(shared.CertFingerprint(r.TLS.PeerCertificates[0])) != nil {
+		return nil
+	}
+
+	return fmt.Errorf("The client certificate is not issued by the client CA")
+}
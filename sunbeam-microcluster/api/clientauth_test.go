@@ -0,0 +1,78 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/canonical/lxd/lxd/response"
+	"github.com/canonical/microcluster/rest"
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/internal/testutil"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/sunbeam"
+)
+
+// loadTestClientCA loads a new CA as the client CA, requiring client
+// certificates on mutating requests if required is set, and returns a
+// function issuing client certificates from it. The client CA is unloaded
+// when the test ends.
+func loadTestClientCA(t *testing.T, required bool) func(commonName string) tls.Certificate {
+	t.Helper()
+
+	caFile, issue := testutil.NewClientCA(t)
+
+	err := sunbeam.LoadClientAuth(caFile, "", required)
+	if err != nil {
+		t.Fatalf("Failed to load client CA bundle: %v", err)
+	}
+
+	t.Cleanup(func() { _ = sunbeam.LoadClientAuth("", "", false) })
+
+	return issue
+}
+
+func TestCertifiedEndpoints(t *testing.T) {
+	issue := loadTestClientCA(t, true)
+
+	ok := func(s *state.State, r *http.Request) response.Response {
+		return response.EmptySyncResponse
+	}
+
+	endpoints := certified([]rest.Endpoint{
+		{Path: "config/{key}", Get: rest.EndpointAction{Handler: ok}, Put: rest.EndpointAction{Handler: ok}},
+		{Path: "manifests", Post: rest.EndpointAction{Handler: ok}},
+	})
+
+	tests := []struct {
+		name   string
+		action rest.EndpointAction
+		remote string
+		certs  []*x509.Certificate
+		status int
+	}{
+		{name: "a read without a certificate", action: endpoints[0].Get, remote: "10.0.0.9:4321", status: http.StatusOK},
+		{name: "a write without a certificate", action: endpoints[0].Put, remote: "10.0.0.9:4321", status: http.StatusForbidden},
+		{name: "a write with a certificate of the client CA", action: endpoints[0].Put, remote: "10.0.0.9:4321", certs: []*x509.Certificate{issue("operator").Leaf}, status: http.StatusOK},
+		{name: "a write on the unix socket", action: endpoints[0].Put, remote: "@", status: http.StatusOK},
+		{name: "a write to another endpoint", action: endpoints[1].Post, remote: "10.0.0.9:4321", status: http.StatusOK},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodPut, "/1.0/config/key", nil)
+		r.RemoteAddr = test.remote
+		r.TLS = &tls.ConnectionState{PeerCertificates: test.certs}
+
+		w := httptest.NewRecorder()
+		err := test.action.Handler(nil, r).Render(w)
+		if err != nil {
+			t.Fatalf("Failed to render response: %v", err)
+		}
+
+		if w.Code != test.status {
+			t.Errorf("Expected %s to get %d, got %d: %s", test.name, test.status, w.Code, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/canonical/lxd/lxd/response"
+	"github.com/canonical/microcluster/rest"
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/sunbeam"
+)
+
+// /1.0/cluster/rebalance endpoint.
+// Rebalances the dqlite voter and stand-by roles across the members, it
+// must be called on the dqlite leader.
+var clusterRebalanceCmd = rest.Endpoint{
+	Path: "cluster/rebalance",
+
+	Post: rest.EndpointAction{Handler: cmdClusterRebalancePost, ProxyTarget: true},
+}
+
+// /1.0/cluster/topology endpoint.
+// Returns the nodes, cluster members and their relationships as a graph.
+var clusterTopologyCmd = rest.Endpoint{
+	Path: "cluster/topology",
+
+	Get: rest.EndpointAction{Handler: cmdClusterTopologyGet, ProxyTarget: true, AllowUntrusted: true},
+}
+
+// /1.0/status endpoint.
+// Returns every cluster member alongside the node of the same name, with
+// members lacking a node and nodes lacking a member flagged.
+var clusterStatusCmd = rest.Endpoint{
+	Path: "status",
+
+	Get: rest.EndpointAction{Handler: cmdClusterStatusGet, ProxyTarget: true, AllowUntrusted: true},
+}
+
+func cmdClusterRebalancePost(s *state.State, r *http.Request) response.Response {
+	roles, err := sunbeam.RebalanceRoles(s)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, roles)
+}
+
+func cmdClusterTopologyGet(s *state.State, r *http.Request) response.Response {
+	topology, err := sunbeam.GetTopology(s)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, topology)
+}
+
+func cmdClusterStatusGet(s *state.State, r *http.Request) response.Response {
+	status, err := sunbeam.GetClusterStatus(s)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, status)
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/canonical/lxd/lxd/response"
+	"github.com/canonical/microcluster/rest"
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/api/types"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/sunbeam"
+)
+
+// /1.0/compact endpoint.
+// Prunes the audit log and change feed of old entries, must be called on
+// the dqlite leader.
+var compactCmd = rest.Endpoint{
+	Path: "compact",
+
+	Post: rest.EndpointAction{Handler: cmdCompactPost, ProxyTarget: true},
+}
+
+func cmdCompactPost(s *state.State, r *http.Request) response.Response {
+	var req types.CompactRequest
+	var retention time.Duration
+
+	if r.ContentLength != 0 {
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			return response.BadRequest(err)
+		}
+	}
+
+	if req.Retention != "" {
+		var err error
+		retention, err = time.ParseDuration(req.Retention)
+		if err != nil {
+			return response.BadRequest(fmt.Errorf("Invalid retention %q: %w", req.Retention, err))
+		}
+	}
+
+	compaction, err := sunbeam.Compact(s, retention)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, compaction)
+}
@@ -2,8 +2,12 @@ package api
 
 import (
 	"bytes"
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/url"
+	"strings"
+	"time"
 
 	"github.com/canonical/lxd/lxd/response"
 	"github.com/canonical/lxd/shared/api"
@@ -11,10 +15,125 @@ import (
 	"github.com/canonical/microcluster/state"
 	"github.com/gorilla/mux"
 
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/api/types"
 	"github.com/canonical/snap-openstack/sunbeam-microcluster/sunbeam"
 )
 
+const (
+	// configEventsBuffer is the number of events buffered for a slow config
+	// events client before further events are dropped.
+	configEventsBuffer = 64
+	// configEventsHeartbeat is how often a comment is sent on an idle config
+	// events stream, so that proxies keep the connection open.
+	configEventsHeartbeat = 15 * time.Second
+)
+
+// /1.0/config endpoint.
+// Returns the config key/value pairs, only those of the comma separated
+// keys given in the "keys" query, or those whose key starts with the
+// "prefix" query, if set. With "keys-only=true", only the sorted keys are
+// returned, filtered by "prefix" if set. They are returned as a bare YAML
+// document if the Accept header asks for YAML. Key/value pairs come with an
+// ETag, and 304 Not Modified is returned when If-None-Match holds it.
+var configsCmd = rest.Endpoint{
+	Path: "config",
+
+	Get: rest.EndpointAction{Handler: cmdConfigsGet, ProxyTarget: true, AllowUntrusted: true},
+}
+
+// /1.0/config/batch endpoint.
+// Writes several config key/value pairs in a single transaction, read as
+// YAML with a YAML Content-Type.
+var configBatchCmd = rest.Endpoint{
+	Path: "config/batch",
+
+	Post: rest.EndpointAction{Handler: cmdConfigBatchPost, ProxyTarget: true, AllowUntrusted: true},
+}
+
+// /1.0/config/import endpoint.
+// Replaces the config with the one of an import document in a single
+// transaction, deleting keys missing from it, and returns the changes made.
+// Terraform states and locks are left alone. The document is read as YAML
+// with a YAML Content-Type.
+var configImportCmd = rest.Endpoint{
+	Path: "config/import",
+
+	Post: rest.EndpointAction{Handler: cmdConfigImportPost, ProxyTarget: true, AllowUntrusted: true},
+}
+
+// /1.0/config/diff endpoint.
+// Previews the changes importing a document through /1.0/config/import
+// would make without applying them. The document is read as YAML with a
+// YAML Content-Type.
+var configDiffCmd = rest.Endpoint{
+	Path: "config/diff",
+
+	Post: rest.EndpointAction{Handler: cmdConfigDiffPost, ProxyTarget: true, AllowUntrusted: true},
+}
+
+// /1.0/config/snapshots endpoint.
+// Lists the config snapshots, or takes a copy of every config key/value
+// pair under the name given.
+var configSnapshotsCmd = rest.Endpoint{
+	Path: "config/snapshots",
+
+	Get:  rest.EndpointAction{Handler: cmdConfigSnapshotsGet, ProxyTarget: true, AllowUntrusted: true},
+	Post: rest.EndpointAction{Handler: cmdConfigSnapshotsPost, ProxyTarget: true, AllowUntrusted: true},
+}
+
+// /1.0/config/snapshots/<name> endpoint.
+var configSnapshotCmd = rest.Endpoint{
+	Path: "config/snapshots/{name}",
+
+	Delete: rest.EndpointAction{Handler: cmdConfigSnapshotDelete, ProxyTarget: true, AllowUntrusted: true},
+}
+
+// /1.0/config/snapshots/<name>/restore endpoint.
+// Replaces the config with the contents of a snapshot in a single
+// transaction, deleting the keys set since, and returns the changes made.
+var configSnapshotRestoreCmd = rest.Endpoint{
+	Path: "config/snapshots/{name}/restore",
+
+	Post: rest.EndpointAction{Handler: cmdConfigSnapshotRestorePost, ProxyTarget: true, AllowUntrusted: true},
+}
+
+// /1.0/config/scheduled endpoint.
+// Lists config changes scheduled to take effect in the future.
+var configScheduledCmd = rest.Endpoint{
+	Path: "config/scheduled",
+
+	Get: rest.EndpointAction{Handler: cmdConfigScheduledGet, ProxyTarget: true, AllowUntrusted: true},
+}
+
+// /1.0/config/search endpoint.
+// Returns the config key/value pairs whose key matches the glob given in
+// the "pattern" query, e.g. "network.*.mtu". Terraform states and locks are
+// left out.
+var configSearchCmd = rest.Endpoint{
+	Path: "config/search",
+
+	Get: rest.EndpointAction{Handler: cmdConfigSearchGet, ProxyTarget: true, AllowUntrusted: true},
+}
+
+// /1.0/config/events endpoint.
+// Streams the changes of config keys made through this member as
+// server-sent events, along with the new value of the key. The event ID is
+// the change feed sequence, so a client that missed events can catch up
+// from /1.0/changes.
+var configEventsCmd = rest.Endpoint{
+	Path: "config/events",
+
+	Get: rest.EndpointAction{Handler: cmdConfigEventsGet, ProxyTarget: true, AllowUntrusted: true},
+}
+
 // /1.0/config/<name> endpoint.
+// With the "type" query, one of "bool", "duration", "float" or "int", the
+// value is returned coerced to that type, durations in seconds. With the
+// "default" query, the value of a key that is not set is the given default,
+// flagged as such, instead of 404 Not Found.
+// Writes with an "If-Match" header only apply if the current value equals
+// the header, those with "If-None-Match: *" only if the key is not set,
+// and are otherwise rejected with 409 Conflict.
 var configCmd = rest.Endpoint{
 	Path: "config/{key}",
 
@@ -23,12 +142,203 @@ var configCmd = rest.Endpoint{
 	Delete: rest.EndpointAction{Handler: cmdConfigDelete, ProxyTarget: true, AllowUntrusted: true},
 }
 
+// /1.0/config/<name>/parent endpoint.
+// Declares the parent key a config key inherits its value from when unset.
+var configParentCmd = rest.Endpoint{
+	Path: "config/{key}/parent",
+
+	Put:    rest.EndpointAction{Handler: cmdConfigParentPut, ProxyTarget: true, AllowUntrusted: true},
+	Delete: rest.EndpointAction{Handler: cmdConfigParentDelete, ProxyTarget: true, AllowUntrusted: true},
+}
+
+// /1.0/config/<name>/history endpoint.
+// Returns the changes of the value of a config key, oldest first. Changes of
+// Terraform states and locks are not recorded.
+var configHistoryCmd = rest.Endpoint{
+	Path: "config/{key}/history",
+
+	Get: rest.EndpointAction{Handler: cmdConfigHistoryGet, ProxyTarget: true, AllowUntrusted: true},
+}
+
+// /1.0/config/<name>/effective endpoint.
+// Returns the value of a config key resolved through its parents. With
+// ?node=<name> the ${node.*} references in the value are resolved for
+// that node.
+var configEffectiveCmd = rest.Endpoint{
+	Path: "config/{key}/effective",
+
+	Get: rest.EndpointAction{Handler: cmdConfigEffectiveGet, ProxyTarget: true, AllowUntrusted: true},
+}
+
+func cmdConfigsGet(s *state.State, r *http.Request) response.Response {
+	if r.URL.Query().Get("keys-only") == "true" {
+		return configKeys(s, r)
+	}
+
+	if r.URL.Query().Has("prefix") {
+		if r.URL.Query().Has("keys") {
+			return response.BadRequest(fmt.Errorf("Only one of keys and prefix may be given"))
+		}
+
+		config, err := sunbeam.GetConfigByPrefix(s, r.URL.Query().Get("prefix"))
+		if err != nil {
+			return response.SmartError(err)
+		}
+
+		return etagResponse(r, config)
+	}
+
+	var keys []string
+	for _, key := range strings.Split(r.URL.Query().Get("keys"), ",") {
+		if key != "" {
+			keys = append(keys, key)
+		}
+	}
+
+	config, err := sunbeam.GetConfigBatch(s, keys)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return etagResponse(r, config)
+}
+
+// configKeys returns the config keys, without their values, filtered by the
+// "prefix" query of the request if set.
+func configKeys(s *state.State, r *http.Request) response.Response {
+	if r.URL.Query().Has("keys") {
+		return response.BadRequest(fmt.Errorf("Only one of keys and keys-only may be given"))
+	}
+
+	var prefix *string
+	if r.URL.Query().Has("prefix") {
+		value := r.URL.Query().Get("prefix")
+		prefix = &value
+	}
+
+	keys, err := sunbeam.GetConfigItemKeys(s, prefix)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	if acceptsYAML(r) {
+		return yamlResponse(keys)
+	}
+
+	return response.SyncResponse(true, keys)
+}
+
+func cmdConfigBatchPost(s *state.State, r *http.Request) response.Response {
+	var req map[string]string
+
+	err := decodeRequest(r, &req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	err = sunbeam.SetConfigBatch(s, req)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.EmptySyncResponse
+}
+
+func cmdConfigSnapshotsGet(s *state.State, r *http.Request) response.Response {
+	snapshots, err := sunbeam.ListConfigSnapshots(s)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, snapshots)
+}
+
+func cmdConfigSnapshotsPost(s *state.State, r *http.Request) response.Response {
+	var req types.ConfigSnapshotPost
+
+	err := decodeRequest(r, &req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	snapshot, err := sunbeam.CreateConfigSnapshot(s, req.Name)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, snapshot)
+}
+
+func cmdConfigSnapshotDelete(s *state.State, r *http.Request) response.Response {
+	name, err := url.PathUnescape(mux.Vars(r)["name"])
+	if err != nil {
+		return response.InternalError(err)
+	}
+
+	err = sunbeam.DeleteConfigSnapshot(s, name)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.EmptySyncResponse
+}
+
+func cmdConfigSnapshotRestorePost(s *state.State, r *http.Request) response.Response {
+	name, err := url.PathUnescape(mux.Vars(r)["name"])
+	if err != nil {
+		return response.InternalError(err)
+	}
+
+	diff, err := sunbeam.RestoreConfigSnapshot(s, name)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, diff)
+}
+
+func cmdConfigHistoryGet(s *state.State, r *http.Request) response.Response {
+	key, err := url.PathUnescape(mux.Vars(r)["key"])
+	if err != nil {
+		return response.InternalError(err)
+	}
+
+	history, err := sunbeam.GetConfigHistory(s, key)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, history)
+}
+
 func cmdConfigGet(s *state.State, r *http.Request) response.Response {
 	var key string
 	key, err := url.PathUnescape(mux.Vars(r)["key"])
 	if err != nil {
 		return response.InternalError(err)
 	}
+	if r.URL.Query().Has("default") {
+		if r.URL.Query().Has("type") {
+			return response.BadRequest(fmt.Errorf("The default and type queries cannot be combined"))
+		}
+
+		config, err := sunbeam.GetConfigOrDefault(s, key, r.URL.Query().Get("default"))
+		if err != nil {
+			return response.SmartError(err)
+		}
+
+		return response.SyncResponse(true, config)
+	}
+
+	if r.URL.Query().Has("type") {
+		config, err := sunbeam.GetTypedConfig(s, key, r.URL.Query().Get("type"))
+		if err != nil {
+			return response.SmartError(err)
+		}
+
+		return response.SyncResponse(true, config)
+	}
+
 	config, err := sunbeam.GetConfig(s, key)
 	if err != nil {
 		if err, ok := err.(api.StatusError); ok {
@@ -36,7 +346,7 @@ func cmdConfigGet(s *state.State, r *http.Request) response.Response {
 				return response.NotFound(err)
 			}
 		}
-		return response.InternalError(err)
+		return response.SmartError(err)
 	}
 
 	return response.SyncResponse(true, config)
@@ -54,12 +364,71 @@ func cmdConfigPut(s *state.State, r *http.Request) response.Response {
 		return response.InternalError(err)
 	}
 
-	err = sunbeam.UpdateConfig(s, key, body.String())
+	warnings := sunbeam.ConfigWarnings(key, body.String())
+
+	expected, conditional, err := configPrecondition(r)
 	if err != nil {
-		return response.InternalError(err)
+		return response.BadRequest(err)
 	}
 
-	return response.EmptySyncResponse
+	effectiveAt := r.URL.Query().Get("effective_at")
+	if effectiveAt != "" {
+		if conditional {
+			return response.BadRequest(fmt.Errorf("Scheduled config changes cannot be conditional"))
+		}
+
+		at, err := time.Parse(time.RFC3339, effectiveAt)
+		if err != nil {
+			return response.BadRequest(fmt.Errorf("Invalid effective_at %q: %w", effectiveAt, err))
+		}
+
+		err = sunbeam.ScheduleConfig(s, key, body.String(), at)
+		if err != nil {
+			return response.SmartError(err)
+		}
+
+		return warningsResponse("config/"+key, warnings)
+	}
+
+	if conditional {
+		err = sunbeam.CompareAndSwapConfig(s, key, expected, body.String())
+	} else {
+		err = sunbeam.UpdateConfig(s, key, body.String())
+	}
+
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return warningsResponse("config/"+key, warnings)
+}
+
+// configPrecondition returns the value a config key is expected to have for
+// a write to apply, given by the "If-Match" header of the request, or nil
+// if the key is expected to be unset, with "If-None-Match: *". It returns
+// false if the write is unconditional.
+func configPrecondition(r *http.Request) (*string, bool, error) {
+	_, ifMatch := r.Header["If-Match"]
+	_, ifNoneMatch := r.Header["If-None-Match"]
+
+	if ifMatch && ifNoneMatch {
+		return nil, false, fmt.Errorf("Only one of If-Match and If-None-Match may be given")
+	}
+
+	if ifNoneMatch {
+		if r.Header.Get("If-None-Match") != "*" {
+			return nil, false, fmt.Errorf("If-None-Match only supports \"*\"")
+		}
+
+		return nil, true, nil
+	}
+
+	if ifMatch {
+		expected := r.Header.Get("If-Match")
+		return &expected, true, nil
+	}
+
+	return nil, false, nil
 }
 
 func cmdConfigDelete(s *state.State, r *http.Request) response.Response {
@@ -75,8 +444,180 @@ func cmdConfigDelete(s *state.State, r *http.Request) response.Response {
 				return response.NotFound(err)
 			}
 		}
-		return response.InternalError(err)
+		return response.SmartError(err)
+	}
+
+	return response.EmptySyncResponse
+}
+
+func cmdConfigParentPut(s *state.State, r *http.Request) response.Response {
+	var req types.ConfigParent
+
+	key, err := url.PathUnescape(mux.Vars(r)["key"])
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	err = sunbeam.SetConfigParent(s, key, req.Parent)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.EmptySyncResponse
+}
+
+func cmdConfigParentDelete(s *state.State, r *http.Request) response.Response {
+	key, err := url.PathUnescape(mux.Vars(r)["key"])
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	err = sunbeam.DeleteConfigParent(s, key)
+	if err != nil {
+		return response.SmartError(err)
 	}
 
 	return response.EmptySyncResponse
 }
+
+func cmdConfigEffectiveGet(s *state.State, r *http.Request) response.Response {
+	key, err := url.PathUnescape(mux.Vars(r)["key"])
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	effective, err := sunbeam.GetEffectiveConfig(s, key, r.URL.Query().Get("node"))
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, effective)
+}
+
+func cmdConfigScheduledGet(s *state.State, r *http.Request) response.Response {
+	scheduled, err := sunbeam.ListScheduledConfig(s)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, scheduled)
+}
+
+func cmdConfigSearchGet(s *state.State, r *http.Request) response.Response {
+	matches, err := sunbeam.SearchConfig(s, r.URL.Query().Get("pattern"))
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, matches)
+}
+
+func cmdConfigEventsGet(s *state.State, r *http.Request) response.Response {
+	return response.ManualResponse(func(w http.ResponseWriter) error {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			return fmt.Errorf("Streaming is not supported by the connection")
+		}
+
+		sub := sunbeam.Events.Subscribe(configEventsBuffer, sunbeam.SubscriberDrop)
+		defer sub.Close()
+
+		clearWriteDeadline(w)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		heartbeat := time.NewTicker(configEventsHeartbeat)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return nil
+
+			case <-s.Context.Done():
+				return nil
+
+			case <-heartbeat.C:
+				_, err := fmt.Fprint(w, ": heartbeat\n\n")
+				if err != nil {
+					return nil
+				}
+
+			case event, ok := <-sub.C:
+				if !ok {
+					return nil
+				}
+
+				configEvent, ok := sunbeam.ConfigEvent(event)
+				if !ok {
+					continue
+				}
+
+				data, err := json.Marshal(configEvent)
+				if err != nil {
+					return err
+				}
+
+				_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Seq, event.Action, data)
+				if err != nil {
+					return nil
+				}
+			}
+
+			flusher.Flush()
+		}
+	})
+}
+
+func cmdConfigImportPost(s *state.State, r *http.Request) response.Response {
+	req, err := parseConfigImport(r)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	diff, err := sunbeam.ImportConfig(s, req.Config)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, diff)
+}
+
+func cmdConfigDiffPost(s *state.State, r *http.Request) response.Response {
+	req, err := parseConfigImport(r)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	diff, err := sunbeam.DiffConfig(s, req.Config)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, diff)
+}
+
+// parseConfigImport decodes a config import document from the request body,
+// as JSON or YAML.
+func parseConfigImport(r *http.Request) (types.ConfigImport, error) {
+	var req types.ConfigImport
+
+	err := decodeRequest(r, &req)
+	if err != nil {
+		return req, fmt.Errorf("Failed to parse config import document: %w", err)
+	}
+
+	if req.Config == nil {
+		return req, fmt.Errorf("Config import document has no \"config\" section")
+	}
+
+	return req, nil
+}
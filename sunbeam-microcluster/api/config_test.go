@@ -0,0 +1,93 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/api/types"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/internal/testutil"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/sunbeam"
+)
+
+func TestConfigEventsStream(t *testing.T) {
+	s := testutil.NewState(t)
+
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(done)
+
+		err := cmdConfigEventsGet(s, r).Render(w)
+		if err != nil {
+			t.Errorf("Failed to stream config events: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/1.0/config/events", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+
+	// The subscription is registered before the response headers are sent.
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to subscribe to config events: %v", err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("Config events are streamed as %q, expected text/event-stream", resp.Header.Get("Content-Type"))
+	}
+
+	// The Terraform state is written first, so that the first event
+	// received shows it was skipped.
+	for _, config := range [][2]string{{"tfstate-openstack", "state"}, {"region", "RegionTwo"}} {
+		err = sunbeam.UpdateConfig(s, config[0], config[1])
+		if err != nil {
+			t.Fatalf("Failed to set config key %q: %v", config[0], err)
+		}
+	}
+
+	var event types.ConfigEvent
+	stream := bufio.NewReader(resp.Body)
+	for {
+		line, err := stream.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Failed to read config event: %v", err)
+		}
+
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok {
+			continue
+		}
+
+		err = json.Unmarshal([]byte(data), &event)
+		if err != nil {
+			t.Fatalf("Failed to parse config event %q: %v", data, err)
+		}
+
+		break
+	}
+
+	if event.Key != "region" || event.Value == nil || *event.Value != "RegionTwo" {
+		t.Fatalf("Received config event %+v, expected region set to RegionTwo and the Terraform state skipped", event)
+	}
+
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected the stream to end once the client disconnects")
+	}
+}
@@ -6,10 +6,35 @@ import (
 )
 
 // Endpoints is a global list of all API endpoints on the /1.0 endpoint of
-// microcluster.
-var Endpoints = []rest.Endpoint{
+// microcluster. Requests are logged and rate limited, and mutating actions
+// are recorded in the audit log, those of the config and nodes endpoints may
+// require a client certificate.
+var Endpoints = accessLogged(rateLimited(audited(certified([]rest.Endpoint{
 	nodesCmd,
+	nodesBatchCmd,
+	nodesGroupByCmd,
+	nodesExportCmd,
+	nodesCapacityCmd,
+	nodesSummaryCmd,
+	nodesManifestSkewCmd,
+	nodesRoleValidationCmd,
+	nodesDeletedCmd,
+	nodeJoinTokenCmd,
+	nodeJoinTokenBatchCmd,
+	nodeRegisterCmd,
 	nodeCmd,
+	nodeHardwareCmd,
+	nodeIdentityCmd,
+	nodeMetadataCmd,
+	nodeAppliedManifestCmd,
+	nodeRemovalSafetyCmd,
+	nodeClaimCmd,
+	nodeReleaseCmd,
+	nodeRenameCmd,
+	nodeHistoryCmd,
+	nodeConfigCmd,
+	nodeConfigHistoryCmd,
+	nodeConfigRevertCmd,
 	terraformStateListCmd,
 	terraformStateCmd,
 	terraformLockListCmd,
@@ -17,7 +42,47 @@ var Endpoints = []rest.Endpoint{
 	terraformUnlockCmd,
 	jujuusersCmd,
 	jujuuserCmd,
+	jujuuserRotateCmd,
+	configsCmd,
+	configBatchCmd,
+	configImportCmd,
+	configDiffCmd,
+	configScheduledCmd,
+	configSearchCmd,
+	configSnapshotsCmd,
+	configSnapshotCmd,
+	configSnapshotRestoreCmd,
+	configEventsCmd,
 	configCmd,
+	configParentCmd,
+	configEffectiveCmd,
+	configHistoryCmd,
 	manifestsCmd,
+	manifestDiffCmd,
 	manifestCmd,
-}
+	manifestValidateNodesCmd,
+	manifestVerifyCmd,
+	manifestRollbackCmd,
+	allowlistCmd,
+	allowlistEntryCmd,
+	changesCmd,
+	changesExportCmd,
+	applyCmd,
+	maintenanceCmd,
+	maintenanceCompleteCmd,
+	maintenanceVacuumCmd,
+	maintenanceWindowCmd,
+	schemaCmd,
+	schemaMigrateCmd,
+	auditCmd,
+	auditExportCmd,
+	clusterFreezeCmd,
+	clusterRebalanceCmd,
+	clusterTopologyCmd,
+	clusterStatusCmd,
+	compactCmd,
+	metricsCmd,
+	healthCmd,
+	exportCmd,
+	importCmd,
+}))))
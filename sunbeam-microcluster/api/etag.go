@@ -0,0 +1,84 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/canonical/lxd/lxd/response"
+)
+
+// etagResponse renders data with a weak ETag derived from its content, or
+// answers 304 Not Modified without a body when the If-None-Match header of
+// the request holds that ETag. The ETag differs between the JSON and YAML
+// renderings of the same data.
+func etagResponse(r *http.Request, data any) response.Response {
+	yaml := acceptsYAML(r)
+
+	etag, err := weakETag(data, yaml)
+	if err != nil {
+		return response.InternalError(err)
+	}
+
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		return response.ManualResponse(func(w http.ResponseWriter) error {
+			w.Header().Set("ETag", etag)
+			w.WriteHeader(http.StatusNotModified)
+
+			return nil
+		})
+	}
+
+	resp := response.SyncResponse(true, data)
+	if yaml {
+		resp = yamlResponse(data)
+	}
+
+	return &etagged{Response: resp, etag: etag}
+}
+
+// etagged sets an ETag header before rendering the wrapped response.
+type etagged struct {
+	response.Response
+	etag string
+}
+
+// Render sets the ETag header and renders the wrapped response.
+func (e *etagged) Render(w http.ResponseWriter) error {
+	w.Header().Set("ETag", e.etag)
+
+	return e.Response.Render(w)
+}
+
+// weakETag returns a weak ETag hashing the JSON encoding of data, which
+// sorts map keys so that the same data always gives the same ETag.
+func weakETag(data any, yaml bool) (string, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	if yaml {
+		hash.Write([]byte("yaml:"))
+	}
+
+	hash.Write(encoded)
+
+	return `W/"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`, nil
+}
+
+// etagMatches returns whether an If-None-Match header value holds the given
+// ETag or "*". ETags are compared weakly, ignoring their W/ prefix.
+func etagMatches(header string, etag string) bool {
+	for _, value := range strings.Split(header, ",") {
+		value = strings.TrimSpace(value)
+		if value == "*" || strings.TrimPrefix(value, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+
+	return false
+}
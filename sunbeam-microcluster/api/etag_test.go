@@ -0,0 +1,111 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/canonical/lxd/lxd/response"
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/api/types"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/internal/testutil"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/sunbeam"
+)
+
+// getWithETag runs a GET request against handler, sending the given ETag in
+// If-None-Match unless empty, and returns the status and ETag of the
+// response.
+func getWithETag(t *testing.T, s *state.State, handler func(*state.State, *http.Request) response.Response, target string, etag string) (int, string) {
+	t.Helper()
+
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	if etag != "" {
+		r.Header.Set("If-None-Match", etag)
+	}
+
+	w := httptest.NewRecorder()
+
+	err := handler(s, r).Render(w)
+	if err != nil {
+		t.Fatalf("Failed to render response: %v", err)
+	}
+
+	if w.Code == http.StatusNotModified && w.Body.Len() != 0 {
+		t.Errorf("Response to GET %s is not modified with a body of %d bytes, expected none", target, w.Body.Len())
+	}
+
+	return w.Code, w.Header().Get("ETag")
+}
+
+func TestETag(t *testing.T) {
+	s := testutil.NewState(t)
+
+	tests := []struct {
+		name    string
+		handler func(*state.State, *http.Request) response.Response
+		target  string
+		mutate  func() error
+	}{
+		{
+			name:    "nodes",
+			handler: cmdNodesGetAll,
+			target:  "/1.0/nodes",
+			mutate: func() error {
+				return sunbeam.AddNode(s, "node1", nil, -1, "", types.NodeHardware{}, false)
+			},
+		},
+		{
+			name:    "config",
+			handler: cmdConfigsGet,
+			target:  "/1.0/config?keys=region",
+			mutate: func() error {
+				return sunbeam.UpdateConfig(s, "region", "RegionOne")
+			},
+		},
+	}
+
+	for _, test := range tests {
+		code, etag := getWithETag(t, s, test.handler, test.target, "")
+		if code != http.StatusOK || etag == "" {
+			t.Fatalf("Listing %s returned %d with ETag %q, expected 200 with an ETag", test.name, code, etag)
+		}
+
+		code, cached := getWithETag(t, s, test.handler, test.target, etag)
+		if code != http.StatusNotModified || cached != etag {
+			t.Errorf("Listing unchanged %s with its ETag returned %d with ETag %q, expected 304 with %q", test.name, code, cached, etag)
+		}
+
+		err := test.mutate()
+		if err != nil {
+			t.Fatalf("Failed to change %s: %v", test.name, err)
+		}
+
+		code, changed := getWithETag(t, s, test.handler, test.target, etag)
+		if code != http.StatusOK || changed == etag {
+			t.Errorf("Listing changed %s with its old ETag returned %d with ETag %q, expected 200 with a new ETag", test.name, code, changed)
+		}
+	}
+}
+
+func TestETagMatches(t *testing.T) {
+	etag := `W/"abc"`
+
+	tests := []struct {
+		header  string
+		matches bool
+	}{
+		{header: `W/"abc"`, matches: true},
+		{header: `"abc"`, matches: true},
+		{header: `W/"def", W/"abc"`, matches: true},
+		{header: `*`, matches: true},
+		{header: `W/"def"`, matches: false},
+		{header: ``, matches: false},
+	}
+
+	for _, test := range tests {
+		if etagMatches(test.header, etag) != test.matches {
+			t.Errorf("If-None-Match %q matching %q is %v, expected %v", test.header, etag, !test.matches, test.matches)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/canonical/lxd/lxd/response"
+	"github.com/canonical/microcluster/rest"
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/api/types"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/sunbeam"
+)
+
+// /1.0/cluster/freeze endpoint.
+// Freezes cluster membership, members can neither join nor be removed
+// until it is unfrozen. It stays available while frozen.
+var clusterFreezeCmd = rest.Endpoint{
+	Path: "cluster/freeze",
+
+	Get: rest.EndpointAction{Handler: cmdClusterFreezeGet, ProxyTarget: true},
+	Put: rest.EndpointAction{Handler: cmdClusterFreezePut, ProxyTarget: true},
+}
+
+func cmdClusterFreezeGet(s *state.State, r *http.Request) response.Response {
+	freeze, err := sunbeam.GetClusterFreeze(s)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, freeze)
+}
+
+func cmdClusterFreezePut(s *state.State, r *http.Request) response.Response {
+	var req types.ClusterFreeze
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	err = sunbeam.SetClusterFreeze(s, req)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.EmptySyncResponse
+}
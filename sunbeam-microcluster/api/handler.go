@@ -0,0 +1,63 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/canonical/lxd/lxd/response"
+	"github.com/canonical/lxd/shared/logger"
+	"github.com/canonical/microcluster/rest"
+	"github.com/canonical/microcluster/state"
+	"github.com/gorilla/mux"
+)
+
+// Handler returns an HTTP handler serving Endpoints under /1.0, for
+// listeners other than the ones MicroCluster serves the API on. Callers are
+// expected to have authenticated the client, requests are not forwarded to
+// other members.
+func Handler(s *state.State) http.Handler {
+	router := mux.NewRouter()
+	router.SkipClean(true)
+	router.UseEncodedPath()
+
+	for _, endpoint := range Endpoints {
+		endpoint := endpoint
+		router.HandleFunc("/1.0/"+endpoint.Path, func(w http.ResponseWriter, r *http.Request) {
+			resp := handleEndpoint(s, endpoint, r)
+
+			err := resp.Render(w)
+			if err != nil {
+				logger.Error("Failed to write HTTP response", logger.Ctx{"url": r.URL, "err": err})
+			}
+		})
+	}
+
+	return router
+}
+
+// handleEndpoint runs the action of the endpoint matching the request method.
+func handleEndpoint(s *state.State, endpoint rest.Endpoint, r *http.Request) response.Response {
+	if !s.Database.IsOpen() {
+		return response.Unavailable(fmt.Errorf("Daemon not yet initialized"))
+	}
+
+	var action rest.EndpointAction
+	switch r.Method {
+	case http.MethodGet:
+		action = endpoint.Get
+	case http.MethodPut:
+		action = endpoint.Put
+	case http.MethodPost:
+		action = endpoint.Post
+	case http.MethodDelete:
+		action = endpoint.Delete
+	case http.MethodPatch:
+		action = endpoint.Patch
+	}
+
+	if action.Handler == nil {
+		return response.NotImplemented(nil)
+	}
+
+	return action.Handler(s, r)
+}
@@ -0,0 +1,29 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/canonical/lxd/lxd/response"
+	"github.com/canonical/microcluster/rest"
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/sunbeam"
+)
+
+// /1.0/health endpoint.
+// Reports the health of the local member, for probes and load balancers.
+// Responds 503 if the member cannot serve reads or reach the dqlite leader.
+var healthCmd = rest.Endpoint{
+	Path: "health",
+
+	Get: rest.EndpointAction{Handler: cmdHealthGet, ProxyTarget: true, AllowUntrusted: true},
+}
+
+func cmdHealthGet(s *state.State, _ *http.Request) response.Response {
+	health, err := sunbeam.GetHealth(s)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, health)
+}
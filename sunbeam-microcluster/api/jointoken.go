@@ -0,0 +1,89 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/canonical/lxd/lxd/response"
+	"github.com/canonical/microcluster/rest"
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/api/types"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/sunbeam"
+)
+
+// /1.0/nodes/jointoken endpoint.
+// Issues single-use tokens nodes register themselves with.
+var nodeJoinTokenCmd = rest.Endpoint{
+	Path: "nodes/jointoken",
+
+	Post: rest.EndpointAction{Handler: cmdNodeJoinTokenPost, ProxyTarget: true},
+}
+
+// /1.0/nodes/jointoken/batch endpoint.
+// Issues many join tokens at once for fleet onboarding.
+var nodeJoinTokenBatchCmd = rest.Endpoint{
+	Path: "nodes/jointoken/batch",
+
+	Post: rest.EndpointAction{Handler: cmdNodeJoinTokenBatchPost, ProxyTarget: true},
+}
+
+// /1.0/nodes/register endpoint.
+// Lets a node holding a join token register itself, no other credentials
+// are needed.
+var nodeRegisterCmd = rest.Endpoint{
+	Path: "nodes/register",
+
+	Post: rest.EndpointAction{Handler: cmdNodeRegisterPost, ProxyTarget: true, AllowUntrusted: true},
+}
+
+func cmdNodeJoinTokenPost(s *state.State, r *http.Request) response.Response {
+	var req types.JoinTokenRequest
+
+	if r.ContentLength != 0 {
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			return response.BadRequest(err)
+		}
+	}
+
+	token, err := sunbeam.IssueJoinToken(s, req.SystemID, time.Duration(req.ExpiresIn)*time.Second)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, token)
+}
+
+func cmdNodeJoinTokenBatchPost(s *state.State, r *http.Request) response.Response {
+	var req types.JoinTokenBatchRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	tokens, err := sunbeam.IssueJoinTokenBatch(s, req.Count, req.SystemIDs, time.Duration(req.ExpiresIn)*time.Second)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, tokens)
+}
+
+func cmdNodeRegisterPost(s *state.State, r *http.Request) response.Response {
+	var req types.NodeRegistration
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	err = sunbeam.RegisterNode(s, req.Token, req.Name, req.SystemID, req.SchemaVersion, req.Role, req.NodeHardware)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.EmptySyncResponse
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/url"
+	"time"
 
 	"github.com/canonical/lxd/lxd/response"
 	"github.com/canonical/lxd/shared/api"
@@ -31,10 +32,18 @@ var jujuuserCmd = rest.Endpoint{
 	Delete: rest.EndpointAction{Handler: cmdJujuUsersDelete, ProxyTarget: true},
 }
 
+// /1.0/jujuusers/<name>/rotate endpoint.
+// Replaces the token of a juju user and returns the new token.
+var jujuuserRotateCmd = rest.Endpoint{
+	Path: "jujuusers/{name}/rotate",
+
+	Post: rest.EndpointAction{Handler: cmdJujuUserRotatePost, ProxyTarget: true},
+}
+
 func cmdJujuUsersGetAll(s *state.State, _ *http.Request) response.Response {
 	users, err := sunbeam.ListJujuUsers(s)
 	if err != nil {
-		return response.InternalError(err)
+		return response.SmartError(err)
 	}
 
 	return response.SyncResponse(true, users)
@@ -53,7 +62,7 @@ func cmdJujuUsersGet(s *state.State, r *http.Request) response.Response {
 				return response.NotFound(err)
 			}
 		}
-		return response.InternalError(err)
+		return response.SmartError(err)
 	}
 
 	return response.SyncResponse(true, jujuUser)
@@ -69,7 +78,7 @@ func cmdJujuUsersPost(s *state.State, r *http.Request) response.Response {
 
 	err = sunbeam.AddJujuUser(s, req.Username, req.Token)
 	if err != nil {
-		return response.InternalError(err)
+		return response.SmartError(err)
 	}
 
 	return response.EmptySyncResponse
@@ -82,8 +91,30 @@ func cmdJujuUsersDelete(s *state.State, r *http.Request) response.Response {
 	}
 	err = sunbeam.DeleteJujuUser(s, name)
 	if err != nil {
-		return response.InternalError(err)
+		return response.SmartError(err)
 	}
 
 	return response.EmptySyncResponse
 }
+
+func cmdJujuUserRotatePost(s *state.State, r *http.Request) response.Response {
+	name, err := url.PathUnescape(mux.Vars(r)["name"])
+	if err != nil {
+		return response.InternalError(err)
+	}
+
+	var req types.JujuUserRotation
+	if r.ContentLength != 0 {
+		err = json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			return response.BadRequest(err)
+		}
+	}
+
+	user, err := sunbeam.RotateJujuUserToken(s, name, req.Token, time.Duration(req.ExpiresIn)*time.Second)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, user)
+}
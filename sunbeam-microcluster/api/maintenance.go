@@ -0,0 +1,123 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/canonical/lxd/lxd/response"
+	"github.com/canonical/microcluster/rest"
+	"github.com/canonical/microcluster/state"
+	"github.com/gorilla/mux"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/api/types"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/sunbeam"
+)
+
+// /1.0/maintenance endpoint.
+// Lists upcoming maintenance windows, or all of them with all=true.
+var maintenanceCmd = rest.Endpoint{
+	Path: "maintenance",
+
+	Get:  rest.EndpointAction{Handler: cmdMaintenanceGetAll, ProxyTarget: true, AllowUntrusted: true},
+	Post: rest.EndpointAction{Handler: cmdMaintenancePost, ProxyTarget: true, AllowUntrusted: true},
+}
+
+// /1.0/maintenance/vacuum endpoint.
+// Checkpoints and vacuums the database to reclaim the space of deleted rows,
+// must be called on the dqlite leader. The database is locked while it runs
+// and it is refused with 429 Too Many Requests if run again too soon.
+var maintenanceVacuumCmd = rest.Endpoint{
+	Path: "maintenance/vacuum",
+
+	Post: rest.EndpointAction{Handler: cmdMaintenanceVacuumPost, ProxyTarget: true},
+}
+
+// /1.0/maintenance/<id> endpoint.
+var maintenanceWindowCmd = rest.Endpoint{
+	Path: "maintenance/{id}",
+
+	Delete: rest.EndpointAction{Handler: cmdMaintenanceDelete, ProxyTarget: true, AllowUntrusted: true},
+}
+
+// /1.0/maintenance/<id>/complete endpoint.
+var maintenanceCompleteCmd = rest.Endpoint{
+	Path: "maintenance/{id}/complete",
+
+	Post: rest.EndpointAction{Handler: cmdMaintenanceCompletePost, ProxyTarget: true, AllowUntrusted: true},
+}
+
+func cmdMaintenanceGetAll(s *state.State, r *http.Request) response.Response {
+	all := r.URL.Query().Get("all") == "true"
+
+	windows, err := sunbeam.ListMaintenance(s, all)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, windows)
+}
+
+func cmdMaintenancePost(s *state.State, r *http.Request) response.Response {
+	var req types.MaintenanceWindow
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	window, err := sunbeam.ScheduleMaintenance(s, req.Node, req.Action, req.StartAt)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, window)
+}
+
+func cmdMaintenanceVacuumPost(s *state.State, r *http.Request) response.Response {
+	vacuum, err := sunbeam.Vacuum(s)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, vacuum)
+}
+
+func cmdMaintenanceDelete(s *state.State, r *http.Request) response.Response {
+	id, err := maintenanceID(r)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	err = sunbeam.CancelMaintenance(s, id)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.EmptySyncResponse
+}
+
+func cmdMaintenanceCompletePost(s *state.State, r *http.Request) response.Response {
+	id, err := maintenanceID(r)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	err = sunbeam.CompleteMaintenance(s, id)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.EmptySyncResponse
+}
+
+// maintenanceID parses the maintenance window ID from the request path.
+func maintenanceID(r *http.Request) (int64, error) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		return -1, fmt.Errorf("Invalid maintenance window ID %q", mux.Vars(r)["id"])
+	}
+
+	return id, nil
+}
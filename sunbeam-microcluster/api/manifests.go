@@ -2,8 +2,12 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
+	"time"
 
 	"github.com/canonical/lxd/lxd/response"
 	"github.com/canonical/lxd/shared/api"
@@ -15,7 +19,26 @@ import (
 	"github.com/canonical/snap-openstack/sunbeam-microcluster/sunbeam"
 )
 
+const (
+	// defaultManifestLimit is the number of manifests listed in a range
+	// when no limit is requested.
+	defaultManifestLimit = 100
+	// maxManifestLimit bounds the number of manifests listed in a range.
+	maxManifestLimit = 1000
+)
+
 // /1.0/manifests endpoint.
+// With any of the "after", "before" (RFC3339 timestamps) or "limit" queries,
+// only the ids and applied dates of the manifests applied in that range are
+// returned, oldest first.
+// With the "cursor" or "contains" queries, the manifests are returned a page
+// of "limit" at a time, oldest first, with the cursor of the next page. An
+// empty cursor gives the first page, "contains" only lists the manifests
+// whose id contains the given string, and the data of the manifests is only
+// returned with "include-data=true".
+// Adding a manifest returns the stored manifest. Without a manifest id, a
+// random one is generated. Manifest ids already in use are rejected with
+// 409 Conflict, and data larger than 4 MiB with 413 Request Entity Too Large.
 var manifestsCmd = rest.Endpoint{
 	Path: "manifests",
 
@@ -32,16 +55,128 @@ var manifestCmd = rest.Endpoint{
 	Delete: rest.EndpointAction{Handler: cmdManifestDelete, ProxyTarget: true, AllowUntrusted: true},
 }
 
-func cmdManifestsGetAll(s *state.State, _ *http.Request) response.Response {
+// /1.0/manifests/diff endpoint.
+// Compares the data of the manifests given by the "from" and "to" queries
+// key by key.
+var manifestDiffCmd = rest.Endpoint{
+	Path: "manifests/diff",
+
+	Get: rest.EndpointAction{Handler: cmdManifestDiffGet, ProxyTarget: true, AllowUntrusted: true},
+}
+
+// /1.0/manifests/<manifestid>/validate-nodes endpoint.
+// Compares the nodes declared under deployment.nodes in the manifest with
+// the recorded nodes.
+var manifestValidateNodesCmd = rest.Endpoint{
+	Path: "manifests/{manifestid}/validate-nodes",
+
+	Get: rest.EndpointAction{Handler: cmdManifestValidateNodesGet, ProxyTarget: true, AllowUntrusted: true},
+}
+
+// /1.0/manifests/<manifestid>/rollback endpoint.
+// Re-applies the data of the manifest as a new manifest, which records the
+// id of the manifest it was rolled back from. Manifests whose data does not
+// match their checksum are rejected.
+var manifestRollbackCmd = rest.Endpoint{
+	Path: "manifests/{manifestid}/rollback",
+
+	Post: rest.EndpointAction{Handler: cmdManifestRollbackPost, ProxyTarget: true, AllowUntrusted: true},
+}
+
+// /1.0/manifests/<manifestid>/verify endpoint.
+// Recomputes the checksum of the manifest data and reports whether it
+// matches the checksum recorded on write.
+var manifestVerifyCmd = rest.Endpoint{
+	Path: "manifests/{manifestid}/verify",
+
+	Get: rest.EndpointAction{Handler: cmdManifestVerifyGet, ProxyTarget: true, AllowUntrusted: true},
+}
+
+func cmdManifestsGetAll(s *state.State, r *http.Request) response.Response {
+	query := r.URL.Query()
+	if query.Has("cursor") || query.Has("contains") {
+		return manifestsPage(s, r)
+	}
+
+	if query.Has("after") || query.Has("before") || query.Has("limit") {
+		return manifestsInRange(s, r)
+	}
 
 	manifests, err := sunbeam.ListManifests(s)
 	if err != nil {
-		return response.InternalError(err)
+		return response.SmartError(err)
 	}
 
 	return response.SyncResponse(true, manifests)
 }
 
+// manifestsInRange returns the manifests applied in the range given by the
+// request query.
+func manifestsInRange(s *state.State, r *http.Request) response.Response {
+	query := r.URL.Query()
+
+	var after, before *time.Time
+	for name, field := range map[string]**time.Time{"after": &after, "before": &before} {
+		if query.Has(name) {
+			t, err := time.Parse(time.RFC3339Nano, query.Get(name))
+			if err != nil {
+				return response.BadRequest(fmt.Errorf("Invalid %s value: %w", name, err))
+			}
+
+			*field = &t
+		}
+	}
+
+	limit, err := manifestLimit(r)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	manifests, err := sunbeam.ListManifestsInRange(s, after, before, limit)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, manifests)
+}
+
+// manifestsPage returns the page of manifests given by the request query.
+func manifestsPage(s *state.State, r *http.Request) response.Response {
+	query := r.URL.Query()
+
+	limit, err := manifestLimit(r)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	page, err := sunbeam.ListManifestsPage(s, query.Get("cursor"), query.Get("contains"), query.Get("include-data") == "true", limit)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, page)
+}
+
+// manifestLimit returns the number of manifests to list given by the "limit"
+// query of the request, capped at maxManifestLimit.
+func manifestLimit(r *http.Request) (int, error) {
+	query := r.URL.Query()
+	if !query.Has("limit") {
+		return defaultManifestLimit, nil
+	}
+
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil || limit <= 0 {
+		return 0, fmt.Errorf("Invalid limit value %q", query.Get("limit"))
+	}
+
+	if limit > maxManifestLimit {
+		limit = maxManifestLimit
+	}
+
+	return limit, nil
+}
+
 func cmdManifestGet(s *state.State, r *http.Request) response.Response {
 	var manifestid string
 	manifestid, err := url.PathUnescape(mux.Vars(r)["manifestid"])
@@ -55,7 +190,7 @@ func cmdManifestGet(s *state.State, r *http.Request) response.Response {
 				return response.NotFound(err)
 			}
 		}
-		return response.InternalError(err)
+		return response.SmartError(err)
 	}
 
 	return response.SyncResponse(true, manifest)
@@ -64,17 +199,42 @@ func cmdManifestGet(s *state.State, r *http.Request) response.Response {
 func cmdManifestsPost(s *state.State, r *http.Request) response.Response {
 	var req types.Manifest
 
+	// Leave room for the JSON encoding around the manifest data.
+	r.Body = http.MaxBytesReader(nil, r.Body, 2*sunbeam.MaxManifestSize)
+
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
-		return response.InternalError(err)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return response.ErrorResponse(http.StatusRequestEntityTooLarge, fmt.Sprintf("Manifest request is larger than %d bytes", tooLarge.Limit))
+		}
+
+		return response.BadRequest(err)
+	}
+
+	warnings := sunbeam.ManifestWarnings(req.Data)
+
+	// With dedupe set, an identical manifest already stored is returned
+	// instead of recording a duplicate.
+	if r.URL.Query().Get("dedupe") == "true" {
+		manifest, err := sunbeam.AddManifestDeduplicated(s, req.ManifestID, req.Data)
+		if err != nil {
+			return response.SmartError(err)
+		}
+
+		sunbeam.LogWarnings("manifests/"+manifest.ManifestID, warnings)
+
+		return response.SyncResponse(true, types.ManifestWithWarnings{Manifest: manifest, Warnings: warnings})
 	}
 
-	err = sunbeam.AddManifest(s, req.ManifestID, req.Data)
+	manifest, err := sunbeam.AddManifest(s, req.ManifestID, req.Data)
 	if err != nil {
-		return response.InternalError(err)
+		return response.SmartError(err)
 	}
 
-	return response.EmptySyncResponse
+	sunbeam.LogWarnings("manifests/"+manifest.ManifestID, warnings)
+
+	return response.SyncResponse(true, types.ManifestWithWarnings{Manifest: manifest, Warnings: warnings})
 }
 
 func cmdManifestDelete(s *state.State, r *http.Request) response.Response {
@@ -84,8 +244,66 @@ func cmdManifestDelete(s *state.State, r *http.Request) response.Response {
 	}
 	err = sunbeam.DeleteManifest(s, manifestid)
 	if err != nil {
-		return response.InternalError(err)
+		return response.SmartError(err)
 	}
 
 	return response.EmptySyncResponse
 }
+
+func cmdManifestDiffGet(s *state.State, r *http.Request) response.Response {
+	query := r.URL.Query()
+	from := query.Get("from")
+	to := query.Get("to")
+	if from == "" || to == "" {
+		return response.BadRequest(errors.New("Both from and to manifest ids are required"))
+	}
+
+	diff, err := sunbeam.DiffManifests(s, from, to)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, diff)
+}
+
+func cmdManifestValidateNodesGet(s *state.State, r *http.Request) response.Response {
+	manifestid, err := url.PathUnescape(mux.Vars(r)["manifestid"])
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	validation, err := sunbeam.ValidateManifestNodes(s, manifestid)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, validation)
+}
+
+func cmdManifestRollbackPost(s *state.State, r *http.Request) response.Response {
+	manifestid, err := url.PathUnescape(mux.Vars(r)["manifestid"])
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	manifest, err := sunbeam.RollbackManifest(s, manifestid)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, manifest)
+}
+
+func cmdManifestVerifyGet(s *state.State, r *http.Request) response.Response {
+	manifestid, err := url.PathUnescape(mux.Vars(r)["manifestid"])
+	if err != nil {
+		return response.InternalError(err)
+	}
+
+	verification, err := sunbeam.VerifyManifest(s, manifestid)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, verification)
+}
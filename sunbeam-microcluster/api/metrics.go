@@ -0,0 +1,34 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/canonical/lxd/lxd/response"
+	"github.com/canonical/microcluster/rest"
+	"github.com/canonical/microcluster/state"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/sunbeam"
+)
+
+// /1.0/metrics endpoint.
+// Exposes the daemon metrics in the Prometheus text format.
+var metricsCmd = rest.Endpoint{
+	Path: "metrics",
+
+	Get: rest.EndpointAction{Handler: cmdMetricsGet, ProxyTarget: true, AllowUntrusted: true},
+}
+
+func cmdMetricsGet(s *state.State, r *http.Request) response.Response {
+	err := sunbeam.UpdateMetrics(s)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.ManualResponse(func(w http.ResponseWriter) error {
+		promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+
+		return nil
+	})
+}
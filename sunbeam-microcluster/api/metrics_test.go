@@ -0,0 +1,38 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/api/types"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/internal/testutil"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/sunbeam"
+)
+
+func TestMetricsNodeCount(t *testing.T) {
+	s := testutil.NewState(t)
+
+	roles := map[string][]string{"node1": {"control", "compute"}, "node2": {"compute"}}
+	for _, name := range []string{"node1", "node2"} {
+		err := sunbeam.AddNode(s, name, roles[name], -1, "", types.NodeHardware{}, false)
+		if err != nil {
+			t.Fatalf("Failed to add node %q: %v", name, err)
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/1.0/metrics", nil)
+	w := httptest.NewRecorder()
+
+	err := cmdMetricsGet(s, r).Render(w)
+	if err != nil {
+		t.Fatalf("Failed to render metrics: %v", err)
+	}
+
+	for _, metric := range []string{`sunbeam_nodes{role="compute"} 2`, `sunbeam_nodes{role="control"} 1`} {
+		if !strings.Contains(w.Body.String(), metric+"\n") {
+			t.Errorf("Scraped metrics do not hold %q:\n%s", metric, w.Body.String())
+		}
+	}
+}
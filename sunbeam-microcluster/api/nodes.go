@@ -1,21 +1,47 @@
 package api
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/canonical/lxd/lxd/response"
+	"github.com/canonical/lxd/lxd/util"
 	"github.com/canonical/lxd/shared/api"
 	"github.com/canonical/microcluster/rest"
 	"github.com/canonical/microcluster/state"
 	"github.com/gorilla/mux"
 
 	"github.com/canonical/snap-openstack/sunbeam-microcluster/api/types"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/database"
 	"github.com/canonical/snap-openstack/sunbeam-microcluster/sunbeam"
 )
 
+const (
+	// defaultNodesLimit is the number of nodes in a page when no limit is
+	// requested.
+	defaultNodesLimit = 100
+	// maxNodesLimit bounds the number of nodes in a page.
+	maxNodesLimit = 1000
+)
+
 // /1.0/nodes endpoint.
+// Nodes are filtered by the "role" queries, a node must hold every role
+// given, by the "owner" query, and by the "label" queries, as key=value, a
+// node must have every label given as a string in its metadata. Listing is
+// paged when the "limit" or
+// "offset" query is given. With the "system_id" query, the single node of
+// that MAAS system is returned instead. Adding a node that already exists
+// updates it with the fields given when the "upsert=true" query is set.
+// Soft deleted nodes are only listed, after the live ones, with the
+// "include-deleted=true" query, which does not apply to paged listings.
+// Listings come with an ETag, and 304 Not Modified is returned when
+// If-None-Match holds it.
 var nodesCmd = rest.Endpoint{
 	Path: "nodes",
 
@@ -23,7 +49,77 @@ var nodesCmd = rest.Endpoint{
 	Post: rest.EndpointAction{Handler: cmdNodesPost, ProxyTarget: true, AllowUntrusted: true},
 }
 
+// /1.0/nodes/batch endpoint.
+// Adds a list of nodes in a single transaction, all or none of them.
+var nodesBatchCmd = rest.Endpoint{
+	Path: "nodes/batch",
+
+	Post: rest.EndpointAction{Handler: cmdNodesBatchPost, ProxyTarget: true, AllowUntrusted: true},
+}
+
+// /1.0/nodes/groupby endpoint.
+// Returns node counts grouped by the attribute given in the "field" query,
+// or by a label of the node metadata with "label:<key>".
+var nodesGroupByCmd = rest.Endpoint{
+	Path: "nodes/groupby",
+
+	Get: rest.EndpointAction{Handler: cmdNodesGroupByGet, ProxyTarget: true, AllowUntrusted: true},
+}
+
+// /1.0/nodes/export endpoint.
+// Renders the nodes in the format given by the "format" query, only
+// "ansible" is supported. See sunbeam.ExportNodesAnsible for how nodes are
+// mapped to Ansible groups.
+var nodesExportCmd = rest.Endpoint{
+	Path: "nodes/export",
+
+	Get: rest.EndpointAction{Handler: cmdNodesExportGet, ProxyTarget: true, AllowUntrusted: true},
+}
+
+// /1.0/nodes/capacity endpoint.
+// Returns the hardware totals across the cluster.
+var nodesCapacityCmd = rest.Endpoint{
+	Path: "nodes/capacity",
+
+	Get: rest.EndpointAction{Handler: cmdNodesCapacityGet, ProxyTarget: true, AllowUntrusted: true},
+}
+
+// /1.0/nodes/summary endpoint.
+// Returns the number of nodes and of nodes holding each role.
+var nodesSummaryCmd = rest.Endpoint{
+	Path: "nodes/summary",
+
+	Get: rest.EndpointAction{Handler: cmdNodesSummaryGet, ProxyTarget: true, AllowUntrusted: true},
+}
+
+// /1.0/nodes/manifest-skew endpoint.
+// Returns which manifest each node runs, to spot partial rollouts.
+var nodesManifestSkewCmd = rest.Endpoint{
+	Path: "nodes/manifest-skew",
+
+	Get: rest.EndpointAction{Handler: cmdNodesManifestSkewGet, ProxyTarget: true, AllowUntrusted: true},
+}
+
+// /1.0/nodes/role-validation endpoint.
+// Returns the roles nodes may hold and the nodes holding other roles.
+var nodesRoleValidationCmd = rest.Endpoint{
+	Path: "nodes/role-validation",
+
+	Get: rest.EndpointAction{Handler: cmdNodesRoleValidationGet, ProxyTarget: true, AllowUntrusted: true},
+}
+
+// /1.0/nodes/deleted endpoint.
+// Permanently deletes the soft deleted nodes, only those deleted longer ago
+// than the "older-than" query, a duration such as "720h", if given.
+var nodesDeletedCmd = rest.Endpoint{
+	Path: "nodes/deleted",
+
+	Delete: rest.EndpointAction{Handler: cmdNodesDeletedDelete, ProxyTarget: true, AllowUntrusted: true},
+}
+
 // /1.0/nodes/<name> endpoint.
+// Deleting a node keeps a tombstone of it when the nodes.soft-delete config
+// key is "true".
 var nodeCmd = rest.Endpoint{
 	Path: "nodes/{name}",
 
@@ -32,15 +128,292 @@ var nodeCmd = rest.Endpoint{
 	Delete: rest.EndpointAction{Handler: cmdNodesDelete, ProxyTarget: true, AllowUntrusted: true},
 }
 
+// /1.0/nodes/<name>/hardware endpoint.
+var nodeHardwareCmd = rest.Endpoint{
+	Path: "nodes/{name}/hardware",
+
+	Put: rest.EndpointAction{Handler: cmdNodeHardwarePut, ProxyTarget: true, AllowUntrusted: true},
+}
+
+// /1.0/nodes/<name>/identity endpoint.
+// Updates the machine and system ids of a node in one go, keeping those not
+// given. A machine id used by another node is rejected with 409 Conflict.
+var nodeIdentityCmd = rest.Endpoint{
+	Path: "nodes/{name}/identity",
+
+	Put: rest.EndpointAction{Handler: cmdNodeIdentityPut, ProxyTarget: true, AllowUntrusted: true},
+}
+
+// /1.0/nodes/<name>/metadata endpoint.
+// Patching merges the JSON object given into the metadata of the node,
+// recursively, and removes the keys set to null.
+var nodeMetadataCmd = rest.Endpoint{
+	Path: "nodes/{name}/metadata",
+
+	Get:   rest.EndpointAction{Handler: cmdNodeMetadataGet, ProxyTarget: true, AllowUntrusted: true},
+	Patch: rest.EndpointAction{Handler: cmdNodeMetadataPatch, ProxyTarget: true, AllowUntrusted: true},
+}
+
+// /1.0/nodes/<name>/applied-manifest endpoint.
+// Nodes report the manifest they have applied here.
+var nodeAppliedManifestCmd = rest.Endpoint{
+	Path: "nodes/{name}/applied-manifest",
+
+	Put: rest.EndpointAction{Handler: cmdNodeAppliedManifestPut, ProxyTarget: true, AllowUntrusted: true},
+}
+
+// /1.0/nodes/<name>/removal-safety endpoint.
+// Reports whether the node can be removed without breaking dqlite quorum or
+// leaving a role below the minimum set in the "roles.minimum" config key.
+var nodeRemovalSafetyCmd = rest.Endpoint{
+	Path: "nodes/{name}/removal-safety",
+
+	Get: rest.EndpointAction{Handler: cmdNodeRemovalSafetyGet, ProxyTarget: true, AllowUntrusted: true},
+}
+
+// /1.0/nodes/<name>/claim endpoint.
+var nodeClaimCmd = rest.Endpoint{
+	Path: "nodes/{name}/claim",
+
+	Post: rest.EndpointAction{Handler: cmdNodeClaimPost, ProxyTarget: true, AllowUntrusted: true},
+}
+
+// /1.0/nodes/<name>/release endpoint.
+var nodeReleaseCmd = rest.Endpoint{
+	Path: "nodes/{name}/release",
+
+	Post: rest.EndpointAction{Handler: cmdNodeReleasePost, ProxyTarget: true, AllowUntrusted: true},
+}
+
+// /1.0/nodes/<name>/rename endpoint.
+var nodeRenameCmd = rest.Endpoint{
+	Path: "nodes/{name}/rename",
+
+	Post: rest.EndpointAction{Handler: cmdNodeRenamePost, ProxyTarget: true, AllowUntrusted: true},
+}
+
+// /1.0/nodes/<name>/history endpoint.
+// Returns the changes recorded for a node when the nodes.history config key
+// is "true", oldest first.
+var nodeHistoryCmd = rest.Endpoint{
+	Path: "nodes/{name}/history",
+
+	Get: rest.EndpointAction{Handler: cmdNodeHistoryGet, ProxyTarget: true, AllowUntrusted: true},
+}
+
+// /1.0/nodes/<name>/config/<key> endpoint.
+// Overrides a config key for a node. Reading a key the node does not
+// override returns its global value.
+var nodeConfigCmd = rest.Endpoint{
+	Path: "nodes/{name}/config/{key}",
+
+	Get: rest.EndpointAction{Handler: cmdNodeConfigGet, ProxyTarget: true, AllowUntrusted: true},
+	Put: rest.EndpointAction{Handler: cmdNodeConfigPut, ProxyTarget: true, AllowUntrusted: true},
+}
+
+// /1.0/nodes/<name>/config/<key>/history endpoint.
+// Returns the changes of the value a node overrides a config key with,
+// oldest first.
+var nodeConfigHistoryCmd = rest.Endpoint{
+	Path: "nodes/{name}/config/{key}/history",
+
+	Get: rest.EndpointAction{Handler: cmdNodeConfigHistoryGet, ProxyTarget: true, AllowUntrusted: true},
+}
+
+// /1.0/nodes/<name>/config/<key>/revert endpoint.
+// Sets the override of a config key for a node back to the value set by the
+// given change of its history.
+var nodeConfigRevertCmd = rest.Endpoint{
+	Path: "nodes/{name}/config/{key}/revert",
+
+	Post: rest.EndpointAction{Handler: cmdNodeConfigRevertPost, ProxyTarget: true, AllowUntrusted: true},
+}
+
 func cmdNodesGetAll(s *state.State, r *http.Request) response.Response {
+	if r.URL.Query().Has("system_id") {
+		node, err := sunbeam.GetNodeBySystemID(s, r.URL.Query().Get("system_id"))
+		if err != nil {
+			return response.SmartError(err)
+		}
+
+		return response.SyncResponse(true, node)
+	}
+
 	roles := r.URL.Query()["role"]
 
-	nodes, err := sunbeam.ListNodes(s, roles)
+	var owner *string
+	if r.URL.Query().Has("owner") {
+		value := r.URL.Query().Get("owner")
+		owner = &value
+	}
+
+	labels, err := nodeLabels(r)
 	if err != nil {
-		return response.InternalError(err)
+		return response.BadRequest(err)
+	}
+
+	includeDeleted := r.URL.Query().Get("include-deleted") == "true"
+
+	if r.URL.Query().Has("limit") || r.URL.Query().Has("offset") {
+		if includeDeleted {
+			return response.BadRequest(fmt.Errorf("Deleted nodes cannot be included in a paged listing"))
+		}
+
+		return nodesPage(s, r, roles, owner, labels)
+	}
+
+	nodes, err := sunbeam.ListNodes(s, roles, owner, labels)
+	if err != nil {
+		return response.SmartError(err)
 	}
 
-	return response.SyncResponse(true, nodes)
+	if includeDeleted {
+		deleted, err := sunbeam.ListDeletedNodes(s, roles, owner, labels)
+		if err != nil {
+			return response.SmartError(err)
+		}
+
+		nodes = append(nodes, deleted...)
+	}
+
+	return etagResponse(r, nodes)
+}
+
+func cmdNodesDeletedDelete(s *state.State, r *http.Request) response.Response {
+	var olderThan time.Duration
+	if r.URL.Query().Has("older-than") {
+		var err error
+		olderThan, err = time.ParseDuration(r.URL.Query().Get("older-than"))
+		if err != nil || olderThan < 0 {
+			return response.BadRequest(fmt.Errorf("Invalid older-than value %q", r.URL.Query().Get("older-than")))
+		}
+	}
+
+	removed, err := sunbeam.PurgeDeletedNodes(s, time.Now().Add(-olderThan))
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, types.DeletedNodesPurge{Removed: removed})
+}
+
+// nodeLabels returns the metadata labels given as key=value by the "label"
+// queries of the request.
+func nodeLabels(r *http.Request) ([]database.NodeLabel, error) {
+	var labels []database.NodeLabel
+	for _, value := range r.URL.Query()["label"] {
+		key, labelValue, ok := strings.Cut(value, "=")
+		if !ok || key == "" || strings.Contains(key, `"`) {
+			return nil, fmt.Errorf("Invalid label %q, expected key=value", value)
+		}
+
+		labels = append(labels, database.NodeLabel{Key: key, Value: labelValue})
+	}
+
+	return labels, nil
+}
+
+// nodesPage returns the page of nodes given by the request query.
+func nodesPage(s *state.State, r *http.Request, roles []string, owner *string, labels []database.NodeLabel) response.Response {
+	query := r.URL.Query()
+
+	limit := defaultNodesLimit
+	if query.Has("limit") {
+		var err error
+		limit, err = strconv.Atoi(query.Get("limit"))
+		if err != nil || limit <= 0 {
+			return response.BadRequest(fmt.Errorf("Invalid limit value %q", query.Get("limit")))
+		}
+
+		if limit > maxNodesLimit {
+			limit = maxNodesLimit
+		}
+	}
+
+	offset := 0
+	if query.Has("offset") {
+		var err error
+		offset, err = strconv.Atoi(query.Get("offset"))
+		if err != nil || offset < 0 {
+			return response.BadRequest(fmt.Errorf("Invalid offset value %q", query.Get("offset")))
+		}
+	}
+
+	page, err := sunbeam.ListNodesPage(s, roles, owner, labels, limit, offset)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return etagResponse(r, page)
+}
+
+func cmdNodesGroupByGet(s *state.State, r *http.Request) response.Response {
+	field := r.URL.Query().Get("field")
+	if field == "" {
+		return response.BadRequest(fmt.Errorf("Missing field to group nodes by"))
+	}
+
+	withNodes := r.URL.Query().Get("nodes") == "true"
+
+	groups, err := sunbeam.GroupNodes(s, field, withNodes)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, groups)
+}
+
+func cmdNodesExportGet(s *state.State, r *http.Request) response.Response {
+	format := r.URL.Query().Get("format")
+	if format != "ansible" {
+		return response.BadRequest(fmt.Errorf("Unsupported export format %q", format))
+	}
+
+	inventory, err := sunbeam.ExportNodesAnsible(s)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	// Send the inventory as is, Ansible expects the bare inventory document.
+	return response.ManualResponse(func(w http.ResponseWriter) error {
+		return util.WriteJSON(w, inventory, nil)
+	})
+}
+
+func cmdNodesCapacityGet(s *state.State, r *http.Request) response.Response {
+	capacity, err := sunbeam.GetNodesCapacity(s)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, capacity)
+}
+
+func cmdNodesSummaryGet(s *state.State, r *http.Request) response.Response {
+	summary, err := sunbeam.GetNodesSummary(s)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, summary)
+}
+
+func cmdNodesManifestSkewGet(s *state.State, r *http.Request) response.Response {
+	skew, err := sunbeam.GetManifestSkew(s)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, skew)
+}
+
+func cmdNodesRoleValidationGet(s *state.State, r *http.Request) response.Response {
+	validation, err := sunbeam.ValidateNodeRoles(s)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, validation)
 }
 
 func cmdNodesGet(s *state.State, r *http.Request) response.Response {
@@ -56,7 +429,7 @@ func cmdNodesGet(s *state.State, r *http.Request) response.Response {
 				return response.NotFound(err)
 			}
 		}
-		return response.InternalError(err)
+		return response.SmartError(err)
 	}
 
 	return response.SyncResponse(true, node)
@@ -70,12 +443,59 @@ func cmdNodesPost(s *state.State, r *http.Request) response.Response {
 		return response.InternalError(err)
 	}
 
-	err = sunbeam.AddNode(s, req.Name, req.Role, req.MachineID, req.SystemID)
+	allowed, err := sunbeam.AllowedNodeRoles(s)
 	if err != nil {
-		return response.InternalError(err)
+		return response.SmartError(err)
 	}
 
-	return response.EmptySyncResponse
+	warnings := sunbeam.NodeWarnings(allowed, req.Role, req.MachineID, req.SystemID)
+
+	upsert := r.URL.Query().Get("upsert") == "true"
+
+	err = sunbeam.AddNode(s, req.Name, req.Role, req.MachineID, req.SystemID, req.NodeHardware, upsert)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return warningsResponse("nodes/"+req.Name, warnings)
+}
+
+func cmdNodesBatchPost(s *state.State, r *http.Request) response.Response {
+	var req []json.RawMessage
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	allowed, err := sunbeam.AllowedNodeRoles(s)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	warnings := []string{}
+	nodes := make(types.Nodes, 0, len(req))
+	for _, raw := range req {
+		node := types.Node{MachineID: -1}
+
+		err = json.Unmarshal(raw, &node)
+		if err != nil {
+			return response.BadRequest(err)
+		}
+
+		for _, warning := range sunbeam.NodeWarnings(allowed, node.Role, node.MachineID, node.SystemID) {
+			warnings = append(warnings, fmt.Sprintf("%s: %s", node.Name, warning))
+		}
+
+		nodes = append(nodes, node)
+	}
+
+	err = sunbeam.AddNodesBatch(s, nodes)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return warningsResponse("nodes", warnings)
 }
 
 func cmdNodesPut(s *state.State, r *http.Request) response.Response {
@@ -91,12 +511,19 @@ func cmdNodesPut(s *state.State, r *http.Request) response.Response {
 		return response.InternalError(err)
 	}
 
+	allowed, err := sunbeam.AllowedNodeRoles(s)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	warnings := sunbeam.NodeWarnings(allowed, req.Role, req.MachineID, req.SystemID)
+
 	err = sunbeam.UpdateNode(s, name, req.Role, req.MachineID, req.SystemID)
 	if err != nil {
-		return response.InternalError(err)
+		return response.SmartError(err)
 	}
 
-	return response.EmptySyncResponse
+	return warningsResponse("nodes/"+name, warnings)
 }
 
 func cmdNodesDelete(s *state.State, r *http.Request) response.Response {
@@ -105,9 +532,289 @@ func cmdNodesDelete(s *state.State, r *http.Request) response.Response {
 		return response.SmartError(err)
 	}
 	err = sunbeam.DeleteNode(s, name)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.EmptySyncResponse
+}
+
+func cmdNodeHardwarePut(s *state.State, r *http.Request) response.Response {
+	var req types.NodeHardware
+
+	name, err := url.PathUnescape(mux.Vars(r)["name"])
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	err = sunbeam.SetNodeHardware(s, name, req)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.EmptySyncResponse
+}
+
+func cmdNodeIdentityPut(s *state.State, r *http.Request) response.Response {
+	var req types.NodeIdentity
+
+	name, err := url.PathUnescape(mux.Vars(r)["name"])
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	err = sunbeam.SetNodeIdentity(s, name, req)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.EmptySyncResponse
+}
+
+func cmdNodeHistoryGet(s *state.State, r *http.Request) response.Response {
+	name, err := url.PathUnescape(mux.Vars(r)["name"])
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	history, err := sunbeam.GetNodeHistory(s, name)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, history)
+}
+
+func cmdNodeMetadataGet(s *state.State, r *http.Request) response.Response {
+	name, err := url.PathUnescape(mux.Vars(r)["name"])
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	metadata, err := sunbeam.GetNodeMetadata(s, name)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, metadata)
+}
+
+func cmdNodeMetadataPatch(s *state.State, r *http.Request) response.Response {
+	var req map[string]any
+
+	name, err := url.PathUnescape(mux.Vars(r)["name"])
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return response.BadRequest(fmt.Errorf("Invalid node metadata: %w", err))
+	}
+
+	metadata, err := sunbeam.PatchNodeMetadata(s, name, req)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, metadata)
+}
+
+func cmdNodeAppliedManifestPut(s *state.State, r *http.Request) response.Response {
+	var req types.AppliedManifest
+
+	name, err := url.PathUnescape(mux.Vars(r)["name"])
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	err = sunbeam.SetNodeAppliedManifest(s, name, req.ManifestID)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.EmptySyncResponse
+}
+
+func cmdNodeRemovalSafetyGet(s *state.State, r *http.Request) response.Response {
+	name, err := url.PathUnescape(mux.Vars(r)["name"])
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	safety, err := sunbeam.CheckNodeRemoval(s, name)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, safety)
+}
+
+func cmdNodeClaimPost(s *state.State, r *http.Request) response.Response {
+	var req types.NodeClaim
+
+	name, err := url.PathUnescape(mux.Vars(r)["name"])
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	err = sunbeam.ClaimNode(s, name, req.Owner)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.EmptySyncResponse
+}
+
+func cmdNodeRenamePost(s *state.State, r *http.Request) response.Response {
+	var req types.NodeRename
+
+	name, err := url.PathUnescape(mux.Vars(r)["name"])
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	err = sunbeam.RenameNode(s, name, req.Name)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.EmptySyncResponse
+}
+
+func cmdNodeReleasePost(s *state.State, r *http.Request) response.Response {
+	var req types.NodeClaim
+
+	name, err := url.PathUnescape(mux.Vars(r)["name"])
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	// The owner is optional on release, an empty body releases unconditionally.
+	if r.ContentLength != 0 {
+		err = json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			return response.BadRequest(err)
+		}
+	}
+
+	err = sunbeam.ReleaseNode(s, name, req.Owner)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.EmptySyncResponse
+}
+
+func cmdNodeConfigGet(s *state.State, r *http.Request) response.Response {
+	name, err := url.PathUnescape(mux.Vars(r)["name"])
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	key, err := url.PathUnescape(mux.Vars(r)["key"])
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	value, err := sunbeam.GetNodeConfig(s, name, key)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, value)
+}
+
+func cmdNodeConfigPut(s *state.State, r *http.Request) response.Response {
+	name, err := url.PathUnescape(mux.Vars(r)["name"])
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	key, err := url.PathUnescape(mux.Vars(r)["key"])
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	var body bytes.Buffer
+	_, err = body.ReadFrom(r.Body)
 	if err != nil {
 		return response.InternalError(err)
 	}
 
+	err = sunbeam.SetNodeConfig(s, name, key, body.String())
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.EmptySyncResponse
+}
+
+func cmdNodeConfigHistoryGet(s *state.State, r *http.Request) response.Response {
+	name, err := url.PathUnescape(mux.Vars(r)["name"])
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	key, err := url.PathUnescape(mux.Vars(r)["key"])
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	history, err := sunbeam.GetNodeConfigHistory(s, name, key)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, history)
+}
+
+func cmdNodeConfigRevertPost(s *state.State, r *http.Request) response.Response {
+	name, err := url.PathUnescape(mux.Vars(r)["name"])
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	key, err := url.PathUnescape(mux.Vars(r)["key"])
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	var req types.NodeConfigRevert
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	err = sunbeam.RevertNodeConfig(s, name, key, req.ID)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
 	return response.EmptySyncResponse
 }
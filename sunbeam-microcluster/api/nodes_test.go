@@ -0,0 +1,73 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/api/types"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/database"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/internal/testutil"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/sunbeam"
+)
+
+func TestNodeMetadataPatchInvalid(t *testing.T) {
+	s := testutil.NewState(t)
+
+	err := sunbeam.AddNode(s, "node1", nil, -1, "", types.NodeHardware{}, false)
+	if err != nil {
+		t.Fatalf("Failed to add node: %v", err)
+	}
+
+	for _, body := range []string{`{"zone": `, `["zone"]`, `"az1"`, `null`} {
+		r := httptest.NewRequest(http.MethodPatch, "/1.0/nodes/node1/metadata", strings.NewReader(body))
+		r = mux.SetURLVars(r, map[string]string{"name": "node1"})
+		w := httptest.NewRecorder()
+
+		err := cmdNodeMetadataPatch(s, r).Render(w)
+		if err != nil {
+			t.Fatalf("Failed to render response: %v", err)
+		}
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected metadata patch %s to be rejected with 400, got %d", body, w.Code)
+		}
+	}
+
+	metadata, err := sunbeam.GetNodeMetadata(s, "node1")
+	if err != nil {
+		t.Fatalf("Failed to get node metadata: %v", err)
+	}
+
+	if len(metadata) != 0 {
+		t.Errorf("Node has metadata %v after rejected patches, expected none", metadata)
+	}
+}
+
+func TestNodeLabels(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/1.0/nodes?label=zone=az1&label=rack=r1=a", nil)
+
+	labels, err := nodeLabels(r)
+	if err != nil {
+		t.Fatalf("Failed to parse labels: %v", err)
+	}
+
+	expected := []database.NodeLabel{{Key: "zone", Value: "az1"}, {Key: "rack", Value: "r1=a"}}
+	if !slices.Equal(labels, expected) {
+		t.Errorf("Parsed labels %v, expected %v", labels, expected)
+	}
+
+	for _, label := range []string{"zone", "=az1", `zo"ne=az1`} {
+		r := httptest.NewRequest(http.MethodGet, "/1.0/nodes?label="+url.QueryEscape(label), nil)
+
+		_, err := nodeLabels(r)
+		if err == nil {
+			t.Errorf("Expected label %q to be rejected", label)
+		}
+	}
+}
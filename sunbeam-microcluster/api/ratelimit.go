@@ -0,0 +1,58 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/canonical/lxd/lxd/response"
+	"github.com/canonical/microcluster/rest"
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/sunbeam"
+)
+
+// unlimitedPaths are the endpoint paths exempt from rate limiting, so that
+// monitoring keeps working while clients are throttled.
+var unlimitedPaths = []string{"health", "metrics"}
+
+// rateLimited wraps the actions of the given endpoints so that requests
+// beyond the rate set by the api.rate_limit config key are rejected with
+// 429 Too Many Requests. Reads and writes are limited separately.
+func rateLimited(endpoints []rest.Endpoint) []rest.Endpoint {
+	for i := range endpoints {
+		if slices.Contains(unlimitedPaths, endpoints[i].Path) {
+			continue
+		}
+
+		for _, action := range []*rest.EndpointAction{&endpoints[i].Get, &endpoints[i].Put, &endpoints[i].Post, &endpoints[i].Delete, &endpoints[i].Patch} {
+			if action.Handler == nil {
+				continue
+			}
+
+			handler := action.Handler
+			action.Handler = func(s *state.State, r *http.Request) response.Response {
+				retryAfter, ok := sunbeam.AllowAPIRequest(s, r.Method != http.MethodGet)
+				if !ok {
+					return tooManyRequests(int(math.Ceil(retryAfter.Seconds())))
+				}
+
+				return handler(s, r)
+			}
+		}
+	}
+
+	return endpoints
+}
+
+// tooManyRequests returns a 429 Too Many Requests response telling the
+// client to retry after the given number of seconds.
+func tooManyRequests(retryAfter int) response.Response {
+	return response.ManualResponse(func(w http.ResponseWriter) error {
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+
+		return response.ErrorResponse(http.StatusTooManyRequests, fmt.Sprintf("API rate limit exceeded, retry after %d seconds", retryAfter)).Render(w)
+	})
+}
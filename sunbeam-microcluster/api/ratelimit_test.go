@@ -0,0 +1,59 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/canonical/lxd/lxd/response"
+	"github.com/canonical/microcluster/rest"
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/internal/testutil"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/sunbeam"
+)
+
+func TestRateLimited(t *testing.T) {
+	s := testutil.NewState(t)
+
+	err := sunbeam.UpdateConfig(s, "api.rate_limit", "1")
+	if err != nil {
+		t.Fatalf("Failed to set API rate limit: %v", err)
+	}
+
+	ok := func(s *state.State, r *http.Request) response.Response {
+		return response.EmptySyncResponse
+	}
+
+	endpoints := rateLimited([]rest.Endpoint{
+		{Path: "config/{key}", Put: rest.EndpointAction{Handler: ok}},
+		{Path: "health", Put: rest.EndpointAction{Handler: ok}},
+	})
+
+	put := func(endpoint rest.Endpoint) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPut, "/1.0/"+endpoint.Path, nil)
+		w := httptest.NewRecorder()
+
+		err := endpoint.Put.Handler(s, r).Render(w)
+		if err != nil {
+			t.Fatalf("Failed to render response: %v", err)
+		}
+
+		return w
+	}
+
+	w := put(endpoints[0])
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the first write to be allowed, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = put(endpoints[0])
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "1" {
+		t.Fatalf("Expected a write beyond the rate to get 429 with Retry-After 1, got %d with %q", w.Code, w.Header().Get("Retry-After"))
+	}
+
+	w = put(endpoints[1])
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the health endpoint not to be rate limited, got %d", w.Code)
+	}
+}
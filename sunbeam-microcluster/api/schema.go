@@ -0,0 +1,45 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/canonical/lxd/lxd/response"
+	"github.com/canonical/microcluster/rest"
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/sunbeam"
+)
+
+// /1.0/schema endpoint.
+// Reports the schema version, to check that members converged on upgrades.
+var schemaCmd = rest.Endpoint{
+	Path: "schema",
+
+	Get: rest.EndpointAction{Handler: cmdSchemaGet, ProxyTarget: true, AllowUntrusted: true},
+}
+
+// /1.0/schema/migrate endpoint.
+// Applies pending schema extensions, must be called on the dqlite leader.
+var schemaMigrateCmd = rest.Endpoint{
+	Path: "schema/migrate",
+
+	Post: rest.EndpointAction{Handler: cmdSchemaMigratePost, ProxyTarget: true},
+}
+
+func cmdSchemaGet(s *state.State, _ *http.Request) response.Response {
+	schema, err := sunbeam.GetSchema(s)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, schema)
+}
+
+func cmdSchemaMigratePost(s *state.State, _ *http.Request) response.Response {
+	migration, err := sunbeam.MigrateSchema(s)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, migration)
+}
@@ -67,7 +67,7 @@ func cmdStateList(s *state.State, _ *http.Request) response.Response {
 	plans, err := sunbeam.GetTerraformStates(s)
 
 	if err != nil {
-		return response.InternalError(err)
+		return response.SmartError(err)
 	}
 
 	return response.SyncResponse(true, plans)
@@ -88,7 +88,7 @@ func cmdStateGet(s *state.State, r *http.Request) response.Response {
 				return response.NotFound(err)
 			}
 		}
-		return response.InternalError(err)
+		return response.SmartError(err)
 	}
 
 	var jsonState map[string]interface{}
@@ -135,7 +135,7 @@ func cmdStatePut(s *state.State, r *http.Request) response.Response {
 				})
 			}
 		}
-		return response.InternalError(err)
+		return response.SmartError(err)
 	}
 
 	return response.EmptySyncResponse
@@ -156,7 +156,7 @@ func cmdStateDelete(s *state.State, r *http.Request) response.Response {
 				return response.NotFound(err)
 			}
 		}
-		return response.InternalError(err)
+		return response.SmartError(err)
 	}
 
 	return response.EmptySyncResponse
@@ -166,7 +166,7 @@ func cmdLockList(s *state.State, _ *http.Request) response.Response {
 	plans, err := sunbeam.GetTerraformLocks(s)
 
 	if err != nil {
-		return response.InternalError(err)
+		return response.SmartError(err)
 	}
 
 	return response.SyncResponse(true, plans)
@@ -187,7 +187,7 @@ func cmdLockGet(s *state.State, r *http.Request) response.Response {
 				return response.NotFound(err)
 			}
 		}
-		return response.InternalError(err)
+		return response.SmartError(err)
 	}
 
 	// Just send state data instead of SyncResponse Json object as
@@ -230,7 +230,7 @@ func cmdLockPut(s *state.State, r *http.Request) response.Response {
 				})
 			}
 		}
-		return response.InternalError(err)
+		return response.SmartError(err)
 	}
 
 	return response.EmptySyncResponse
@@ -264,7 +264,7 @@ func cmdUnlockPut(s *state.State, r *http.Request) response.Response {
 				})
 			}
 		}
-		return response.InternalError(err)
+		return response.SmartError(err)
 	}
 
 	return response.EmptySyncResponse
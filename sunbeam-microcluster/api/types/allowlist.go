@@ -0,0 +1,7 @@
+// Package types provides shared types and structs.
+package types
+
+// AllowedSystemID structure to hold a system_id approved to join the cluster
+type AllowedSystemID struct {
+	SystemID string `json:"systemid" yaml:"systemid"`
+}
@@ -0,0 +1,9 @@
+// Package types provides shared types and structs.
+package types
+
+// Apply structure to hold a manifest and the config changes to be applied
+// along with it
+type Apply struct {
+	Manifest Manifest          `json:"manifest" yaml:"manifest"`
+	Config   map[string]string `json:"config" yaml:"config"`
+}
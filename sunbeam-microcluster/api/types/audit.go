@@ -0,0 +1,18 @@
+// Package types provides shared types and structs.
+package types
+
+import (
+	"time"
+)
+
+// AuditEntries holds list of AuditEntry type
+type AuditEntries []AuditEntry
+
+// AuditEntry structure to hold an action recorded in the audit log
+type AuditEntry struct {
+	ID        int64     `json:"id" yaml:"id"`
+	Timestamp time.Time `json:"timestamp" yaml:"timestamp"`
+	Actor     string    `json:"actor" yaml:"actor"`
+	Action    string    `json:"action" yaml:"action"`
+	Target    string    `json:"target" yaml:"target"`
+}
@@ -0,0 +1,14 @@
+// Package types provides shared types and structs.
+package types
+
+// ClusterExport structure to hold a snapshot of the logical cluster state
+type ClusterExport struct {
+	// SchemaVersion is the number of schema extensions applied when the
+	// snapshot was taken
+	SchemaVersion int               `json:"schema_version" yaml:"schema_version"`
+	Nodes         Nodes             `json:"nodes" yaml:"nodes"`
+	Config        map[string]string `json:"config" yaml:"config"`
+	// JujuUsers only hold their token if secrets were requested
+	JujuUsers JujuUsers `json:"jujuusers" yaml:"jujuusers"`
+	Manifests Manifests `json:"manifests" yaml:"manifests"`
+}
@@ -0,0 +1,18 @@
+// Package types provides shared types and structs.
+package types
+
+import (
+	"time"
+)
+
+// Changes holds list of Change type
+type Changes []Change
+
+// Change structure to hold an entry of the change feed
+type Change struct {
+	Seq       int64     `json:"seq" yaml:"seq"`
+	Entity    string    `json:"entity" yaml:"entity"`
+	Key       string    `json:"key" yaml:"key"`
+	Action    string    `json:"action" yaml:"action"`
+	Timestamp time.Time `json:"timestamp" yaml:"timestamp"`
+}
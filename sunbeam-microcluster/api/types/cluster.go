@@ -0,0 +1,55 @@
+// Package types provides shared types and structs.
+package types
+
+// ClusterMemberRoles holds list of ClusterMemberRole type
+type ClusterMemberRoles []ClusterMemberRole
+
+// ClusterMemberRole structure to hold the dqlite role of a cluster member
+type ClusterMemberRole struct {
+	Name    string `json:"name" yaml:"name"`
+	Address string `json:"address" yaml:"address"`
+	Role    string `json:"role" yaml:"role"`
+	// Previous is the role before a rebalance, empty if unchanged
+	Previous string `json:"previous,omitempty" yaml:"previous,omitempty"`
+}
+
+// Topology structure to hold the cluster as a graph of vertices and edges
+type Topology struct {
+	Nodes []TopologyNode `json:"nodes" yaml:"nodes"`
+	Edges []TopologyEdge `json:"edges" yaml:"edges"`
+}
+
+// TopologyNode structure to hold a vertex of the topology graph. Kind is
+// one of "node", "member", "role", "owner" or "dqlite-role"
+type TopologyNode struct {
+	ID         string            `json:"id" yaml:"id"`
+	Kind       string            `json:"kind" yaml:"kind"`
+	Name       string            `json:"name" yaml:"name"`
+	Attributes map[string]string `json:"attributes,omitempty" yaml:"attributes,omitempty"`
+}
+
+// TopologyEdge structure to hold a relationship between two vertices
+type TopologyEdge struct {
+	Source string `json:"source" yaml:"source"`
+	Target string `json:"target" yaml:"target"`
+	Kind   string `json:"kind" yaml:"kind"`
+}
+
+// ClusterStatus holds list of ClusterStatusEntry type
+type ClusterStatus []ClusterStatusEntry
+
+// ClusterStatusEntry structure to hold a cluster member along with the node
+// of the same name. Missing is "node" for a member without a node, "member"
+// for a node without a member, and empty when both exist
+type ClusterStatusEntry struct {
+	Name       string   `json:"name" yaml:"name"`
+	Address    string   `json:"address" yaml:"address"`
+	DqliteRole string   `json:"dqlite_role" yaml:"dqlite_role"`
+	Online     bool     `json:"online" yaml:"online"`
+	Role       []string `json:"role" yaml:"role"`
+	MachineID  int      `json:"machineid" yaml:"machineid"`
+	SystemID   string   `json:"systemid" yaml:"systemid"`
+	// Status is the node status tracked from heartbeats
+	Status  string `json:"status" yaml:"status"`
+	Missing string `json:"missing,omitempty" yaml:"missing,omitempty"`
+}
@@ -0,0 +1,36 @@
+// Package types provides shared types and structs.
+package types
+
+// CompactRequest structure to hold the parameters of a compaction
+type CompactRequest struct {
+	// Retention is how long entries are kept, as a Go duration, a default
+	// applies if empty
+	Retention string `json:"retention" yaml:"retention"`
+}
+
+// Compaction structure to hold the number of rows a compaction removed
+type Compaction struct {
+	AuditRemoved   int64 `json:"audit_removed" yaml:"audit_removed"`
+	ChangesRemoved int64 `json:"changes_removed" yaml:"changes_removed"`
+	// ConfigHistoryRemoved is the number of global and node config changes
+	// removed from the history, which has its own retention
+	ConfigHistoryRemoved int64 `json:"config_history_removed" yaml:"config_history_removed"`
+	// DeletedNodesRemoved is the number of soft deleted nodes permanently
+	// deleted, which have their own retention
+	DeletedNodesRemoved int64 `json:"deleted_nodes_removed" yaml:"deleted_nodes_removed"`
+	// NodeHistoryRemoved is the number of node changes removed from the
+	// history, which has its own retention
+	NodeHistoryRemoved int64 `json:"node_history_removed" yaml:"node_history_removed"`
+	// LowWaterMark is the change sequence no change past was removed, -1 if
+	// none is configured
+	LowWaterMark int64 `json:"low_water_mark" yaml:"low_water_mark"`
+}
+
+// Vacuum structure to hold the size of the database in bytes before and
+// after it was vacuumed
+type Vacuum struct {
+	SizeBefore int64 `json:"size_before" yaml:"size_before"`
+	SizeAfter  int64 `json:"size_after" yaml:"size_after"`
+	// Duration is how long the vacuum held the database, as a Go duration
+	Duration string `json:"duration" yaml:"duration"`
+}
@@ -0,0 +1,94 @@
+// Package types provides shared types and structs.
+package types
+
+import (
+	"time"
+)
+
+// ConfigImport holds a document of config key/value pairs to be imported
+type ConfigImport struct {
+	Config map[string]string `json:"config" yaml:"config"`
+}
+
+// ConfigChange holds the old and new value of a changed config key
+type ConfigChange struct {
+	Old string `json:"old" yaml:"old"`
+	New string `json:"new" yaml:"new"`
+}
+
+// ConfigDiff holds the changes importing a config document would make
+type ConfigDiff struct {
+	Added   map[string]string       `json:"added" yaml:"added"`
+	Changed map[string]ConfigChange `json:"changed" yaml:"changed"`
+	Removed []string                `json:"removed" yaml:"removed"`
+}
+
+// ConfigSnapshot holds a named copy of the config key/value pairs
+type ConfigSnapshot struct {
+	Name      string            `json:"name" yaml:"name"`
+	Config    map[string]string `json:"config" yaml:"config"`
+	CreatedAt time.Time         `json:"created_at" yaml:"created_at"`
+}
+
+// ConfigSnapshotPost holds the name of a config snapshot to take
+type ConfigSnapshotPost struct {
+	Name string `json:"name" yaml:"name"`
+}
+
+// ConfigParent holds the parent key a config key inherits its value from
+type ConfigParent struct {
+	Parent string `json:"parent" yaml:"parent"`
+}
+
+// EffectiveConfig holds the resolved value of a config key and the key
+// the value was read from
+type EffectiveConfig struct {
+	Key    string `json:"key" yaml:"key"`
+	Value  string `json:"value" yaml:"value"`
+	Source string `json:"source" yaml:"source"`
+	// Node is the node the value was rendered for, if any
+	Node string `json:"node,omitempty" yaml:"node,omitempty"`
+}
+
+// TypedConfig holds the value of a config key coerced to the requested
+// type. Durations are given in seconds
+type TypedConfig struct {
+	Key   string `json:"key" yaml:"key"`
+	Type  string `json:"type" yaml:"type"`
+	Value any    `json:"value" yaml:"value"`
+}
+
+// DefaultedConfig holds the value of a config key, or the default the
+// caller gave if the key is not set
+type DefaultedConfig struct {
+	Key       string `json:"key" yaml:"key"`
+	Value     string `json:"value" yaml:"value"`
+	IsDefault bool   `json:"is_default" yaml:"is_default"`
+}
+
+// ScheduledConfig holds a config value that takes effect at a future time
+type ScheduledConfig struct {
+	ID          int64     `json:"id" yaml:"id"`
+	Key         string    `json:"key" yaml:"key"`
+	Value       string    `json:"value" yaml:"value"`
+	EffectiveAt time.Time `json:"effective_at" yaml:"effective_at"`
+}
+
+// ConfigHistoryEntry holds a change of the value of a config key. OldValue
+// is unset when the key was created, NewValue when it was deleted
+type ConfigHistoryEntry struct {
+	Key       string    `json:"key" yaml:"key"`
+	OldValue  *string   `json:"old_value" yaml:"old_value"`
+	NewValue  *string   `json:"new_value" yaml:"new_value"`
+	ChangedAt time.Time `json:"changed_at" yaml:"changed_at"`
+}
+
+// ConfigEvent holds a change of a config key streamed to subscribers. Value
+// is unset when the key was deleted
+type ConfigEvent struct {
+	Seq       int64     `json:"seq" yaml:"seq"`
+	Key       string    `json:"key" yaml:"key"`
+	Action    string    `json:"action" yaml:"action"`
+	Value     *string   `json:"value" yaml:"value"`
+	Timestamp time.Time `json:"timestamp" yaml:"timestamp"`
+}
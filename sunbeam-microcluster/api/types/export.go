@@ -0,0 +1,28 @@
+// Package types provides shared types and structs.
+package types
+
+// AnsibleInventory holds an Ansible dynamic inventory, keyed by group name
+// with the host variables under "_meta"
+type AnsibleInventory map[string]any
+
+// AnsibleGroup structure to hold the hosts and child groups of an Ansible group
+type AnsibleGroup struct {
+	Hosts    []string `json:"hosts,omitempty" yaml:"hosts,omitempty"`
+	Children []string `json:"children,omitempty" yaml:"children,omitempty"`
+}
+
+// AnsibleMeta structure to hold the host variables of an Ansible inventory
+type AnsibleMeta struct {
+	HostVars map[string]AnsibleHostVars `json:"hostvars" yaml:"hostvars"`
+}
+
+// AnsibleHostVars structure to hold the variables of a host in an Ansible inventory
+type AnsibleHostVars struct {
+	// AnsibleHost is only set for nodes that are cluster members
+	AnsibleHost string   `json:"ansible_host,omitempty" yaml:"ansible_host,omitempty"`
+	Roles       []string `json:"sunbeam_roles" yaml:"sunbeam_roles"`
+	MachineID   int      `json:"sunbeam_machine_id" yaml:"sunbeam_machine_id"`
+	SystemID    string   `json:"sunbeam_system_id" yaml:"sunbeam_system_id"`
+	Owner       string   `json:"sunbeam_owner" yaml:"sunbeam_owner"`
+	Cordoned    bool     `json:"sunbeam_cordoned" yaml:"sunbeam_cordoned"`
+}
@@ -0,0 +1,9 @@
+// Package types provides shared types and structs.
+package types
+
+// ClusterFreeze structure to hold whether cluster membership changes are
+// blocked, and why
+type ClusterFreeze struct {
+	Frozen bool   `json:"frozen" yaml:"frozen"`
+	Reason string `json:"reason" yaml:"reason"`
+}
@@ -0,0 +1,15 @@
+// Package types provides shared types and structs.
+package types
+
+// Health structure to hold the health of the local cluster member
+type Health struct {
+	Name string `json:"name" yaml:"name"`
+	// Role is the dqlite role of the member: voter, stand-by or spare
+	Role string `json:"role" yaml:"role"`
+	// Leader is the address of the dqlite leader
+	Leader string `json:"leader" yaml:"leader"`
+	// Quorum is set while a majority of the dqlite voters is online
+	Quorum bool `json:"quorum" yaml:"quorum"`
+	// SchemaVersion is the number of schema extensions applied
+	SchemaVersion int `json:"schema_version" yaml:"schema_version"`
+}
@@ -0,0 +1,47 @@
+// Package types provides shared types and structs.
+package types
+
+import (
+	"time"
+)
+
+// JoinTokenRequest structure to hold the parameters of a join token to issue
+type JoinTokenRequest struct {
+	// SystemID binds the token to a node's system_id, optional
+	SystemID string `json:"systemid" yaml:"systemid"`
+	// ExpiresIn is the token lifetime in seconds, a default applies if 0
+	ExpiresIn int64 `json:"expires_in" yaml:"expires_in"`
+}
+
+// JoinTokenBatchRequest structure to hold the parameters of a batch of join
+// tokens to issue
+type JoinTokenBatchRequest struct {
+	// Count is the number of unbound tokens to issue
+	Count int `json:"count" yaml:"count"`
+	// SystemIDs are issued one bound token each, in addition to Count
+	SystemIDs []string `json:"systemids" yaml:"systemids"`
+	// ExpiresIn is the lifetime of each token in seconds, a default applies if 0
+	ExpiresIn int64 `json:"expires_in" yaml:"expires_in"`
+}
+
+// JoinToken structure to hold an issued join token, the token is only
+// returned at issuance
+type JoinToken struct {
+	Token     string    `json:"token" yaml:"token"`
+	SystemID  string    `json:"systemid" yaml:"systemid"`
+	ExpiresAt time.Time `json:"expires_at" yaml:"expires_at"`
+}
+
+// NodeRegistration structure to hold the details a node presents to
+// register itself
+type NodeRegistration struct {
+	Token    string   `json:"token" yaml:"token"`
+	Name     string   `json:"name" yaml:"name"`
+	SystemID string   `json:"systemid" yaml:"systemid"`
+	Role     []string `json:"role" yaml:"role"`
+	// SchemaVersion is the number of schema extensions the node runs, not
+	// checked if 0
+	SchemaVersion int `json:"schema_version" yaml:"schema_version"`
+
+	NodeHardware `yaml:",inline"`
+}
@@ -1,6 +1,10 @@
 // Package types provides shared types and structs.
 package types
 
+import (
+	"time"
+)
+
 // JujuUsers is list of JujuUser struct
 type JujuUsers []JujuUser
 
@@ -8,4 +12,17 @@ type JujuUsers []JujuUser
 type JujuUser struct {
 	Username string `json:"username" yaml:"username"`
 	Token    string `json:"token" yaml:"token"`
+	// ExpiresAt is when the token stops being valid, unset if it never
+	// expires
+	ExpiresAt *time.Time `json:"expires_at,omitempty" yaml:"expires_at,omitempty"`
+	Expired   bool       `json:"expired" yaml:"expired"`
+}
+
+// JujuUserRotation structure to hold the parameters of a token rotation
+type JujuUserRotation struct {
+	// Token is the new token, one is generated if empty
+	Token string `json:"token" yaml:"token"`
+	// ExpiresIn is the lifetime of the new token in seconds, a default
+	// applies if 0
+	ExpiresIn int64 `json:"expires_in" yaml:"expires_in"`
 }
@@ -0,0 +1,20 @@
+// Package types provides shared types and structs.
+package types
+
+import (
+	"time"
+)
+
+// MaintenanceWindows holds list of MaintenanceWindow type
+type MaintenanceWindows []MaintenanceWindow
+
+// MaintenanceWindow structure to hold a maintenance action scheduled on a node
+type MaintenanceWindow struct {
+	ID   int64  `json:"id" yaml:"id"`
+	Node string `json:"node" yaml:"node"`
+	// Action is the maintenance to perform, either reboot or drain
+	Action  string    `json:"action" yaml:"action"`
+	StartAt time.Time `json:"start_at" yaml:"start_at"`
+	// State is one of scheduled, started or completed
+	State string `json:"state" yaml:"state"`
+}
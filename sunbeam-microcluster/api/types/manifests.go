@@ -9,4 +9,86 @@ type Manifest struct {
 	ManifestID  string `json:"manifestid" yaml:"manifestid"`
 	AppliedDate string `json:"applieddate" yaml:"applieddate"`
 	Data        string `json:"data" yaml:"data"`
+	// AppliedByVersion is the version of the daemon that recorded the
+	// manifest, "unknown" for manifests recorded before it was tracked
+	AppliedByVersion string `json:"appliedbyversion" yaml:"appliedbyversion"`
+	// Checksum is the SHA-256 of the data recorded when the manifest was
+	// written, empty in listings that leave it out
+	Checksum string `json:"checksum,omitempty" yaml:"checksum,omitempty"`
+	// RolledBackFrom is the id of the manifest whose data this manifest
+	// re-applied, if it was recorded by a rollback
+	RolledBackFrom string `json:"rolledbackfrom,omitempty" yaml:"rolledbackfrom,omitempty"`
+}
+
+// ManifestsPage structure to hold a page of manifests and the cursor of the
+// next page, empty on the last page
+type ManifestsPage struct {
+	Manifests  Manifests `json:"manifests" yaml:"manifests"`
+	NextCursor string    `json:"next_cursor,omitempty" yaml:"next_cursor,omitempty"`
+}
+
+// ManifestSummaries holds list of ManifestSummary type
+type ManifestSummaries []ManifestSummary
+
+// ManifestSummary structure to hold when a manifest was applied, without
+// its data
+type ManifestSummary struct {
+	ManifestID       string `json:"manifestid" yaml:"manifestid"`
+	AppliedDate      string `json:"applieddate" yaml:"applieddate"`
+	AppliedByVersion string `json:"appliedbyversion" yaml:"appliedbyversion"`
+}
+
+// ManifestVerification structure to hold the outcome of recomputing the
+// checksum of a manifest's data
+type ManifestVerification struct {
+	ManifestID string `json:"manifestid" yaml:"manifestid"`
+	// Checksum is the SHA-256 recorded when the manifest was written
+	Checksum string `json:"checksum" yaml:"checksum"`
+	// Actual is the SHA-256 of the data as stored now, empty if the data
+	// cannot be read
+	Actual string `json:"actual" yaml:"actual"`
+	Valid  bool   `json:"valid" yaml:"valid"`
+}
+
+// ManifestWithWarnings holds a stored manifest along with the non-fatal
+// issues raised when writing it
+type ManifestWithWarnings struct {
+	Manifest
+	Warnings []string `json:"warnings" yaml:"warnings"`
+}
+
+// ManifestNodesValidation structure to hold the differences between the
+// nodes a manifest declares and the recorded nodes
+type ManifestNodesValidation struct {
+	ManifestID string `json:"manifestid" yaml:"manifestid"`
+	// Missing are the declared nodes that are not recorded
+	Missing []string `json:"missing" yaml:"missing"`
+	// Extra are the recorded nodes the manifest does not declare
+	Extra []string `json:"extra" yaml:"extra"`
+	// Mismatched are the nodes recorded with other roles than declared
+	Mismatched []NodeRoleMismatch `json:"mismatched" yaml:"mismatched"`
+}
+
+// NodeRoleMismatch structure to hold the declared and recorded roles of a node
+type NodeRoleMismatch struct {
+	Name     string   `json:"name" yaml:"name"`
+	Expected []string `json:"expected" yaml:"expected"`
+	Actual   []string `json:"actual" yaml:"actual"`
+}
+
+// ManifestChange structure to hold the old and new value of a key changed
+// between two manifests
+type ManifestChange struct {
+	Old any `json:"old" yaml:"old"`
+	New any `json:"new" yaml:"new"`
+}
+
+// ManifestDiff structure to hold the keys added, changed and removed from
+// one manifest to another, by path
+type ManifestDiff struct {
+	From    string                    `json:"from" yaml:"from"`
+	To      string                    `json:"to" yaml:"to"`
+	Added   map[string]any            `json:"added" yaml:"added"`
+	Changed map[string]ManifestChange `json:"changed" yaml:"changed"`
+	Removed []string                  `json:"removed" yaml:"removed"`
 }
@@ -1,6 +1,10 @@
 // Package types provides shared types and structs.
 package types
 
+import (
+	"time"
+)
+
 // Nodes holds list of Node type
 type Nodes []Node
 
@@ -12,4 +16,152 @@ type Node struct {
 	MachineID int `json:"machineid" yaml:"machineid"`
 	// SystemID is the unique identifier for the node in machine provider
 	SystemID string `json:"systemid" yaml:"systemid"`
+	// Owner is the tenant the node is reserved to, empty if unreserved
+	Owner string `json:"owner" yaml:"owner"`
+	// Cordoned is set while the node is under maintenance
+	Cordoned bool `json:"cordoned" yaml:"cordoned"`
+	// LastManifestID is the manifest the node last reported as applied
+	LastManifestID string `json:"last_manifest_id" yaml:"last_manifest_id"`
+	// Status is "online", "offline" or "unknown", tracked from heartbeats
+	Status string `json:"status" yaml:"status"`
+	// LastSeen is the last heartbeat of the node, unset if never seen
+	LastSeen *time.Time `json:"last_seen,omitempty" yaml:"last_seen,omitempty"`
+	// CreatedAt is when the node record was first inserted
+	CreatedAt time.Time `json:"created_at" yaml:"created_at"`
+	// UpdatedAt is when the node record was last modified
+	UpdatedAt time.Time `json:"updated_at" yaml:"updated_at"`
+	// DeletedAt is when the node was soft deleted, unset for live nodes
+	DeletedAt *time.Time `json:"deleted_at,omitempty" yaml:"deleted_at,omitempty"`
+	// Metadata holds arbitrary labels attached to the node, such as its
+	// rack or zone
+	Metadata map[string]any `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+
+	NodeHardware `yaml:",inline"`
+}
+
+// NodeHistoryEntry holds a change of a field of a node. Deletions are
+// recorded as a change of the "deleted" field to "soft" or "hard", with
+// OldValue unset
+type NodeHistoryEntry struct {
+	Name      string    `json:"name" yaml:"name"`
+	Field     string    `json:"field" yaml:"field"`
+	OldValue  *string   `json:"old_value" yaml:"old_value"`
+	NewValue  *string   `json:"new_value" yaml:"new_value"`
+	ChangedAt time.Time `json:"changed_at" yaml:"changed_at"`
+}
+
+// NodeConfigHistoryEntry holds a change of the value a node overrides a
+// config key with. OldValue is unset when the override was created. The ID
+// identifies the value the change set to revert to it
+type NodeConfigHistoryEntry struct {
+	ID        int64     `json:"id" yaml:"id"`
+	Node      string    `json:"node" yaml:"node"`
+	Key       string    `json:"key" yaml:"key"`
+	OldValue  *string   `json:"old_value" yaml:"old_value"`
+	NewValue  *string   `json:"new_value" yaml:"new_value"`
+	ChangedAt time.Time `json:"changed_at" yaml:"changed_at"`
+}
+
+// NodeConfigRevert structure to hold the ID of the node config history
+// entry whose value to revert an override to
+type NodeConfigRevert struct {
+	ID int64 `json:"id" yaml:"id"`
+}
+
+// NodesPage structure to hold a page of the node list
+type NodesPage struct {
+	Nodes Nodes `json:"nodes" yaml:"nodes"`
+	// NextOffset is the offset of the next page, unset on the last page
+	NextOffset *int `json:"next_offset,omitempty" yaml:"next_offset,omitempty"`
+}
+
+// DeletedNodesPurge structure to hold the number of soft deleted nodes
+// permanently deleted
+type DeletedNodesPurge struct {
+	Removed int64 `json:"removed" yaml:"removed"`
+}
+
+// NodeHardware structure to hold the hardware facts of a node
+type NodeHardware struct {
+	CPUCount int `json:"cpu_count" yaml:"cpu_count"`
+	MemoryMB int `json:"memory_mb" yaml:"memory_mb"`
+	DiskGB   int `json:"disk_gb" yaml:"disk_gb"`
+}
+
+// NodesCapacity structure to hold the hardware totals across the cluster
+type NodesCapacity struct {
+	Nodes int `json:"nodes" yaml:"nodes"`
+
+	NodeHardware `yaml:",inline"`
+}
+
+// NodesSummary structure to hold the number of nodes and of nodes holding
+// each role
+type NodesSummary struct {
+	Nodes int            `json:"nodes" yaml:"nodes"`
+	Roles map[string]int `json:"roles" yaml:"roles"`
+}
+
+// NodeClaim structure to hold the tenant claiming or releasing a node
+type NodeClaim struct {
+	Owner string `json:"owner" yaml:"owner"`
+}
+
+// NodeIdentity structure to hold the machine provider identifiers of a
+// node, either may be left unset to keep the current value
+type NodeIdentity struct {
+	MachineID *int    `json:"machineid,omitempty" yaml:"machineid,omitempty"`
+	SystemID  *string `json:"systemid,omitempty" yaml:"systemid,omitempty"`
+}
+
+// NodeRename structure to hold the new name of a node
+type NodeRename struct {
+	Name string `json:"name" yaml:"name"`
+}
+
+// NodeGroups holds list of NodeGroup type
+type NodeGroups []NodeGroup
+
+// NodeGroup structure to hold the nodes sharing a value of the grouped attribute
+type NodeGroup struct {
+	Value string `json:"value" yaml:"value"`
+	Count int    `json:"count" yaml:"count"`
+	// Nodes is only populated when the node names are requested
+	Nodes []string `json:"nodes,omitempty" yaml:"nodes,omitempty"`
+}
+
+// AppliedManifest structure to hold the manifest a node reports as applied
+type AppliedManifest struct {
+	ManifestID string `json:"manifestid" yaml:"manifestid"`
+}
+
+// ManifestSkew structure to hold which manifest each node runs
+type ManifestSkew struct {
+	// Latest is the most recently added manifest
+	Latest string `json:"latest" yaml:"latest"`
+	// Skewed is set when the nodes do not all run the latest manifest
+	Skewed bool `json:"skewed" yaml:"skewed"`
+	// Manifests maps the applied manifest ids to the nodes running them,
+	// nodes that never reported a manifest are listed under ""
+	Manifests map[string][]string `json:"manifests" yaml:"manifests"`
+}
+
+// NodeRemovalSafety structure to hold whether a node can be removed
+// without breaking quorum or role minimums
+type NodeRemovalSafety struct {
+	Name string `json:"name" yaml:"name"`
+	Safe bool   `json:"safe" yaml:"safe"`
+	// Reasons are the problems removing the node would cause
+	Reasons []string `json:"reasons" yaml:"reasons"`
+	// Warnings are issues that do not prevent the removal
+	Warnings []string `json:"warnings" yaml:"warnings"`
+}
+
+// NodeRoleValidation structure to hold the roles nodes may hold and the
+// nodes holding other roles
+type NodeRoleValidation struct {
+	Allowed []string `json:"allowed" yaml:"allowed"`
+	// Invalid maps the names of nodes holding roles that are not allowed
+	// to those roles
+	Invalid map[string][]string `json:"invalid" yaml:"invalid"`
 }
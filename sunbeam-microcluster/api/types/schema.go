@@ -0,0 +1,33 @@
+// Package types provides shared types and structs.
+package types
+
+import (
+	"time"
+)
+
+// Schema structure to hold the schema version of the database
+type Schema struct {
+	// Version is the number of schema extensions applied
+	Version int `json:"version" yaml:"version"`
+	// InternalVersion is the number of microcluster schema updates applied
+	InternalVersion int `json:"internal_version" yaml:"internal_version"`
+	// Applied lists the names of the applied schema extensions, in order
+	Applied []string `json:"applied" yaml:"applied"`
+	// Pending lists the names of the schema extensions known to this member
+	// and not applied yet
+	Pending []string `json:"pending" yaml:"pending"`
+}
+
+// SchemaMigration holds the result of applying pending schema extensions
+type SchemaMigration struct {
+	// Version is the schema extensions version after the migration
+	Version int                `json:"version" yaml:"version"`
+	Applied []AppliedMigration `json:"applied" yaml:"applied"`
+}
+
+// AppliedMigration structure to hold a schema extension applied on demand
+type AppliedMigration struct {
+	Version   int       `json:"version" yaml:"version"`
+	Name      string    `json:"name" yaml:"name"`
+	AppliedAt time.Time `json:"applied_at" yaml:"applied_at"`
+}
@@ -0,0 +1,7 @@
+// Package types provides shared types and structs.
+package types
+
+// Warnings holds the non-fatal issues raised by a successful write
+type Warnings struct {
+	Warnings []string `json:"warnings" yaml:"warnings"`
+}
@@ -0,0 +1,16 @@
+package api
+
+import (
+	"github.com/canonical/lxd/lxd/response"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/api/types"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/sunbeam"
+)
+
+// warningsResponse logs the warnings raised by a committed write and
+// returns them to the client.
+func warningsResponse(target string, warnings []string) response.Response {
+	sunbeam.LogWarnings(target, warnings)
+
+	return response.SyncResponse(true, types.Warnings{Warnings: warnings})
+}
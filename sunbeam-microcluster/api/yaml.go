@@ -0,0 +1,85 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/canonical/lxd/lxd/response"
+	"gopkg.in/yaml.v2"
+)
+
+// yamlMediaTypes are the media types YAML documents are accepted as, the
+// first one is used for responses.
+var yamlMediaTypes = []string{"application/yaml", "application/x-yaml", "text/yaml"}
+
+// isYAMLMediaType returns whether the media type, possibly with parameters,
+// is one of yamlMediaTypes.
+func isYAMLMediaType(value string) bool {
+	mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(value))
+	if err != nil {
+		return false
+	}
+
+	for _, t := range yamlMediaTypes {
+		if mediaType == t {
+			return true
+		}
+	}
+
+	return false
+}
+
+// acceptsYAML returns whether the Accept header of the request asks for a
+// YAML document.
+func acceptsYAML(r *http.Request) bool {
+	for _, value := range strings.Split(r.Header.Get("Accept"), ",") {
+		if isYAMLMediaType(value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// decodeRequest decodes the body of the request into v, as YAML if its
+// Content-Type says so and as JSON otherwise. YAML documents with fields
+// unknown to v are rejected, to catch typos in hand written documents.
+func decodeRequest(r *http.Request, v any) error {
+	if !isYAMLMediaType(r.Header.Get("Content-Type")) {
+		return json.NewDecoder(r.Body).Decode(v)
+	}
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+
+	err = yaml.UnmarshalStrict(data, v)
+	if err != nil {
+		return fmt.Errorf("Failed to parse YAML document: %w", err)
+	}
+
+	return nil
+}
+
+// yamlResponse returns a response rendering v as a bare YAML document, in
+// the form decodeRequest reads back.
+func yamlResponse(v any) response.Response {
+	return response.ManualResponse(func(w http.ResponseWriter) error {
+		data, err := yaml.Marshal(v)
+		if err != nil {
+			return err
+		}
+
+		w.Header().Set("Content-Type", yamlMediaTypes[0])
+		w.WriteHeader(http.StatusOK)
+
+		_, err = w.Write(data)
+
+		return err
+	})
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/canonical/lxd/shared/logger"
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/api"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/sunbeam"
+)
+
+const (
+	// listenReadHeaderTimeout bounds how long a client of the additional
+	// TCP listener may take to send the request headers.
+	listenReadHeaderTimeout = 30 * time.Second
+
+	// defaultListenReadTimeout bounds how long a client may take to send a
+	// whole request, body included, unless set with --listen-read-timeout.
+	defaultListenReadTimeout = 5 * time.Minute
+
+	// defaultListenWriteTimeout bounds how long writing a response may
+	// take, unless set with --listen-write-timeout. Streamed responses lift
+	// it.
+	defaultListenWriteTimeout = 5 * time.Minute
+
+	// defaultListenIdleTimeout bounds how long a keep-alive connection is
+	// kept open between requests, unless set with --listen-idle-timeout.
+	defaultListenIdleTimeout = 2 * time.Minute
+)
+
+// listenTimeouts are the timeouts of the additional TCP listener, zero for
+// none.
+type listenTimeouts struct {
+	Read  time.Duration
+	Write time.Duration
+	Idle  time.Duration
+}
+
+// validate rejects negative timeouts.
+func (t listenTimeouts) validate() error {
+	timeouts := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"read", t.Read},
+		{"write", t.Write},
+		{"idle", t.Idle},
+	}
+
+	for _, timeout := range timeouts {
+		if timeout.value < 0 {
+			return fmt.Errorf("The %s timeout of the TCP listener must not be negative, got %s", timeout.name, timeout.value)
+		}
+	}
+
+	return nil
+}
+
+// listenTCP binds the additional TCP listener given with --listen. Binding
+// happens before the daemon starts, so that a bad address stops it.
+func listenTCP(address string) (net.Listener, error) {
+	if sunbeam.ClientCAs() == nil {
+		return nil, fmt.Errorf("--listen requires --client-ca-file, clients of the TCP listener must present a certificate")
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to listen on %q: %w", address, err)
+	}
+
+	return listener, nil
+}
+
+// serveTCP serves the API over TLS on the given listener until the daemon
+// shuts down. The server certificate of the member is used, and clients
+// must present a certificate issued by the client CA. The client CA is read
+// for each connection, so that reloading it takes effect immediately. Slow
+// or idle clients are disconnected according to the given timeouts, except
+// while a response is streamed.
+func serveTCP(s *state.State, listener net.Listener, timeouts listenTimeouts) {
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{s.ServerCert().KeyPair()},
+				ClientAuth:   tls.RequireAndVerifyClientCert,
+				ClientCAs:    sunbeam.ClientCAs(),
+			}, nil
+		},
+	}
+
+	server := &http.Server{
+		Handler:           api.Handler(s),
+		ReadHeaderTimeout: listenReadHeaderTimeout,
+		ReadTimeout:       timeouts.Read,
+		WriteTimeout:      timeouts.Write,
+		IdleTimeout:       timeouts.Idle,
+	}
+
+	go func() {
+		<-s.Context.Done()
+		_ = server.Close()
+	}()
+
+	go func() {
+		logger.Info("Serving the API over TCP", logger.Ctx{"address": listener.Addr().String()})
+
+		err := server.Serve(tls.NewListener(listener, config))
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("Failed to serve the API over TCP", logger.Ctx{"address": listener.Addr().String(), "err": err})
+		}
+	}()
+}
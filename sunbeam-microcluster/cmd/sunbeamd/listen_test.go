@@ -0,0 +1,85 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/canonical/lxd/shared"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/internal/testutil"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/sunbeam"
+)
+
+func TestListenTCPRequiresClientCA(t *testing.T) {
+	_, err := listenTCP("127.0.0.1:0")
+	if err == nil || !strings.Contains(err.Error(), "--client-ca-file") {
+		t.Fatalf("Expected listening without a client CA to be refused, got %v", err)
+	}
+}
+
+func TestServeTCP(t *testing.T) {
+	s := testutil.NewState(t)
+	s.ServerCert = shared.TestingKeyPair
+
+	caFile, issue := testutil.NewClientCA(t)
+	err := sunbeam.LoadClientAuth(caFile, "", true)
+	if err != nil {
+		t.Fatalf("Failed to load client CA bundle: %v", err)
+	}
+
+	t.Cleanup(func() { _ = sunbeam.LoadClientAuth("", "", false) })
+
+	listener, err := listenTCP("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	defer func() { _ = listener.Close() }()
+
+	_, err = listenTCP(listener.Addr().String())
+	if err == nil || !strings.Contains(err.Error(), listener.Addr().String()) {
+		t.Fatalf("Expected binding an address in use to fail naming it, got %v", err)
+	}
+
+	serveTCP(s, listener, listenTimeouts{})
+
+	get := func(certificates []tls.Certificate) (*http.Response, error) {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			Certificates: certificates,
+			// The member certificate is self-signed.
+			InsecureSkipVerify: true,
+		}}}
+
+		return client.Get("https://" + listener.Addr().String() + "/1.0/config/region")
+	}
+
+	_, err = get(nil)
+	if err == nil {
+		t.Fatalf("Expected a client without a certificate to be refused")
+	}
+
+	resp, err := get([]tls.Certificate{issue("monitor")})
+	if err != nil {
+		t.Fatalf("Failed to query the API over TCP: %v", err)
+	}
+
+	defer resp.Body.Close()
+
+	// The API answers, though the test state has no dqlite database for it
+	// to serve the request from.
+	var body struct {
+		Error string `json:"error"`
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	if err != nil {
+		t.Fatalf("Failed to decode API response: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusServiceUnavailable || body.Error != "Daemon not yet initialized" {
+		t.Fatalf("API over TCP answered %d %q, expected it to report the uninitialized daemon", resp.StatusCode, body.Error)
+	}
+}
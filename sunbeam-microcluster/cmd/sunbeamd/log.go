@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/canonical/lxd/shared/logger"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// logFormatText is the human readable log format MicroCluster uses.
+	logFormatText = "text"
+	// logFormatJSON logs each line as a JSON object.
+	logFormatJSON = "json"
+)
+
+// newDaemonLogger returns a logger writing to out in the given format, at
+// the same levels logger.InitLogger enables for the verbose and debug flags.
+// JSON lines hold the level, timestamp and message keys, along with the
+// context of the log call.
+func newDaemonLogger(format string, out io.Writer, verbose bool, debug bool) (logger.Logger, error) {
+	l := logrus.New()
+	l.SetOutput(out)
+
+	switch format {
+	case logFormatText:
+		l.Formatter = &logrus.TextFormatter{PadLevelText: true, FullTimestamp: true}
+	case logFormatJSON:
+		l.Formatter = &logrus.JSONFormatter{
+			TimestampFormat: time.RFC3339Nano,
+			FieldMap: logrus.FieldMap{
+				logrus.FieldKeyTime: "timestamp",
+				logrus.FieldKeyMsg:  "message",
+			},
+		}
+	default:
+		return nil, fmt.Errorf("Invalid log format %q, must be %q or %q", format, logFormatText, logFormatJSON)
+	}
+
+	l.Level = logrus.WarnLevel
+	if debug {
+		l.Level = logrus.DebugLevel
+	} else if verbose {
+		l.Level = logrus.InfoLevel
+	}
+
+	return &logrusLogger{target: logrus.NewEntry(l)}, nil
+}
+
+// daemonLogger returns the logger configured by the global flags, along
+// with the log file it writes to if any. The logger is nil if the defaults
+// apply and MicroCluster's own logger can be kept.
+func (c *cmdGlobal) daemonLogger() (logger.Logger, *logFile, error) {
+	if c.flagLogFormat == logFormatText && c.flagLogFile == "" {
+		return nil, nil, nil
+	}
+
+	var out io.Writer = os.Stderr
+	var file *logFile
+	if c.flagLogFile != "" {
+		var err error
+		file, err = openLogFile(c.flagLogFile)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		out = io.MultiWriter(os.Stderr, file)
+	}
+
+	l, err := newDaemonLogger(c.flagLogFormat, out, c.flagLogVerbose, c.flagLogDebug)
+	if err != nil {
+		if file != nil {
+			_ = file.Close()
+		}
+
+		return nil, nil, err
+	}
+
+	return l, file, nil
+}
+
+// logFile is a log file that is reopened on demand, so that logrotate can
+// move it away and have the daemon write to a new file.
+type logFile struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+// openLogFile opens the log file at path for appending, creating it and its
+// parent directories if needed.
+func openLogFile(path string) (*logFile, error) {
+	f := &logFile{path: path}
+
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create log directory: %w", err)
+	}
+
+	f.file, err = f.open()
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// open opens the file at the log file path for appending.
+func (f *logFile) open() (*os.File, error) {
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open log file: %w", err)
+	}
+
+	return file, nil
+}
+
+// Write writes to the currently open file.
+func (f *logFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.file.Write(p)
+}
+
+// Reopen closes the file and opens the log file path again. The current
+// file is kept if the path cannot be opened.
+func (f *logFile) Reopen() error {
+	file, err := f.open()
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	old := f.file
+	f.file = file
+
+	return old.Close()
+}
+
+// Close closes the file.
+func (f *logFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.file.Close()
+}
+
+// reopenOnSignal reopens the log file whenever one of the given signals is
+// received, until the returned function is called.
+func reopenOnSignal(f *logFile, signals ...os.Signal) func() {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, signals...)
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-sigCh:
+				err := f.Reopen()
+				if err != nil {
+					logger.Error("Failed to reopen log file", logger.Ctx{"path": f.path, "err": err})
+				}
+
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(sigCh)
+		close(done)
+	}
+}
+
+// logrusLogger implements logger.Logger on top of a logrus entry, so the
+// daemon can pick the formatter and output of its logs.
+type logrusLogger struct {
+	target *logrus.Entry
+}
+
+// withCtx returns the entry with all provided ctx applied.
+func (l *logrusLogger) withCtx(ctx ...logger.Ctx) *logrus.Entry {
+	entry := l.target
+	for _, c := range ctx {
+		entry = entry.WithFields(logrus.Fields(c))
+	}
+
+	return entry
+}
+
+func (l *logrusLogger) Panic(msg string, ctx ...logger.Ctx) {
+	l.withCtx(ctx...).Panic(msg)
+}
+
+func (l *logrusLogger) Fatal(msg string, ctx ...logger.Ctx) {
+	l.withCtx(ctx...).Fatal(msg)
+}
+
+func (l *logrusLogger) Error(msg string, ctx ...logger.Ctx) {
+	l.withCtx(ctx...).Error(msg)
+}
+
+func (l *logrusLogger) Warn(msg string, ctx ...logger.Ctx) {
+	l.withCtx(ctx...).Warn(msg)
+}
+
+func (l *logrusLogger) Info(msg string, ctx ...logger.Ctx) {
+	l.withCtx(ctx...).Info(msg)
+}
+
+func (l *logrusLogger) Debug(msg string, ctx ...logger.Ctx) {
+	l.withCtx(ctx...).Debug(msg)
+}
+
+func (l *logrusLogger) Trace(msg string, ctx ...logger.Ctx) {
+	l.withCtx(ctx...).Trace(msg)
+}
+
+func (l *logrusLogger) AddContext(ctx logger.Ctx) logger.Logger {
+	return &logrusLogger{target: l.withCtx(ctx)}
+}
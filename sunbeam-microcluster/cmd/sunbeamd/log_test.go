@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/canonical/lxd/shared/logger"
+)
+
+func TestJSONLogging(t *testing.T) {
+	var out bytes.Buffer
+
+	l, err := newDaemonLogger(logFormatJSON, &out, true, false)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+
+	l.Info("Registered node", logger.Ctx{"name": "node1"})
+	l.Debug("Not logged without debug")
+
+	lines := bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+	if len(lines) != 1 {
+		t.Fatalf("Logged %d lines, expected 1: %s", len(lines), out.String())
+	}
+
+	var line map[string]any
+	err = json.Unmarshal(lines[0], &line)
+	if err != nil {
+		t.Fatalf("Log line %q is not valid JSON: %v", lines[0], err)
+	}
+
+	expected := map[string]string{"level": "info", "message": "Registered node", "name": "node1"}
+	for key, value := range expected {
+		if line[key] != value {
+			t.Errorf("Log line has %q set to %v, expected %q", key, line[key], value)
+		}
+	}
+
+	if _, ok := line["timestamp"]; !ok {
+		t.Errorf("Log line %s has no timestamp", lines[0])
+	}
+}
+
+func TestInvalidLogFormat(t *testing.T) {
+	_, err := newDaemonLogger("xml", &bytes.Buffer{}, false, false)
+	if err == nil {
+		t.Fatal("Expected an unknown log format to be rejected")
+	}
+}
+
+func TestLogFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "sunbeamd.log")
+	global := &cmdGlobal{flagLogFormat: logFormatText, flagLogFile: path, flagLogVerbose: true}
+
+	l, file, err := global.daemonLogger()
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+
+	defer func() { _ = file.Close() }()
+
+	l.Info("Before rotation")
+
+	// Rotate the file as logrotate does.
+	rotated := path + ".1"
+	err = os.Rename(path, rotated)
+	if err != nil {
+		t.Fatalf("Failed to rotate log file: %v", err)
+	}
+
+	err = file.Reopen()
+	if err != nil {
+		t.Fatalf("Failed to reopen log file: %v", err)
+	}
+
+	l.Info("After rotation")
+
+	for name, message := range map[string]string{rotated: "Before rotation", path: "After rotation"} {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatalf("Failed to read log file: %v", err)
+		}
+
+		if !strings.Contains(string(data), message) {
+			t.Errorf("Log file %q holds %q, expected it to hold %q", name, data, message)
+		}
+	}
+}
+
+func TestLogFileUnwritable(t *testing.T) {
+	dir := t.TempDir()
+	blocker := filepath.Join(dir, "file")
+	err := os.WriteFile(blocker, nil, 0600)
+	if err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	global := &cmdGlobal{flagLogFormat: logFormatText, flagLogFile: filepath.Join(blocker, "sunbeamd.log")}
+
+	_, _, err = global.daemonLogger()
+	if err == nil {
+		t.Fatal("Expected a log file that cannot be created to fail")
+	}
+}
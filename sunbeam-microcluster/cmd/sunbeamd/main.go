@@ -3,8 +3,12 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"math/rand"
+	"net"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/canonical/lxd/shared/logger"
@@ -15,9 +19,18 @@ import (
 
 	"github.com/canonical/snap-openstack/sunbeam-microcluster/api"
 	"github.com/canonical/snap-openstack/sunbeam-microcluster/database"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/sunbeam"
 	"github.com/canonical/snap-openstack/sunbeam-microcluster/version"
 )
 
+// shutdownTimeout bounds how long the daemon waits for MicroCluster to stop
+// once asked to shut down.
+const shutdownTimeout = 30 * time.Second
+
+// manifestGCInterval is how often the dqlite leader deletes manifests beyond
+// the configured retention, unless overridden in the config.
+const manifestGCInterval = 5 * time.Minute
+
 // Debug indicates whether to log debug messages or not.
 var Debug bool
 
@@ -32,6 +45,8 @@ type cmdGlobal struct {
 
 	flagLogDebug   bool
 	flagLogVerbose bool
+	flagLogFormat  string
+	flagLogFile    string
 }
 
 func (c *cmdGlobal) Run(_ *cobra.Command, _ []string) error {
@@ -44,8 +59,19 @@ func (c *cmdGlobal) Run(_ *cobra.Command, _ []string) error {
 type cmdDaemon struct {
 	global *cmdGlobal
 
-	flagStateDir    string
-	flagSocketGroup string
+	flagStateDir           string
+	flagSocketGroup        string
+	flagClientCAFile       string
+	flagClientIdentityFile string
+	flagRequireClientCert  bool
+	flagSecretsKeyFile     string
+	flagPlaintextSecrets   bool
+	flagCheckSchema        bool
+	flagListen             string
+	flagListenTimeouts     listenTimeouts
+	flagDBRetryAttempts    int
+	flagDBRetryDelay       time.Duration
+	flagDatabaseTimeout    time.Duration
 }
 
 func (c *cmdDaemon) Command() *cobra.Command {
@@ -61,6 +87,103 @@ func (c *cmdDaemon) Command() *cobra.Command {
 }
 
 func (c *cmdDaemon) Run(_ *cobra.Command, _ []string) error {
+	// A log file that cannot be opened stops the daemon before it starts.
+	daemonLogger, logFile, err := c.global.daemonLogger()
+	if err != nil {
+		return err
+	}
+
+	if daemonLogger != nil {
+		logger.Log = daemonLogger
+	}
+
+	if logFile != nil {
+		defer func() { _ = logFile.Close() }()
+
+		stopReopen := reopenOnSignal(logFile, syscall.SIGHUP)
+		defer stopReopen()
+	}
+
+	// A state directory or socket group that cannot be used stops the daemon
+	// with a message naming it, rather than failing once MicroCluster starts.
+	err = checkStateDir(c.flagStateDir)
+	if err != nil {
+		return err
+	}
+
+	err = checkSocketGroup(c.flagSocketGroup)
+	if err != nil {
+		return err
+	}
+
+	// Checking the schema only reports the pending updates, the daemon is
+	// not started.
+	if c.flagCheckSchema {
+		m, err := microcluster.App(microcluster.Args{StateDir: c.flagStateDir, SocketGroup: c.flagSocketGroup, Verbose: c.global.flagLogVerbose, Debug: c.global.flagLogDebug})
+		if err != nil {
+			return err
+		}
+
+		return checkSchema(context.Background(), m, os.Stdout)
+	}
+
+	err = sunbeam.SetTransactionRetry(c.flagDBRetryAttempts, c.flagDBRetryDelay)
+	if err != nil {
+		return err
+	}
+
+	err = sunbeam.SetTransactionTimeout(c.flagDatabaseTimeout)
+	if err != nil {
+		return err
+	}
+
+	err = registerMaintenanceTasks()
+	if err != nil {
+		return err
+	}
+
+	err = sunbeam.LoadClientAuth(c.flagClientCAFile, c.flagClientIdentityFile, c.flagRequireClientCert)
+	if err != nil {
+		return err
+	}
+
+	stopReload := reloadClientAuthOnSignal(syscall.SIGHUP)
+	defer stopReload()
+
+	// The additional TCP listener is bound up front so that an address that
+	// cannot be bound stops the daemon. It is served once the daemon state
+	// is available.
+	var listener net.Listener
+	if c.flagListen != "" {
+		err = c.flagListenTimeouts.validate()
+		if err != nil {
+			return err
+		}
+
+		listener, err = listenTCP(c.flagListen)
+		if err != nil {
+			return err
+		}
+
+		defer func() { _ = listener.Close() }()
+	}
+
+	// A missing or unreadable key file stops the daemon rather than leaving
+	// secrets in plaintext, unless plaintext secrets were explicitly allowed.
+	err = checkSecretsKeyFile(c.flagSecretsKeyFile, c.flagPlaintextSecrets)
+	if err != nil {
+		return err
+	}
+
+	err = database.LoadSecretsKey(c.flagSecretsKeyFile)
+	if err != nil {
+		return err
+	}
+
+	if c.flagSecretsKeyFile == "" {
+		logger.Warn("Secrets are stored unencrypted as allowed with --allow-plaintext-secrets")
+	}
+
 	m, err := microcluster.App(microcluster.Args{StateDir: c.flagStateDir, SocketGroup: c.flagSocketGroup, Verbose: c.global.flagLogVerbose, Debug: c.global.flagLogDebug})
 	if err != nil {
 		return err
@@ -76,16 +199,37 @@ func (c *cmdDaemon) Run(_ *cobra.Command, _ []string) error {
 		},
 
 		// PostBootstrap is run after the daemon is initialized and bootstrapped.
-		PostBootstrap: func(_ *state.State, _ map[string]string) error {
+		// The config keys that are not set yet get their default value.
+		PostBootstrap: func(s *state.State, _ map[string]string) error {
 			logger.Info("This is a hook that runs after the daemon is initialized and bootstrapped")
 
-			return nil
+			return sunbeam.SeedDefaultConfig(s)
 		},
 
 		// OnStart is run after the daemon is started.
-		OnStart: func(_ *state.State) error {
+		// MicroCluster sets up its own logger on start, the daemon logger
+		// replaces it from here on. Node statuses recorded before the
+		// restart are reset to unknown until the next heartbeat round, if
+		// the member already has a database. The API is also served on the
+		// additional TCP listener, if any.
+		OnStart: func(s *state.State) error {
+			if daemonLogger != nil {
+				logger.Log = daemonLogger
+			}
+
 			logger.Info("This is a hook that runs after the daemon first starts")
 
+			if s.Database.IsOpen() {
+				err := sunbeam.ResetNodeStatus(s)
+				if err != nil {
+					logger.Warn("Failed to reset node statuses", logger.Ctx{"err": err})
+				}
+			}
+
+			if listener != nil {
+				serveTCP(s, listener, c.flagListenTimeouts)
+			}
+
 			return nil
 		},
 
@@ -97,42 +241,182 @@ func (c *cmdDaemon) Run(_ *cobra.Command, _ []string) error {
 		},
 
 		// PreJoin is run after the daemon is initialized and joins a cluster.
-		PreJoin: func(_ *state.State, _ map[string]string) error {
+		// Joining is rejected while the cluster is frozen or when the schema
+		// extensions of this member differ from the cluster's. The joining
+		// member's system_id is verified against the allowlist, it is expected
+		// under the "system_id" key of the join config. As the member reports
+		// it itself, nodes registering with a join token are verified again by
+		// the member consuming the token.
+		PreJoin: func(s *state.State, initConfig map[string]string) error {
 			logger.Info("This is a hook that runs after the daemon is initialized and joins an existing cluster, before OnNewMember runs on all peers")
 
-			return nil
+			err := sunbeam.VerifyNotFrozen(s, "join", s.Name())
+			if err != nil {
+				return err
+			}
+
+			err = sunbeam.VerifySchemaCompatible(s)
+			if err != nil {
+				return err
+			}
+
+			return sunbeam.VerifySystemID(s, initConfig["system_id"])
 		},
 
 		// PostRemove is run after the daemon is removed from a cluster.
+		// The node records of the departed member are purged.
 		PostRemove: func(s *state.State, _ bool) error {
 			logger.Infof("This is a hook that is run on peer %q after a cluster member is removed", s.Name())
 
-			return nil
+			sunbeam.Events.Publish(sunbeam.Event{Entity: "cluster", Key: s.Name(), Action: "member-removed", Timestamp: time.Now().UTC()})
+
+			return sunbeam.PurgeRemovedMembers(s)
 		},
 
 		// PreRemove is run before the daemon is removed from the cluster.
+		// Removal is rejected while the cluster is frozen. The nodes
+		// recorded through the member are handed over to another member,
+		// as they would otherwise prevent its removal.
 		PreRemove: func(s *state.State, _ bool) error {
 			logger.Infof("This is a hook that is run on peer %q just before it is removed", s.Name())
 
-			return nil
+			err := sunbeam.VerifyNotFrozen(s, "remove", s.Name())
+			if err != nil {
+				return err
+			}
+
+			return sunbeam.HandOverMemberNodes(s, s.Name())
 		},
 
 		// OnHeartbeat is run after a successful heartbeat round.
-		OnHeartbeat: func(_ *state.State) error {
+		// Node statuses are refreshed from the member heartbeats, and
+		// scheduled maintenance windows and config changes that are due are
+		// applied here, as the hook only runs on the dqlite leader. The
+		// registered maintenance tasks that are due are run. The outcome is
+		// counted in the heartbeat metrics.
+		OnHeartbeat: func(s *state.State) error {
 			logger.Info("This is a hook that is run on the dqlite leader after a successful heartbeat")
 
-			return nil
+			err := onHeartbeat(s)
+			sunbeam.RecordHeartbeat(err)
+
+			return err
 		},
 
 		// OnNewMember is run after a new member has joined.
+		// A node is recorded for the new member if it has none yet.
 		OnNewMember: func(s *state.State) error {
 			logger.Infof("This is a hook that is run on peer %q when a new cluster member has joined", s.Name())
 
-			return nil
+			sunbeam.Events.Publish(sunbeam.Event{Entity: "cluster", Key: s.Name(), Action: "member-joined", Timestamp: time.Now().UTC()})
+
+			return sunbeam.RegisterNewMembers(s)
 		},
 	}
 
-	return m.Start(context.Background(), api.Endpoints, database.SchemaExtensions, h)
+	return runUntilSignalled(func(ctx context.Context) error {
+		return m.Start(ctx, api.Endpoints, database.SchemaExtensions, h)
+	}, syscall.SIGTERM, syscall.SIGINT)
+}
+
+// onHeartbeat runs the heartbeat work of the dqlite leader.
+func onHeartbeat(s *state.State) error {
+	err := sunbeam.UpdateNodeStatus(s)
+	if err != nil {
+		return err
+	}
+
+	err = sunbeam.StartDueMaintenance(s)
+	if err != nil {
+		return err
+	}
+
+	err = sunbeam.PromoteScheduledConfig(s)
+	if err != nil {
+		return err
+	}
+
+	return sunbeam.RunMaintenanceTasks(s)
+}
+
+// registerMaintenanceTasks registers the work the dqlite leader runs from its
+// heartbeat at an interval rather than on every heartbeat.
+func registerMaintenanceTasks() error {
+	return sunbeam.RegisterMaintenanceTask(sunbeam.MaintenanceTask{
+		Name:     "manifest-gc",
+		Interval: manifestGCInterval,
+		Run:      sunbeam.GarbageCollectManifests,
+	})
+}
+
+// runUntilSignalled runs start until it returns or one of the given signals
+// is received. On a signal the context passed to start is cancelled and start
+// is given shutdownTimeout to return, so in-flight writes can complete.
+func runUntilSignalled(start func(ctx context.Context) error, signals ...os.Signal) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, signals...)
+	defer signal.Stop(sigCh)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- start(ctx)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case sig := <-sigCh:
+		logger.Info("Shutting down the daemon", logger.Ctx{"signal": sig.String()})
+		cancel()
+	}
+
+	select {
+	case err := <-errCh:
+		if err != nil {
+			return err
+		}
+
+		logger.Info("Daemon shut down cleanly")
+
+		return nil
+	case <-time.After(shutdownTimeout):
+		return fmt.Errorf("Daemon did not shut down within %s", shutdownTimeout)
+	}
+}
+
+// reloadClientAuthOnSignal reloads the client CA bundle and identities
+// whenever one of the given signals is received, until the returned function
+// is called.
+func reloadClientAuthOnSignal(signals ...os.Signal) func() {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, signals...)
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-sigCh:
+				err := sunbeam.ReloadClientAuth()
+				if err != nil {
+					logger.Error("Failed to reload client CA bundle", logger.Ctx{"err": err})
+					continue
+				}
+
+				logger.Info("Reloaded client CA bundle")
+
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(sigCh)
+		close(done)
+	}
 }
 
 func init() {
@@ -149,9 +433,24 @@ func main() {
 	app.PersistentFlags().BoolVar(&daemonCmd.global.flagVersion, "version", false, "Print version number")
 	app.PersistentFlags().BoolVarP(&daemonCmd.global.flagLogDebug, "debug", "d", false, "Show all debug messages")
 	app.PersistentFlags().BoolVarP(&daemonCmd.global.flagLogVerbose, "verbose", "v", false, "Show all information messages")
+	app.PersistentFlags().StringVar(&daemonCmd.global.flagLogFile, "log-file", "", "File to also write logs to, reopened on SIGHUP")
+	app.PersistentFlags().StringVar(&daemonCmd.global.flagLogFormat, "log-format", logFormatText, "Format of log lines, \"text\" or \"json\"")
 
 	app.PersistentFlags().StringVar(&daemonCmd.flagStateDir, "state-dir", "", "Path to store state information"+"``")
 	app.PersistentFlags().StringVar(&daemonCmd.flagSocketGroup, "socket-group", "", "Group to set socket's group ownership to")
+	app.PersistentFlags().StringVar(&daemonCmd.flagClientCAFile, "client-ca-file", "", "PEM bundle of the CAs issuing client certificates that identify API callers")
+	app.PersistentFlags().StringVar(&daemonCmd.flagClientIdentityFile, "client-identities-file", "", "YAML mapping of client certificate subjects to identities")
+	app.PersistentFlags().BoolVar(&daemonCmd.flagRequireClientCert, "require-client-cert", false, "Require a client certificate issued by the client CA to modify config and nodes")
+	app.PersistentFlags().StringVar(&daemonCmd.flagListen, "listen", "", "Address to also serve the API on over TCP, as host:port, clients must present a certificate issued by the client CA")
+	app.PersistentFlags().DurationVar(&daemonCmd.flagListenTimeouts.Read, "listen-read-timeout", defaultListenReadTimeout, "Time a client of the TCP listener may take to send a request, 0 for no limit")
+	app.PersistentFlags().DurationVar(&daemonCmd.flagListenTimeouts.Write, "listen-write-timeout", defaultListenWriteTimeout, "Time a client of the TCP listener may take to read a response, streamed responses excepted, 0 for no limit")
+	app.PersistentFlags().DurationVar(&daemonCmd.flagListenTimeouts.Idle, "listen-idle-timeout", defaultListenIdleTimeout, "Time an idle keep-alive connection to the TCP listener is kept open, 0 for no limit")
+	app.PersistentFlags().BoolVar(&daemonCmd.flagCheckSchema, "check-schema", false, "Print the schema updates that would be applied to the database of the running daemon, then exit")
+	app.PersistentFlags().IntVar(&daemonCmd.flagDBRetryAttempts, "db-retry-attempts", 5, "Number of times a database transaction failing because the database is busy is attempted")
+	app.PersistentFlags().DurationVar(&daemonCmd.flagDBRetryDelay, "db-retry-delay", 50*time.Millisecond, "Delay before retrying a database transaction that failed because the database is busy, doubled on each further retry")
+	app.PersistentFlags().DurationVar(&daemonCmd.flagDatabaseTimeout, "database-timeout", 30*time.Second, "Time after which a database transaction, retries included, is abandoned, 0 for none")
+	app.PersistentFlags().StringVar(&daemonCmd.flagSecretsKeyFile, "secrets-key-file", "", "File holding the key secrets are encrypted with at rest, shared by all cluster members")
+	app.PersistentFlags().BoolVar(&daemonCmd.flagPlaintextSecrets, "allow-plaintext-secrets", false, "Start without --secrets-key-file, storing secrets unencrypted")
 
 	app.SetVersionTemplate("{{.Version}}\n")
 
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestRunUntilSignalled(t *testing.T) {
+	err := runUntilSignalled(func(ctx context.Context) error {
+		err := syscall.Kill(os.Getpid(), syscall.SIGUSR2)
+		if err != nil {
+			return err
+		}
+
+		<-ctx.Done()
+
+		return nil
+	}, syscall.SIGUSR2)
+	if err != nil {
+		t.Fatalf("Expected a clean stop on signal, got %v", err)
+	}
+}
+
+func TestRunUntilSignalledError(t *testing.T) {
+	failed := errors.New("failed to start")
+
+	err := runUntilSignalled(func(ctx context.Context) error {
+		return failed
+	}, syscall.SIGUSR2)
+	if !errors.Is(err, failed) {
+		t.Fatalf("Expected the error of start to be returned, got %v", err)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+)
+
+// checkStateDir makes sure the state directory given with --state-dir
+// exists, creating it if needed, and that the daemon can write to it.
+func checkStateDir(dir string) error {
+	if dir == "" {
+		return fmt.Errorf("No state directory given, set one with --state-dir")
+	}
+
+	err := os.MkdirAll(dir, 0o700)
+	if err != nil {
+		return fmt.Errorf("Failed to create state directory %q given with --state-dir: %w", dir, err)
+	}
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("Failed to access state directory %q given with --state-dir: %w", dir, err)
+	}
+
+	if !info.IsDir() {
+		return fmt.Errorf("State directory %q given with --state-dir is not a directory", dir)
+	}
+
+	probe, err := os.CreateTemp(dir, ".sunbeamd-write-check-*")
+	if err != nil {
+		return fmt.Errorf("State directory %q given with --state-dir is not writable, check its ownership and permissions: %w", dir, err)
+	}
+
+	_ = probe.Close()
+
+	return os.Remove(probe.Name())
+}
+
+// checkSocketGroup makes sure the group given with --socket-group exists, so
+// that the control socket can be given to it.
+func checkSocketGroup(group string) error {
+	if group == "" {
+		return nil
+	}
+
+	_, err := user.LookupGroup(group)
+	if err != nil {
+		return fmt.Errorf("Socket group %q given with --socket-group cannot be resolved, create it or give an existing group: %w", group, err)
+	}
+
+	return nil
+}
+
+// checkSecretsKeyFile makes sure secrets are encrypted at rest, with the key
+// file given with --secrets-key-file, unless storing them in plaintext was
+// explicitly allowed with --allow-plaintext-secrets.
+func checkSecretsKeyFile(keyFile string, allowPlaintext bool) error {
+	if keyFile == "" && !allowPlaintext {
+		return fmt.Errorf("No secrets key file given, set one shared by all cluster members with --secrets-key-file, or store secrets in plaintext with --allow-plaintext-secrets")
+	}
+
+	return nil
+}
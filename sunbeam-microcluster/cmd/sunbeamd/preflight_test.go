@@ -0,0 +1,98 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckStateDir(t *testing.T) {
+	dir := t.TempDir()
+
+	file := filepath.Join(dir, "file")
+	err := os.WriteFile(file, nil, 0o600)
+	if err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	created := filepath.Join(dir, "state", "sunbeamd")
+	err = checkStateDir(created)
+	if err != nil {
+		t.Fatalf("Failed to check a state directory to create: %v", err)
+	}
+
+	info, err := os.Stat(created)
+	if err != nil || !info.IsDir() {
+		t.Fatalf("Expected the missing state directory to be created, got %v", err)
+	}
+
+	tests := []struct {
+		name string
+		dir  string
+	}{
+		{name: "an empty path", dir: ""},
+		{name: "a file", dir: file},
+		{name: "a path below a file", dir: filepath.Join(file, "state")},
+	}
+
+	for _, test := range tests {
+		err := checkStateDir(test.dir)
+		if err == nil || !strings.Contains(err.Error(), "--state-dir") {
+			t.Errorf("Expected %s to be rejected as state directory naming the flag, got %v", test.name, err)
+		}
+	}
+}
+
+func TestCheckStateDirUnwritable(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("Permissions do not restrict root")
+	}
+
+	dir := filepath.Join(t.TempDir(), "state")
+	err := os.Mkdir(dir, 0o500)
+	if err != nil {
+		t.Fatalf("Failed to create state directory: %v", err)
+	}
+
+	err = checkStateDir(dir)
+	if err == nil || !strings.Contains(err.Error(), "not writable") || !strings.Contains(err.Error(), dir) {
+		t.Fatalf("Expected an unwritable state directory to be rejected naming it, got %v", err)
+	}
+}
+
+func TestCheckSocketGroup(t *testing.T) {
+	for _, group := range []string{"", "root"} {
+		err := checkSocketGroup(group)
+		if err != nil {
+			t.Errorf("Failed to check socket group %q: %v", group, err)
+		}
+	}
+
+	err := checkSocketGroup("sunbeam-no-such-group")
+	if err == nil || !strings.Contains(err.Error(), `"sunbeam-no-such-group"`) {
+		t.Fatalf("Expected a nonexistent socket group to be rejected naming it, got %v", err)
+	}
+}
+
+func TestCheckSecretsKeyFile(t *testing.T) {
+	tests := []struct {
+		keyFile        string
+		allowPlaintext bool
+		valid          bool
+	}{
+		{keyFile: "/etc/sunbeam/secrets.key", allowPlaintext: false, valid: true},
+		{keyFile: "/etc/sunbeam/secrets.key", allowPlaintext: true, valid: true},
+		{keyFile: "", allowPlaintext: true, valid: true},
+		{keyFile: "", allowPlaintext: false, valid: false},
+	}
+
+	for _, test := range tests {
+		err := checkSecretsKeyFile(test.keyFile, test.allowPlaintext)
+		if test.valid && err != nil {
+			t.Errorf("Failed to check secrets key file %q with plaintext allowed %v: %v", test.keyFile, test.allowPlaintext, err)
+		} else if !test.valid && (err == nil || !strings.Contains(err.Error(), "--allow-plaintext-secrets")) {
+			t.Errorf("Expected no secrets key file to be rejected naming the opt-out, got %v", err)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/canonical/lxd/shared/api"
+	"github.com/canonical/microcluster/microcluster"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/api/types"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/database"
+)
+
+// checkSchema writes the schema updates this daemon would apply to the
+// database of the daemon running from the same state directory, without
+// applying them. The schema version is read from the running daemon, so the
+// database is not opened a second time.
+func checkSchema(ctx context.Context, m *microcluster.MicroCluster, out io.Writer) error {
+	c, err := m.LocalClient()
+	if err != nil {
+		return err
+	}
+
+	var schema types.Schema
+	err = c.Query(ctx, "GET", api.NewURL().Path("schema"), nil, &schema)
+	if err != nil {
+		return fmt.Errorf("Failed to get the schema version of the running daemon: %w", err)
+	}
+
+	return writePendingSchema(out, schema.Version)
+}
+
+// writePendingSchema writes the schema updates that would be applied to a
+// database at the given schema version.
+func writePendingSchema(out io.Writer, version int) error {
+	if version > len(database.SchemaExtensions) {
+		return fmt.Errorf("Database is at schema version %d, newer than the %d schema updates of this daemon", version, len(database.SchemaExtensions))
+	}
+
+	if version == len(database.SchemaExtensions) {
+		_, err := fmt.Fprintf(out, "Schema is up to date at version %d\n", version)
+		return err
+	}
+
+	_, err := fmt.Fprintf(out, "Schema is at version %d, these updates would be applied:\n", version)
+	if err != nil {
+		return err
+	}
+
+	for i := version; i < len(database.SchemaExtensions); i++ {
+		_, err = fmt.Fprintf(out, "%d\t%s\n", i+1, database.SchemaExtensionName(i))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/database"
+)
+
+func TestWritePendingSchema(t *testing.T) {
+	latest := len(database.SchemaExtensions)
+
+	tests := []struct {
+		version int
+		pending []int
+	}{
+		{version: latest, pending: nil},
+		{version: latest - 1, pending: []int{latest}},
+		{version: latest - 3, pending: []int{latest - 2, latest - 1, latest}},
+	}
+
+	for _, test := range tests {
+		var out bytes.Buffer
+
+		err := writePendingSchema(&out, test.version)
+		if err != nil {
+			t.Fatalf("Failed to write pending schema updates at version %d: %v", test.version, err)
+		}
+
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		if len(lines) != len(test.pending)+1 {
+			t.Fatalf("Wrote %d lines at version %d, expected a heading and %d updates:\n%s", len(lines), test.version, len(test.pending), out.String())
+		}
+
+		for i, version := range test.pending {
+			expected := fmt.Sprintf("%d\t%s", version, database.SchemaExtensionName(version-1))
+			if lines[i+1] != expected {
+				t.Errorf("Pending update %d at version %d is %q, expected %q", i+1, test.version, lines[i+1], expected)
+			}
+		}
+	}
+
+	err := writePendingSchema(&bytes.Buffer{}, latest+1)
+	if err == nil {
+		t.Fatalf("Expected a database newer than the daemon to be reported")
+	}
+}
@@ -0,0 +1,114 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"github.com/canonical/lxd/lxd/db/query"
+	"github.com/canonical/lxd/shared/api"
+	"github.com/canonical/microcluster/cluster"
+)
+
+var allowedSystemIDObjects = cluster.RegisterStmt(`
+SELECT allowed_system_ids.system_id
+  FROM allowed_system_ids
+  ORDER BY allowed_system_ids.system_id
+`)
+
+var allowedSystemIDCreate = cluster.RegisterStmt(`
+INSERT INTO allowed_system_ids (system_id)
+  VALUES (?)
+`)
+
+var allowedSystemIDDelete = cluster.RegisterStmt(`
+DELETE FROM allowed_system_ids WHERE system_id = ?
+`)
+
+// GetAllowedSystemIDs returns the system_ids approved to join the cluster.
+func GetAllowedSystemIDs(ctx context.Context, tx *sql.Tx) ([]string, error) {
+	stmt, err := cluster.Stmt(tx, allowedSystemIDObjects)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get \"allowedSystemIDObjects\" prepared statement: %w", err)
+	}
+
+	systemIDs := make([]string, 0)
+
+	dest := func(scan func(dest ...any) error) error {
+		var systemID string
+		err := scan(&systemID)
+		if err != nil {
+			return err
+		}
+
+		systemIDs = append(systemIDs, systemID)
+
+		return nil
+	}
+
+	err = query.SelectObjects(ctx, stmt, dest)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to fetch from \"allowed_system_ids\" table: %w", err)
+	}
+
+	return systemIDs, nil
+}
+
+// AllowedSystemIDExists checks if the given system_id is approved to join the cluster.
+func AllowedSystemIDExists(ctx context.Context, tx *sql.Tx, systemID string) (bool, error) {
+	count, err := query.Count(ctx, tx, "allowed_system_ids", "system_id = ?", systemID)
+	if err != nil {
+		return false, fmt.Errorf("Failed to count \"allowed_system_ids\" entries: %w", err)
+	}
+
+	return count > 0, nil
+}
+
+// CreateAllowedSystemID approves a system_id to join the cluster.
+func CreateAllowedSystemID(ctx context.Context, tx *sql.Tx, systemID string) error {
+	exists, err := AllowedSystemIDExists(ctx, tx, systemID)
+	if err != nil {
+		return fmt.Errorf("Failed to check for duplicates: %w", err)
+	}
+
+	if exists {
+		return api.StatusErrorf(http.StatusConflict, "This \"allowed_system_ids\" entry already exists")
+	}
+
+	stmt, err := cluster.Stmt(tx, allowedSystemIDCreate)
+	if err != nil {
+		return fmt.Errorf("Failed to get \"allowedSystemIDCreate\" prepared statement: %w", err)
+	}
+
+	_, err = stmt.Exec(systemID)
+	if err != nil {
+		return fmt.Errorf("Failed to create \"allowed_system_ids\" entry: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteAllowedSystemID revokes the approval of a system_id.
+func DeleteAllowedSystemID(_ context.Context, tx *sql.Tx, systemID string) error {
+	stmt, err := cluster.Stmt(tx, allowedSystemIDDelete)
+	if err != nil {
+		return fmt.Errorf("Failed to get \"allowedSystemIDDelete\" prepared statement: %w", err)
+	}
+
+	result, err := stmt.Exec(systemID)
+	if err != nil {
+		return fmt.Errorf("Delete \"allowed_system_ids\": %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("Fetch affected rows: %w", err)
+	}
+
+	if n == 0 {
+		return api.StatusErrorf(http.StatusNotFound, "AllowedSystemID not found")
+	}
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/canonical/lxd/lxd/db/query"
+	"github.com/canonical/microcluster/cluster"
+)
+
+// AuditEntry records an action an actor performed against a target.
+type AuditEntry struct {
+	ID        int64
+	Timestamp time.Time
+	Actor     string
+	Action    string
+	Target    string
+}
+
+// AuditEntryFilter selects audit entries, unset fields match everything.
+type AuditEntryFilter struct {
+	Target *string
+	Action *string
+	Actor  *string
+	Since  *time.Time
+	Until  *time.Time
+	// After only selects entries with a greater ID, used for pagination.
+	After int64
+	Limit int
+}
+
+var auditEntryCreate = cluster.RegisterStmt(`
+INSERT INTO audit_log (timestamp, actor, action, target)
+  VALUES (?, ?, ?, ?)
+`)
+
+// CreateAuditEntry records an action in the audit log.
+func CreateAuditEntry(_ context.Context, tx *sql.Tx, actor string, action string, target string) (int64, error) {
+	stmt, err := cluster.Stmt(tx, auditEntryCreate)
+	if err != nil {
+		return -1, fmt.Errorf("Failed to get \"auditEntryCreate\" prepared statement: %w", err)
+	}
+
+	result, err := stmt.Exec(time.Now().UTC(), actor, action, target)
+	if err != nil {
+		return -1, fmt.Errorf("Failed to create \"audit_log\" entry: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return -1, fmt.Errorf("Failed to fetch \"audit_log\" entry ID: %w", err)
+	}
+
+	return id, nil
+}
+
+// GetAuditEntries returns the audit entries matching the filter, oldest first.
+// Filtering on target uses the (target, timestamp) index.
+func GetAuditEntries(ctx context.Context, tx *sql.Tx, filter AuditEntryFilter) ([]AuditEntry, error) {
+	where := []string{"audit_log.id > ?"}
+	args := []any{filter.After}
+
+	if filter.Target != nil {
+		where = append(where, "audit_log.target = ?")
+		args = append(args, *filter.Target)
+	}
+
+	if filter.Action != nil {
+		where = append(where, "audit_log.action = ?")
+		args = append(args, *filter.Action)
+	}
+
+	if filter.Actor != nil {
+		where = append(where, "audit_log.actor = ?")
+		args = append(args, *filter.Actor)
+	}
+
+	if filter.Since != nil {
+		where = append(where, "audit_log.timestamp >= ?")
+		args = append(args, filter.Since.UTC())
+	}
+
+	if filter.Until != nil {
+		where = append(where, "audit_log.timestamp <= ?")
+		args = append(args, filter.Until.UTC())
+	}
+
+	sql := fmt.Sprintf(`
+SELECT audit_log.id, audit_log.timestamp, audit_log.actor, audit_log.action, audit_log.target
+  FROM audit_log
+  WHERE %s
+  ORDER BY audit_log.id
+  LIMIT ?
+`, strings.Join(where, " AND "))
+	args = append(args, filter.Limit)
+
+	objects := make([]AuditEntry, 0)
+	dest := func(scan func(dest ...any) error) error {
+		e := AuditEntry{}
+		err := scan(&e.ID, &e.Timestamp, &e.Actor, &e.Action, &e.Target)
+		if err != nil {
+			return err
+		}
+
+		objects = append(objects, e)
+
+		return nil
+	}
+
+	err := query.Scan(ctx, tx, sql, dest, args...)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to fetch from \"audit_log\" table: %w", err)
+	}
+
+	return objects, nil
+}
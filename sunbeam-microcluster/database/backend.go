@@ -0,0 +1,38 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Backend gives access to the database of a cluster member. Daemons use
+// the dqlite database MicroCluster manages, other backends are given to a
+// state through its context with WithBackend.
+type Backend interface {
+	// Transaction runs f in a transaction of the database.
+	Transaction(ctx context.Context, f func(context.Context, *sql.Tx) error) error
+
+	// LeaderAddress returns the address of the dqlite leader.
+	LeaderAddress(ctx context.Context) (string, error)
+
+	// Open opens a database handle of its own on the database, for
+	// statements that cannot run within a transaction. The caller closes
+	// the handle.
+	Open(ctx context.Context) (*sql.DB, error)
+}
+
+// backendKey is the context key holding the backend given with WithBackend.
+type backendKey struct{}
+
+// WithBackend returns a copy of ctx carrying the given backend, used in
+// place of the dqlite database by states with that context.
+func WithBackend(ctx context.Context, backend Backend) context.Context {
+	return context.WithValue(ctx, backendKey{}, backend)
+}
+
+// BackendFromContext returns the backend carried by ctx, if any.
+func BackendFromContext(ctx context.Context) (Backend, bool) {
+	backend, ok := ctx.Value(backendKey{}).(Backend)
+
+	return backend, ok
+}
@@ -0,0 +1,96 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/canonical/lxd/lxd/db/query"
+	"github.com/canonical/microcluster/cluster"
+)
+
+// Actions recorded in the change feed.
+const (
+	ChangeCreate = "create"
+	ChangeUpdate = "update"
+	ChangeDelete = "delete"
+)
+
+// Change is an entry of the change feed. Every mutation is recorded with a
+// monotonically increasing sequence number so that consumers can resume
+// from the last sequence they processed.
+type Change struct {
+	Seq       int64
+	Entity    string
+	Key       string
+	Action    string
+	ChangedAt time.Time
+}
+
+var changeCreate = cluster.RegisterStmt(`
+INSERT INTO changes (entity, key, action, changed_at)
+  VALUES (?, ?, ?, ?)
+`)
+
+var changeObjectsSince = cluster.RegisterStmt(`
+SELECT changes.seq, changes.entity, changes.key, changes.action, changes.changed_at
+  FROM changes
+  WHERE changes.seq > ?
+  ORDER BY changes.seq
+  LIMIT ?
+`)
+
+// CreateChange records a mutation of the given entity in the change feed.
+func CreateChange(_ context.Context, tx *sql.Tx, entity string, key string, action string) (int64, error) {
+	stmt, err := cluster.Stmt(tx, changeCreate)
+	if err != nil {
+		return -1, fmt.Errorf("Failed to get \"changeCreate\" prepared statement: %w", err)
+	}
+
+	result, err := stmt.Exec(entity, key, action, time.Now().UTC())
+	if err != nil {
+		return -1, fmt.Errorf("Failed to create \"changes\" entry: %w", err)
+	}
+
+	seq, err := result.LastInsertId()
+	if err != nil {
+		return -1, fmt.Errorf("Failed to fetch \"changes\" entry sequence: %w", err)
+	}
+
+	return seq, nil
+}
+
+// GetChangesSince returns at most limit changes recorded after the given sequence.
+func GetChangesSince(ctx context.Context, tx *sql.Tx, since int64, limit int) ([]Change, error) {
+	stmt, err := cluster.Stmt(tx, changeObjectsSince)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get \"changeObjectsSince\" prepared statement: %w", err)
+	}
+
+	return getChanges(ctx, stmt, since, limit)
+}
+
+// getChanges can be used to run handwritten sql.Stmts to return a slice of changes.
+func getChanges(ctx context.Context, stmt *sql.Stmt, args ...any) ([]Change, error) {
+	objects := make([]Change, 0)
+
+	dest := func(scan func(dest ...any) error) error {
+		c := Change{}
+		err := scan(&c.Seq, &c.Entity, &c.Key, &c.Action, &c.ChangedAt)
+		if err != nil {
+			return err
+		}
+
+		objects = append(objects, c)
+
+		return nil
+	}
+
+	err := query.SelectObjects(ctx, stmt, dest, args...)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to fetch from \"changes\" table: %w", err)
+	}
+
+	return objects, nil
+}
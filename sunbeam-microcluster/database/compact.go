@@ -0,0 +1,92 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/canonical/microcluster/cluster"
+)
+
+var auditEntriesDeleteBefore = cluster.RegisterStmt(`
+DELETE FROM audit_log WHERE id IN (
+  SELECT id FROM audit_log WHERE timestamp < ? ORDER BY id LIMIT ?
+)
+`)
+
+var changesDeleteBefore = cluster.RegisterStmt(`
+DELETE FROM changes WHERE seq IN (
+  SELECT seq FROM changes WHERE changed_at < ? AND seq <= ? ORDER BY seq LIMIT ?
+)
+`)
+
+// DeleteAuditEntriesBefore deletes at most limit audit entries recorded
+// before the given time, oldest first, and returns the number deleted.
+func DeleteAuditEntriesBefore(_ context.Context, tx *sql.Tx, before time.Time, limit int) (int64, error) {
+	stmt, err := cluster.Stmt(tx, auditEntriesDeleteBefore)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to get \"auditEntriesDeleteBefore\" prepared statement: %w", err)
+	}
+
+	result, err := stmt.Exec(before.UTC(), limit)
+	if err != nil {
+		return 0, fmt.Errorf("Delete \"audit_log\": %w", err)
+	}
+
+	return result.RowsAffected()
+}
+
+// DeleteChangesBefore deletes at most limit changes recorded before the
+// given time with a sequence up to maxSeq, oldest first, and returns the
+// number deleted.
+func DeleteChangesBefore(_ context.Context, tx *sql.Tx, before time.Time, maxSeq int64, limit int) (int64, error) {
+	stmt, err := cluster.Stmt(tx, changesDeleteBefore)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to get \"changesDeleteBefore\" prepared statement: %w", err)
+	}
+
+	result, err := stmt.Exec(before.UTC(), maxSeq, limit)
+	if err != nil {
+		return 0, fmt.Errorf("Delete \"changes\": %w", err)
+	}
+
+	return result.RowsAffected()
+}
+
+// GetDatabaseSize returns the size of the database in bytes, free pages
+// included.
+func GetDatabaseSize(ctx context.Context, tx *sql.Tx) (int64, error) {
+	var size int64
+	err := tx.QueryRowContext(ctx, "SELECT page_count * page_size FROM pragma_page_count(), pragma_page_size()").Scan(&size)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to get the database size: %w", err)
+	}
+
+	return size, nil
+}
+
+// Vacuum checkpoints the write-ahead log and rebuilds the database to
+// reclaim the pages freed by deleted rows. Neither can run within a
+// transaction, so both run on a connection of the given database handle
+// rather than in a transaction of the cluster database.
+func Vacuum(ctx context.Context, db *sql.DB) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("Failed to get a database connection: %w", err)
+	}
+
+	defer func() { _ = conn.Close() }()
+
+	_, err = conn.ExecContext(ctx, "PRAGMA wal_checkpoint(TRUNCATE)")
+	if err != nil {
+		return fmt.Errorf("Failed to checkpoint database: %w", err)
+	}
+
+	_, err = conn.ExecContext(ctx, "VACUUM")
+	if err != nil {
+		return fmt.Errorf("Failed to vacuum database: %w", err)
+	}
+
+	return nil
+}
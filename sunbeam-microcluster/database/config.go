@@ -4,8 +4,12 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"net/http"
+	"sort"
+	"strings"
 
 	"github.com/canonical/lxd/lxd/db/query"
+	"github.com/canonical/lxd/shared/api"
 )
 
 //go:generate -command mapper lxd-generate db mapper -t config.mapper.go
@@ -39,17 +43,19 @@ type ConfigItemFilter struct {
 	Key *string
 }
 
-// GetConfigItemKeys returns the list of ConfigItem keys from the database, filtered by prefix if provided.
+// GetConfigItemKeys returns the sorted list of ConfigItem keys from the database, filtered by prefix if provided.
 func GetConfigItemKeys(ctx context.Context, tx *sql.Tx, prefix *string) ([]string, error) {
 	stmt := `SELECT config.key FROM config`
 
 	args := make([]any, 0)
 
 	if prefix != nil {
-		stmt += ` WHERE config.key LIKE ?`
-		args = append(args, *prefix+"%")
+		stmt += ` WHERE config.key LIKE ? ESCAPE '\'`
+		args = append(args, likePrefix(*prefix))
 	}
 
+	stmt += ` ORDER BY config.key`
+
 	configs := make([]string, 0)
 
 	dest := func(scan func(dest ...any) error) error {
@@ -71,3 +77,70 @@ func GetConfigItemKeys(ctx context.Context, tx *sql.Tx, prefix *string) ([]strin
 
 	return configs, nil
 }
+
+// GetConfigByPrefix returns the config key/value pairs whose key starts with
+// the given prefix. LIKE ignores case, so keys are checked against the
+// prefix once more.
+func GetConfigByPrefix(ctx context.Context, tx *sql.Tx, prefix string) (map[string]string, error) {
+	stmt := `SELECT config.key, config.value FROM config WHERE config.key LIKE ? ESCAPE '\' ORDER BY config.key`
+
+	config := make(map[string]string)
+	dest := func(scan func(dest ...any) error) error {
+		var key, value string
+		err := scan(&key, &value)
+		if err != nil {
+			return err
+		}
+
+		if strings.HasPrefix(key, prefix) {
+			config[key] = value
+		}
+
+		return nil
+	}
+
+	err := query.Scan(ctx, tx, stmt, dest, likePrefix(prefix))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to fetch from \"config\" table: %w", err)
+	}
+
+	return config, nil
+}
+
+// likePrefix returns a LIKE pattern matching values starting with prefix,
+// escaping the LIKE wildcards with a backslash.
+func likePrefix(prefix string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(prefix) + "%"
+}
+
+// SetConfigBatch creates or updates the given config items and returns the
+// previous value of each key, unset for the keys it created. Callers run it
+// in a single transaction so a failure leaves none of the items written.
+func SetConfigBatch(ctx context.Context, tx *sql.Tx, items map[string]string) (map[string]sql.NullString, error) {
+	keys := make([]string, 0, len(items))
+	for key := range items {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	previous := make(map[string]sql.NullString, len(items))
+	for _, key := range keys {
+		item := ConfigItem{Key: key, Value: items[key]}
+
+		current, err := GetConfigItem(ctx, tx, key)
+		if err == nil {
+			previous[key] = sql.NullString{String: current.Value, Valid: true}
+			err = UpdateConfigItem(ctx, tx, key, item)
+		} else if api.StatusErrorCheck(err, http.StatusNotFound) {
+			previous[key] = sql.NullString{}
+			_, err = CreateConfigItem(ctx, tx, item)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to record config item %q: %w", key, err)
+		}
+	}
+
+	return previous, nil
+}
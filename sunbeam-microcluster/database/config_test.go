@@ -0,0 +1,48 @@
+package database_test
+
+import (
+	"context"
+	"maps"
+	"testing"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/database"
+)
+
+func TestGetConfigByPrefix(t *testing.T) {
+	tx := beginTestTx(t)
+	ctx := context.Background()
+
+	for _, key := range []string{"a.key", "ba.key", "A.key", "a_b.key", "axb.key", "a%c.key", "abc.key"} {
+		_, err := database.CreateConfigItem(ctx, tx, database.ConfigItem{Key: key, Value: "value of " + key})
+		if err != nil {
+			t.Fatalf("Failed to create config item %q: %v", key, err)
+		}
+	}
+
+	tests := []struct {
+		prefix string
+		keys   []string
+	}{
+		{prefix: "a", keys: []string{"a.key", "a_b.key", "axb.key", "a%c.key", "abc.key"}},
+		{prefix: "a_", keys: []string{"a_b.key"}},
+		{prefix: "a%", keys: []string{"a%c.key"}},
+		{prefix: "b", keys: []string{"ba.key"}},
+		{prefix: "c", keys: []string{}},
+	}
+
+	for _, test := range tests {
+		config, err := database.GetConfigByPrefix(ctx, tx, test.prefix)
+		if err != nil {
+			t.Fatalf("Failed to get config by prefix %q: %v", test.prefix, err)
+		}
+
+		expected := make(map[string]string, len(test.keys))
+		for _, key := range test.keys {
+			expected[key] = "value of " + key
+		}
+
+		if !maps.Equal(config, expected) {
+			t.Errorf("Config with prefix %q is %v, expected %v", test.prefix, config, expected)
+		}
+	}
+}
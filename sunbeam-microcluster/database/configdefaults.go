@@ -0,0 +1,31 @@
+package database
+
+// DefaultConfig holds the config keys seeded on bootstrap along with their
+// default values. Keys already set are never overwritten.
+var DefaultConfig = map[string]string{
+	// api.access-log is whether API requests are logged.
+	"api.access-log": "true",
+	// attestation.mode is how join requests are checked against the
+	// system_id allowlist, one of "disabled", "warn" or "enforce".
+	"attestation.mode": "disabled",
+	// config.history-retention-days is the number of days config changes
+	// are kept in the history.
+	"config.history-retention-days": "90",
+	// nodes.history is whether node changes are recorded in the history.
+	"nodes.history": "false",
+	// nodes.history-retention-days is the number of days node changes are
+	// kept in the history.
+	"nodes.history-retention-days": "90",
+	// nodes.offline-threshold is the time, as a Go duration, after which a
+	// node that has not heartbeated is marked offline.
+	"nodes.offline-threshold": "3m",
+	// nodes.soft-delete is whether deleted nodes are kept as tombstones
+	// rather than removed.
+	"nodes.soft-delete": "false",
+	// nodes.tombstone-retention-days is the number of days soft deleted
+	// nodes are kept.
+	"nodes.tombstone-retention-days": "90",
+	// roles.minimum maps roles to the number of nodes that must keep holding
+	// them for a node removal to be considered safe.
+	"roles.minimum": "{}",
+}
@@ -0,0 +1,14 @@
+package database
+
+import (
+	"testing"
+)
+
+func TestDefaultConfigValid(t *testing.T) {
+	for key, value := range DefaultConfig {
+		err := ValidateConfigValue(key, value)
+		if err != nil {
+			t.Errorf("Default of config key %q is invalid: %v", key, err)
+		}
+	}
+}
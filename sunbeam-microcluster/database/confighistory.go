@@ -0,0 +1,104 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/canonical/lxd/lxd/db/query"
+	"github.com/canonical/microcluster/cluster"
+)
+
+// ConfigHistoryEntry records a change of the value of a config key. The old
+// value is unset when the key was created, the new value when it was
+// deleted.
+type ConfigHistoryEntry struct {
+	ID        int64
+	Key       string
+	OldValue  sql.NullString
+	NewValue  sql.NullString
+	ChangedAt time.Time
+}
+
+var configHistoryCreate = cluster.RegisterStmt(`
+INSERT INTO config_history (key, old_value, new_value, changed_at)
+  VALUES (?, ?, ?, ?)
+`)
+
+var configHistoryObjectsByKey = cluster.RegisterStmt(`
+SELECT config_history.id, config_history.key, config_history.old_value, config_history.new_value, config_history.changed_at
+  FROM config_history
+  WHERE config_history.key = ?
+  ORDER BY config_history.id
+`)
+
+var configHistoryDeleteBefore = cluster.RegisterStmt(`
+DELETE FROM config_history WHERE id IN (
+  SELECT id FROM config_history WHERE changed_at < ? ORDER BY id LIMIT ?
+)
+`)
+
+// CreateConfigHistoryEntry records a change of the value of a config key.
+func CreateConfigHistoryEntry(_ context.Context, tx *sql.Tx, key string, oldValue sql.NullString, newValue sql.NullString) (int64, error) {
+	stmt, err := cluster.Stmt(tx, configHistoryCreate)
+	if err != nil {
+		return -1, fmt.Errorf("Failed to get \"configHistoryCreate\" prepared statement: %w", err)
+	}
+
+	result, err := stmt.Exec(key, oldValue, newValue, time.Now().UTC())
+	if err != nil {
+		return -1, fmt.Errorf("Failed to create \"config_history\" entry: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return -1, fmt.Errorf("Failed to fetch \"config_history\" entry ID: %w", err)
+	}
+
+	return id, nil
+}
+
+// GetConfigHistory returns the changes of a config key, oldest first.
+func GetConfigHistory(ctx context.Context, tx *sql.Tx, key string) ([]ConfigHistoryEntry, error) {
+	stmt, err := cluster.Stmt(tx, configHistoryObjectsByKey)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get \"configHistoryObjectsByKey\" prepared statement: %w", err)
+	}
+
+	entries := make([]ConfigHistoryEntry, 0)
+	dest := func(scan func(dest ...any) error) error {
+		e := ConfigHistoryEntry{}
+		err := scan(&e.ID, &e.Key, &e.OldValue, &e.NewValue, &e.ChangedAt)
+		if err != nil {
+			return err
+		}
+
+		entries = append(entries, e)
+
+		return nil
+	}
+
+	err = query.SelectObjects(ctx, stmt, dest, key)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to fetch from \"config_history\" table: %w", err)
+	}
+
+	return entries, nil
+}
+
+// DeleteConfigHistoryBefore deletes at most limit config changes recorded
+// before the given time, oldest first, and returns the number deleted.
+func DeleteConfigHistoryBefore(_ context.Context, tx *sql.Tx, before time.Time, limit int) (int64, error) {
+	stmt, err := cluster.Stmt(tx, configHistoryDeleteBefore)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to get \"configHistoryDeleteBefore\" prepared statement: %w", err)
+	}
+
+	result, err := stmt.Exec(before.UTC(), limit)
+	if err != nil {
+		return 0, fmt.Errorf("Delete \"config_history\": %w", err)
+	}
+
+	return result.RowsAffected()
+}
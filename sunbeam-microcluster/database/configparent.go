@@ -0,0 +1,96 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"github.com/canonical/lxd/lxd/db/query"
+	"github.com/canonical/lxd/shared/api"
+	"github.com/canonical/microcluster/cluster"
+)
+
+var configParentObjects = cluster.RegisterStmt(`
+SELECT config_parents.key, config_parents.parent
+  FROM config_parents
+  ORDER BY config_parents.key
+`)
+
+var configParentUpsert = cluster.RegisterStmt(`
+INSERT INTO config_parents (key, parent)
+  VALUES (?, ?)
+  ON CONFLICT(key) DO UPDATE SET parent = excluded.parent
+`)
+
+var configParentDelete = cluster.RegisterStmt(`
+DELETE FROM config_parents WHERE key = ?
+`)
+
+// GetConfigParents returns the declared parent of each config key that has one.
+func GetConfigParents(ctx context.Context, tx *sql.Tx) (map[string]string, error) {
+	stmt, err := cluster.Stmt(tx, configParentObjects)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get \"configParentObjects\" prepared statement: %w", err)
+	}
+
+	parents := make(map[string]string)
+
+	dest := func(scan func(dest ...any) error) error {
+		var key, parent string
+		err := scan(&key, &parent)
+		if err != nil {
+			return err
+		}
+
+		parents[key] = parent
+
+		return nil
+	}
+
+	err = query.SelectObjects(ctx, stmt, dest)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to fetch from \"config_parents\" table: %w", err)
+	}
+
+	return parents, nil
+}
+
+// SetConfigParent declares the parent a config key inherits its value from.
+func SetConfigParent(_ context.Context, tx *sql.Tx, key string, parent string) error {
+	stmt, err := cluster.Stmt(tx, configParentUpsert)
+	if err != nil {
+		return fmt.Errorf("Failed to get \"configParentUpsert\" prepared statement: %w", err)
+	}
+
+	_, err = stmt.Exec(key, parent)
+	if err != nil {
+		return fmt.Errorf("Failed to record \"config_parents\" entry: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteConfigParent removes the declared parent of a config key.
+func DeleteConfigParent(_ context.Context, tx *sql.Tx, key string) error {
+	stmt, err := cluster.Stmt(tx, configParentDelete)
+	if err != nil {
+		return fmt.Errorf("Failed to get \"configParentDelete\" prepared statement: %w", err)
+	}
+
+	result, err := stmt.Exec(key)
+	if err != nil {
+		return fmt.Errorf("Delete \"config_parents\": %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("Fetch affected rows: %w", err)
+	}
+
+	if n == 0 {
+		return api.StatusErrorf(http.StatusNotFound, "ConfigParent not found")
+	}
+
+	return nil
+}
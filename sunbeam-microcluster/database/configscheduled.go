@@ -0,0 +1,159 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/canonical/lxd/lxd/db/query"
+	"github.com/canonical/microcluster/cluster"
+)
+
+// ScheduledConfigItem is a config value that takes effect at a future time.
+type ScheduledConfigItem struct {
+	ID          int64
+	Key         string
+	Value       string
+	EffectiveAt time.Time
+}
+
+var scheduledConfigItemCreate = cluster.RegisterStmt(`
+INSERT INTO config_scheduled (key, value, effective_at)
+  VALUES (?, ?, ?)
+`)
+
+var scheduledConfigItemObjects = cluster.RegisterStmt(`
+SELECT config_scheduled.id, config_scheduled.key, config_scheduled.value, config_scheduled.effective_at
+  FROM config_scheduled
+  ORDER BY config_scheduled.effective_at, config_scheduled.id
+`)
+
+var scheduledConfigItemObjectsDue = cluster.RegisterStmt(`
+SELECT config_scheduled.id, config_scheduled.key, config_scheduled.value, config_scheduled.effective_at
+  FROM config_scheduled
+  WHERE config_scheduled.effective_at <= ?
+  ORDER BY config_scheduled.effective_at, config_scheduled.id
+`)
+
+var scheduledConfigItemObjectsDueByKey = cluster.RegisterStmt(`
+SELECT config_scheduled.id, config_scheduled.key, config_scheduled.value, config_scheduled.effective_at
+  FROM config_scheduled
+  WHERE config_scheduled.key = ? AND config_scheduled.effective_at <= ?
+  ORDER BY config_scheduled.effective_at, config_scheduled.id
+`)
+
+var scheduledConfigItemDeleteByID = cluster.RegisterStmt(`
+DELETE FROM config_scheduled WHERE id = ?
+`)
+
+var scheduledConfigItemDeleteDueByKey = cluster.RegisterStmt(`
+DELETE FROM config_scheduled WHERE key = ? AND effective_at <= ?
+`)
+
+// CreateScheduledConfigItem schedules a config value to take effect at the given time.
+func CreateScheduledConfigItem(_ context.Context, tx *sql.Tx, key string, value string, effectiveAt time.Time) (int64, error) {
+	stmt, err := cluster.Stmt(tx, scheduledConfigItemCreate)
+	if err != nil {
+		return -1, fmt.Errorf("Failed to get \"scheduledConfigItemCreate\" prepared statement: %w", err)
+	}
+
+	result, err := stmt.Exec(key, value, effectiveAt.UTC())
+	if err != nil {
+		return -1, fmt.Errorf("Failed to create \"config_scheduled\" entry: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return -1, fmt.Errorf("Failed to fetch \"config_scheduled\" entry ID: %w", err)
+	}
+
+	return id, nil
+}
+
+// GetScheduledConfigItems returns all scheduled config values, oldest first.
+func GetScheduledConfigItems(ctx context.Context, tx *sql.Tx) ([]ScheduledConfigItem, error) {
+	stmt, err := cluster.Stmt(tx, scheduledConfigItemObjects)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get \"scheduledConfigItemObjects\" prepared statement: %w", err)
+	}
+
+	return getScheduledConfigItems(ctx, stmt)
+}
+
+// GetDueScheduledConfigItems returns the scheduled config values taking
+// effect at or before the given time, oldest first.
+func GetDueScheduledConfigItems(ctx context.Context, tx *sql.Tx, now time.Time) ([]ScheduledConfigItem, error) {
+	stmt, err := cluster.Stmt(tx, scheduledConfigItemObjectsDue)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get \"scheduledConfigItemObjectsDue\" prepared statement: %w", err)
+	}
+
+	return getScheduledConfigItems(ctx, stmt, now.UTC())
+}
+
+// GetDueScheduledConfigItemsByKey returns the scheduled values of a config
+// key taking effect at or before the given time, oldest first.
+func GetDueScheduledConfigItemsByKey(ctx context.Context, tx *sql.Tx, key string, now time.Time) ([]ScheduledConfigItem, error) {
+	stmt, err := cluster.Stmt(tx, scheduledConfigItemObjectsDueByKey)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get \"scheduledConfigItemObjectsDueByKey\" prepared statement: %w", err)
+	}
+
+	return getScheduledConfigItems(ctx, stmt, key, now.UTC())
+}
+
+// DeleteScheduledConfigItem removes a scheduled config value.
+func DeleteScheduledConfigItem(_ context.Context, tx *sql.Tx, id int64) error {
+	stmt, err := cluster.Stmt(tx, scheduledConfigItemDeleteByID)
+	if err != nil {
+		return fmt.Errorf("Failed to get \"scheduledConfigItemDeleteByID\" prepared statement: %w", err)
+	}
+
+	_, err = stmt.Exec(id)
+	if err != nil {
+		return fmt.Errorf("Delete \"config_scheduled\": %w", err)
+	}
+
+	return nil
+}
+
+// DeleteDueScheduledConfigItemsByKey removes the scheduled values of a
+// config key taking effect at or before the given time.
+func DeleteDueScheduledConfigItemsByKey(_ context.Context, tx *sql.Tx, key string, now time.Time) error {
+	stmt, err := cluster.Stmt(tx, scheduledConfigItemDeleteDueByKey)
+	if err != nil {
+		return fmt.Errorf("Failed to get \"scheduledConfigItemDeleteDueByKey\" prepared statement: %w", err)
+	}
+
+	_, err = stmt.Exec(key, now.UTC())
+	if err != nil {
+		return fmt.Errorf("Delete \"config_scheduled\": %w", err)
+	}
+
+	return nil
+}
+
+// getScheduledConfigItems runs the given statement and returns the resulting scheduled config values.
+func getScheduledConfigItems(ctx context.Context, stmt *sql.Stmt, args ...any) ([]ScheduledConfigItem, error) {
+	objects := make([]ScheduledConfigItem, 0)
+
+	dest := func(scan func(dest ...any) error) error {
+		c := ScheduledConfigItem{}
+		err := scan(&c.ID, &c.Key, &c.Value, &c.EffectiveAt)
+		if err != nil {
+			return err
+		}
+
+		objects = append(objects, c)
+
+		return nil
+	}
+
+	err := query.SelectObjects(ctx, stmt, dest, args...)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to fetch from \"config_scheduled\" table: %w", err)
+	}
+
+	return objects, nil
+}
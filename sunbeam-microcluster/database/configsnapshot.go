@@ -0,0 +1,148 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/canonical/lxd/lxd/db/query"
+	"github.com/canonical/lxd/shared/api"
+	"github.com/canonical/microcluster/cluster"
+)
+
+// ConfigSnapshot is a named copy of the config table taken at a point in
+// time. Config holds the key/value pairs as a JSON object.
+type ConfigSnapshot struct {
+	ID        int64
+	Name      string
+	Config    string
+	CreatedAt time.Time
+}
+
+var configSnapshotCreate = cluster.RegisterStmt(`
+INSERT INTO config_snapshots (name, config, created_at)
+  VALUES (?, ?, ?)
+`)
+
+var configSnapshotObjects = cluster.RegisterStmt(`
+SELECT config_snapshots.id, config_snapshots.name, config_snapshots.config, config_snapshots.created_at
+  FROM config_snapshots
+  ORDER BY config_snapshots.name
+`)
+
+var configSnapshotObjectsByName = cluster.RegisterStmt(`
+SELECT config_snapshots.id, config_snapshots.name, config_snapshots.config, config_snapshots.created_at
+  FROM config_snapshots
+  WHERE config_snapshots.name = ?
+`)
+
+var configSnapshotDelete = cluster.RegisterStmt(`
+DELETE FROM config_snapshots WHERE name = ?
+`)
+
+// CreateConfigSnapshot records a config snapshot under the given name.
+func CreateConfigSnapshot(ctx context.Context, tx *sql.Tx, name string, config string) (int64, error) {
+	count, err := query.Count(ctx, tx, "config_snapshots", "name = ?", name)
+	if err != nil {
+		return -1, fmt.Errorf("Failed to check for duplicates: %w", err)
+	}
+
+	if count > 0 {
+		return -1, api.StatusErrorf(http.StatusConflict, "This \"config_snapshots\" entry already exists")
+	}
+
+	stmt, err := cluster.Stmt(tx, configSnapshotCreate)
+	if err != nil {
+		return -1, fmt.Errorf("Failed to get \"configSnapshotCreate\" prepared statement: %w", err)
+	}
+
+	result, err := stmt.Exec(name, config, time.Now().UTC())
+	if err != nil {
+		return -1, fmt.Errorf("Failed to create \"config_snapshots\" entry: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return -1, fmt.Errorf("Failed to fetch \"config_snapshots\" entry ID: %w", err)
+	}
+
+	return id, nil
+}
+
+// GetConfigSnapshots returns the config snapshots, sorted by name.
+func GetConfigSnapshots(ctx context.Context, tx *sql.Tx) ([]ConfigSnapshot, error) {
+	stmt, err := cluster.Stmt(tx, configSnapshotObjects)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get \"configSnapshotObjects\" prepared statement: %w", err)
+	}
+
+	return getConfigSnapshots(ctx, stmt)
+}
+
+// GetConfigSnapshot returns the config snapshot with the given name.
+func GetConfigSnapshot(ctx context.Context, tx *sql.Tx, name string) (*ConfigSnapshot, error) {
+	stmt, err := cluster.Stmt(tx, configSnapshotObjectsByName)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get \"configSnapshotObjectsByName\" prepared statement: %w", err)
+	}
+
+	snapshots, err := getConfigSnapshots(ctx, stmt, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(snapshots) == 0 {
+		return nil, api.StatusErrorf(http.StatusNotFound, "ConfigSnapshot not found")
+	}
+
+	return &snapshots[0], nil
+}
+
+// getConfigSnapshots runs a config snapshot query with the given args.
+func getConfigSnapshots(ctx context.Context, stmt *sql.Stmt, args ...any) ([]ConfigSnapshot, error) {
+	snapshots := make([]ConfigSnapshot, 0)
+	dest := func(scan func(dest ...any) error) error {
+		c := ConfigSnapshot{}
+		err := scan(&c.ID, &c.Name, &c.Config, &c.CreatedAt)
+		if err != nil {
+			return err
+		}
+
+		snapshots = append(snapshots, c)
+
+		return nil
+	}
+
+	err := query.SelectObjects(ctx, stmt, dest, args...)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to fetch from \"config_snapshots\" table: %w", err)
+	}
+
+	return snapshots, nil
+}
+
+// DeleteConfigSnapshot deletes the config snapshot with the given name.
+func DeleteConfigSnapshot(_ context.Context, tx *sql.Tx, name string) error {
+	stmt, err := cluster.Stmt(tx, configSnapshotDelete)
+	if err != nil {
+		return fmt.Errorf("Failed to get \"configSnapshotDelete\" prepared statement: %w", err)
+	}
+
+	result, err := stmt.Exec(name)
+	if err != nil {
+		return fmt.Errorf("Delete \"config_snapshots\": %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("Fetch affected rows: %w", err)
+	}
+
+	if n == 0 {
+		return api.StatusErrorf(http.StatusNotFound, "ConfigSnapshot not found")
+	}
+
+	return nil
+}
@@ -0,0 +1,125 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/canonical/lxd/shared/api"
+)
+
+// ConfigValidator checks a config value and describes why it is invalid.
+type ConfigValidator func(value string) error
+
+// ConfigValidators maps config keys to the validator their values must pass.
+// Keys without a validator accept any value.
+var ConfigValidators = map[string]ConfigValidator{
+	"api.access-log":                 ValidateBool,
+	"api.rate_limit":                 ValidateNonNegativeInt,
+	"attestation.mode":               ValidateEnum("disabled", "warn", "enforce"),
+	"changes.low-water-mark":         ValidateInt,
+	"config.history-retention-days":  ValidateInt,
+	"manifest.retention":             ValidateInt,
+	"nodes.history":                  ValidateBool,
+	"nodes.history-retention-days":   ValidateInt,
+	"nodes.offline-threshold":        ValidateDuration,
+	"nodes.soft-delete":              ValidateBool,
+	"nodes.tombstone-retention-days": ValidateInt,
+	AllowedNodeRolesKey:              ValidateStringList,
+}
+
+// ValidateConfigValue runs the validator of the given config key, if any,
+// on the value.
+func ValidateConfigValue(key string, value string) error {
+	validator, ok := ConfigValidators[key]
+	if !ok {
+		return nil
+	}
+
+	err := validator(value)
+	if err != nil {
+		return api.StatusErrorf(http.StatusBadRequest, "Invalid value %q for config key %q: %v", value, key, err)
+	}
+
+	return nil
+}
+
+// ValidateInt accepts base 10 integers.
+func ValidateInt(value string) error {
+	_, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return fmt.Errorf("Must be an integer")
+	}
+
+	return nil
+}
+
+// ValidateNonNegativeInt accepts base 10 integers of zero or more.
+func ValidateNonNegativeInt(value string) error {
+	number, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || number < 0 {
+		return fmt.Errorf("Must be an integer of zero or more")
+	}
+
+	return nil
+}
+
+// ValidateFloat accepts finite decimal numbers.
+func ValidateFloat(value string) error {
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(number) || math.IsInf(number, 0) {
+		return fmt.Errorf("Must be a number")
+	}
+
+	return nil
+}
+
+// ValidateBool accepts "true" and "false".
+func ValidateBool(value string) error {
+	if value != "true" && value != "false" {
+		return fmt.Errorf("Must be \"true\" or \"false\"")
+	}
+
+	return nil
+}
+
+// ValidateDuration accepts Go durations, such as "30s" or "5m".
+func ValidateDuration(value string) error {
+	_, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("Must be a duration such as \"30s\" or \"5m\"")
+	}
+
+	return nil
+}
+
+// ValidateStringList accepts JSON lists of non-empty strings.
+func ValidateStringList(value string) error {
+	var list []string
+	err := json.Unmarshal([]byte(value), &list)
+	if err != nil {
+		return fmt.Errorf("Must be a JSON list of strings")
+	}
+
+	if slices.Contains(list, "") {
+		return fmt.Errorf("Must not contain empty strings")
+	}
+
+	return nil
+}
+
+// ValidateEnum returns a validator accepting only the given values.
+func ValidateEnum(values ...string) ConfigValidator {
+	return func(value string) error {
+		if !slices.Contains(values, value) {
+			return fmt.Errorf("Must be one of %s", strings.Join(values, ", "))
+		}
+
+		return nil
+	}
+}
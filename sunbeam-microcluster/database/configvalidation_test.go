@@ -0,0 +1,38 @@
+package database
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/canonical/lxd/shared/api"
+)
+
+func TestValidateConfigValue(t *testing.T) {
+	tests := []struct {
+		key   string
+		value string
+		valid bool
+	}{
+		{key: "manifest.retention", value: "abc", valid: false},
+		{key: "manifest.retention", value: "10", valid: true},
+		{key: "nodes.offline-threshold", value: "30s", valid: true},
+		{key: "nodes.offline-threshold", value: "30", valid: false},
+		{key: "nodes.history", value: "yes", valid: false},
+		{key: "attestation.mode", value: "enforce", valid: true},
+		{key: "attestation.mode", value: "strict", valid: false},
+		{key: "api.rate_limit", value: "-1", valid: false},
+		{key: AllowedNodeRolesKey, value: `["control", ""]`, valid: false},
+		{key: "unknown.key", value: "anything", valid: true},
+	}
+
+	for _, test := range tests {
+		err := ValidateConfigValue(test.key, test.value)
+		if test.valid && err != nil {
+			t.Errorf("Expected %q to be valid for %q, got %v", test.value, test.key, err)
+		}
+
+		if !test.valid && !api.StatusErrorCheck(err, http.StatusBadRequest) {
+			t.Errorf("Expected %q to be rejected for %q with 400, got %v", test.value, test.key, err)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/canonical/lxd/lxd/db/query"
+	"github.com/canonical/microcluster/cluster"
+)
+
+// DeletedNode is a soft deleted node. Node holds the node as it was when
+// deleted, JSON encoded.
+type DeletedNode struct {
+	ID        int64
+	Name      string
+	Node      string
+	DeletedAt time.Time
+}
+
+var deletedNodeCreate = cluster.RegisterStmt(`
+INSERT INTO deleted_nodes (name, node, deleted_at)
+  VALUES (?, ?, ?)
+`)
+
+var deletedNodeObjects = cluster.RegisterStmt(`
+SELECT deleted_nodes.id, deleted_nodes.name, deleted_nodes.node, deleted_nodes.deleted_at
+  FROM deleted_nodes
+  ORDER BY deleted_nodes.name, deleted_nodes.deleted_at, deleted_nodes.id
+`)
+
+var deletedNodesDeleteBefore = cluster.RegisterStmt(`
+DELETE FROM deleted_nodes WHERE id IN (
+  SELECT id FROM deleted_nodes WHERE deleted_at < ? ORDER BY id LIMIT ?
+)
+`)
+
+// CreateDeletedNode records a soft deleted node.
+func CreateDeletedNode(_ context.Context, tx *sql.Tx, name string, node string, deletedAt time.Time) error {
+	stmt, err := cluster.Stmt(tx, deletedNodeCreate)
+	if err != nil {
+		return fmt.Errorf("Failed to get \"deletedNodeCreate\" prepared statement: %w", err)
+	}
+
+	_, err = stmt.Exec(name, node, deletedAt.UTC())
+	if err != nil {
+		return fmt.Errorf("Failed to create \"deleted_nodes\" entry: %w", err)
+	}
+
+	return nil
+}
+
+// GetDeletedNodes returns the soft deleted nodes, ordered by name and then
+// by when they were deleted.
+func GetDeletedNodes(ctx context.Context, tx *sql.Tx) ([]DeletedNode, error) {
+	stmt, err := cluster.Stmt(tx, deletedNodeObjects)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get \"deletedNodeObjects\" prepared statement: %w", err)
+	}
+
+	objects := make([]DeletedNode, 0)
+	dest := func(scan func(dest ...any) error) error {
+		n := DeletedNode{}
+		err := scan(&n.ID, &n.Name, &n.Node, &n.DeletedAt)
+		if err != nil {
+			return err
+		}
+
+		objects = append(objects, n)
+
+		return nil
+	}
+
+	err = query.SelectObjects(ctx, stmt, dest)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to fetch from \"deleted_nodes\" table: %w", err)
+	}
+
+	return objects, nil
+}
+
+// DeleteDeletedNodesBefore permanently deletes at most limit nodes soft
+// deleted before the given time, oldest first, and returns the number
+// deleted.
+func DeleteDeletedNodesBefore(_ context.Context, tx *sql.Tx, before time.Time, limit int) (int64, error) {
+	stmt, err := cluster.Stmt(tx, deletedNodesDeleteBefore)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to get \"deletedNodesDeleteBefore\" prepared statement: %w", err)
+	}
+
+	result, err := stmt.Exec(before.UTC(), limit)
+	if err != nil {
+		return 0, fmt.Errorf("Delete \"deleted_nodes\": %w", err)
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,137 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/canonical/lxd/lxd/db/query"
+	"github.com/canonical/lxd/shared/api"
+	"github.com/canonical/microcluster/cluster"
+)
+
+// JoinToken is a single-use token a node presents to register itself. Only
+// the hash of the token is stored.
+type JoinToken struct {
+	ID        int64
+	TokenHash string
+	// SystemID is the system_id the token is bound to, empty if unbound.
+	SystemID  string
+	Node      string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+}
+
+var joinTokenCreate = cluster.RegisterStmt(`
+INSERT INTO join_tokens (token_hash, system_id, created_at, expires_at)
+  VALUES (?, ?, ?, ?)
+`)
+
+var joinTokenObjectsByTokenHash = cluster.RegisterStmt(`
+SELECT join_tokens.id, join_tokens.token_hash, join_tokens.system_id, join_tokens.node, join_tokens.created_at, join_tokens.expires_at, join_tokens.used_at
+  FROM join_tokens
+  WHERE join_tokens.token_hash = ?
+`)
+
+var joinTokenMarkUsed = cluster.RegisterStmt(`
+UPDATE join_tokens SET node = ?, used_at = ? WHERE id = ? AND used_at IS NULL
+`)
+
+var joinTokenRenameNode = cluster.RegisterStmt(`
+UPDATE join_tokens SET node = ? WHERE node = ?
+`)
+
+// CreateJoinToken records a join token hash, optionally bound to a system_id.
+func CreateJoinToken(_ context.Context, tx *sql.Tx, tokenHash string, systemID string, expiresAt time.Time) (int64, error) {
+	stmt, err := cluster.Stmt(tx, joinTokenCreate)
+	if err != nil {
+		return -1, fmt.Errorf("Failed to get \"joinTokenCreate\" prepared statement: %w", err)
+	}
+
+	result, err := stmt.Exec(tokenHash, systemID, time.Now().UTC(), expiresAt.UTC())
+	if err != nil {
+		return -1, fmt.Errorf("Failed to create \"join_tokens\" entry: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return -1, fmt.Errorf("Failed to fetch \"join_tokens\" entry ID: %w", err)
+	}
+
+	return id, nil
+}
+
+// GetJoinTokenByHash returns the join token with the given hash.
+func GetJoinTokenByHash(ctx context.Context, tx *sql.Tx, tokenHash string) (*JoinToken, error) {
+	stmt, err := cluster.Stmt(tx, joinTokenObjectsByTokenHash)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get \"joinTokenObjectsByTokenHash\" prepared statement: %w", err)
+	}
+
+	tokens := make([]JoinToken, 0)
+	dest := func(scan func(dest ...any) error) error {
+		t := JoinToken{}
+		err := scan(&t.ID, &t.TokenHash, &t.SystemID, &t.Node, &t.CreatedAt, &t.ExpiresAt, &t.UsedAt)
+		if err != nil {
+			return err
+		}
+
+		tokens = append(tokens, t)
+
+		return nil
+	}
+
+	err = query.SelectObjects(ctx, stmt, dest, tokenHash)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to fetch from \"join_tokens\" table: %w", err)
+	}
+
+	if len(tokens) == 0 {
+		return nil, api.StatusErrorf(http.StatusNotFound, "JoinToken not found")
+	}
+
+	return &tokens[0], nil
+}
+
+// MarkJoinTokenUsed binds a join token to the node that registered with it.
+// A token can only be used once.
+func MarkJoinTokenUsed(_ context.Context, tx *sql.Tx, id int64, node string) error {
+	stmt, err := cluster.Stmt(tx, joinTokenMarkUsed)
+	if err != nil {
+		return fmt.Errorf("Failed to get \"joinTokenMarkUsed\" prepared statement: %w", err)
+	}
+
+	result, err := stmt.Exec(node, time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("Update \"join_tokens\" entry failed: %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("Fetch affected rows: %w", err)
+	}
+
+	if n != 1 {
+		return api.StatusErrorf(http.StatusForbidden, "Join token has already been used")
+	}
+
+	return nil
+}
+
+// RenameJoinTokenNode rebinds the join tokens used by a node to its new name.
+func RenameJoinTokenNode(_ context.Context, tx *sql.Tx, name string, newName string) error {
+	stmt, err := cluster.Stmt(tx, joinTokenRenameNode)
+	if err != nil {
+		return fmt.Errorf("Failed to get \"joinTokenRenameNode\" prepared statement: %w", err)
+	}
+
+	_, err = stmt.Exec(newName, name)
+	if err != nil {
+		return fmt.Errorf("Update \"join_tokens\" entry failed: %w", err)
+	}
+
+	return nil
+}
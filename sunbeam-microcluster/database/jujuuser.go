@@ -1,5 +1,9 @@
 package database
 
+import (
+	"database/sql"
+)
+
 //go:generate -command mapper lxd-generate db mapper -t jujuuser.mapper.go
 //go:generate mapper reset
 //
@@ -23,6 +27,9 @@ type JujuUser struct {
 	ID       int
 	Username string `db:"primary=yes"`
 	Token    string
+	// ExpiresAt is when the token stops being valid, unset if it never
+	// expires.
+	ExpiresAt sql.NullTime
 }
 
 // JujuUserFilter is a required struct for use with lxd-generate. It is used for filtering fields on database fetches.
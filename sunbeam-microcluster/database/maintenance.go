@@ -0,0 +1,219 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/canonical/lxd/lxd/db/query"
+	"github.com/canonical/lxd/shared/api"
+	"github.com/canonical/microcluster/cluster"
+)
+
+// Maintenance actions that can be scheduled on a node.
+const (
+	MaintenanceReboot = "reboot"
+	MaintenanceDrain  = "drain"
+)
+
+// Maintenance window states.
+const (
+	MaintenanceScheduled = "scheduled"
+	MaintenanceStarted   = "started"
+	MaintenanceCompleted = "completed"
+)
+
+// MaintenanceWindow is a maintenance action scheduled on a node.
+type MaintenanceWindow struct {
+	ID      int64
+	Node    string
+	Action  string
+	StartAt time.Time
+	State   string
+}
+
+var maintenanceWindowCreate = cluster.RegisterStmt(`
+INSERT INTO maintenance_windows (node_id, action, start_at, state)
+  VALUES ((SELECT nodes.id FROM nodes WHERE nodes.name = ?), ?, ?, ?)
+`)
+
+var maintenanceWindowObjects = cluster.RegisterStmt(`
+SELECT maintenance_windows.id, nodes.name, maintenance_windows.action, maintenance_windows.start_at, maintenance_windows.state
+  FROM maintenance_windows
+  JOIN nodes ON maintenance_windows.node_id = nodes.id
+  ORDER BY maintenance_windows.start_at, maintenance_windows.id
+`)
+
+var maintenanceWindowObjectsByState = cluster.RegisterStmt(`
+SELECT maintenance_windows.id, nodes.name, maintenance_windows.action, maintenance_windows.start_at, maintenance_windows.state
+  FROM maintenance_windows
+  JOIN nodes ON maintenance_windows.node_id = nodes.id
+  WHERE maintenance_windows.state = ?
+  ORDER BY maintenance_windows.start_at, maintenance_windows.id
+`)
+
+var maintenanceWindowObjectsDue = cluster.RegisterStmt(`
+SELECT maintenance_windows.id, nodes.name, maintenance_windows.action, maintenance_windows.start_at, maintenance_windows.state
+  FROM maintenance_windows
+  JOIN nodes ON maintenance_windows.node_id = nodes.id
+  WHERE maintenance_windows.state = 'scheduled' AND maintenance_windows.start_at <= ?
+  ORDER BY maintenance_windows.start_at, maintenance_windows.id
+`)
+
+var maintenanceWindowObjectsByID = cluster.RegisterStmt(`
+SELECT maintenance_windows.id, nodes.name, maintenance_windows.action, maintenance_windows.start_at, maintenance_windows.state
+  FROM maintenance_windows
+  JOIN nodes ON maintenance_windows.node_id = nodes.id
+  WHERE maintenance_windows.id = ?
+`)
+
+var maintenanceWindowUpdateState = cluster.RegisterStmt(`
+UPDATE maintenance_windows SET state = ? WHERE id = ?
+`)
+
+var maintenanceWindowDeleteByID = cluster.RegisterStmt(`
+DELETE FROM maintenance_windows WHERE id = ?
+`)
+
+// CreateMaintenanceWindow schedules a maintenance action on the given node.
+func CreateMaintenanceWindow(_ context.Context, tx *sql.Tx, node string, action string, startAt time.Time) (int64, error) {
+	stmt, err := cluster.Stmt(tx, maintenanceWindowCreate)
+	if err != nil {
+		return -1, fmt.Errorf("Failed to get \"maintenanceWindowCreate\" prepared statement: %w", err)
+	}
+
+	result, err := stmt.Exec(node, action, startAt.UTC(), MaintenanceScheduled)
+	if err != nil {
+		return -1, fmt.Errorf("Failed to create \"maintenance_windows\" entry: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return -1, fmt.Errorf("Failed to fetch \"maintenance_windows\" entry ID: %w", err)
+	}
+
+	return id, nil
+}
+
+// GetMaintenanceWindows returns the maintenance windows, filtered by state if provided.
+func GetMaintenanceWindows(ctx context.Context, tx *sql.Tx, state *string) ([]MaintenanceWindow, error) {
+	if state == nil {
+		stmt, err := cluster.Stmt(tx, maintenanceWindowObjects)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get \"maintenanceWindowObjects\" prepared statement: %w", err)
+		}
+
+		return getMaintenanceWindows(ctx, stmt)
+	}
+
+	stmt, err := cluster.Stmt(tx, maintenanceWindowObjectsByState)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get \"maintenanceWindowObjectsByState\" prepared statement: %w", err)
+	}
+
+	return getMaintenanceWindows(ctx, stmt, *state)
+}
+
+// GetDueMaintenanceWindows returns the scheduled maintenance windows starting
+// at or before the given time.
+func GetDueMaintenanceWindows(ctx context.Context, tx *sql.Tx, now time.Time) ([]MaintenanceWindow, error) {
+	stmt, err := cluster.Stmt(tx, maintenanceWindowObjectsDue)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get \"maintenanceWindowObjectsDue\" prepared statement: %w", err)
+	}
+
+	return getMaintenanceWindows(ctx, stmt, now.UTC())
+}
+
+// GetMaintenanceWindow returns the maintenance window with the given ID.
+func GetMaintenanceWindow(ctx context.Context, tx *sql.Tx, id int64) (*MaintenanceWindow, error) {
+	stmt, err := cluster.Stmt(tx, maintenanceWindowObjectsByID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get \"maintenanceWindowObjectsByID\" prepared statement: %w", err)
+	}
+
+	windows, err := getMaintenanceWindows(ctx, stmt, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(windows) == 0 {
+		return nil, api.StatusErrorf(http.StatusNotFound, "MaintenanceWindow not found")
+	}
+
+	return &windows[0], nil
+}
+
+// UpdateMaintenanceWindowState sets the state of the maintenance window with the given ID.
+func UpdateMaintenanceWindowState(_ context.Context, tx *sql.Tx, id int64, state string) error {
+	stmt, err := cluster.Stmt(tx, maintenanceWindowUpdateState)
+	if err != nil {
+		return fmt.Errorf("Failed to get \"maintenanceWindowUpdateState\" prepared statement: %w", err)
+	}
+
+	result, err := stmt.Exec(state, id)
+	if err != nil {
+		return fmt.Errorf("Update \"maintenance_windows\" entry failed: %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("Fetch affected rows: %w", err)
+	}
+
+	if n == 0 {
+		return api.StatusErrorf(http.StatusNotFound, "MaintenanceWindow not found")
+	}
+
+	return nil
+}
+
+// DeleteMaintenanceWindow deletes the maintenance window with the given ID.
+func DeleteMaintenanceWindow(_ context.Context, tx *sql.Tx, id int64) error {
+	stmt, err := cluster.Stmt(tx, maintenanceWindowDeleteByID)
+	if err != nil {
+		return fmt.Errorf("Failed to get \"maintenanceWindowDeleteByID\" prepared statement: %w", err)
+	}
+
+	result, err := stmt.Exec(id)
+	if err != nil {
+		return fmt.Errorf("Delete \"maintenance_windows\": %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("Fetch affected rows: %w", err)
+	}
+
+	if n == 0 {
+		return api.StatusErrorf(http.StatusNotFound, "MaintenanceWindow not found")
+	}
+
+	return nil
+}
+
+// getMaintenanceWindows can be used to run handwritten sql.Stmts to return a slice of maintenance windows.
+func getMaintenanceWindows(ctx context.Context, stmt *sql.Stmt, args ...any) ([]MaintenanceWindow, error) {
+	objects := make([]MaintenanceWindow, 0)
+
+	dest := func(scan func(dest ...any) error) error {
+		m := MaintenanceWindow{}
+		err := scan(&m.ID, &m.Node, &m.Action, &m.StartAt, &m.State)
+		if err != nil {
+			return err
+		}
+
+		objects = append(objects, m)
+
+		return nil
+	}
+
+	err := query.SelectObjects(ctx, stmt, dest, args...)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to fetch from \"maintenance_windows\" table: %w", err)
+	}
+
+	return objects, nil
+}
@@ -1,11 +1,20 @@
 package database
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"fmt"
+	"io"
+	"math"
 	"net/http"
+	"strings"
+	"time"
 
+	"github.com/canonical/lxd/lxd/db/query"
 	"github.com/canonical/lxd/shared/api"
 	"github.com/canonical/microcluster/cluster"
 )
@@ -28,11 +37,23 @@ import (
 // ManifestItem is used to save the Sunbeam manifests provided by user.
 // AppliedDate is saved as Timestamp in database but retreived as string
 // Probable Bug: https://github.com/mattn/go-sqlite3/issues/951
+// AppliedDate only has a precision of a second, AppliedAt holds the time
+// the manifest was applied in Unix nanoseconds and orders manifests.
+// Data is stored gzipped when Compressed is set, use Content to read it.
+// Checksum is the SHA-256 of the uncompressed data, computed on write.
+// AppliedByVersion is the version of the daemon that recorded the manifest.
+// RolledBackFrom is the id of the manifest whose data a rollback re-applied,
+// empty for manifests that are not rollbacks.
 type ManifestItem struct {
-	ID          int
-	ManifestID  string `db:"primary=yes"`
-	AppliedDate string
-	Data        string
+	ID               int
+	ManifestID       string `db:"primary=yes"`
+	AppliedDate      string
+	AppliedAt        int64
+	Data             string
+	Compressed       bool
+	Checksum         string
+	AppliedByVersion string
+	RolledBackFrom   string
 }
 
 // ManifestItemFilter is a required struct for use with lxd-generate. It is used for filtering fields on database fetches.
@@ -40,18 +61,73 @@ type ManifestItemFilter struct {
 	ManifestID *string
 }
 
+// UnknownManifestVersion is the version recorded for manifests applied
+// before versions were recorded, or restored without one.
+const UnknownManifestVersion = "unknown"
+
 var manifestItemCreate = cluster.RegisterStmt(`
-INSERT INTO manifest (manifest_id, data)
-  VALUES (?, ?)
+INSERT INTO manifest (manifest_id, applied_at, data, compressed, checksum, applied_by_version, rolled_back_from)
+  VALUES (?, ?, ?, ?, ?, ?, ?)
 `)
 
 var latestManifestItemObject = cluster.RegisterStmt(`
-SELECT manifest.id, manifest.manifest_id, manifest.applied_date, manifest.data
+SELECT manifest.id, manifest.manifest_id, manifest.applied_date, manifest.applied_at, manifest.data, manifest.compressed, manifest.checksum, manifest.applied_by_version, manifest.rolled_back_from
+  FROM manifest
+  ORDER BY manifest.applied_at DESC, manifest.id DESC
+  LIMIT 1
+`)
+
+var manifestItemObjectByChecksum = cluster.RegisterStmt(`
+SELECT manifest.id, manifest.manifest_id, manifest.applied_date, manifest.applied_at, manifest.data, manifest.compressed, manifest.checksum, manifest.applied_by_version, manifest.rolled_back_from
+  FROM manifest
+  WHERE manifest.checksum = ?
+  ORDER BY manifest.id
+  LIMIT 1
+`)
+
+var manifestItemsInRange = cluster.RegisterStmt(`
+SELECT manifest.id, manifest.manifest_id, manifest.applied_date, manifest.applied_at, manifest.applied_by_version
   FROM manifest
-  WHERE manifest.applied_date = (SELECT MAX(applied_date) FROM manifest)
+  WHERE manifest.applied_at > ? AND manifest.applied_at < ?
+  ORDER BY manifest.applied_at, manifest.id
+  LIMIT ?
+`)
+
+var manifestItemsPage = cluster.RegisterStmt(`
+SELECT manifest.id, manifest.manifest_id, manifest.applied_date, manifest.applied_at, manifest.applied_by_version
+  FROM manifest
+  WHERE (manifest.applied_at > ? OR (manifest.applied_at = ? AND manifest.id > ?))
+    AND (? = '' OR instr(manifest.manifest_id, ?) > 0)
+  ORDER BY manifest.applied_at, manifest.id
+  LIMIT ?
+`)
+
+var manifestItemsPageWithData = cluster.RegisterStmt(`
+SELECT manifest.id, manifest.manifest_id, manifest.applied_date, manifest.applied_at, manifest.applied_by_version, manifest.data, manifest.compressed
+  FROM manifest
+  WHERE (manifest.applied_at > ? OR (manifest.applied_at = ? AND manifest.id > ?))
+    AND (? = '' OR instr(manifest.manifest_id, ?) > 0)
+  ORDER BY manifest.applied_at, manifest.id
+  LIMIT ?
+`)
+
+var manifestItemsDeleteExceptLatest = cluster.RegisterStmt(`
+DELETE FROM manifest
+  WHERE manifest.id NOT IN (
+    SELECT manifest.id FROM manifest
+      ORDER BY manifest.applied_at DESC, manifest.id DESC
+      LIMIT ?
+  )
+  AND manifest.manifest_id NOT IN (SELECT nodes.last_manifest_id FROM nodes)
+`)
+
+var manifestItemCount = cluster.RegisterStmt(`
+SELECT count(manifest.id) FROM manifest
 `)
 
-// CreateManifestItem adds a new ManifestItem to the database.
+// CreateManifestItem adds a new ManifestItem to the database. It is recorded
+// as applied now unless AppliedAt is set, by an unknown version unless
+// AppliedByVersion is set.
 // generator: ManifestItem Create
 func CreateManifestItem(ctx context.Context, tx *sql.Tx, object ManifestItem) (int64, error) {
 	// Check if a ManifestItem with the same key exists.
@@ -64,11 +140,29 @@ func CreateManifestItem(ctx context.Context, tx *sql.Tx, object ManifestItem) (i
 		return -1, api.StatusErrorf(http.StatusConflict, "This \"manifest\" entry already exists")
 	}
 
-	args := make([]any, 2)
+	data, compressed, err := compressManifestData(object.Data)
+	if err != nil {
+		return -1, err
+	}
+
+	args := make([]any, 7)
 
 	// Populate the statement arguments.
 	args[0] = object.ManifestID
-	args[1] = object.Data
+	args[1] = object.AppliedAt
+	if object.AppliedAt == 0 {
+		args[1] = time.Now().UnixNano()
+	}
+
+	args[2] = data
+	args[3] = compressed
+	args[4] = ManifestChecksum(object.Data)
+	args[5] = object.AppliedByVersion
+	if object.AppliedByVersion == "" {
+		args[5] = UnknownManifestVersion
+	}
+
+	args[6] = object.RolledBackFrom
 
 	// Prepared statement to use.
 	stmt, err := cluster.Stmt(tx, manifestItemCreate)
@@ -117,3 +211,191 @@ func GetLatestManifestItem(ctx context.Context, tx *sql.Tx) (*ManifestItem, erro
 		return &objects[objectsLen-1], nil
 	}
 }
+
+// GetManifestItemByChecksum returns the first recorded manifest whose data
+// has the given checksum.
+func GetManifestItemByChecksum(ctx context.Context, tx *sql.Tx, checksum string) (*ManifestItem, error) {
+	stmt, err := cluster.Stmt(tx, manifestItemObjectByChecksum)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get \"manifestItemObjectByChecksum\" prepared statement: %w", err)
+	}
+
+	objects, err := getManifestItems(ctx, stmt, checksum)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to fetch from \"manifest\" table: %w", err)
+	}
+
+	if len(objects) == 0 {
+		return nil, api.StatusErrorf(http.StatusNotFound, "ManifestItem not found")
+	}
+
+	return &objects[0], nil
+}
+
+// GetManifestsInRange returns at most limit manifests applied strictly
+// between after and before, oldest first, without their data. A nil bound
+// leaves the range open on that side.
+func GetManifestsInRange(ctx context.Context, tx *sql.Tx, after *time.Time, before *time.Time, limit int) ([]ManifestItem, error) {
+	stmt, err := cluster.Stmt(tx, manifestItemsInRange)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get \"manifestItemsInRange\" prepared statement: %w", err)
+	}
+
+	lower := int64(math.MinInt64)
+	if after != nil {
+		lower = after.UnixNano()
+	}
+
+	upper := int64(math.MaxInt64)
+	if before != nil {
+		upper = before.UnixNano()
+	}
+
+	objects := make([]ManifestItem, 0)
+	dest := func(scan func(dest ...any) error) error {
+		m := ManifestItem{}
+		err := scan(&m.ID, &m.ManifestID, &m.AppliedDate, &m.AppliedAt, &m.AppliedByVersion)
+		if err != nil {
+			return err
+		}
+
+		objects = append(objects, m)
+
+		return nil
+	}
+
+	err = query.SelectObjects(ctx, stmt, dest, lower, upper, limit)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to fetch from \"manifest\" table: %w", err)
+	}
+
+	return objects, nil
+}
+
+// GetManifestsPage returns at most limit manifests ordered by applied_at
+// and id, starting after the manifest applied at afterAppliedAt with ID
+// afterID. Only manifests whose manifest_id contains the given string are
+// returned, if it is not empty. The data of the manifests is only read with
+// includeData.
+func GetManifestsPage(ctx context.Context, tx *sql.Tx, afterAppliedAt int64, afterID int, contains string, includeData bool, limit int) ([]ManifestItem, error) {
+	name := "manifestItemsPage"
+	code := manifestItemsPage
+	if includeData {
+		name = "manifestItemsPageWithData"
+		code = manifestItemsPageWithData
+	}
+
+	stmt, err := cluster.Stmt(tx, code)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get %q prepared statement: %w", name, err)
+	}
+
+	objects := make([]ManifestItem, 0)
+	dest := func(scan func(dest ...any) error) error {
+		m := ManifestItem{}
+		fields := []any{&m.ID, &m.ManifestID, &m.AppliedDate, &m.AppliedAt, &m.AppliedByVersion}
+		if includeData {
+			fields = append(fields, &m.Data, &m.Compressed)
+		}
+
+		err := scan(fields...)
+		if err != nil {
+			return err
+		}
+
+		objects = append(objects, m)
+
+		return nil
+	}
+
+	err = query.SelectObjects(ctx, stmt, dest, afterAppliedAt, afterAppliedAt, afterID, contains, contains, limit)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to fetch from \"manifest\" table: %w", err)
+	}
+
+	return objects, nil
+}
+
+// Content returns the data of the manifest, decompressed if it was stored
+// compressed.
+func (m ManifestItem) Content() (string, error) {
+	if !m.Compressed {
+		return m.Data, nil
+	}
+
+	reader, err := gzip.NewReader(strings.NewReader(m.Data))
+	if err != nil {
+		return "", fmt.Errorf("Failed to decompress manifest %q: %w", m.ManifestID, err)
+	}
+
+	defer func() { _ = reader.Close() }()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return "", fmt.Errorf("Failed to decompress manifest %q: %w", m.ManifestID, err)
+	}
+
+	return string(data), nil
+}
+
+// CountManifests returns the number of manifests.
+func CountManifests(ctx context.Context, tx *sql.Tx) (int, error) {
+	stmt, err := cluster.Stmt(tx, manifestItemCount)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to get \"manifestItemCount\" prepared statement: %w", err)
+	}
+
+	var count int
+	err = stmt.QueryRowContext(ctx).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to count \"manifest\" entries: %w", err)
+	}
+
+	return count, nil
+}
+
+// DeleteManifestsExceptLatest deletes all manifests but the keep most
+// recently applied ones and those a node last applied, and returns the
+// number deleted.
+func DeleteManifestsExceptLatest(ctx context.Context, tx *sql.Tx, keep int) (int64, error) {
+	stmt, err := cluster.Stmt(tx, manifestItemsDeleteExceptLatest)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to get \"manifestItemsDeleteExceptLatest\" prepared statement: %w", err)
+	}
+
+	result, err := stmt.ExecContext(ctx, keep)
+	if err != nil {
+		return 0, fmt.Errorf("Delete \"manifest\": %w", err)
+	}
+
+	return result.RowsAffected()
+}
+
+// ManifestChecksum returns the hex encoded SHA-256 of manifest data.
+func ManifestChecksum(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+// compressManifestData returns the value to store for the given manifest
+// data, gzipped unless compression would not make it smaller.
+func compressManifestData(data string) (any, bool, error) {
+	var buf bytes.Buffer
+
+	writer := gzip.NewWriter(&buf)
+	_, err := writer.Write([]byte(data))
+	if err != nil {
+		return nil, false, fmt.Errorf("Failed to compress manifest: %w", err)
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return nil, false, fmt.Errorf("Failed to compress manifest: %w", err)
+	}
+
+	if buf.Len() >= len(data) {
+		return data, false, nil
+	}
+
+	return buf.Bytes(), true, nil
+}
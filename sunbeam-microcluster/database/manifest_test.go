@@ -0,0 +1,91 @@
+package database_test
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/database"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/internal/testutil"
+)
+
+// beginTestTx begins a transaction on a new test database, rolled back when
+// the test ends.
+func beginTestTx(t *testing.T) *sql.Tx {
+	t.Helper()
+
+	db, _ := testutil.NewDB(t)
+
+	tx, err := db.BeginTx(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("Failed to begin transaction: %v", err)
+	}
+
+	t.Cleanup(func() { _ = tx.Rollback() })
+
+	return tx
+}
+
+func TestManifestCompression(t *testing.T) {
+	tx := beginTestTx(t)
+	ctx := context.Background()
+
+	data := strings.Repeat("core:\n  config:\n    proxy:\n      proxy_required: false\n", 10000)
+
+	_, err := database.CreateManifestItem(ctx, tx, database.ManifestItem{ManifestID: "large", Data: data})
+	if err != nil {
+		t.Fatalf("Failed to create manifest: %v", err)
+	}
+
+	var stored int
+	var compressed bool
+	err = tx.QueryRowContext(ctx, "SELECT length(data), compressed FROM manifest WHERE manifest_id = ?", "large").Scan(&stored, &compressed)
+	if err != nil {
+		t.Fatalf("Failed to read stored manifest: %v", err)
+	}
+
+	if !compressed || stored >= len(data) {
+		t.Errorf("Manifest of %d bytes stored in %d bytes with compressed %v, expected it compressed smaller", len(data), stored, compressed)
+	}
+
+	record, err := database.GetManifestItem(ctx, tx, "large")
+	if err != nil {
+		t.Fatalf("Failed to get manifest: %v", err)
+	}
+
+	content, err := record.Content()
+	if err != nil {
+		t.Fatalf("Failed to read manifest data: %v", err)
+	}
+
+	if content != data {
+		t.Error("Manifest data read back differs from the data written")
+	}
+}
+
+func TestManifestUncompressed(t *testing.T) {
+	tx := beginTestTx(t)
+	ctx := context.Background()
+
+	// Data gzip cannot shrink is stored as is, like manifests written
+	// before compression.
+	_, err := database.CreateManifestItem(ctx, tx, database.ManifestItem{ManifestID: "small", Data: "a: b"})
+	if err != nil {
+		t.Fatalf("Failed to create manifest: %v", err)
+	}
+
+	record, err := database.GetManifestItem(ctx, tx, "small")
+	if err != nil {
+		t.Fatalf("Failed to get manifest: %v", err)
+	}
+
+	content, err := record.Content()
+	if err != nil {
+		t.Fatalf("Failed to read manifest data: %v", err)
+	}
+
+	if record.Compressed || content != "a: b" {
+		t.Errorf("Small manifest read back as %q with compressed %v, expected %q uncompressed", content, record.Compressed, "a: b")
+	}
+}
@@ -0,0 +1,96 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/canonical/lxd/lxd/db/query"
+)
+
+const (
+	// schemaTypeInternal is the type microcluster records its own schema
+	// updates with in its schemas table.
+	schemaTypeInternal = 0
+	// schemaTypeExternal is the type microcluster records extension updates
+	// with in its schemas table.
+	schemaTypeExternal = 1
+)
+
+// MigrationLogEntry records a schema extension applied on demand.
+type MigrationLogEntry struct {
+	Version   int
+	Name      string
+	AppliedAt time.Time
+}
+
+// GetSchemaExtensionsVersion returns the number of schema extensions applied
+// to the database.
+func GetSchemaExtensionsVersion(ctx context.Context, tx *sql.Tx) (int, error) {
+	versions, err := query.SelectIntegers(ctx, tx, "SELECT COALESCE(MAX(version), 0) FROM schemas WHERE type = ?", schemaTypeExternal)
+	if err != nil {
+		return -1, fmt.Errorf("Failed to fetch schema version: %w", err)
+	}
+
+	return versions[0], nil
+}
+
+// GetSchemaInternalVersion returns the number of microcluster's own schema
+// updates applied to the database.
+func GetSchemaInternalVersion(ctx context.Context, tx *sql.Tx) (int, error) {
+	versions, err := query.SelectIntegers(ctx, tx, "SELECT COALESCE(MAX(version), 0) FROM schemas WHERE type = ?", schemaTypeInternal)
+	if err != nil {
+		return -1, fmt.Errorf("Failed to fetch internal schema version: %w", err)
+	}
+
+	return versions[0], nil
+}
+
+// ApplySchemaExtension runs the schema extension at the given index and
+// records it as applied, both in microcluster's schemas table and in the
+// migration log.
+func ApplySchemaExtension(ctx context.Context, tx *sql.Tx, index int) (MigrationLogEntry, error) {
+	entry := MigrationLogEntry{
+		Version:   index + 1,
+		Name:      SchemaExtensionName(index),
+		AppliedAt: time.Now().UTC(),
+	}
+
+	err := SchemaExtensions[index](ctx, tx)
+	if err != nil {
+		return entry, fmt.Errorf("Failed to apply schema extension %d (%s): %w", entry.Version, entry.Name, err)
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO schemas (version, type, updated_at) VALUES (?, ?, strftime("%s"))`, entry.Version, schemaTypeExternal)
+	if err != nil {
+		return entry, fmt.Errorf("Failed to record schema version %d: %w", entry.Version, err)
+	}
+
+	// Extensions older than the migration log can't be recorded in it.
+	count, err := query.Count(ctx, tx, "sqlite_master", "type = 'table' AND name = ?", "migration_log")
+	if err != nil {
+		return entry, fmt.Errorf("Failed to check for the migration log: %w", err)
+	}
+
+	if count == 0 {
+		return entry, nil
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO migration_log (version, name, applied_at) VALUES (?, ?, ?)", entry.Version, entry.Name, entry.AppliedAt)
+	if err != nil {
+		return entry, fmt.Errorf("Failed to record \"migration_log\" entry: %w", err)
+	}
+
+	return entry, nil
+}
+
+// SchemaExtensionName returns the name of the schema extension at the given index.
+func SchemaExtensionName(index int) string {
+	name := runtime.FuncForPC(reflect.ValueOf(SchemaExtensions[index]).Pointer()).Name()
+
+	return name[strings.LastIndex(name, ".")+1:]
+}
@@ -4,8 +4,12 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"net/http"
 	"strings"
+	"time"
 
+	"github.com/canonical/lxd/lxd/db/query"
+	"github.com/canonical/lxd/shared/api"
 	"github.com/canonical/microcluster/cluster"
 )
 
@@ -17,6 +21,7 @@ import (
 //go:generate mapper stmt -d github.com/canonical/microcluster/cluster -e node objects-by-Name table=nodes
 //go:generate mapper stmt -d github.com/canonical/microcluster/cluster -e node objects-by-Role table=nodes
 //go:generate mapper stmt -d github.com/canonical/microcluster/cluster -e node objects-by-MachineID table=nodes
+//go:generate mapper stmt -d github.com/canonical/microcluster/cluster -e node objects-by-SystemID table=nodes
 //go:generate mapper stmt -d github.com/canonical/microcluster/cluster -e node id table=nodes
 //go:generate mapper stmt -d github.com/canonical/microcluster/cluster -e node create table=nodes
 //go:generate mapper stmt -d github.com/canonical/microcluster/cluster -e node delete-by-Name table=nodes
@@ -32,47 +37,204 @@ import (
 
 // Node is used to track Node information.
 type Node struct {
-	ID        int
-	Member    string `db:"join=internal_cluster_members.name&joinon=nodes.member_id"`
-	Name      string `db:"primary=yes"`
+	ID     int
+	Member string `db:"join=internal_cluster_members.name&joinon=nodes.member_id"`
+	Name   string `db:"primary=yes"`
+	// Role is the legacy single role column, holding the first of the
+	// node's roles. The roles themselves are kept in node_roles.
 	Role      string
 	MachineID int
 	SystemID  string
+	Owner     string
+	Cordoned  bool
+	CPUCount  int
+	MemoryMB  int
+	DiskGB    int
+	// LastManifestID is the manifest the node last reported as applied.
+	LastManifestID string
+	Status         string
+	LastSeen       sql.NullTime
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	// Metadata holds the labels attached to the node, as a JSON object.
+	Metadata string
 }
 
+// Node statuses, tracked from the cluster member heartbeats.
+const (
+	NodeStatusUnknown = "unknown"
+	NodeStatusOnline  = "online"
+	NodeStatusOffline = "offline"
+)
+
 // NodeFilter is a required struct for use with lxd-generate. It is used for filtering fields on database fetches.
 type NodeFilter struct {
 	Member    *string
 	Name      *string
 	Role      *string
 	MachineID *int
+	SystemID  *string
 }
 
-// GetNodesFromRoles returns a slice of Nodes that match the given roles.
-func GetNodesFromRoles(ctx context.Context, tx *sql.Tx, roles []string) ([]Node, error) {
+// NodeLabel is a key of the metadata of a node along with the string value
+// it must hold.
+type NodeLabel struct {
+	Key   string
+	Value string
+}
 
-	stmt, err := cluster.StmtString(nodeObjects)
+// GetNodesFromRoles returns a slice of Nodes that match the given roles and
+// labels, filtered by owner if provided.
+func GetNodesFromRoles(ctx context.Context, tx *sql.Tx, roles []string, owner *string, labels []NodeLabel) ([]Node, error) {
+	stmt, args, err := nodesFilterQuery(roles, owner, labels)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt += " ORDER BY nodes.name"
 
+	nodes, err := getNodesRaw(ctx, tx, stmt, args...)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to fetch prepared statement nodeObjets: %v", err)
+		return nil, fmt.Errorf("Failed to fetch from \"nodes\" table: %w", err)
 	}
 
-	queryParts := strings.SplitN(stmt, "ORDER BY", 2)
+	return nodes, nil
+}
 
-	args := make([]any, 0)
+// AddNodesBatch adds the given Nodes and returns their IDs, in order. A name
+// or machine ID repeated within the batch or already taken by an existing
+// node fails the whole batch, the caller is expected to roll back the
+// transaction.
+func AddNodesBatch(ctx context.Context, tx *sql.Tx, nodes []Node) ([]int64, error) {
+	seen := make(map[string]bool, len(nodes))
+	machines := make(map[int]string, len(nodes))
+	for _, node := range nodes {
+		if seen[node.Name] {
+			return nil, api.StatusErrorf(http.StatusBadRequest, "Node %q is listed more than once", node.Name)
+		}
 
-	if len(roles) > 0 {
-		queryParts[0] += " WHERE"
-		for i, role := range roles {
-			if i > 0 {
-				queryParts[0] += " AND"
+		seen[node.Name] = true
+
+		if node.MachineID >= 0 {
+			other, ok := machines[node.MachineID]
+			if ok {
+				return nil, api.StatusErrorf(http.StatusBadRequest, "Nodes %q and %q have the same machine ID %d", other, node.Name, node.MachineID)
 			}
-			queryParts[0] += " instr(nodes.role, ?) > 0"
-			args = append(args, role)
+
+			machines[node.MachineID] = node.Name
+		}
+
+		err := VerifyMachineIDFree(ctx, tx, node.MachineID, node.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		exists, err := NodeExists(ctx, tx, node.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		if exists {
+			return nil, api.StatusErrorf(http.StatusConflict, "Node %q already exists", node.Name)
 		}
 	}
 
-	stmt = strings.Join(queryParts, " ORDER BY")
+	ids := make([]int64, 0, len(nodes))
+	for _, node := range nodes {
+		id, err := CreateNode(ctx, tx, node)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to record node %q: %w", node.Name, err)
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// GetNodeByMachineID returns the Node with the given machine ID.
+func GetNodeByMachineID(ctx context.Context, tx *sql.Tx, machineID int) (*Node, error) {
+	filter := NodeFilter{MachineID: &machineID}
+
+	objects, err := GetNodes(ctx, tx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to fetch from \"nodes\" table: %w", err)
+	}
+
+	switch len(objects) {
+	case 0:
+		return nil, api.StatusErrorf(http.StatusNotFound, "Node not found")
+	case 1:
+		return &objects[0], nil
+	default:
+		return nil, fmt.Errorf("More than one \"nodes\" entry matches")
+	}
+}
+
+// GetNodeBySystemID returns the Node with the given MAAS system ID. The empty
+// system ID of nodes not deployed by MAAS never matches.
+func GetNodeBySystemID(ctx context.Context, tx *sql.Tx, systemID string) (*Node, error) {
+	if systemID == "" {
+		return nil, api.StatusErrorf(http.StatusBadRequest, "System ID must not be empty")
+	}
+
+	filter := NodeFilter{SystemID: &systemID}
+
+	objects, err := GetNodes(ctx, tx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to fetch from \"nodes\" table: %w", err)
+	}
+
+	switch len(objects) {
+	case 0:
+		return nil, api.StatusErrorf(http.StatusNotFound, "Node not found")
+	case 1:
+		return &objects[0], nil
+	default:
+		return nil, fmt.Errorf("More than one \"nodes\" entry matches")
+	}
+}
+
+// VerifyMachineIDFree returns a conflict error if a node other than the named
+// one has the given machine ID. Negative machine IDs are never taken.
+func VerifyMachineIDFree(ctx context.Context, tx *sql.Tx, machineID int, name string) error {
+	if machineID < 0 {
+		return nil
+	}
+
+	node, err := GetNodeByMachineID(ctx, tx, machineID)
+	if err != nil {
+		if api.StatusErrorCheck(err, http.StatusNotFound) {
+			return nil
+		}
+
+		return err
+	}
+
+	if node.Name != name {
+		return api.StatusErrorf(http.StatusConflict, "Machine ID %d is already used by node %q", machineID, node.Name)
+	}
+
+	return nil
+}
+
+// GetNodesByRole returns the Nodes holding the given role. A role no node
+// holds yields an empty slice.
+func GetNodesByRole(ctx context.Context, tx *sql.Tx, role string) ([]Node, error) {
+	return GetNodesFromRoles(ctx, tx, []string{role}, nil, nil)
+}
+
+// GetNodesPage returns at most limit Nodes that match the given roles and
+// labels, filtered by owner if provided, ordered by id and skipping the
+// first offset matches.
+func GetNodesPage(ctx context.Context, tx *sql.Tx, roles []string, owner *string, labels []NodeLabel, limit int, offset int) ([]Node, error) {
+	stmt, args, err := nodesFilterQuery(roles, owner, labels)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt += " ORDER BY nodes.id LIMIT ? OFFSET ?"
+	args = append(args, limit, offset)
 
 	nodes, err := getNodesRaw(ctx, tx, stmt, args...)
 	if err != nil {
@@ -80,5 +242,196 @@ func GetNodesFromRoles(ctx context.Context, tx *sql.Tx, roles []string) ([]Node,
 	}
 
 	return nodes, nil
+}
+
+// nodesFilterQuery returns the nodes query, without ordering, matching the
+// given roles, owner and labels, along with its arguments.
+func nodesFilterQuery(roles []string, owner *string, labels []NodeLabel) (string, []any, error) {
+	stmt, err := cluster.StmtString(nodeObjects)
+	if err != nil {
+		return "", nil, fmt.Errorf("Failed to fetch prepared statement nodeObjets: %v", err)
+	}
+
+	stmt, _, _ = strings.Cut(stmt, "ORDER BY")
+
+	args := make([]any, 0)
+	conditions := make([]string, 0)
+
+	for _, role := range roles {
+		conditions = append(conditions, "nodes.id IN (SELECT node_roles.node_id FROM node_roles WHERE node_roles.role = ?)")
+		args = append(args, role)
+	}
+
+	if owner != nil {
+		conditions = append(conditions, "nodes.owner = ?")
+		args = append(args, *owner)
+	}
+
+	for _, label := range labels {
+		conditions = append(conditions, "json_extract(nodes.metadata, ?) = ?")
+		args = append(args, `$."`+label.Key+`"`, label.Value)
+	}
+
+	if len(conditions) > 0 {
+		stmt += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	return stmt, args, nil
+}
+
+// nodeGroupColumns maps the node attributes that can be grouped in SQL to
+// their column.
+var nodeGroupColumns = map[string]string{
+	"member": "internal_cluster_members.name",
+	"owner":  "nodes.owner",
+	"status": "nodes.status",
+}
+
+// NodeGroupCount holds the number of nodes sharing a value of a grouped attribute.
+type NodeGroupCount struct {
+	Value string
+	Count int
+}
+
+// CountNodesGroupedBy returns the number of nodes for each value of the
+// given attribute.
+func CountNodesGroupedBy(ctx context.Context, tx *sql.Tx, field string) ([]NodeGroupCount, error) {
+	column, ok := nodeGroupColumns[field]
+	if !ok {
+		return nil, fmt.Errorf("Nodes cannot be grouped by %q", field)
+	}
+
+	stmt := fmt.Sprintf(`
+SELECT %s, count(nodes.id)
+  FROM nodes
+  JOIN internal_cluster_members ON nodes.member_id = internal_cluster_members.id
+  GROUP BY %s
+  ORDER BY %s
+`, column, column, column)
+
+	counts := make([]NodeGroupCount, 0)
+	dest := func(scan func(dest ...any) error) error {
+		c := NodeGroupCount{}
+		err := scan(&c.Value, &c.Count)
+		if err != nil {
+			return err
+		}
+
+		counts = append(counts, c)
+
+		return nil
+	}
+
+	err := query.Scan(ctx, tx, stmt, dest)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to fetch from \"nodes\" table: %w", err)
+	}
+
+	return counts, nil
+}
+
+// NodeSummary holds the number of nodes and of nodes holding each role.
+type NodeSummary struct {
+	Nodes int
+	Roles map[string]int
+}
+
+// nodeSummary counts the nodes on a first row with a NULL role, followed by
+// a row per role.
+var nodeSummary = cluster.RegisterStmt(`
+SELECT NULL, count(nodes.id)
+  FROM nodes
+UNION ALL
+SELECT node_roles.role, count(node_roles.node_id)
+  FROM node_roles
+  GROUP BY node_roles.role
+`)
+
+// GetNodeSummary returns the number of nodes and of nodes holding each role,
+// counted in a single query.
+func GetNodeSummary(ctx context.Context, tx *sql.Tx) (NodeSummary, error) {
+	summary := NodeSummary{Roles: map[string]int{}}
+
+	stmt, err := cluster.Stmt(tx, nodeSummary)
+	if err != nil {
+		return summary, fmt.Errorf("Failed to get \"nodeSummary\" prepared statement: %w", err)
+	}
+
+	dest := func(scan func(dest ...any) error) error {
+		var role sql.NullString
+		var count int
+		err := scan(&role, &count)
+		if err != nil {
+			return err
+		}
+
+		if role.Valid {
+			summary.Roles[role.String] = count
+		} else {
+			summary.Nodes = count
+		}
+
+		return nil
+	}
+
+	err = query.SelectObjects(ctx, stmt, dest)
+	if err != nil {
+		return summary, fmt.Errorf("Failed to fetch from \"nodes\" table: %w", err)
+	}
+
+	return summary, nil
+}
+
+// nodeMemberMove moves the nodes recorded through one cluster member to
+// another.
+var nodeMemberMove = cluster.RegisterStmt(`
+UPDATE nodes SET member_id = (SELECT id FROM internal_cluster_members WHERE name = ?)
+  WHERE member_id = (SELECT id FROM internal_cluster_members WHERE name = ?)
+`)
+
+// MoveNodesToMember records the nodes recorded through the given cluster
+// member as recorded through newMember instead, and returns how many were
+// moved.
+func MoveNodesToMember(_ context.Context, tx *sql.Tx, member string, newMember string) (int64, error) {
+	stmt, err := cluster.Stmt(tx, nodeMemberMove)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to get \"nodeMemberMove\" prepared statement: %w", err)
+	}
+
+	result, err := stmt.Exec(newMember, member)
+	if err != nil {
+		return 0, fmt.Errorf("Update \"nodes\" entry failed: %w", err)
+	}
+
+	return result.RowsAffected()
+}
+
+// NodeCapacity holds the hardware totals across nodes.
+type NodeCapacity struct {
+	Nodes    int
+	CPUCount int
+	MemoryMB int
+	DiskGB   int
+}
+
+var nodeCapacity = cluster.RegisterStmt(`
+SELECT count(nodes.id), coalesce(sum(nodes.cpu_count), 0), coalesce(sum(nodes.memory_mb), 0), coalesce(sum(nodes.disk_gb), 0)
+  FROM nodes
+`)
+
+// GetNodeCapacity returns the hardware totals across all nodes.
+func GetNodeCapacity(ctx context.Context, tx *sql.Tx) (NodeCapacity, error) {
+	capacity := NodeCapacity{}
+
+	stmt, err := cluster.Stmt(tx, nodeCapacity)
+	if err != nil {
+		return capacity, fmt.Errorf("Failed to get \"nodeCapacity\" prepared statement: %w", err)
+	}
+
+	err = stmt.QueryRowContext(ctx).Scan(&capacity.Nodes, &capacity.CPUCount, &capacity.MemoryMB, &capacity.DiskGB)
+	if err != nil {
+		return capacity, fmt.Errorf("Failed to fetch from \"nodes\" table: %w", err)
+	}
 
+	return capacity, nil
 }
@@ -18,14 +18,14 @@ import (
 var _ = api.ServerEnvironment{}
 
 var nodeObjects = cluster.RegisterStmt(`
-SELECT nodes.id, internal_cluster_members.name AS member, nodes.name, nodes.role, nodes.machine_id, nodes.system_id
+SELECT nodes.id, internal_cluster_members.name AS member, nodes.name, nodes.role, nodes.machine_id, nodes.system_id, nodes.owner, nodes.cordoned, nodes.cpu_count, nodes.memory_mb, nodes.disk_gb, nodes.last_manifest_id, nodes.status, nodes.last_seen, nodes.created_at, nodes.updated_at, nodes.metadata
   FROM nodes
   JOIN internal_cluster_members ON nodes.member_id = internal_cluster_members.id
   ORDER BY nodes.name
 `)
 
 var nodeObjectsByMember = cluster.RegisterStmt(`
-SELECT nodes.id, internal_cluster_members.name AS member, nodes.name, nodes.role, nodes.machine_id, nodes.system_id
+SELECT nodes.id, internal_cluster_members.name AS member, nodes.name, nodes.role, nodes.machine_id, nodes.system_id, nodes.owner, nodes.cordoned, nodes.cpu_count, nodes.memory_mb, nodes.disk_gb, nodes.last_manifest_id, nodes.status, nodes.last_seen, nodes.created_at, nodes.updated_at, nodes.metadata
   FROM nodes
   JOIN internal_cluster_members ON nodes.member_id = internal_cluster_members.id
   WHERE ( member = ? )
@@ -33,7 +33,7 @@ SELECT nodes.id, internal_cluster_members.name AS member, nodes.name, nodes.role
 `)
 
 var nodeObjectsByName = cluster.RegisterStmt(`
-SELECT nodes.id, internal_cluster_members.name AS member, nodes.name, nodes.role, nodes.machine_id, nodes.system_id
+SELECT nodes.id, internal_cluster_members.name AS member, nodes.name, nodes.role, nodes.machine_id, nodes.system_id, nodes.owner, nodes.cordoned, nodes.cpu_count, nodes.memory_mb, nodes.disk_gb, nodes.last_manifest_id, nodes.status, nodes.last_seen, nodes.created_at, nodes.updated_at, nodes.metadata
   FROM nodes
   JOIN internal_cluster_members ON nodes.member_id = internal_cluster_members.id
   WHERE ( nodes.name = ? )
@@ -41,7 +41,7 @@ SELECT nodes.id, internal_cluster_members.name AS member, nodes.name, nodes.role
 `)
 
 var nodeObjectsByRole = cluster.RegisterStmt(`
-SELECT nodes.id, internal_cluster_members.name AS member, nodes.name, nodes.role, nodes.machine_id, nodes.system_id
+SELECT nodes.id, internal_cluster_members.name AS member, nodes.name, nodes.role, nodes.machine_id, nodes.system_id, nodes.owner, nodes.cordoned, nodes.cpu_count, nodes.memory_mb, nodes.disk_gb, nodes.last_manifest_id, nodes.status, nodes.last_seen, nodes.created_at, nodes.updated_at, nodes.metadata
   FROM nodes
   JOIN internal_cluster_members ON nodes.member_id = internal_cluster_members.id
   WHERE ( nodes.role = ? )
@@ -49,21 +49,29 @@ SELECT nodes.id, internal_cluster_members.name AS member, nodes.name, nodes.role
 `)
 
 var nodeObjectsByMachineID = cluster.RegisterStmt(`
-SELECT nodes.id, internal_cluster_members.name AS member, nodes.name, nodes.role, nodes.machine_id, nodes.system_id
+SELECT nodes.id, internal_cluster_members.name AS member, nodes.name, nodes.role, nodes.machine_id, nodes.system_id, nodes.owner, nodes.cordoned, nodes.cpu_count, nodes.memory_mb, nodes.disk_gb, nodes.last_manifest_id, nodes.status, nodes.last_seen, nodes.created_at, nodes.updated_at, nodes.metadata
   FROM nodes
   JOIN internal_cluster_members ON nodes.member_id = internal_cluster_members.id
   WHERE ( nodes.machine_id = ? )
   ORDER BY nodes.name
 `)
 
+var nodeObjectsBySystemID = cluster.RegisterStmt(`
+SELECT nodes.id, internal_cluster_members.name AS member, nodes.name, nodes.role, nodes.machine_id, nodes.system_id, nodes.owner, nodes.cordoned, nodes.cpu_count, nodes.memory_mb, nodes.disk_gb, nodes.last_manifest_id, nodes.status, nodes.last_seen, nodes.created_at, nodes.updated_at, nodes.metadata
+  FROM nodes
+  JOIN internal_cluster_members ON nodes.member_id = internal_cluster_members.id
+  WHERE ( nodes.system_id = ? )
+  ORDER BY nodes.name
+`)
+
 var nodeID = cluster.RegisterStmt(`
 SELECT nodes.id FROM nodes
   WHERE nodes.name = ?
 `)
 
 var nodeCreate = cluster.RegisterStmt(`
-INSERT INTO nodes (member_id, name, role, machine_id, system_id)
-  VALUES ((SELECT internal_cluster_members.id FROM internal_cluster_members WHERE internal_cluster_members.name = ?), ?, ?, ?, ?)
+INSERT INTO nodes (member_id, name, role, machine_id, system_id, owner, cordoned, cpu_count, memory_mb, disk_gb, last_manifest_id, status, last_seen, created_at, updated_at, metadata)
+  VALUES ((SELECT internal_cluster_members.id FROM internal_cluster_members WHERE internal_cluster_members.name = ?), ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 `)
 
 var nodeDeleteByName = cluster.RegisterStmt(`
@@ -72,14 +80,14 @@ DELETE FROM nodes WHERE name = ?
 
 var nodeUpdate = cluster.RegisterStmt(`
 UPDATE nodes
-  SET member_id = (SELECT internal_cluster_members.id FROM internal_cluster_members WHERE internal_cluster_members.name = ?), name = ?, role = ?, machine_id = ?, system_id = ?
+  SET member_id = (SELECT internal_cluster_members.id FROM internal_cluster_members WHERE internal_cluster_members.name = ?), name = ?, role = ?, machine_id = ?, system_id = ?, owner = ?, cordoned = ?, cpu_count = ?, memory_mb = ?, disk_gb = ?, last_manifest_id = ?, status = ?, last_seen = ?, created_at = ?, updated_at = ?, metadata = ?
  WHERE id = ?
 `)
 
 // nodeColumns returns a string of column names to be used with a SELECT statement for the entity.
 // Use this function when building statements to retrieve database entries matching the Node entity.
 func nodeColumns() string {
-	return "nodes.id, internal_cluster_members.name AS member, nodes.name, nodes.role, nodes.machine_id, nodes.system_id"
+	return "nodes.id, internal_cluster_members.name AS member, nodes.name, nodes.role, nodes.machine_id, nodes.system_id, nodes.owner, nodes.cordoned, nodes.cpu_count, nodes.memory_mb, nodes.disk_gb, nodes.last_manifest_id, nodes.status, nodes.last_seen, nodes.created_at, nodes.updated_at, nodes.metadata"
 }
 
 // getNodes can be used to run handwritten sql.Stmts to return a slice of objects.
@@ -88,7 +96,7 @@ func getNodes(ctx context.Context, stmt *sql.Stmt, args ...any) ([]Node, error)
 
 	dest := func(scan func(dest ...any) error) error {
 		n := Node{}
-		err := scan(&n.ID, &n.Member, &n.Name, &n.Role, &n.MachineID, &n.SystemID)
+		err := scan(&n.ID, &n.Member, &n.Name, &n.Role, &n.MachineID, &n.SystemID, &n.Owner, &n.Cordoned, &n.CPUCount, &n.MemoryMB, &n.DiskGB, &n.LastManifestID, &n.Status, &n.LastSeen, &n.CreatedAt, &n.UpdatedAt, &n.Metadata)
 		if err != nil {
 			return err
 		}
@@ -112,7 +120,7 @@ func getNodesRaw(ctx context.Context, tx *sql.Tx, sql string, args ...any) ([]No
 
 	dest := func(scan func(dest ...any) error) error {
 		n := Node{}
-		err := scan(&n.ID, &n.Member, &n.Name, &n.Role, &n.MachineID, &n.SystemID)
+		err := scan(&n.ID, &n.Member, &n.Name, &n.Role, &n.MachineID, &n.SystemID, &n.Owner, &n.Cordoned, &n.CPUCount, &n.MemoryMB, &n.DiskGB, &n.LastManifestID, &n.Status, &n.LastSeen, &n.CreatedAt, &n.UpdatedAt, &n.Metadata)
 		if err != nil {
 			return err
 		}
@@ -151,7 +159,31 @@ func GetNodes(ctx context.Context, tx *sql.Tx, filters ...NodeFilter) ([]Node, e
 	}
 
 	for i, filter := range filters {
-		if filter.Role != nil && filter.Member == nil && filter.Name == nil && filter.MachineID == nil {
+		if filter.SystemID != nil && filter.Member == nil && filter.Name == nil && filter.Role == nil && filter.MachineID == nil {
+			args = append(args, []any{filter.SystemID}...)
+			if len(filters) == 1 {
+				sqlStmt, err = cluster.Stmt(tx, nodeObjectsBySystemID)
+				if err != nil {
+					return nil, fmt.Errorf("Failed to get \"nodeObjectsBySystemID\" prepared statement: %w", err)
+				}
+
+				break
+			}
+
+			query, err := cluster.StmtString(nodeObjectsBySystemID)
+			if err != nil {
+				return nil, fmt.Errorf("Failed to get \"nodeObjects\" prepared statement: %w", err)
+			}
+
+			parts := strings.SplitN(query, "ORDER BY", 2)
+			if i == 0 {
+				copy(queryParts[:], parts)
+				continue
+			}
+
+			_, where, _ := strings.Cut(parts[0], "WHERE")
+			queryParts[0] += "OR" + where
+		} else if filter.Role != nil && filter.Member == nil && filter.Name == nil && filter.MachineID == nil && filter.SystemID == nil {
 			args = append(args, []any{filter.Role}...)
 			if len(filters) == 1 {
 				sqlStmt, err = cluster.Stmt(tx, nodeObjectsByRole)
@@ -175,7 +207,7 @@ func GetNodes(ctx context.Context, tx *sql.Tx, filters ...NodeFilter) ([]Node, e
 
 			_, where, _ := strings.Cut(parts[0], "WHERE")
 			queryParts[0] += "OR" + where
-		} else if filter.Name != nil && filter.Member == nil && filter.Role == nil && filter.MachineID == nil {
+		} else if filter.Name != nil && filter.Member == nil && filter.Role == nil && filter.MachineID == nil && filter.SystemID == nil {
 			args = append(args, []any{filter.Name}...)
 			if len(filters) == 1 {
 				sqlStmt, err = cluster.Stmt(tx, nodeObjectsByName)
@@ -199,7 +231,7 @@ func GetNodes(ctx context.Context, tx *sql.Tx, filters ...NodeFilter) ([]Node, e
 
 			_, where, _ := strings.Cut(parts[0], "WHERE")
 			queryParts[0] += "OR" + where
-		} else if filter.Member != nil && filter.Name == nil && filter.Role == nil && filter.MachineID == nil {
+		} else if filter.Member != nil && filter.Name == nil && filter.Role == nil && filter.MachineID == nil && filter.SystemID == nil {
 			args = append(args, []any{filter.Member}...)
 			if len(filters) == 1 {
 				sqlStmt, err = cluster.Stmt(tx, nodeObjectsByMember)
@@ -223,7 +255,7 @@ func GetNodes(ctx context.Context, tx *sql.Tx, filters ...NodeFilter) ([]Node, e
 
 			_, where, _ := strings.Cut(parts[0], "WHERE")
 			queryParts[0] += "OR" + where
-		} else if filter.MachineID != nil && filter.Member == nil && filter.Name == nil && filter.Role == nil {
+		} else if filter.MachineID != nil && filter.Member == nil && filter.Name == nil && filter.Role == nil && filter.SystemID == nil {
 			args = append(args, []any{filter.MachineID}...)
 			if len(filters) == 1 {
 				sqlStmt, err = cluster.Stmt(tx, nodeObjectsByMachineID)
@@ -247,7 +279,7 @@ func GetNodes(ctx context.Context, tx *sql.Tx, filters ...NodeFilter) ([]Node, e
 
 			_, where, _ := strings.Cut(parts[0], "WHERE")
 			queryParts[0] += "OR" + where
-		} else if filter.Member == nil && filter.Name == nil && filter.Role == nil && filter.MachineID == nil {
+		} else if filter.Member == nil && filter.Name == nil && filter.Role == nil && filter.MachineID == nil && filter.SystemID == nil {
 			return nil, fmt.Errorf("Cannot filter on empty NodeFilter")
 		} else {
 			return nil, fmt.Errorf("No statement exists for the given Filter")
@@ -340,7 +372,7 @@ func CreateNode(ctx context.Context, tx *sql.Tx, object Node) (int64, error) {
 		return -1, api.StatusErrorf(http.StatusConflict, "This \"nodes\" entry already exists")
 	}
 
-	args := make([]any, 5)
+	args := make([]any, 16)
 
 	// Populate the statement arguments.
 	args[0] = object.Member
@@ -348,6 +380,17 @@ func CreateNode(ctx context.Context, tx *sql.Tx, object Node) (int64, error) {
 	args[2] = object.Role
 	args[3] = object.MachineID
 	args[4] = object.SystemID
+	args[5] = object.Owner
+	args[6] = object.Cordoned
+	args[7] = object.CPUCount
+	args[8] = object.MemoryMB
+	args[9] = object.DiskGB
+	args[10] = object.LastManifestID
+	args[11] = object.Status
+	args[12] = object.LastSeen
+	args[13] = object.CreatedAt
+	args[14] = object.UpdatedAt
+	args[15] = object.Metadata
 
 	// Prepared statement to use.
 	stmt, err := cluster.Stmt(tx, nodeCreate)
@@ -409,7 +452,7 @@ func UpdateNode(ctx context.Context, tx *sql.Tx, name string, object Node) error
 		return fmt.Errorf("Failed to get \"nodeUpdate\" prepared statement: %w", err)
 	}
 
-	result, err := stmt.Exec(object.Member, object.Name, object.Role, object.MachineID, object.SystemID, id)
+	result, err := stmt.Exec(object.Member, object.Name, object.Role, object.MachineID, object.SystemID, object.Owner, object.Cordoned, object.CPUCount, object.MemoryMB, object.DiskGB, object.LastManifestID, object.Status, object.LastSeen, object.CreatedAt, object.UpdatedAt, object.Metadata, id)
 	if err != nil {
 		return fmt.Errorf("Update \"nodes\" entry failed: %w", err)
 	}
@@ -0,0 +1,93 @@
+package database_test
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/canonical/lxd/shared/api"
+	"github.com/canonical/microcluster/cluster"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/database"
+)
+
+// addTestNodes records the cluster member "member1" and a node through it
+// for each of the given names, with the machine ID the names map to.
+func addTestNodes(t *testing.T, tx *sql.Tx, machineIDs map[string]int) {
+	t.Helper()
+
+	ctx := context.Background()
+	_, err := cluster.CreateInternalClusterMember(ctx, tx, cluster.InternalClusterMember{
+		Name:        "member1",
+		Address:     "10.0.0.1:7000",
+		Certificate: "certificate of member1",
+		Heartbeat:   time.Now().UTC(),
+		Role:        cluster.Role("voter"),
+	})
+	if err != nil {
+		t.Fatalf("Failed to add cluster member: %v", err)
+	}
+
+	for name, machineID := range machineIDs {
+		_, err = database.CreateNode(ctx, tx, database.Node{Member: "member1", Name: name, MachineID: machineID, Status: database.NodeStatusUnknown, Metadata: "{}"})
+		if err != nil {
+			t.Fatalf("Failed to create node %q: %v", name, err)
+		}
+	}
+}
+
+func TestGetNodeByMachineID(t *testing.T) {
+	tx := beginTestTx(t)
+	ctx := context.Background()
+	addTestNodes(t, tx, map[string]int{"node1": 1, "node2": 2, "node3": -1, "node4": -1})
+
+	node, err := database.GetNodeByMachineID(ctx, tx, 2)
+	if err != nil {
+		t.Fatalf("Failed to get node by machine ID: %v", err)
+	}
+
+	if node.Name != "node2" {
+		t.Errorf("Machine ID 2 resolves to node %q, expected %q", node.Name, "node2")
+	}
+
+	_, err = database.GetNodeByMachineID(ctx, tx, 3)
+	if !api.StatusErrorCheck(err, http.StatusNotFound) {
+		t.Errorf("Expected an unknown machine ID to fail with 404, got %v", err)
+	}
+
+	_, err = database.CreateNode(ctx, tx, database.Node{Member: "member1", Name: "node5", MachineID: 1, Status: database.NodeStatusUnknown, Metadata: "{}"})
+	if err == nil {
+		t.Error("Expected a node reusing a machine ID to be rejected")
+	}
+}
+
+func TestAddMachineIDIndexDuplicates(t *testing.T) {
+	tx := beginTestTx(t)
+	ctx := context.Background()
+
+	// Go back to before the index existed.
+	_, err := tx.ExecContext(ctx, "DROP INDEX nodes_machine_id")
+	if err != nil {
+		t.Fatalf("Failed to drop index: %v", err)
+	}
+
+	addTestNodes(t, tx, map[string]int{"node1": 1, "node2": 1, "node3": 2, "node4": -1, "node5": -1})
+
+	err = database.AddMachineIDIndexToNodes(ctx, tx)
+	if err == nil || !strings.Contains(err.Error(), "machine 1 (") || strings.Contains(err.Error(), "machine 2") {
+		t.Fatalf("Expected the nodes sharing machine ID 1 to be reported, got %v", err)
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE nodes SET machine_id = 3 WHERE name = 'node2'")
+	if err != nil {
+		t.Fatalf("Failed to update node: %v", err)
+	}
+
+	err = database.AddMachineIDIndexToNodes(ctx, tx)
+	if err != nil {
+		t.Fatalf("Failed to add the machine ID index once duplicates are fixed: %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/canonical/lxd/shared/api"
+	"github.com/canonical/microcluster/cluster"
+)
+
+var nodeConfigValue = cluster.RegisterStmt(`
+SELECT node_config.value
+  FROM node_config
+  JOIN nodes ON node_config.node_id = nodes.id
+  WHERE nodes.name = ? AND node_config.key = ?
+`)
+
+var nodeConfigUpsert = cluster.RegisterStmt(`
+INSERT INTO node_config (node_id, key, value)
+  VALUES ((SELECT nodes.id FROM nodes WHERE nodes.name = ?), ?, ?)
+  ON CONFLICT(node_id, key) DO UPDATE SET value = excluded.value
+`)
+
+// GetNodeConfigItem returns the value a node overrides the given config key
+// with.
+func GetNodeConfigItem(ctx context.Context, tx *sql.Tx, node string, key string) (string, error) {
+	stmt, err := cluster.Stmt(tx, nodeConfigValue)
+	if err != nil {
+		return "", fmt.Errorf("Failed to get \"nodeConfigValue\" prepared statement: %w", err)
+	}
+
+	var value string
+	err = stmt.QueryRowContext(ctx, node, key).Scan(&value)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", api.StatusErrorf(http.StatusNotFound, "NodeConfigItem not found")
+		}
+
+		return "", fmt.Errorf("Failed to fetch from \"node_config\" table: %w", err)
+	}
+
+	return value, nil
+}
+
+// SetNodeConfigItem overrides the given config key for a node.
+func SetNodeConfigItem(ctx context.Context, tx *sql.Tx, node string, key string, value string) error {
+	_, err := GetNode(ctx, tx, node)
+	if err != nil {
+		return err
+	}
+
+	stmt, err := cluster.Stmt(tx, nodeConfigUpsert)
+	if err != nil {
+		return fmt.Errorf("Failed to get \"nodeConfigUpsert\" prepared statement: %w", err)
+	}
+
+	_, err = stmt.Exec(node, key, value)
+	if err != nil {
+		return fmt.Errorf("Failed to record \"node_config\" entry: %w", err)
+	}
+
+	return nil
+}
+
+// GetEffectiveConfig returns the value of a config key for a node, that is
+// the value the node overrides it with, or else the global value.
+func GetEffectiveConfig(ctx context.Context, tx *sql.Tx, node string, key string) (string, error) {
+	value, err := GetNodeConfigItem(ctx, tx, node, key)
+	if err == nil {
+		return value, nil
+	}
+
+	if !api.StatusErrorCheck(err, http.StatusNotFound) {
+		return "", err
+	}
+
+	record, err := GetConfigItem(ctx, tx, key)
+	if err != nil {
+		return "", err
+	}
+
+	return record.Value, nil
+}
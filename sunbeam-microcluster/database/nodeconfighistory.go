@@ -0,0 +1,158 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/canonical/lxd/lxd/db/query"
+	"github.com/canonical/lxd/shared/api"
+	"github.com/canonical/microcluster/cluster"
+)
+
+// NodeConfigHistoryEntry records a change of the value a node overrides a
+// config key with. The old value is unset when the override was created.
+type NodeConfigHistoryEntry struct {
+	ID        int64
+	Node      string
+	Key       string
+	OldValue  sql.NullString
+	NewValue  sql.NullString
+	ChangedAt time.Time
+}
+
+var nodeConfigHistoryCreate = cluster.RegisterStmt(`
+INSERT INTO node_config_history (node_id, key, old_value, new_value, changed_at)
+  VALUES ((SELECT nodes.id FROM nodes WHERE nodes.name = ?), ?, ?, ?, ?)
+`)
+
+var nodeConfigHistoryObjectsByNodeAndKey = cluster.RegisterStmt(`
+SELECT node_config_history.id, nodes.name, node_config_history.key, node_config_history.old_value, node_config_history.new_value, node_config_history.changed_at
+  FROM node_config_history
+  JOIN nodes ON node_config_history.node_id = nodes.id
+  WHERE nodes.name = ? AND node_config_history.key = ?
+  ORDER BY node_config_history.id
+`)
+
+var nodeConfigHistoryObjectByID = cluster.RegisterStmt(`
+SELECT node_config_history.id, nodes.name, node_config_history.key, node_config_history.old_value, node_config_history.new_value, node_config_history.changed_at
+  FROM node_config_history
+  JOIN nodes ON node_config_history.node_id = nodes.id
+  WHERE node_config_history.id = ?
+`)
+
+var nodeConfigHistoryDeleteByNode = cluster.RegisterStmt(`
+DELETE FROM node_config_history WHERE node_id = (SELECT nodes.id FROM nodes WHERE nodes.name = ?)
+`)
+
+var nodeConfigHistoryDeleteBefore = cluster.RegisterStmt(`
+DELETE FROM node_config_history WHERE id IN (
+  SELECT id FROM node_config_history WHERE changed_at < ? ORDER BY id LIMIT ?
+)
+`)
+
+// CreateNodeConfigHistoryEntry records a change of the value a node
+// overrides a config key with.
+func CreateNodeConfigHistoryEntry(_ context.Context, tx *sql.Tx, node string, key string, oldValue sql.NullString, newValue sql.NullString) (int64, error) {
+	stmt, err := cluster.Stmt(tx, nodeConfigHistoryCreate)
+	if err != nil {
+		return -1, fmt.Errorf("Failed to get \"nodeConfigHistoryCreate\" prepared statement: %w", err)
+	}
+
+	result, err := stmt.Exec(node, key, oldValue, newValue, time.Now().UTC())
+	if err != nil {
+		return -1, fmt.Errorf("Failed to create \"node_config_history\" entry: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return -1, fmt.Errorf("Failed to fetch \"node_config_history\" entry ID: %w", err)
+	}
+
+	return id, nil
+}
+
+// GetNodeConfigHistory returns the changes of the value a node overrides a
+// config key with, oldest first.
+func GetNodeConfigHistory(ctx context.Context, tx *sql.Tx, node string, key string) ([]NodeConfigHistoryEntry, error) {
+	stmt, err := cluster.Stmt(tx, nodeConfigHistoryObjectsByNodeAndKey)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get \"nodeConfigHistoryObjectsByNodeAndKey\" prepared statement: %w", err)
+	}
+
+	entries := make([]NodeConfigHistoryEntry, 0)
+	dest := func(scan func(dest ...any) error) error {
+		e := NodeConfigHistoryEntry{}
+		err := scan(&e.ID, &e.Node, &e.Key, &e.OldValue, &e.NewValue, &e.ChangedAt)
+		if err != nil {
+			return err
+		}
+
+		entries = append(entries, e)
+
+		return nil
+	}
+
+	err = query.SelectObjects(ctx, stmt, dest, node, key)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to fetch from \"node_config_history\" table: %w", err)
+	}
+
+	return entries, nil
+}
+
+// GetNodeConfigHistoryEntry returns the node config change with the given ID.
+func GetNodeConfigHistoryEntry(ctx context.Context, tx *sql.Tx, id int64) (*NodeConfigHistoryEntry, error) {
+	stmt, err := cluster.Stmt(tx, nodeConfigHistoryObjectByID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get \"nodeConfigHistoryObjectByID\" prepared statement: %w", err)
+	}
+
+	e := NodeConfigHistoryEntry{}
+	err = stmt.QueryRowContext(ctx, id).Scan(&e.ID, &e.Node, &e.Key, &e.OldValue, &e.NewValue, &e.ChangedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, api.StatusErrorf(http.StatusNotFound, "NodeConfigHistoryEntry not found")
+		}
+
+		return nil, fmt.Errorf("Failed to fetch from \"node_config_history\" table: %w", err)
+	}
+
+	return &e, nil
+}
+
+// DeleteNodeConfigHistory deletes the config changes of the node with the
+// given name.
+func DeleteNodeConfigHistory(_ context.Context, tx *sql.Tx, node string) error {
+	stmt, err := cluster.Stmt(tx, nodeConfigHistoryDeleteByNode)
+	if err != nil {
+		return fmt.Errorf("Failed to get \"nodeConfigHistoryDeleteByNode\" prepared statement: %w", err)
+	}
+
+	_, err = stmt.Exec(node)
+	if err != nil {
+		return fmt.Errorf("Delete \"node_config_history\": %w", err)
+	}
+
+	return nil
+}
+
+// DeleteNodeConfigHistoryBefore deletes at most limit node config changes
+// recorded before the given time, oldest first, and returns the number
+// deleted.
+func DeleteNodeConfigHistoryBefore(_ context.Context, tx *sql.Tx, before time.Time, limit int) (int64, error) {
+	stmt, err := cluster.Stmt(tx, nodeConfigHistoryDeleteBefore)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to get \"nodeConfigHistoryDeleteBefore\" prepared statement: %w", err)
+	}
+
+	result, err := stmt.Exec(before.UTC(), limit)
+	if err != nil {
+		return 0, fmt.Errorf("Delete \"node_config_history\": %w", err)
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,124 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/canonical/lxd/lxd/db/query"
+	"github.com/canonical/microcluster/cluster"
+)
+
+// NodeHistoryEntry records a change of a field of a node. Deletions are
+// recorded as a change of the "deleted" field, with no old value.
+type NodeHistoryEntry struct {
+	ID        int64
+	Name      string
+	Field     string
+	OldValue  sql.NullString
+	NewValue  sql.NullString
+	ChangedAt time.Time
+}
+
+var nodeHistoryCreate = cluster.RegisterStmt(`
+INSERT INTO node_history (name, field, old_value, new_value, changed_at)
+  VALUES (?, ?, ?, ?, ?)
+`)
+
+var nodeHistoryObjectsByName = cluster.RegisterStmt(`
+SELECT node_history.id, node_history.name, node_history.field, node_history.old_value, node_history.new_value, node_history.changed_at
+  FROM node_history
+  WHERE node_history.name = ?
+  ORDER BY node_history.id
+`)
+
+var nodeHistoryRename = cluster.RegisterStmt(`
+UPDATE node_history SET name = ? WHERE name = ?
+`)
+
+var nodeHistoryDeleteBefore = cluster.RegisterStmt(`
+DELETE FROM node_history WHERE id IN (
+  SELECT id FROM node_history WHERE changed_at < ? ORDER BY id LIMIT ?
+)
+`)
+
+// CreateNodeHistoryEntry records a change of a field of a node.
+func CreateNodeHistoryEntry(_ context.Context, tx *sql.Tx, name string, field string, oldValue sql.NullString, newValue sql.NullString) (int64, error) {
+	stmt, err := cluster.Stmt(tx, nodeHistoryCreate)
+	if err != nil {
+		return -1, fmt.Errorf("Failed to get \"nodeHistoryCreate\" prepared statement: %w", err)
+	}
+
+	result, err := stmt.Exec(name, field, oldValue, newValue, time.Now().UTC())
+	if err != nil {
+		return -1, fmt.Errorf("Failed to create \"node_history\" entry: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return -1, fmt.Errorf("Failed to fetch \"node_history\" entry ID: %w", err)
+	}
+
+	return id, nil
+}
+
+// GetNodeHistory returns the changes of the node with the given name,
+// oldest first.
+func GetNodeHistory(ctx context.Context, tx *sql.Tx, name string) ([]NodeHistoryEntry, error) {
+	stmt, err := cluster.Stmt(tx, nodeHistoryObjectsByName)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get \"nodeHistoryObjectsByName\" prepared statement: %w", err)
+	}
+
+	entries := make([]NodeHistoryEntry, 0)
+	dest := func(scan func(dest ...any) error) error {
+		e := NodeHistoryEntry{}
+		err := scan(&e.ID, &e.Name, &e.Field, &e.OldValue, &e.NewValue, &e.ChangedAt)
+		if err != nil {
+			return err
+		}
+
+		entries = append(entries, e)
+
+		return nil
+	}
+
+	err = query.SelectObjects(ctx, stmt, dest, name)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to fetch from \"node_history\" table: %w", err)
+	}
+
+	return entries, nil
+}
+
+// RenameNodeHistory moves the changes recorded for a node to its new name.
+func RenameNodeHistory(_ context.Context, tx *sql.Tx, name string, newName string) error {
+	stmt, err := cluster.Stmt(tx, nodeHistoryRename)
+	if err != nil {
+		return fmt.Errorf("Failed to get \"nodeHistoryRename\" prepared statement: %w", err)
+	}
+
+	_, err = stmt.Exec(newName, name)
+	if err != nil {
+		return fmt.Errorf("Update \"node_history\" entry failed: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteNodeHistoryBefore deletes at most limit node changes recorded
+// before the given time, oldest first, and returns the number deleted.
+func DeleteNodeHistoryBefore(_ context.Context, tx *sql.Tx, before time.Time, limit int) (int64, error) {
+	stmt, err := cluster.Stmt(tx, nodeHistoryDeleteBefore)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to get \"nodeHistoryDeleteBefore\" prepared statement: %w", err)
+	}
+
+	result, err := stmt.Exec(before.UTC(), limit)
+	if err != nil {
+		return 0, fmt.Errorf("Delete \"node_history\": %w", err)
+	}
+
+	return result.RowsAffected()
+}
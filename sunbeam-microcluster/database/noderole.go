@@ -0,0 +1,168 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/canonical/lxd/lxd/db/query"
+	"github.com/canonical/lxd/shared/api"
+	"github.com/canonical/microcluster/cluster"
+)
+
+// AllowedNodeRolesKey is the config key overriding the roles nodes may hold,
+// as a JSON list of role names.
+const AllowedNodeRolesKey = "roles.allowed"
+
+// DefaultNodeRoles are the roles nodes may hold unless AllowedNodeRolesKey
+// is set.
+var DefaultNodeRoles = []string{"compute", "control", "storage"}
+
+var nodeRoleObjects = cluster.RegisterStmt(`
+SELECT node_roles.node_id, node_roles.role
+  FROM node_roles
+  ORDER BY node_roles.node_id, node_roles.role
+`)
+
+var nodeRoleObjectsByNodeID = cluster.RegisterStmt(`
+SELECT node_roles.node_id, node_roles.role
+  FROM node_roles
+  WHERE node_roles.node_id = ?
+  ORDER BY node_roles.role
+`)
+
+var nodeRoleCreate = cluster.RegisterStmt(`
+INSERT INTO node_roles (node_id, role) VALUES (?, ?)
+`)
+
+var nodeRoleDeleteByNodeID = cluster.RegisterStmt(`
+DELETE FROM node_roles WHERE node_id = ?
+`)
+
+// LegacyRole returns the value of the legacy nodes.role column for the given
+// sorted roles, which is the first role.
+func LegacyRole(roles []string) string {
+	if len(roles) == 0 {
+		return ""
+	}
+
+	return roles[0]
+}
+
+// GetAllowedNodeRoles returns the roles nodes may hold, those set in the
+// AllowedNodeRolesKey config key or DefaultNodeRoles if it is unset.
+func GetAllowedNodeRoles(ctx context.Context, tx *sql.Tx) ([]string, error) {
+	item, err := GetConfigItem(ctx, tx, AllowedNodeRolesKey)
+	if err != nil {
+		if api.StatusErrorCheck(err, http.StatusNotFound) {
+			return DefaultNodeRoles, nil
+		}
+
+		return nil, err
+	}
+
+	var roles []string
+	err = json.Unmarshal([]byte(item.Value), &roles)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid %q value: %w", AllowedNodeRolesKey, err)
+	}
+
+	return roles, nil
+}
+
+// ValidateNodeRoles returns a bad request error if any of the given roles is
+// not one nodes may hold.
+func ValidateNodeRoles(ctx context.Context, tx *sql.Tx, roles []string) error {
+	allowed, err := GetAllowedNodeRoles(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	for _, role := range roles {
+		if !slices.Contains(allowed, role) {
+			return api.StatusErrorf(http.StatusBadRequest, "Invalid role %q, must be one of %s", role, strings.Join(allowed, ", "))
+		}
+	}
+
+	return nil
+}
+
+// GetNodeRoles returns the sorted roles of every node, keyed by node ID.
+func GetNodeRoles(ctx context.Context, tx *sql.Tx) (map[int][]string, error) {
+	stmt, err := cluster.Stmt(tx, nodeRoleObjects)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get \"nodeRoleObjects\" prepared statement: %w", err)
+	}
+
+	return getNodeRoles(ctx, stmt)
+}
+
+// GetNodeRolesByNodeID returns the sorted roles of a node.
+func GetNodeRolesByNodeID(ctx context.Context, tx *sql.Tx, nodeID int) ([]string, error) {
+	stmt, err := cluster.Stmt(tx, nodeRoleObjectsByNodeID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get \"nodeRoleObjectsByNodeID\" prepared statement: %w", err)
+	}
+
+	roles, err := getNodeRoles(ctx, stmt, nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	return roles[nodeID], nil
+}
+
+// SetNodeRoles replaces the roles of a node.
+func SetNodeRoles(ctx context.Context, tx *sql.Tx, nodeID int, roles []string) error {
+	stmt, err := cluster.Stmt(tx, nodeRoleDeleteByNodeID)
+	if err != nil {
+		return fmt.Errorf("Failed to get \"nodeRoleDeleteByNodeID\" prepared statement: %w", err)
+	}
+
+	_, err = stmt.ExecContext(ctx, nodeID)
+	if err != nil {
+		return fmt.Errorf("Failed to delete \"node_roles\" entries: %w", err)
+	}
+
+	stmt, err = cluster.Stmt(tx, nodeRoleCreate)
+	if err != nil {
+		return fmt.Errorf("Failed to get \"nodeRoleCreate\" prepared statement: %w", err)
+	}
+
+	for _, role := range roles {
+		_, err = stmt.ExecContext(ctx, nodeID, role)
+		if err != nil {
+			return fmt.Errorf("Failed to create \"node_roles\" entry: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// getNodeRoles runs a node_roles query and groups the roles by node ID.
+func getNodeRoles(ctx context.Context, stmt *sql.Stmt, args ...any) (map[int][]string, error) {
+	roles := map[int][]string{}
+	dest := func(scan func(dest ...any) error) error {
+		var nodeID int
+		var role string
+		err := scan(&nodeID, &role)
+		if err != nil {
+			return err
+		}
+
+		roles[nodeID] = append(roles[nodeID], role)
+
+		return nil
+	}
+
+	err := query.SelectObjects(ctx, stmt, dest, args...)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to fetch from \"node_roles\" table: %w", err)
+	}
+
+	return roles, nil
+}
@@ -4,6 +4,11 @@ package database
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/canonical/lxd/lxd/db/schema"
 )
@@ -16,6 +21,37 @@ var SchemaExtensions = []schema.Update{
 	JujuUserSchemaUpdate,
 	ManifestsSchemaUpdate,
 	AddSystemIDToNodes,
+	AddOwnerToNodes,
+	AllowedSystemIDsSchemaUpdate,
+	ChangesSchemaUpdate,
+	AddCordonedToNodes,
+	MaintenanceWindowsSchemaUpdate,
+	ConfigParentsSchemaUpdate,
+	MigrationLogSchemaUpdate,
+	JoinTokensSchemaUpdate,
+	ConfigScheduledSchemaUpdate,
+	AuditLogSchemaUpdate,
+	AddHardwareToNodes,
+	AddLastManifestToNodes,
+	AddStatusToNodes,
+	NodeRolesSchemaUpdate,
+	AddTimestampsToNodes,
+	ConfigHistorySchemaUpdate,
+	AddExpiryToJujuUser,
+	AddAppliedAtToManifest,
+	AddCompressedToManifest,
+	AddChecksumToManifest,
+	AddMachineIDIndexToNodes,
+	NodeConfigSchemaUpdate,
+	AddAppliedByVersionToManifest,
+	DeletedNodesSchemaUpdate,
+	AddRolledBackFromToManifest,
+	AddMetadataToNodes,
+	NodeHistorySchemaUpdate,
+	ConfigSnapshotsSchemaUpdate,
+	AddChecksumIndexToManifest,
+	PurgeTerraformConfigHistory,
+	NodeConfigHistorySchemaUpdate,
 }
 
 // NodesSchemaUpdate is schema for table nodes
@@ -70,7 +106,7 @@ CREATE TABLE jujuuser (
 }
 
 // ManifestsSchemaUpdate is schema for table manifest
-// TOCHK: TIMESTAMP(6) not storing nano seconds
+// TOCHK: TIMESTAMP(6) not storing nano seconds, see AddAppliedAtToManifest
 func ManifestsSchemaUpdate(_ context.Context, tx *sql.Tx) error {
 	stmt := `
 CREATE TABLE manifest (
@@ -96,3 +132,619 @@ ALTER TABLE nodes ADD COLUMN system_id TEXT default '';
 
 	return err
 }
+
+// AddOwnerToNodes is schema update for table nodes
+func AddOwnerToNodes(_ context.Context, tx *sql.Tx) error {
+	stmt := `
+ALTER TABLE nodes ADD COLUMN owner TEXT default '';
+  `
+
+	_, err := tx.Exec(stmt)
+
+	return err
+}
+
+// AllowedSystemIDsSchemaUpdate is schema for table allowed_system_ids
+func AllowedSystemIDsSchemaUpdate(_ context.Context, tx *sql.Tx) error {
+	stmt := `
+CREATE TABLE allowed_system_ids (
+  id                            INTEGER  PRIMARY KEY AUTOINCREMENT NOT NULL,
+  system_id                     TEXT     NOT  NULL,
+  UNIQUE(system_id)
+);
+  `
+
+	_, err := tx.Exec(stmt)
+
+	return err
+}
+
+// ChangesSchemaUpdate is schema for table changes
+func ChangesSchemaUpdate(_ context.Context, tx *sql.Tx) error {
+	stmt := `
+CREATE TABLE changes (
+  seq                           INTEGER  PRIMARY KEY AUTOINCREMENT NOT NULL,
+  entity                        TEXT     NOT  NULL,
+  key                           TEXT     NOT  NULL,
+  action                        TEXT     NOT  NULL,
+  changed_at                    TIMESTAMP NOT NULL
+);
+  `
+
+	_, err := tx.Exec(stmt)
+
+	return err
+}
+
+// AddCordonedToNodes is schema update for table nodes
+func AddCordonedToNodes(_ context.Context, tx *sql.Tx) error {
+	stmt := `
+ALTER TABLE nodes ADD COLUMN cordoned INTEGER NOT NULL default 0;
+  `
+
+	_, err := tx.Exec(stmt)
+
+	return err
+}
+
+// MaintenanceWindowsSchemaUpdate is schema for table maintenance_windows
+func MaintenanceWindowsSchemaUpdate(_ context.Context, tx *sql.Tx) error {
+	stmt := `
+CREATE TABLE maintenance_windows (
+  id                            INTEGER  PRIMARY KEY AUTOINCREMENT NOT NULL,
+  node_id                       INTEGER  NOT  NULL,
+  action                        TEXT     NOT  NULL,
+  start_at                      TIMESTAMP NOT NULL,
+  state                         TEXT     NOT  NULL default 'scheduled',
+  FOREIGN KEY (node_id) REFERENCES "nodes" (id) ON DELETE CASCADE
+);
+CREATE INDEX maintenance_windows_state_start_at ON maintenance_windows (state, start_at);
+  `
+
+	_, err := tx.Exec(stmt)
+
+	return err
+}
+
+// ConfigParentsSchemaUpdate is schema for table config_parents
+func ConfigParentsSchemaUpdate(_ context.Context, tx *sql.Tx) error {
+	stmt := `
+CREATE TABLE config_parents (
+  id                            INTEGER  PRIMARY KEY AUTOINCREMENT NOT NULL,
+  key                           TEXT     NOT  NULL,
+  parent                        TEXT     NOT  NULL,
+  UNIQUE(key)
+);
+  `
+
+	_, err := tx.Exec(stmt)
+
+	return err
+}
+
+// MigrationLogSchemaUpdate is schema for table migration_log
+func MigrationLogSchemaUpdate(_ context.Context, tx *sql.Tx) error {
+	stmt := `
+CREATE TABLE migration_log (
+  id                            INTEGER  PRIMARY KEY AUTOINCREMENT NOT NULL,
+  version                       INTEGER  NOT  NULL,
+  name                          TEXT     NOT  NULL,
+  applied_at                    TIMESTAMP NOT NULL
+);
+  `
+
+	_, err := tx.Exec(stmt)
+
+	return err
+}
+
+// JoinTokensSchemaUpdate is schema for table join_tokens
+func JoinTokensSchemaUpdate(_ context.Context, tx *sql.Tx) error {
+	stmt := `
+CREATE TABLE join_tokens (
+  id                            INTEGER  PRIMARY KEY AUTOINCREMENT NOT NULL,
+  token_hash                    TEXT     NOT  NULL,
+  system_id                     TEXT     NOT  NULL default '',
+  node                          TEXT     NOT  NULL default '',
+  created_at                    TIMESTAMP NOT NULL,
+  expires_at                    TIMESTAMP NOT NULL,
+  used_at                       TIMESTAMP,
+  UNIQUE(token_hash)
+);
+  `
+
+	_, err := tx.Exec(stmt)
+
+	return err
+}
+
+// ConfigScheduledSchemaUpdate is schema for table config_scheduled
+func ConfigScheduledSchemaUpdate(_ context.Context, tx *sql.Tx) error {
+	stmt := `
+CREATE TABLE config_scheduled (
+  id                            INTEGER  PRIMARY KEY AUTOINCREMENT NOT NULL,
+  key                           TEXT     NOT  NULL,
+  value                         TEXT     NOT  NULL,
+  effective_at                  TIMESTAMP NOT NULL
+);
+
+CREATE INDEX config_scheduled_effective_at ON config_scheduled (effective_at);
+  `
+
+	_, err := tx.Exec(stmt)
+
+	return err
+}
+
+// AuditLogSchemaUpdate is schema for table audit_log
+func AuditLogSchemaUpdate(_ context.Context, tx *sql.Tx) error {
+	stmt := `
+CREATE TABLE audit_log (
+  id                            INTEGER  PRIMARY KEY AUTOINCREMENT NOT NULL,
+  timestamp                     TIMESTAMP NOT NULL,
+  actor                         TEXT     NOT  NULL,
+  action                        TEXT     NOT  NULL,
+  target                        TEXT     NOT  NULL
+);
+
+CREATE INDEX audit_log_target_timestamp ON audit_log (target, timestamp);
+CREATE INDEX audit_log_timestamp ON audit_log (timestamp);
+  `
+
+	_, err := tx.Exec(stmt)
+
+	return err
+}
+
+// AddHardwareToNodes is schema update for table nodes
+func AddHardwareToNodes(_ context.Context, tx *sql.Tx) error {
+	stmt := `
+ALTER TABLE nodes ADD COLUMN cpu_count INTEGER NOT NULL default 0;
+ALTER TABLE nodes ADD COLUMN memory_mb INTEGER NOT NULL default 0;
+ALTER TABLE nodes ADD COLUMN disk_gb INTEGER NOT NULL default 0;
+  `
+
+	_, err := tx.Exec(stmt)
+
+	return err
+}
+
+// AddLastManifestToNodes is schema update for table nodes
+func AddLastManifestToNodes(_ context.Context, tx *sql.Tx) error {
+	stmt := `
+ALTER TABLE nodes ADD COLUMN last_manifest_id TEXT NOT NULL default '';
+  `
+
+	_, err := tx.Exec(stmt)
+
+	return err
+}
+
+// AddStatusToNodes is schema update for table nodes
+func AddStatusToNodes(_ context.Context, tx *sql.Tx) error {
+	stmt := `
+ALTER TABLE nodes ADD COLUMN status TEXT NOT NULL default 'unknown';
+ALTER TABLE nodes ADD COLUMN last_seen TIMESTAMP;
+  `
+
+	_, err := tx.Exec(stmt)
+
+	return err
+}
+
+// NodeRolesSchemaUpdate is schema for table node_roles. The roles held as a
+// JSON list in nodes.role are moved to the new table, and nodes.role is kept
+// for backward compatibility with only the first role.
+func NodeRolesSchemaUpdate(ctx context.Context, tx *sql.Tx) error {
+	stmt := `
+CREATE TABLE node_roles (
+  id                            INTEGER  PRIMARY KEY AUTOINCREMENT NOT NULL,
+  node_id                       INTEGER  NOT  NULL,
+  role                          TEXT     NOT  NULL,
+  FOREIGN KEY (node_id) REFERENCES "nodes" (id) ON DELETE CASCADE,
+  UNIQUE(node_id, role)
+);
+  `
+
+	_, err := tx.Exec(stmt)
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.QueryContext(ctx, "SELECT id, coalesce(role, '') FROM nodes")
+	if err != nil {
+		return err
+	}
+
+	legacy := map[int]string{}
+	for rows.Next() {
+		var id int
+		var role string
+		err = rows.Scan(&id, &role)
+		if err != nil {
+			_ = rows.Close()
+			return err
+		}
+
+		legacy[id] = role
+	}
+
+	err = rows.Err()
+	if err != nil {
+		_ = rows.Close()
+		return err
+	}
+
+	err = rows.Close()
+	if err != nil {
+		return err
+	}
+
+	for id, role := range legacy {
+		var roles []string
+		if json.Unmarshal([]byte(role), &roles) != nil && role != "" {
+			roles = []string{role}
+		}
+
+		sort.Strings(roles)
+		for _, r := range roles {
+			_, err = tx.ExecContext(ctx, "INSERT OR IGNORE INTO node_roles (node_id, role) VALUES (?, ?)", id, r)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = tx.ExecContext(ctx, "UPDATE nodes SET role = ? WHERE id = ?", LegacyRole(roles), id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// AddTimestampsToNodes is schema update for table nodes. SQLite does not
+// allow a CURRENT_TIMESTAMP default on added columns, so existing rows are
+// backfilled with the time of the migration.
+func AddTimestampsToNodes(ctx context.Context, tx *sql.Tx) error {
+	stmt := `
+ALTER TABLE nodes ADD COLUMN created_at TIMESTAMP;
+ALTER TABLE nodes ADD COLUMN updated_at TIMESTAMP;
+  `
+
+	_, err := tx.Exec(stmt)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	_, err = tx.ExecContext(ctx, "UPDATE nodes SET created_at = ?, updated_at = ?", now, now)
+
+	return err
+}
+
+// ConfigHistorySchemaUpdate is schema for table config_history
+func ConfigHistorySchemaUpdate(_ context.Context, tx *sql.Tx) error {
+	stmt := `
+CREATE TABLE config_history (
+  id                            INTEGER  PRIMARY KEY AUTOINCREMENT NOT NULL,
+  key                           TEXT     NOT  NULL,
+  old_value                     TEXT,
+  new_value                     TEXT,
+  changed_at                    TIMESTAMP NOT NULL
+);
+
+CREATE INDEX config_history_key ON config_history (key, id);
+CREATE INDEX config_history_changed_at ON config_history (changed_at);
+  `
+
+	_, err := tx.Exec(stmt)
+
+	return err
+}
+
+// AddExpiryToJujuUser is schema update for table jujuuser
+func AddExpiryToJujuUser(_ context.Context, tx *sql.Tx) error {
+	stmt := `
+ALTER TABLE jujuuser ADD COLUMN expires_at TIMESTAMP;
+  `
+
+	_, err := tx.Exec(stmt)
+
+	return err
+}
+
+// AddAppliedAtToManifest is schema update for table manifest. applied_date
+// only has a precision of a second, so manifests applied within the same
+// second sort ambiguously. applied_at holds the time in Unix nanoseconds,
+// existing rows get their applied_date and keep their insertion order.
+func AddAppliedAtToManifest(_ context.Context, tx *sql.Tx) error {
+	stmt := `
+ALTER TABLE manifest ADD COLUMN applied_at INTEGER NOT NULL default 0;
+UPDATE manifest SET applied_at = coalesce(CAST(strftime('%s', applied_date) AS INTEGER), 0) * 1000000000;
+CREATE INDEX manifest_applied_at ON manifest (applied_at, id);
+  `
+
+	_, err := tx.Exec(stmt)
+
+	return err
+}
+
+// AddCompressedToManifest is schema update for table manifest. Rows stored
+// before have their data uncompressed.
+func AddCompressedToManifest(_ context.Context, tx *sql.Tx) error {
+	stmt := `
+ALTER TABLE manifest ADD COLUMN compressed BOOLEAN NOT NULL default false;
+  `
+
+	_, err := tx.Exec(stmt)
+
+	return err
+}
+
+// AddChecksumToManifest is schema update for table manifest. The checksum
+// of the existing rows is computed from their data.
+func AddChecksumToManifest(ctx context.Context, tx *sql.Tx) error {
+	stmt := `
+ALTER TABLE manifest ADD COLUMN checksum TEXT NOT NULL default '';
+  `
+
+	_, err := tx.Exec(stmt)
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.QueryContext(ctx, "SELECT id, manifest_id, coalesce(data, ''), compressed FROM manifest")
+	if err != nil {
+		return err
+	}
+
+	var manifests []ManifestItem
+	for rows.Next() {
+		m := ManifestItem{}
+		err = rows.Scan(&m.ID, &m.ManifestID, &m.Data, &m.Compressed)
+		if err != nil {
+			_ = rows.Close()
+			return err
+		}
+
+		manifests = append(manifests, m)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		_ = rows.Close()
+		return err
+	}
+
+	err = rows.Close()
+	if err != nil {
+		return err
+	}
+
+	for _, m := range manifests {
+		data, err := m.Content()
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, "UPDATE manifest SET checksum = ? WHERE id = ?", ManifestChecksum(data), m.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// AddMachineIDIndexToNodes is schema update for table nodes. Machine IDs are
+// unique across nodes, nodes without a machine have a negative one and are
+// not constrained. Nodes sharing a machine ID are reported rather than
+// failing on the constraint, they must be fixed before upgrading.
+func AddMachineIDIndexToNodes(ctx context.Context, tx *sql.Tx) error {
+	rows, err := tx.QueryContext(ctx, `
+SELECT nodes.machine_id, group_concat(nodes.name, ', ')
+  FROM nodes
+  WHERE nodes.machine_id >= 0
+  GROUP BY nodes.machine_id
+  HAVING count(nodes.id) > 1
+  ORDER BY nodes.machine_id
+`)
+	if err != nil {
+		return err
+	}
+
+	var duplicates []string
+	for rows.Next() {
+		var machineID int
+		var names string
+		err = rows.Scan(&machineID, &names)
+		if err != nil {
+			_ = rows.Close()
+			return err
+		}
+
+		duplicates = append(duplicates, fmt.Sprintf("machine %d (%s)", machineID, names))
+	}
+
+	err = rows.Err()
+	if err != nil {
+		_ = rows.Close()
+		return err
+	}
+
+	err = rows.Close()
+	if err != nil {
+		return err
+	}
+
+	if len(duplicates) > 0 {
+		return fmt.Errorf("Nodes share machine IDs, give each node its own machine ID before upgrading: %s", strings.Join(duplicates, "; "))
+	}
+
+	stmt := `
+CREATE UNIQUE INDEX nodes_machine_id ON nodes (machine_id) WHERE machine_id >= 0;
+  `
+
+	_, err = tx.Exec(stmt)
+
+	return err
+}
+
+// NodeConfigSchemaUpdate is schema for table node_config
+func NodeConfigSchemaUpdate(_ context.Context, tx *sql.Tx) error {
+	stmt := `
+CREATE TABLE node_config (
+  id                            INTEGER  PRIMARY KEY AUTOINCREMENT NOT NULL,
+  node_id                       INTEGER  NOT  NULL,
+  key                           TEXT     NOT  NULL,
+  value                         TEXT     NOT  NULL,
+  FOREIGN KEY (node_id) REFERENCES "nodes" (id) ON DELETE CASCADE,
+  UNIQUE(node_id, key)
+);
+  `
+
+	_, err := tx.Exec(stmt)
+
+	return err
+}
+
+// AddAppliedByVersionToManifest is schema update for table manifest. Rows
+// stored before are recorded as applied by an unknown version.
+func AddAppliedByVersionToManifest(_ context.Context, tx *sql.Tx) error {
+	stmt := `
+ALTER TABLE manifest ADD COLUMN applied_by_version TEXT NOT NULL default 'unknown';
+  `
+
+	_, err := tx.Exec(stmt)
+
+	return err
+}
+
+// DeletedNodesSchemaUpdate is schema for table deleted_nodes. Soft deleted
+// nodes are moved there from nodes, so that their name can be reused.
+func DeletedNodesSchemaUpdate(_ context.Context, tx *sql.Tx) error {
+	stmt := `
+CREATE TABLE deleted_nodes (
+  id                            INTEGER  PRIMARY KEY AUTOINCREMENT NOT NULL,
+  name                          TEXT     NOT  NULL,
+  node                          TEXT     NOT  NULL,
+  deleted_at                    TIMESTAMP NOT NULL
+);
+
+CREATE INDEX deleted_nodes_deleted_at ON deleted_nodes (deleted_at);
+  `
+
+	_, err := tx.Exec(stmt)
+
+	return err
+}
+
+// AddRolledBackFromToManifest is schema update for table manifest. Rows
+// stored before are not rollbacks.
+func AddRolledBackFromToManifest(_ context.Context, tx *sql.Tx) error {
+	stmt := `
+ALTER TABLE manifest ADD COLUMN rolled_back_from TEXT NOT NULL default '';
+  `
+
+	_, err := tx.Exec(stmt)
+
+	return err
+}
+
+// AddMetadataToNodes is schema update for table nodes. Nodes start with no
+// metadata.
+func AddMetadataToNodes(_ context.Context, tx *sql.Tx) error {
+	stmt := `
+ALTER TABLE nodes ADD COLUMN metadata TEXT NOT NULL default '{}';
+  `
+
+	_, err := tx.Exec(stmt)
+
+	return err
+}
+
+// NodeHistorySchemaUpdate is schema for table node_history
+func NodeHistorySchemaUpdate(_ context.Context, tx *sql.Tx) error {
+	stmt := `
+CREATE TABLE node_history (
+  id                            INTEGER  PRIMARY KEY AUTOINCREMENT NOT NULL,
+  name                          TEXT     NOT  NULL,
+  field                         TEXT     NOT  NULL,
+  old_value                     TEXT,
+  new_value                     TEXT,
+  changed_at                    TIMESTAMP NOT NULL
+);
+
+CREATE INDEX node_history_name ON node_history (name, id);
+CREATE INDEX node_history_changed_at ON node_history (changed_at);
+  `
+
+	_, err := tx.Exec(stmt)
+
+	return err
+}
+
+// ConfigSnapshotsSchemaUpdate is schema for table config_snapshots
+func ConfigSnapshotsSchemaUpdate(_ context.Context, tx *sql.Tx) error {
+	stmt := `
+CREATE TABLE config_snapshots (
+  id                            INTEGER  PRIMARY KEY AUTOINCREMENT NOT NULL,
+  name                          TEXT     NOT  NULL,
+  config                        TEXT     NOT  NULL,
+  created_at                    TIMESTAMP NOT NULL,
+  UNIQUE(name)
+);
+  `
+
+	_, err := tx.Exec(stmt)
+
+	return err
+}
+
+// AddChecksumIndexToManifest is schema update for table manifest, so that
+// manifests can be looked up by the checksum of their data.
+func AddChecksumIndexToManifest(_ context.Context, tx *sql.Tx) error {
+	stmt := `
+CREATE INDEX manifest_checksum ON manifest (checksum);
+  `
+
+	_, err := tx.Exec(stmt)
+
+	return err
+}
+
+// PurgeTerraformConfigHistory is schema update for table config_history.
+// Changes of Terraform states and locks are no longer recorded, those
+// recorded before are removed.
+func PurgeTerraformConfigHistory(_ context.Context, tx *sql.Tx) error {
+	stmt := `
+DELETE FROM config_history WHERE key LIKE 'tfstate-%' OR key LIKE 'tflock-%';
+  `
+
+	_, err := tx.Exec(stmt)
+
+	return err
+}
+
+// NodeConfigHistorySchemaUpdate is schema for table node_config_history
+func NodeConfigHistorySchemaUpdate(_ context.Context, tx *sql.Tx) error {
+	stmt := `
+CREATE TABLE node_config_history (
+  id                            INTEGER  PRIMARY KEY AUTOINCREMENT NOT NULL,
+  node_id                       INTEGER  NOT  NULL,
+  key                           TEXT     NOT  NULL,
+  old_value                     TEXT,
+  new_value                     TEXT,
+  changed_at                    TIMESTAMP NOT NULL,
+  FOREIGN KEY (node_id) REFERENCES "nodes" (id) ON DELETE CASCADE
+);
+
+CREATE INDEX node_config_history_node_key ON node_config_history (node_id, key, id);
+CREATE INDEX node_config_history_changed_at ON node_config_history (changed_at);
+  `
+
+	_, err := tx.Exec(stmt)
+
+	return err
+}
@@ -0,0 +1,124 @@
+package database
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// secretPrefix marks a value encrypted with the secrets key, and the version
+// of the encryption scheme. Values without it were stored in plaintext.
+const secretPrefix = "enc:v1:"
+
+// minSecretsKeyLength is the minimum size of the secrets key file.
+const minSecretsKeyLength = 32
+
+// secrets holds the cipher secret values are encrypted with.
+var secrets struct {
+	mu   sync.RWMutex
+	aead cipher.AEAD
+}
+
+// LoadSecretsKey configures the key secret values are encrypted with at
+// rest. The key is derived from the contents of keyFile, which every cluster
+// member must share. Without a key file, secrets are stored in plaintext.
+func LoadSecretsKey(keyFile string) error {
+	if keyFile == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		return fmt.Errorf("Failed to read secrets key: %w", err)
+	}
+
+	if len(data) < minSecretsKeyLength {
+		return fmt.Errorf("Secrets key %q is shorter than %d bytes", keyFile, minSecretsKeyLength)
+	}
+
+	key := sha256.Sum256(data)
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return fmt.Errorf("Failed to create secrets cipher: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return fmt.Errorf("Failed to create secrets cipher: %w", err)
+	}
+
+	secrets.mu.Lock()
+	defer secrets.mu.Unlock()
+
+	secrets.aead = aead
+
+	return nil
+}
+
+// SecretsEncrypted returns whether a secrets key is configured.
+func SecretsEncrypted() bool {
+	secrets.mu.RLock()
+	defer secrets.mu.RUnlock()
+
+	return secrets.aead != nil
+}
+
+// EncryptSecret returns the value to store for the given secret, encrypted
+// if a secrets key is configured.
+func EncryptSecret(value string) (string, error) {
+	secrets.mu.RLock()
+	defer secrets.mu.RUnlock()
+
+	if secrets.aead == nil {
+		return value, nil
+	}
+
+	nonce := make([]byte, secrets.aead.NonceSize())
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return "", fmt.Errorf("Failed to generate nonce: %w", err)
+	}
+
+	sealed := secrets.aead.Seal(nonce, nonce, []byte(value), nil)
+
+	return secretPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptSecret returns the secret held by a stored value. legacy is set if
+// the value was stored in plaintext while a secrets key is configured, in
+// which case it should be stored again encrypted.
+func DecryptSecret(stored string) (value string, legacy bool, err error) {
+	secrets.mu.RLock()
+	defer secrets.mu.RUnlock()
+
+	if !strings.HasPrefix(stored, secretPrefix) {
+		return stored, secrets.aead != nil, nil
+	}
+
+	if secrets.aead == nil {
+		return "", false, fmt.Errorf("Secret is encrypted but no secrets key is configured")
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(stored, secretPrefix))
+	if err != nil {
+		return "", false, fmt.Errorf("Failed to decode secret: %w", err)
+	}
+
+	nonceSize := secrets.aead.NonceSize()
+	if len(sealed) < nonceSize {
+		return "", false, fmt.Errorf("Secret is too short")
+	}
+
+	plain, err := secrets.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], nil)
+	if err != nil {
+		return "", false, fmt.Errorf("Failed to decrypt secret: %w", err)
+	}
+
+	return string(plain), false, nil
+}
@@ -0,0 +1,90 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeSecretsKey writes a secrets key file and loads it, the key is
+// unloaded when the test ends.
+func writeSecretsKey(t *testing.T, key string) {
+	t.Helper()
+
+	t.Cleanup(func() {
+		secrets.mu.Lock()
+		defer secrets.mu.Unlock()
+
+		secrets.aead = nil
+	})
+
+	keyFile := filepath.Join(t.TempDir(), "secrets.key")
+	err := os.WriteFile(keyFile, []byte(key), 0o600)
+	if err != nil {
+		t.Fatalf("Failed to write secrets key: %v", err)
+	}
+
+	err = LoadSecretsKey(keyFile)
+	if err != nil {
+		t.Fatalf("Failed to load secrets key: %v", err)
+	}
+}
+
+func TestSecretRoundTrip(t *testing.T) {
+	writeSecretsKey(t, strings.Repeat("k", minSecretsKeyLength))
+
+	stored, err := EncryptSecret("token")
+	if err != nil {
+		t.Fatalf("Failed to encrypt secret: %v", err)
+	}
+
+	if !strings.HasPrefix(stored, secretPrefix) || strings.Contains(stored, "token") {
+		t.Fatalf("Stored secret %q is not encrypted", stored)
+	}
+
+	value, legacy, err := DecryptSecret(stored)
+	if err != nil {
+		t.Fatalf("Failed to decrypt secret: %v", err)
+	}
+
+	if value != "token" || legacy {
+		t.Errorf("Decrypted secret is %q with legacy %v, expected %q without", value, legacy, "token")
+	}
+
+	value, legacy, err = DecryptSecret("plaintext")
+	if err != nil {
+		t.Fatalf("Failed to read plaintext secret: %v", err)
+	}
+
+	if value != "plaintext" || !legacy {
+		t.Errorf("Plaintext secret read as %q with legacy %v, expected %q with", value, legacy, "plaintext")
+	}
+}
+
+func TestSecretsKeyMissing(t *testing.T) {
+	err := LoadSecretsKey(filepath.Join(t.TempDir(), "missing.key"))
+	if err == nil {
+		t.Error("Expected a missing secrets key file to fail")
+	}
+
+	keyFile := filepath.Join(t.TempDir(), "short.key")
+	err = os.WriteFile(keyFile, []byte("short"), 0o600)
+	if err != nil {
+		t.Fatalf("Failed to write secrets key: %v", err)
+	}
+
+	err = LoadSecretsKey(keyFile)
+	if err == nil {
+		t.Error("Expected a short secrets key to fail")
+	}
+
+	if SecretsEncrypted() {
+		t.Fatal("Secrets are encrypted after failing to load the key")
+	}
+
+	_, _, err = DecryptSecret(secretPrefix + "c2VjcmV0")
+	if err == nil {
+		t.Error("Expected decrypting a secret without a key to fail")
+	}
+}
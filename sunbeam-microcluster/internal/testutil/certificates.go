@@ -0,0 +1,78 @@
+package testutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// NewClientCA writes the bundle of a new CA to a file and returns its path
+// along with a function issuing client certificates with the given common
+// name from the CA, along with their key.
+func NewClientCA(t *testing.T) (string, func(commonName string) tls.Certificate) {
+	t.Helper()
+
+	ca, key := newCertificate(t, "Test CA", nil, nil)
+
+	caFile := filepath.Join(t.TempDir(), "client-ca.crt")
+	err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw}), 0600)
+	if err != nil {
+		t.Fatalf("Failed to write client CA bundle: %v", err)
+	}
+
+	return caFile, func(commonName string) tls.Certificate {
+		cert, certKey := newCertificate(t, commonName, ca, key)
+
+		return tls.Certificate{Certificate: [][]byte{cert.Raw}, PrivateKey: certKey, Leaf: cert}
+	}
+}
+
+// newCertificate returns a certificate with the given common name and its
+// key. The certificate is a CA certificate signed by itself if parent is nil,
+// and otherwise a client certificate signed by parent with parentKey.
+func newCertificate(t *testing.T, commonName string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign
+		parent, parentKey = template, key
+	} else {
+		template.KeyUsage = x509.KeyUsageDigitalSignature
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatalf("Failed to create certificate %q: %v", commonName, err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate %q: %v", commonName, err)
+	}
+
+	return cert, key
+}
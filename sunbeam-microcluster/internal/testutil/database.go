@@ -0,0 +1,147 @@
+// Package testutil holds the fixtures shared by the tests of the daemon: a
+// SQLite database standing in for dqlite and the state of a cluster member
+// backed by it.
+package testutil
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/canonical/microcluster/cluster"
+	// Register the driver of the databases used in tests.
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/database"
+)
+
+const (
+	// schemaTypeInternal and schemaTypeExternal are the types MicroCluster
+	// records its own schema updates and extension updates with in its
+	// schemas table.
+	schemaTypeInternal = 0
+	schemaTypeExternal = 1
+)
+
+// testInternalSchema creates the tables MicroCluster manages itself, as they
+// are once its own schema updates are applied.
+const testInternalSchema = `
+CREATE TABLE schemas (
+  id          INTEGER    PRIMARY  KEY    AUTOINCREMENT  NOT  NULL,
+  version     INTEGER    NOT      NULL,
+  type        INTEGER    NOT      NULL,
+  updated_at  DATETIME   NOT      NULL,
+  UNIQUE      (version,  type)
+);
+
+CREATE TABLE internal_token_records (
+  id           INTEGER         PRIMARY  KEY    AUTOINCREMENT  NOT  NULL,
+  name         TEXT            NOT      NULL,
+  secret       TEXT            NOT      NULL,
+  UNIQUE       (name),
+  UNIQUE       (secret)
+);
+
+CREATE TABLE internal_cluster_members (
+  id                   INTEGER   PRIMARY  KEY    AUTOINCREMENT  NOT  NULL,
+  name                 TEXT      NOT      NULL,
+  address              TEXT      NOT      NULL,
+  certificate          TEXT      NOT      NULL,
+  schema_internal      INTEGER   NOT      NULL,
+  schema_external      INTEGER   NOT      NULL,
+  heartbeat            DATETIME  NOT      NULL,
+  role                 TEXT      NOT      NULL,
+  UNIQUE(name),
+  UNIQUE(certificate)
+);
+`
+
+// testInternalSchemaVersion is the number of MicroCluster's own schema
+// updates testInternalSchema stands for.
+const testInternalSchemaVersion = 2
+
+// NewDB returns a database for tests, along with the path of its file. It
+// has the tables MicroCluster manages and every schema extension applied, as
+// recorded in the schemas table, and the statements registered by the
+// database package are prepared against it. The database is closed when the test
+// ends. Prepared statements are shared by the whole process, so tests using
+// it must not run in parallel.
+func NewDB(t *testing.T) (*sql.DB, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "database.db")
+	db := OpenDB(t, path)
+
+	err := applyTestSchema(context.Background(), db)
+	if err != nil {
+		t.Fatalf("Failed to create the test database schema: %v", err)
+	}
+
+	err = cluster.PrepareStmts(db, statementsProject(), false)
+	if err != nil {
+		t.Fatalf("Failed to prepare statements: %v", err)
+	}
+
+	return db, path
+}
+
+// OpenDB opens a handle on the test database at the given path, closed
+// when the test ends. Foreign keys are enforced, as they are by dqlite.
+func OpenDB(t *testing.T, path string) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?_foreign_keys=1&_busy_timeout=5000&_journal_mode=WAL", path))
+	if err != nil {
+		t.Fatalf("Failed to open the test database: %v", err)
+	}
+
+	t.Cleanup(func() { _ = db.Close() })
+
+	return db
+}
+
+// applyTestSchema creates the tables of MicroCluster, then applies and
+// records each schema extension as MicroCluster does.
+func applyTestSchema(ctx context.Context, db *sql.DB) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = tx.Rollback() }()
+
+	_, err = tx.ExecContext(ctx, testInternalSchema)
+	if err != nil {
+		return err
+	}
+
+	for version := 1; version <= testInternalSchemaVersion; version++ {
+		_, err = tx.ExecContext(ctx, `INSERT INTO schemas (version, type, updated_at) VALUES (?, ?, strftime("%s"))`, version, schemaTypeInternal)
+		if err != nil {
+			return err
+		}
+	}
+
+	for i, update := range database.SchemaExtensions {
+		err = update(ctx, tx)
+		if err != nil {
+			return fmt.Errorf("Failed to apply schema extension %d (%s): %w", i+1, database.SchemaExtensionName(i), err)
+		}
+
+		_, err = tx.ExecContext(ctx, `INSERT INTO schemas (version, type, updated_at) VALUES (?, ?, strftime("%s"))`, i+1, schemaTypeExternal)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// statementsProject returns the project the statements of the database
+// package are registered under, which MicroCluster derives from the path of
+// the file registering them, the same for every package of the module.
+func statementsProject() string {
+	return cluster.GetCallerProject()
+}
@@ -0,0 +1,129 @@
+package testutil
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/canonical/lxd/lxd/db/query"
+	"github.com/canonical/lxd/shared/api"
+	"github.com/canonical/microcluster/cluster"
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/database"
+)
+
+// MemberName and MemberAddress are the name and address of the cluster
+// member of the states returned by NewState.
+const (
+	MemberName    = "member1"
+	MemberAddress = "10.0.0.1:7000"
+)
+
+// Backend is a database backend running against a database from NewDB.
+// Transactions are attempted once, busy errors are left to the caller.
+type Backend struct {
+	// DB is the database transactions run against.
+	DB *sql.DB
+
+	// Leader is the address reported as the one of the dqlite leader.
+	Leader string
+
+	t    *testing.T
+	path string
+}
+
+// Transaction runs f in a transaction of the database.
+func (b *Backend) Transaction(ctx context.Context, f func(context.Context, *sql.Tx) error) error {
+	return query.Transaction(ctx, b.DB, f)
+}
+
+// LeaderAddress returns the address set as the one of the leader.
+func (b *Backend) LeaderAddress(_ context.Context) (string, error) {
+	return b.Leader, nil
+}
+
+// Open opens a handle of its own on the database, closed when the test ends
+// if the caller does not close it first.
+func (b *Backend) Open(_ context.Context) (*sql.DB, error) {
+	return OpenDB(b.t, b.path), nil
+}
+
+// NewState returns the state of a cluster member for tests, whose context
+// carries a Backend on a database from NewDB in which the member is
+// recorded as the only cluster member. The member is the dqlite leader.
+func NewState(t *testing.T) *state.State {
+	t.Helper()
+
+	db, path := NewDB(t)
+	backend := &Backend{DB: db, Leader: MemberAddress, t: t, path: path}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	s := &state.State{
+		Context: database.WithBackend(ctx, backend),
+		Name:    func() string { return MemberName },
+		Address: func() *api.URL { return api.NewURL().Scheme("https").Host(MemberAddress) },
+	}
+
+	AddMember(t, s, MemberName, MemberAddress)
+
+	return s
+}
+
+// StateBackend returns the Backend of a state returned by NewState.
+func StateBackend(t *testing.T, s *state.State) *Backend {
+	t.Helper()
+
+	backend, ok := database.BackendFromContext(s.Context)
+	if !ok {
+		t.Fatal("State has no database backend")
+	}
+
+	testBackend, ok := backend.(*Backend)
+	if !ok {
+		t.Fatalf("State has a database backend of type %T, expected a test backend", backend)
+	}
+
+	return testBackend
+}
+
+// AddMember records a cluster member with the given name and address in the
+// database of a state returned by NewState, as MicroCluster does when a
+// member joins.
+func AddMember(t *testing.T, s *state.State, name string, address string) {
+	t.Helper()
+
+	err := StateBackend(t, s).Transaction(s.Context, func(ctx context.Context, tx *sql.Tx) error {
+		_, err := cluster.CreateInternalClusterMember(ctx, tx, cluster.InternalClusterMember{
+			Name:           name,
+			Address:        address,
+			Certificate:    "certificate of " + name,
+			SchemaInternal: 2,
+			SchemaExternal: uint64(len(database.SchemaExtensions)),
+			Heartbeat:      time.Now().UTC(),
+			Role:           cluster.Role("voter"),
+		})
+
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Failed to add cluster member %q: %v", name, err)
+	}
+}
+
+// RemoveMember deletes the cluster member at the given address from the
+// database of a state returned by NewState, as MicroCluster does when a
+// member is removed.
+func RemoveMember(t *testing.T, s *state.State, address string) {
+	t.Helper()
+
+	err := StateBackend(t, s).Transaction(s.Context, func(ctx context.Context, tx *sql.Tx) error {
+		return cluster.DeleteInternalClusterMember(ctx, tx, address)
+	})
+	if err != nil {
+		t.Fatalf("Failed to remove cluster member at %q: %v", address, err)
+	}
+}
@@ -0,0 +1,59 @@
+package sunbeam
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/canonical/lxd/shared/api"
+	"github.com/canonical/lxd/shared/logger"
+	"github.com/canonical/microcluster/state"
+)
+
+// apiAccessLogKey is the config key holding whether API requests are logged.
+// Unset is enabled.
+const apiAccessLogKey = "api.access-log"
+
+// apiAccessLog holds whether API requests are logged, read from
+// apiAccessLogKey at most every apiRateLimitRefresh.
+var apiAccessLog = struct {
+	mu        sync.Mutex
+	enabled   bool
+	refreshed time.Time
+}{
+	enabled: true,
+}
+
+// AccessLogEnabled returns whether API requests are logged, as set by the
+// api.access-log config key. The previous setting is kept if the key cannot
+// be read.
+func AccessLogEnabled(s *state.State) bool {
+	apiAccessLog.mu.Lock()
+	if time.Since(apiAccessLog.refreshed) < apiRateLimitRefresh {
+		enabled := apiAccessLog.enabled
+		apiAccessLog.mu.Unlock()
+
+		return enabled
+	}
+
+	// Concurrent requests keep using the previous setting while it is read.
+	apiAccessLog.refreshed = time.Now()
+	enabled := apiAccessLog.enabled
+	apiAccessLog.mu.Unlock()
+
+	value, err := GetConfig(s, apiAccessLogKey)
+	if err == nil {
+		enabled = value != "false"
+	} else if api.StatusErrorCheck(err, http.StatusNotFound) {
+		enabled = true
+	} else {
+		logger.Warn("Failed to read API access log setting", logger.Ctx{"key": apiAccessLogKey, "err": err})
+	}
+
+	apiAccessLog.mu.Lock()
+	defer apiAccessLog.mu.Unlock()
+
+	apiAccessLog.enabled = enabled
+
+	return enabled
+}
@@ -0,0 +1,44 @@
+package sunbeam
+
+import (
+	"testing"
+	"time"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/internal/testutil"
+)
+
+func TestAccessLogEnabled(t *testing.T) {
+	s := testutil.NewState(t)
+
+	// Forget the setting read by earlier requests, so that the key is read
+	// again.
+	reset := func() {
+		apiAccessLog.mu.Lock()
+		defer apiAccessLog.mu.Unlock()
+
+		apiAccessLog.enabled = true
+		apiAccessLog.refreshed = time.Time{}
+	}
+
+	reset()
+	t.Cleanup(reset)
+
+	if !AccessLogEnabled(s) {
+		t.Fatalf("Expected access logging to be enabled while the key is unset")
+	}
+
+	err := UpdateConfig(s, apiAccessLogKey, "false")
+	if err != nil {
+		t.Fatalf("Failed to disable access logging: %v", err)
+	}
+
+	if !AccessLogEnabled(s) {
+		t.Errorf("Expected the setting to be kept until it is refreshed")
+	}
+
+	reset()
+
+	if AccessLogEnabled(s) {
+		t.Errorf("Expected access logging to be disabled once the key is read again")
+	}
+}
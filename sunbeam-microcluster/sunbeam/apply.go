@@ -0,0 +1,52 @@
+package sunbeam
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"sort"
+
+	"github.com/canonical/lxd/shared/api"
+	"github.com/canonical/lxd/shared/logger"
+	"github.com/canonical/microcluster/state"
+)
+
+// Apply records a manifest along with the config changes accompanying it in
+// a single transaction, either everything is applied or nothing is.
+func Apply(s *state.State, manifestid string, data string, config map[string]string) error {
+	if manifestid == "" {
+		return api.StatusErrorf(http.StatusBadRequest, "Manifest ID must not be empty")
+	}
+
+	// Apply the config keys in a stable order.
+	keys := make([]string, 0, len(config))
+	for key := range config {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		err := addManifest(ctx, tx, manifestid, data)
+		if err != nil {
+			return err
+		}
+
+		for _, key := range keys {
+			err = updateConfig(ctx, tx, key, config[key])
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Only announce the manifest once it is committed.
+	logger.Info("Applied manifest", logger.Ctx{"manifestid": manifestid, "config": keys})
+
+	return nil
+}
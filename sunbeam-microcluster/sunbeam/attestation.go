@@ -0,0 +1,121 @@
+package sunbeam
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"github.com/canonical/lxd/shared/api"
+	"github.com/canonical/lxd/shared/logger"
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/database"
+)
+
+// attestationModeKey is the config key controlling system_id verification
+// at join. Supported values are "disabled" (default), "warn" and "enforce".
+const attestationModeKey = "attestation.mode"
+
+const (
+	attestationDisabled = "disabled"
+	attestationWarn     = "warn"
+	attestationEnforce  = "enforce"
+)
+
+// ListAllowedSystemIDs returns the system_ids approved to join the cluster
+func ListAllowedSystemIDs(s *state.State) ([]string, error) {
+	var systemIDs []string
+
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		var err error
+		systemIDs, err = database.GetAllowedSystemIDs(ctx, tx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return systemIDs, nil
+}
+
+// AddAllowedSystemID approves a system_id to join the cluster
+func AddAllowedSystemID(s *state.State, systemID string) error {
+	if systemID == "" {
+		return api.StatusErrorf(http.StatusBadRequest, "System ID must not be empty")
+	}
+
+	return transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		err := database.CreateAllowedSystemID(ctx, tx, systemID)
+		if err != nil {
+			return err
+		}
+
+		return recordChange(ctx, tx, "allowed_system_ids", systemID, database.ChangeCreate)
+	})
+}
+
+// DeleteAllowedSystemID revokes the approval of a system_id
+func DeleteAllowedSystemID(s *state.State, systemID string) error {
+	return transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		err := database.DeleteAllowedSystemID(ctx, tx, systemID)
+		if err != nil {
+			return err
+		}
+
+		return recordChange(ctx, tx, "allowed_system_ids", systemID, database.ChangeDelete)
+	})
+}
+
+// VerifySystemID checks the system_id a member reports in its join config
+// against the allowlist, as it joins. The member vouches for the system_id
+// itself, nodes registering through a join token are also checked by the
+// member consuming the token.
+func VerifySystemID(s *state.State, systemID string) error {
+	return transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		return verifyNodeSystemID(ctx, tx, s.Name(), systemID)
+	})
+}
+
+// verifyNodeSystemID checks the system_id of the named node against the
+// allowlist, within the given transaction. Besides a joining member checking
+// the system_id it reports, it runs on the member issuing or consuming a join
+// token, so that registering nodes do not vouch for themselves. Depending on the attestation mode an
+// unlisted system_id is either rejected, only logged, or not checked at all.
+func verifyNodeSystemID(ctx context.Context, tx *sql.Tx, name string, systemID string) error {
+	mode, err := effectiveConfigValue(ctx, tx, attestationModeKey)
+	if err != nil {
+		if !api.StatusErrorCheck(err, http.StatusNotFound) {
+			return err
+		}
+
+		mode = attestationDisabled
+	}
+
+	if mode == attestationDisabled {
+		return nil
+	}
+
+	if mode != attestationWarn && mode != attestationEnforce {
+		return fmt.Errorf("Invalid %q value %q", attestationModeKey, mode)
+	}
+
+	allowed := false
+	if systemID != "" {
+		allowed, err = database.AllowedSystemIDExists(ctx, tx, systemID)
+		if err != nil {
+			return err
+		}
+	}
+
+	if allowed {
+		return nil
+	}
+
+	if mode == attestationWarn {
+		logger.Warn("Node joining with unlisted system_id", logger.Ctx{"name": name, "systemid": systemID})
+		return nil
+	}
+
+	return api.StatusErrorf(http.StatusForbidden, "System ID %q is not in the allowlist", systemID)
+}
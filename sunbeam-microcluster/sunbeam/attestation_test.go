@@ -0,0 +1,45 @@
+package sunbeam
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/canonical/lxd/shared/api"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/internal/testutil"
+)
+
+func TestVerifySystemID(t *testing.T) {
+	s := testutil.NewState(t)
+
+	err := AddAllowedSystemID(s, "allowed")
+	if err != nil {
+		t.Fatalf("Failed to allow system_id: %v", err)
+	}
+
+	tests := []struct {
+		mode     string
+		systemID string
+		valid    bool
+	}{
+		{mode: "disabled", systemID: "rogue", valid: true},
+		{mode: "warn", systemID: "rogue", valid: true},
+		{mode: "enforce", systemID: "allowed", valid: true},
+		{mode: "enforce", systemID: "rogue", valid: false},
+		{mode: "enforce", systemID: "", valid: false},
+	}
+
+	for _, test := range tests {
+		err := UpdateConfig(s, attestationModeKey, test.mode)
+		if err != nil {
+			t.Fatalf("Failed to set attestation mode: %v", err)
+		}
+
+		err = VerifySystemID(s, test.systemID)
+		if test.valid && err != nil {
+			t.Errorf("Expected system_id %q to be accepted in %s mode, got %v", test.systemID, test.mode, err)
+		} else if !test.valid && !api.StatusErrorCheck(err, http.StatusForbidden) {
+			t.Errorf("Expected system_id %q to be rejected with 403 in %s mode, got %v", test.systemID, test.mode, err)
+		}
+	}
+}
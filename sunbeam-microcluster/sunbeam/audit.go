@@ -0,0 +1,53 @@
+package sunbeam
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/api/types"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/database"
+)
+
+// RecordAudit records an action performed by actor against target.
+func RecordAudit(s *state.State, actor string, action string, target string) error {
+	return transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		_, err := database.CreateAuditEntry(ctx, tx, actor, action, target)
+		if err != nil {
+			return fmt.Errorf("Failed to record audit entry: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// ListAudit returns the audit entries matching the filter.
+func ListAudit(s *state.State, filter database.AuditEntryFilter) (types.AuditEntries, error) {
+	entries := types.AuditEntries{}
+
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		records, err := database.GetAuditEntries(ctx, tx, filter)
+		if err != nil {
+			return err
+		}
+
+		for _, record := range records {
+			entries = append(entries, types.AuditEntry{
+				ID:        record.ID,
+				Timestamp: record.Timestamp,
+				Actor:     record.Actor,
+				Action:    record.Action,
+				Target:    record.Target,
+			})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
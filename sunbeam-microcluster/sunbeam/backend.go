@@ -0,0 +1,42 @@
+package sunbeam
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/database"
+)
+
+// memberBackend is the dqlite database MicroCluster manages for the member.
+type memberBackend struct {
+	s *state.State
+}
+
+// Transaction runs f in a transaction of the dqlite database.
+func (b memberBackend) Transaction(ctx context.Context, f func(context.Context, *sql.Tx) error) error {
+	return b.s.Database.Transaction(ctx, f)
+}
+
+// LeaderAddress returns the address of the dqlite leader.
+func (b memberBackend) LeaderAddress(ctx context.Context) (string, error) {
+	return dqliteLeaderAddress(ctx, b.s)
+}
+
+// Open opens a database handle of its own on the dqlite database.
+func (b memberBackend) Open(ctx context.Context) (*sql.DB, error) {
+	return openDqlite(ctx, b.s)
+}
+
+// databaseBackend returns the backend the state accesses its database
+// through: the one carried by its context if any, and otherwise the dqlite
+// database of the member.
+func databaseBackend(s *state.State) database.Backend {
+	backend, ok := database.BackendFromContext(s.Context)
+	if ok {
+		return backend
+	}
+
+	return memberBackend{s: s}
+}
@@ -0,0 +1,369 @@
+package sunbeam
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/canonical/lxd/shared/api"
+	"github.com/canonical/microcluster/cluster"
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/api/types"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/database"
+)
+
+// ExportCluster returns a snapshot of the nodes, config, juju users and
+// manifests, read in a single transaction not bound by the transaction
+// timeout. Juju user tokens are left out unless includeSecrets is set.
+// Nothing is written, tokens stored in plaintext are not encrypted on the
+// way.
+func ExportCluster(s *state.State, includeSecrets bool) (types.ClusterExport, error) {
+	export := types.ClusterExport{
+		Nodes:     types.Nodes{},
+		Config:    map[string]string{},
+		JujuUsers: types.JujuUsers{},
+		Manifests: types.Manifests{},
+	}
+
+	err := bulkTransaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		var err error
+		export.SchemaVersion, err = database.GetSchemaExtensionsVersion(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		nodes, err := database.GetNodes(ctx, tx)
+		if err != nil {
+			return fmt.Errorf("Failed to fetch nodes: %w", err)
+		}
+
+		roles, err := database.GetNodeRoles(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		for _, node := range nodes {
+			export.Nodes = append(export.Nodes, nodeFromRecord(node, roles[node.ID]))
+		}
+
+		items, err := database.GetConfigItems(ctx, tx)
+		if err != nil {
+			return fmt.Errorf("Failed to fetch config: %w", err)
+		}
+
+		for _, item := range items {
+			export.Config[item.Key] = item.Value
+		}
+
+		users, err := database.GetJujuUsers(ctx, tx)
+		if err != nil {
+			return fmt.Errorf("Failed to fetch juju users: %w", err)
+		}
+
+		for _, user := range users {
+			jujuUser := types.JujuUser{
+				Username:  user.Username,
+				ExpiresAt: jujuUserExpiry(user),
+				Expired:   jujuUserExpired(user),
+			}
+
+			if includeSecrets {
+				jujuUser.Token, _, err = database.DecryptSecret(user.Token)
+				if err != nil {
+					return fmt.Errorf("Failed to read token of juju user %q: %w", user.Username, err)
+				}
+			}
+
+			export.JujuUsers = append(export.JujuUsers, jujuUser)
+		}
+
+		manifests, err := database.GetManifestItems(ctx, tx)
+		if err != nil {
+			return fmt.Errorf("Failed to fetch manifests: %w", err)
+		}
+
+		sortManifestItems(manifests)
+
+		for _, manifest := range manifests {
+			data, err := manifest.Content()
+			if err != nil {
+				return err
+			}
+
+			export.Manifests = append(export.Manifests, types.Manifest{
+				ManifestID:       manifest.ManifestID,
+				AppliedDate:      manifestAppliedDate(manifest),
+				Data:             data,
+				AppliedByVersion: manifest.AppliedByVersion,
+				RolledBackFrom:   manifest.RolledBackFrom,
+			})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return types.ClusterExport{}, err
+	}
+
+	return export, nil
+}
+
+const (
+	// importMerge keeps the rows missing from an import, rows present in
+	// both are overwritten.
+	importMerge = "merge"
+	// importReplace deletes the rows missing from an import.
+	importReplace = "replace"
+)
+
+// ImportCluster restores the nodes, config and manifests of a snapshot taken
+// by ExportCluster, in a single transaction not bound by the transaction
+// timeout. The snapshot must have been taken at the running schema version.
+// In merge mode, the existing rows not in the snapshot are kept, in replace
+// mode they are deleted. The mode defaults to merge. Juju users are not
+// restored. Restored nodes have an unknown status until their cluster member
+// heartbeats.
+func ImportCluster(s *state.State, export types.ClusterExport, mode string) error {
+	if mode == "" {
+		mode = importMerge
+	}
+
+	if mode != importMerge && mode != importReplace {
+		return api.StatusErrorf(http.StatusBadRequest, "Import mode must be %q or %q", importMerge, importReplace)
+	}
+
+	appliedAt := make([]int64, 0, len(export.Manifests))
+	for _, manifest := range export.Manifests {
+		t, err := time.Parse(time.RFC3339Nano, manifest.AppliedDate)
+		if err != nil {
+			return api.StatusErrorf(http.StatusBadRequest, "Invalid applied date of manifest %q: %v", manifest.ManifestID, err)
+		}
+
+		appliedAt = append(appliedAt, t.UnixNano())
+	}
+
+	return bulkTransaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		version, err := database.GetSchemaExtensionsVersion(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		if export.SchemaVersion != version {
+			return api.StatusErrorf(http.StatusConflict, "Export was taken at schema version %d, the running schema version is %d", export.SchemaVersion, version)
+		}
+
+		if mode == importReplace {
+			err = clearImportedTables(ctx, tx, export)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = importNodes(ctx, tx, s.Name(), export.Nodes)
+		if err != nil {
+			return err
+		}
+
+		keys := make([]string, 0, len(export.Config))
+		for key := range export.Config {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			err = updateConfig(ctx, tx, key, export.Config[key])
+			if err != nil {
+				return err
+			}
+		}
+
+		for i, manifest := range export.Manifests {
+			err = importManifest(ctx, tx, manifest, appliedAt[i])
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// clearImportedTables deletes the nodes, config items and manifests missing
+// from the given snapshot.
+func clearImportedTables(ctx context.Context, tx *sql.Tx, export types.ClusterExport) error {
+	imported := map[string]bool{}
+	for _, node := range export.Nodes {
+		imported[node.Name] = true
+	}
+
+	nodes, err := database.GetNodes(ctx, tx)
+	if err != nil {
+		return fmt.Errorf("Failed to fetch nodes: %w", err)
+	}
+
+	for _, node := range nodes {
+		if imported[node.Name] {
+			continue
+		}
+
+		err = database.DeleteNode(ctx, tx, node.Name)
+		if err != nil {
+			return fmt.Errorf("Failed to delete node %q: %w", node.Name, err)
+		}
+
+		err = recordChange(ctx, tx, "nodes", node.Name, database.ChangeDelete)
+		if err != nil {
+			return err
+		}
+	}
+
+	keys, err := database.GetConfigItemKeys(ctx, tx, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		_, ok := export.Config[key]
+		if ok {
+			continue
+		}
+
+		err = deleteConfig(ctx, tx, key)
+		if err != nil {
+			return err
+		}
+	}
+
+	imported = map[string]bool{}
+	for _, manifest := range export.Manifests {
+		imported[manifest.ManifestID] = true
+	}
+
+	manifests, err := database.GetManifestItems(ctx, tx)
+	if err != nil {
+		return fmt.Errorf("Failed to fetch manifests: %w", err)
+	}
+
+	for _, manifest := range manifests {
+		if imported[manifest.ManifestID] {
+			continue
+		}
+
+		err = database.DeleteManifestItem(ctx, tx, manifest.ManifestID)
+		if err != nil {
+			return fmt.Errorf("Failed to delete manifest %q: %w", manifest.ManifestID, err)
+		}
+
+		err = recordChange(ctx, tx, "manifest", manifest.ManifestID, database.ChangeDelete)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// importNodes creates or overwrites the given nodes. A node is bound to the
+// cluster member of the same name if there is one, to the local member
+// otherwise.
+func importNodes(ctx context.Context, tx *sql.Tx, localMember string, nodes types.Nodes) error {
+	members, err := cluster.GetInternalClusterMembers(ctx, tx)
+	if err != nil {
+		return fmt.Errorf("Failed to fetch cluster members: %w", err)
+	}
+
+	memberNames := make(map[string]bool, len(members))
+	for _, member := range members {
+		memberNames[member.Name] = true
+	}
+
+	for _, node := range nodes {
+		role := nodeRoles(node.Role)
+		record := database.Node{
+			Member:         localMember,
+			Name:           node.Name,
+			Role:           database.LegacyRole(role),
+			MachineID:      node.MachineID,
+			SystemID:       node.SystemID,
+			Owner:          node.Owner,
+			Cordoned:       node.Cordoned,
+			CPUCount:       node.CPUCount,
+			MemoryMB:       node.MemoryMB,
+			DiskGB:         node.DiskGB,
+			LastManifestID: node.LastManifestID,
+			Status:         database.NodeStatusUnknown,
+			Metadata:       encodeNodeMetadata(node.Metadata),
+			CreatedAt:      node.CreatedAt,
+			UpdatedAt:      node.UpdatedAt,
+		}
+
+		if memberNames[node.Name] {
+			record.Member = node.Name
+		}
+
+		var id int64
+		existing, err := database.GetNode(ctx, tx, node.Name)
+		if err == nil {
+			id = int64(existing.ID)
+			err = database.UpdateNode(ctx, tx, node.Name, record)
+			if err != nil {
+				return fmt.Errorf("Failed to update node %q: %w", node.Name, err)
+			}
+
+			err = recordChange(ctx, tx, "nodes", node.Name, database.ChangeUpdate)
+		} else if api.StatusErrorCheck(err, http.StatusNotFound) {
+			id, err = database.CreateNode(ctx, tx, record)
+			if err != nil {
+				return fmt.Errorf("Failed to record node %q: %w", node.Name, err)
+			}
+
+			err = recordChange(ctx, tx, "nodes", node.Name, database.ChangeCreate)
+		}
+		if err != nil {
+			return err
+		}
+
+		err = database.SetNodeRoles(ctx, tx, int(id), role)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// importManifest records a manifest applied at the given time, in Unix
+// nanoseconds. An existing manifest of the same id is kept if its data
+// matches and overwritten otherwise.
+func importManifest(ctx context.Context, tx *sql.Tx, manifest types.Manifest, appliedAt int64) error {
+	existing, err := database.GetManifestItem(ctx, tx, manifest.ManifestID)
+	if err == nil {
+		if existing.Checksum == database.ManifestChecksum(manifest.Data) {
+			return nil
+		}
+
+		err = database.DeleteManifestItem(ctx, tx, manifest.ManifestID)
+		if err != nil {
+			return fmt.Errorf("Failed to delete manifest %q: %w", manifest.ManifestID, err)
+		}
+	} else if !api.StatusErrorCheck(err, http.StatusNotFound) {
+		return err
+	}
+
+	_, err = database.CreateManifestItem(ctx, tx, database.ManifestItem{ManifestID: manifest.ManifestID, AppliedAt: appliedAt, Data: manifest.Data, AppliedByVersion: manifest.AppliedByVersion, RolledBackFrom: manifest.RolledBackFrom})
+	if err != nil {
+		return fmt.Errorf("Failed to record manifest %q: %w", manifest.ManifestID, err)
+	}
+
+	action := database.ChangeCreate
+	if existing != nil {
+		action = database.ChangeUpdate
+	}
+
+	return recordChange(ctx, tx, "manifest", manifest.ManifestID, action)
+}
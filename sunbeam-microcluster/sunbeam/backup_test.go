@@ -0,0 +1,212 @@
+package sunbeam
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/canonical/lxd/shared/api"
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/api/types"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/internal/testutil"
+)
+
+// addTestClusterState adds nodes, config, a juju user and manifests.
+func addTestClusterState(t *testing.T, s *state.State) {
+	t.Helper()
+
+	addTestNodes(t, s, map[string][]string{"node1": {"control"}, "node2": {"compute", "storage"}}, "node1", "node2")
+
+	err := SetConfigBatch(s, map[string]string{"backup.a": "1", "backup.b": "2"})
+	if err != nil {
+		t.Fatalf("Failed to set config: %v", err)
+	}
+
+	err = AddJujuUser(s, "alice", "token")
+	if err != nil {
+		t.Fatalf("Failed to add juju user: %v", err)
+	}
+
+	addTestManifests(t, s, "m1", "m2")
+}
+
+// exportedState returns the parts of an export an import restores, in a
+// comparable form.
+func exportedState(export types.ClusterExport) (map[string]string, map[string]string, []string) {
+	nodes := map[string]string{}
+	for _, node := range export.Nodes {
+		encoded, _ := json.Marshal([]any{node.Role, node.MachineID, node.SystemID, node.Owner, node.Metadata, node.NodeHardware})
+		nodes[node.Name] = string(encoded)
+	}
+
+	manifests := make([]string, 0, len(export.Manifests))
+	for _, manifest := range export.Manifests {
+		manifests = append(manifests, manifest.ManifestID+" "+manifest.AppliedDate+" "+manifest.Data)
+	}
+
+	return nodes, export.Config, manifests
+}
+
+// exportCluster exports the cluster, through its JSON encoding as served.
+func exportCluster(t *testing.T, s *state.State) types.ClusterExport {
+	t.Helper()
+
+	export, err := ExportCluster(s, false)
+	if err != nil {
+		t.Fatalf("Failed to export cluster: %v", err)
+	}
+
+	data, err := json.Marshal(export)
+	if err != nil {
+		t.Fatalf("Failed to encode export: %v", err)
+	}
+
+	var decoded types.ClusterExport
+	err = json.Unmarshal(data, &decoded)
+	if err != nil {
+		t.Fatalf("Failed to decode export: %v", err)
+	}
+
+	return decoded
+}
+
+// assertExported fails the test if the cluster state differs from the
+// expected export.
+func assertExported(t *testing.T, s *state.State, expected types.ClusterExport) {
+	t.Helper()
+
+	nodes, config, manifests := exportedState(exportCluster(t, s))
+	expectedNodes, expectedConfig, expectedManifests := exportedState(expected)
+
+	if !maps.Equal(nodes, expectedNodes) {
+		t.Errorf("Nodes are %v, expected %v", nodes, expectedNodes)
+	}
+
+	if !maps.Equal(config, expectedConfig) {
+		t.Errorf("Config is %v, expected %v", config, expectedConfig)
+	}
+
+	if !slices.Equal(manifests, expectedManifests) {
+		t.Errorf("Manifests are %v, expected %v", manifests, expectedManifests)
+	}
+}
+
+func TestExportSecrets(t *testing.T) {
+	s := testutil.NewState(t)
+	addTestClusterState(t, s)
+
+	for _, includeSecrets := range []bool{false, true} {
+		export, err := ExportCluster(s, includeSecrets)
+		if err != nil {
+			t.Fatalf("Failed to export cluster: %v", err)
+		}
+
+		expected := ""
+		if includeSecrets {
+			expected = "token"
+		}
+
+		if len(export.JujuUsers) != 1 || export.JujuUsers[0].Token != expected {
+			t.Errorf("Exported juju users %+v with secrets %v, expected alice with token %q", export.JujuUsers, includeSecrets, expected)
+		}
+	}
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	s := testutil.NewState(t)
+	addTestClusterState(t, s)
+
+	export := exportCluster(t, s)
+
+	err := DeleteNode(s, "node1")
+	if err != nil {
+		t.Fatalf("Failed to delete node: %v", err)
+	}
+
+	err = UpdateNode(s, "node2", []string{"control"}, 3, "")
+	if err != nil {
+		t.Fatalf("Failed to update node: %v", err)
+	}
+
+	err = UpdateConfig(s, "backup.a", "changed")
+	if err != nil {
+		t.Fatalf("Failed to update config: %v", err)
+	}
+
+	err = DeleteManifest(s, "m1")
+	if err != nil {
+		t.Fatalf("Failed to delete manifest: %v", err)
+	}
+
+	err = ImportCluster(s, export, importReplace)
+	if err != nil {
+		t.Fatalf("Failed to import cluster: %v", err)
+	}
+
+	assertExported(t, s, export)
+}
+
+func TestImportModes(t *testing.T) {
+	for _, mode := range []string{importMerge, importReplace} {
+		t.Run(mode, func(t *testing.T) {
+			s := testutil.NewState(t)
+			addTestClusterState(t, s)
+
+			export := exportCluster(t, s)
+
+			// Rows added after the export are kept by merges only.
+			addTestNodes(t, s, nil, "node3")
+
+			err := CreateConfig(s, "backup.c", "3")
+			if err != nil {
+				t.Fatalf("Failed to create config: %v", err)
+			}
+
+			addTestManifests(t, s, "m3")
+
+			err = UpdateConfig(s, "backup.a", "changed")
+			if err != nil {
+				t.Fatalf("Failed to update config: %v", err)
+			}
+
+			expected := exportCluster(t, s)
+			expected.Config["backup.a"] = "1"
+			if mode == importReplace {
+				expected = export
+			}
+
+			err = ImportCluster(s, export, mode)
+			if err != nil {
+				t.Fatalf("Failed to import cluster: %v", err)
+			}
+
+			assertExported(t, s, expected)
+		})
+	}
+}
+
+func TestImportSchemaMismatch(t *testing.T) {
+	s := testutil.NewState(t)
+	addTestClusterState(t, s)
+
+	export := exportCluster(t, s)
+	export.SchemaVersion++
+	export.Config["backup.a"] = "changed"
+
+	err := ImportCluster(s, export, importReplace)
+	if !api.StatusErrorCheck(err, http.StatusConflict) {
+		t.Fatalf("Expected an export from another schema version to be rejected with 409, got %v", err)
+	}
+
+	value, err := GetConfig(s, "backup.a")
+	if err != nil {
+		t.Fatalf("Failed to get config: %v", err)
+	}
+
+	if value != "1" {
+		t.Errorf("Config key holds %q after a rejected import, expected %q", value, "1")
+	}
+}
@@ -0,0 +1,74 @@
+package sunbeam
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/api/types"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/database"
+)
+
+// ListChanges returns at most limit changes recorded after the given sequence
+func ListChanges(s *state.State, since int64, limit int) (types.Changes, error) {
+	changes := types.Changes{}
+
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		records, err := database.GetChangesSince(ctx, tx, since, limit)
+		if err != nil {
+			return fmt.Errorf("Failed to fetch changes: %w", err)
+		}
+
+		for _, change := range records {
+			changes = append(changes, types.Change{
+				Seq:       change.Seq,
+				Entity:    change.Entity,
+				Key:       change.Key,
+				Action:    change.Action,
+				Timestamp: change.ChangedAt,
+			})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+// recordChange records a mutation in the change feed, within the
+// transaction performing the mutation, and queues its event.
+func recordChange(ctx context.Context, tx *sql.Tx, entity string, key string, action string) error {
+	return recordChangeEvent(ctx, tx, Event{Entity: entity, Key: key, Action: action})
+}
+
+// recordConfigChange records a change of a config key in the change feed and
+// queues its event, carrying the value committed, nil if the key was deleted.
+func recordConfigChange(ctx context.Context, tx *sql.Tx, key string, action string, value sql.NullString) error {
+	event := Event{Entity: "config", Key: key, Action: action}
+	if value.Valid {
+		event.Value = &value.String
+	}
+
+	return recordChangeEvent(ctx, tx, event)
+}
+
+// recordChangeEvent records the mutation described by an event in the change
+// feed and queues the event with its sequence and time set.
+func recordChangeEvent(ctx context.Context, tx *sql.Tx, event Event) error {
+	seq, err := database.CreateChange(ctx, tx, event.Entity, event.Key, event.Action)
+	if err != nil {
+		return fmt.Errorf("Failed to record change: %w", err)
+	}
+
+	event.Seq = seq
+	event.Timestamp = time.Now().UTC()
+	queueEvent(ctx, event)
+
+	return nil
+}
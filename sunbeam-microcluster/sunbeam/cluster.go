@@ -0,0 +1,210 @@
+package sunbeam
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	dqliteClient "github.com/canonical/go-dqlite/client"
+	"github.com/canonical/lxd/shared/api"
+	"github.com/canonical/lxd/shared/logger"
+	"github.com/canonical/microcluster/cluster"
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/api/types"
+)
+
+const (
+	// memberOfflineAfter is the time without heartbeat after which a cluster
+	// member is considered offline. The leader heartbeats every minute.
+	memberOfflineAfter = 3 * time.Minute
+
+	// targetVoters and targetStandBys are the number of dqlite voters and
+	// stand-bys a balanced cluster has, matching the dqlite defaults.
+	targetVoters   = 3
+	targetStandBys = 3
+)
+
+// clusterMember is a cluster member along with its dqlite role.
+type clusterMember struct {
+	ID      uint64
+	Name    string
+	Address string
+	Role    dqliteClient.NodeRole
+	Online  bool
+}
+
+// clusterMembers returns the cluster members as known to dqlite, sorted by name.
+func clusterMembers(ctx context.Context, s *state.State, client *dqliteClient.Client) ([]clusterMember, error) {
+	nodes, err := s.Database.Cluster(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	byAddress := map[string]cluster.InternalClusterMember{}
+	err = transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		records, err := cluster.GetInternalClusterMembers(ctx, tx)
+		if err != nil {
+			return fmt.Errorf("Failed to fetch cluster members: %w", err)
+		}
+
+		for _, record := range records {
+			byAddress[record.Address] = record
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]clusterMember, 0, len(nodes))
+	for _, node := range nodes {
+		member := clusterMember{ID: node.ID, Name: node.Address, Address: node.Address, Role: node.Role, Online: true}
+
+		record, ok := byAddress[node.Address]
+		if ok {
+			member.Name = record.Name
+			member.Online = memberOnline(record)
+		}
+
+		members = append(members, member)
+	}
+
+	sort.Slice(members, func(i, j int) bool { return members[i].Name < members[j].Name })
+
+	return members, nil
+}
+
+// memberOnline returns whether a cluster member has heartbeated recently.
+// Members that have not had a heartbeat yet are considered online.
+func memberOnline(member cluster.InternalClusterMember) bool {
+	return member.Heartbeat.IsZero() || time.Since(member.Heartbeat) < memberOfflineAfter
+}
+
+// RebalanceRoles assigns the dqlite roles so that the cluster has the
+// target number of voters and stand-bys, changing as few roles as possible.
+// The leader always stays a voter, and members are promoted before others
+// are demoted so that quorum is never reduced. It only runs on the leader
+// and refuses to run on small or degraded clusters.
+func RebalanceRoles(s *state.State) (types.ClusterMemberRoles, error) {
+	err := requireLeader(s)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(s.Context, leaderTimeout)
+	defer cancel()
+
+	client, err := s.Database.Leader(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to connect to the dqlite leader: %w", err)
+	}
+
+	defer func() { _ = client.Close() }()
+
+	members, err := clusterMembers(ctx, s, client)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(members) < targetVoters {
+		return nil, api.StatusErrorf(http.StatusConflict, "Rebalancing needs at least %d cluster members, found %d", targetVoters, len(members))
+	}
+
+	for _, member := range members {
+		if !member.Online {
+			return nil, api.StatusErrorf(http.StatusConflict, "Cluster is degraded, member %q is offline", member.Name)
+		}
+	}
+
+	leader, err := client.Leader(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get the dqlite leader: %w", err)
+	}
+
+	desired := balancedRoles(members, leader.ID)
+
+	// Promote first, then demote, so the voter count never drops below its
+	// current value while roles change.
+	for _, promote := range []bool{true, false} {
+		for _, member := range members {
+			role := desired[member.ID]
+			if role == member.Role {
+				continue
+			}
+
+			promotion := roleRank(role) > roleRank(member.Role)
+			if promotion != promote {
+				continue
+			}
+
+			err = client.Assign(ctx, member.ID, role)
+			if err != nil {
+				return nil, fmt.Errorf("Failed to assign role %s to %q: %w", role, member.Name, err)
+			}
+
+			logger.Info("Changed dqlite role", logger.Ctx{"name": member.Name, "from": member.Role.String(), "to": role.String()})
+		}
+	}
+
+	roles := make(types.ClusterMemberRoles, 0, len(members))
+	for _, member := range members {
+		role := types.ClusterMemberRole{Name: member.Name, Address: member.Address, Role: desired[member.ID].String()}
+		if desired[member.ID] != member.Role {
+			role.Previous = member.Role.String()
+		}
+
+		roles = append(roles, role)
+	}
+
+	return roles, nil
+}
+
+// balancedRoles returns the role each member should have, keeping current
+// roles where possible. The leader is always a voter.
+func balancedRoles(members []clusterMember, leaderID uint64) map[uint64]dqliteClient.NodeRole {
+	desired := make(map[uint64]dqliteClient.NodeRole, len(members))
+	voters, standBys := 0, 0
+
+	// Candidates ordered by preference: the leader, then by current role.
+	candidates := make([]clusterMember, len(members))
+	copy(candidates, members)
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].ID == leaderID || candidates[j].ID == leaderID {
+			return candidates[i].ID == leaderID
+		}
+
+		return roleRank(candidates[i].Role) > roleRank(candidates[j].Role)
+	})
+
+	for _, member := range candidates {
+		switch {
+		case voters < targetVoters:
+			desired[member.ID] = dqliteClient.Voter
+			voters++
+		case standBys < targetStandBys:
+			desired[member.ID] = dqliteClient.StandBy
+			standBys++
+		default:
+			desired[member.ID] = dqliteClient.Spare
+		}
+	}
+
+	return desired
+}
+
+// roleRank orders the dqlite roles by their weight in the cluster.
+func roleRank(role dqliteClient.NodeRole) int {
+	switch role {
+	case dqliteClient.Voter:
+		return 2
+	case dqliteClient.StandBy:
+		return 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,79 @@
+package sunbeam
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/api/types"
+)
+
+// GetClusterStatus returns every cluster member alongside the node of the
+// same name. Members without a node and nodes without a member are listed
+// too, flagged with what is missing.
+func GetClusterStatus(s *state.State) (types.ClusterStatus, error) {
+	ctx, cancel := context.WithTimeout(s.Context, leaderTimeout)
+	defer cancel()
+
+	client, err := s.Database.Leader(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to connect to the dqlite leader: %w", err)
+	}
+
+	defer func() { _ = client.Close() }()
+
+	members, err := clusterMembers(ctx, s, client)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, err := ListNodes(s, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return clusterStatus(members, nodes), nil
+}
+
+// clusterStatus correlates the given cluster members with the nodes of the
+// same name, sorted by name.
+func clusterStatus(members []clusterMember, nodes types.Nodes) types.ClusterStatus {
+	entries := make(map[string]*types.ClusterStatusEntry, len(members))
+	for _, member := range members {
+		entries[member.Name] = &types.ClusterStatusEntry{
+			Name:       member.Name,
+			Address:    member.Address,
+			DqliteRole: member.Role.String(),
+			Online:     member.Online,
+			Role:       []string{},
+			MachineID:  -1,
+			Missing:    "node",
+		}
+	}
+
+	for _, node := range nodes {
+		entry, ok := entries[node.Name]
+		if ok {
+			entry.Missing = ""
+		} else {
+			entry = &types.ClusterStatusEntry{Name: node.Name, Missing: "member"}
+			entries[node.Name] = entry
+		}
+
+		entry.Role = node.Role
+		entry.MachineID = node.MachineID
+		entry.SystemID = node.SystemID
+		entry.Status = node.Status
+	}
+
+	status := make(types.ClusterStatus, 0, len(entries))
+	for _, entry := range entries {
+		status = append(status, *entry)
+	}
+
+	sort.Slice(status, func(i, j int) bool { return status[i].Name < status[j].Name })
+
+	return status
+}
@@ -0,0 +1,33 @@
+package sunbeam
+
+import (
+	"reflect"
+	"testing"
+
+	dqliteClient "github.com/canonical/go-dqlite/client"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/api/types"
+)
+
+func TestClusterStatusMismatch(t *testing.T) {
+	members := []clusterMember{
+		{Name: "member1", Address: "10.0.0.1:7000", Role: dqliteClient.Voter, Online: true},
+		{Name: "member2", Address: "10.0.0.2:7000", Role: dqliteClient.Spare, Online: false},
+	}
+
+	nodes := types.Nodes{
+		{Name: "member1", Role: []string{"compute", "control"}, MachineID: 3, SystemID: "sys-3", Status: "online"},
+		{Name: "orphan", Role: []string{"storage"}, MachineID: 4, SystemID: "sys-4", Status: "offline"},
+	}
+
+	expected := types.ClusterStatus{
+		{Name: "member1", Address: "10.0.0.1:7000", DqliteRole: "voter", Online: true, Role: []string{"compute", "control"}, MachineID: 3, SystemID: "sys-3", Status: "online"},
+		{Name: "member2", Address: "10.0.0.2:7000", DqliteRole: "spare", Role: []string{}, MachineID: -1, Missing: "node"},
+		{Name: "orphan", Role: []string{"storage"}, MachineID: 4, SystemID: "sys-4", Status: "offline", Missing: "member"},
+	}
+
+	status := clusterStatus(members, nodes)
+	if !reflect.DeepEqual(status, expected) {
+		t.Fatalf("Cluster status is %+v, expected %+v", status, expected)
+	}
+}
@@ -0,0 +1,140 @@
+package sunbeam
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/canonical/lxd/shared/api"
+	"github.com/canonical/lxd/shared/logger"
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/api/types"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/database"
+)
+
+const (
+	// defaultRetention is how long audit entries and changes are kept when
+	// compacting without an explicit retention.
+	defaultRetention = 30 * 24 * time.Hour
+
+	// compactBatchSize is the number of rows deleted per transaction, to
+	// keep transactions short.
+	compactBatchSize = 1000
+)
+
+// changesLowWaterMarkKey is the config key holding the lowest change
+// sequence any consumer has yet to process. Compaction never removes
+// changes past it.
+const changesLowWaterMarkKey = "changes.low-water-mark"
+
+// Compact prunes the audit log and the change feed of entries older than
+// the retention, in batches. Changes past the low-water mark are kept
+// whatever their age. The config history, deleted nodes and node history
+// have their own retention, in days, read from the config. It only runs on the dqlite leader.
+func Compact(s *state.State, retention time.Duration) (types.Compaction, error) {
+	compaction := types.Compaction{LowWaterMark: -1}
+
+	if retention < 0 {
+		return compaction, api.StatusErrorf(http.StatusBadRequest, "Retention must not be negative")
+	}
+
+	if retention == 0 {
+		retention = defaultRetention
+	}
+
+	err := requireLeader(s)
+	if err != nil {
+		return compaction, err
+	}
+
+	maxSeq := int64(math.MaxInt64)
+	value, err := GetConfig(s, changesLowWaterMarkKey)
+	if err == nil {
+		mark, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return compaction, api.StatusErrorf(http.StatusBadRequest, "Invalid %q value %q", changesLowWaterMarkKey, value)
+		}
+
+		compaction.LowWaterMark = mark
+		maxSeq = mark - 1
+	} else if !api.StatusErrorCheck(err, http.StatusNotFound) {
+		return compaction, err
+	}
+
+	before := time.Now().Add(-retention)
+
+	compaction.AuditRemoved, err = deleteInBatches(s, func(ctx context.Context, tx *sql.Tx) (int64, error) {
+		return database.DeleteAuditEntriesBefore(ctx, tx, before, compactBatchSize)
+	})
+	if err != nil {
+		return compaction, err
+	}
+
+	compaction.ChangesRemoved, err = deleteInBatches(s, func(ctx context.Context, tx *sql.Tx) (int64, error) {
+		return database.DeleteChangesBefore(ctx, tx, before, maxSeq, compactBatchSize)
+	})
+	if err != nil {
+		return compaction, err
+	}
+
+	days, err := configHistoryRetention(s)
+	if err != nil {
+		return compaction, err
+	}
+
+	compaction.ConfigHistoryRemoved, err = TrimConfigHistory(s, days)
+	if err != nil {
+		return compaction, err
+	}
+
+	days, err = nodesTombstoneRetention(s)
+	if err != nil {
+		return compaction, err
+	}
+
+	compaction.DeletedNodesRemoved, err = PurgeDeletedNodes(s, time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		return compaction, err
+	}
+
+	days, err = nodesHistoryRetention(s)
+	if err != nil {
+		return compaction, err
+	}
+
+	compaction.NodeHistoryRemoved, err = TrimNodeHistory(s, days)
+	if err != nil {
+		return compaction, err
+	}
+
+	logger.Info("Compacted audit log, change feed, config history, deleted nodes and node history", logger.Ctx{"audit": compaction.AuditRemoved, "changes": compaction.ChangesRemoved, "config_history": compaction.ConfigHistoryRemoved, "deleted_nodes": compaction.DeletedNodesRemoved, "node_history": compaction.NodeHistoryRemoved})
+
+	return compaction, nil
+}
+
+// deleteInBatches runs f in its own transaction until it deletes fewer rows
+// than a full batch, and returns the total number of rows deleted.
+func deleteInBatches(s *state.State, f func(ctx context.Context, tx *sql.Tx) (int64, error)) (int64, error) {
+	var total int64
+
+	for {
+		var n int64
+		err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+			var err error
+			n, err = f(ctx, tx)
+			return err
+		})
+		if err != nil {
+			return total, err
+		}
+
+		total += n
+		if n < compactBatchSize {
+			return total, nil
+		}
+	}
+}
@@ -5,24 +5,26 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
-	"strings"
+	"net/http"
+	"sort"
 
+	"github.com/canonical/lxd/shared/api"
+	"github.com/canonical/lxd/shared/logger"
 	"github.com/canonical/microcluster/state"
 
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/api/types"
 	"github.com/canonical/snap-openstack/sunbeam-microcluster/database"
 )
 
-// GetConfig returns the ConfigItem based on key from the database
+// GetConfig returns the currently effective value of a ConfigItem based on
+// key from the database
 func GetConfig(s *state.State, key string) (string, error) {
 	var value string
 
-	err := s.Database.Transaction(s.Context, func(ctx context.Context, tx *sql.Tx) error {
-		record, err := database.GetConfigItem(ctx, tx, key)
-		if err != nil {
-			return err
-		}
-		value = record.Value
-		return nil
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		var err error
+		value, err = effectiveConfigValue(ctx, tx, key)
+		return err
 	})
 
 	if err != nil {
@@ -32,11 +34,27 @@ func GetConfig(s *state.State, key string) (string, error) {
 	return value, nil
 }
 
-// GetConfigItemKeys returns the list of ConfigItem keys from the database
+// GetConfigOrDefault returns the currently effective value of a config key,
+// or the given fallback if the key is not set. The fallback is not stored.
+func GetConfigOrDefault(s *state.State, key string, fallback string) (types.DefaultedConfig, error) {
+	value, err := GetConfig(s, key)
+	if err != nil {
+		if api.StatusErrorCheck(err, http.StatusNotFound) {
+			return types.DefaultedConfig{Key: key, Value: fallback, IsDefault: true}, nil
+		}
+
+		return types.DefaultedConfig{}, err
+	}
+
+	return types.DefaultedConfig{Key: key, Value: value}, nil
+}
+
+// GetConfigItemKeys returns the sorted list of ConfigItem keys from the
+// database, filtered by prefix if provided
 func GetConfigItemKeys(s *state.State, prefix *string) ([]string, error) {
 	var keys []string
 
-	err := s.Database.Transaction(s.Context, func(ctx context.Context, tx *sql.Tx) error {
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
 		var err error
 		keys, err = database.GetConfigItemKeys(ctx, tx, prefix)
 		if err != nil {
@@ -54,36 +72,409 @@ func GetConfigItemKeys(s *state.State, prefix *string) ([]string, error) {
 
 // CreateConfig adds a new ConfigItem to the database
 func CreateConfig(s *state.State, key string, value string) error {
+	err := database.ValidateConfigValue(key, value)
+	if err != nil {
+		return err
+	}
 
-	return s.Database.Transaction(s.Context, func(ctx context.Context, tx *sql.Tx) error {
+	return transaction(s, func(ctx context.Context, tx *sql.Tx) error {
 		_, err := database.CreateConfigItem(ctx, tx, database.ConfigItem{Key: key, Value: value})
 		if err != nil {
 			return fmt.Errorf("Failed to record config item: %w", err)
 		}
+
+		err = recordConfigHistory(ctx, tx, key, sql.NullString{}, sql.NullString{String: value, Valid: true})
+		if err != nil {
+			return err
+		}
+
+		return recordConfigChange(ctx, tx, key, database.ChangeCreate, sql.NullString{String: value, Valid: true})
+	})
+}
+
+// GetConfigBatch returns the currently effective value of the given config
+// keys, keys that are not set are left out. Without keys, every config item
+// is returned.
+func GetConfigBatch(s *state.State, keys []string) (map[string]string, error) {
+	config := make(map[string]string)
+
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		if len(keys) == 0 {
+			var err error
+			keys, err = database.GetConfigItemKeys(ctx, tx, nil)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, key := range keys {
+			value, err := effectiveConfigValue(ctx, tx, key)
+			if err != nil {
+				if api.StatusErrorCheck(err, http.StatusNotFound) {
+					continue
+				}
+
+				return err
+			}
+
+			config[key] = value
+		}
+
 		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
+
+	return config, nil
 }
 
-// UpdateConfig updates a ConfigItem in the database
-func UpdateConfig(s *state.State, key string, value string) error {
-	configItem := database.ConfigItem{Key: key, Value: value}
+// GetConfigByPrefix returns the config key/value pairs whose key starts with
+// the given prefix.
+func GetConfigByPrefix(s *state.State, prefix string) (map[string]string, error) {
+	var config map[string]string
 
-	return s.Database.Transaction(s.Context, func(ctx context.Context, tx *sql.Tx) error {
-		err := database.UpdateConfigItem(ctx, tx, key, configItem)
-		if err != nil && strings.Contains(err.Error(), "ConfigItem not found") {
-			_, err = database.CreateConfigItem(ctx, tx, configItem)
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		var err error
+		config, err = database.GetConfigByPrefix(ctx, tx, prefix)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// SetConfigBatch writes the given config key/value pairs in a single
+// transaction, either all of them are written or none is.
+func SetConfigBatch(s *state.State, config map[string]string) error {
+	if len(config) == 0 {
+		return api.StatusErrorf(http.StatusBadRequest, "Config batch must not be empty")
+	}
+
+	for key, value := range config {
+		if key == "" {
+			return api.StatusErrorf(http.StatusBadRequest, "Config key must not be empty")
 		}
+
+		err := database.ValidateConfigValue(key, value)
 		if err != nil {
-			return fmt.Errorf("Failed to record config item: %w", err)
+			return err
+		}
+	}
+
+	return transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		previous, err := database.SetConfigBatch(ctx, tx, config)
+		if err != nil {
+			return err
+		}
+
+		keys := make([]string, 0, len(previous))
+		for key := range previous {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			err = recordConfigHistory(ctx, tx, key, previous[key], sql.NullString{String: config[key], Valid: true})
+			if err != nil {
+				return err
+			}
+
+			action := database.ChangeUpdate
+			if !previous[key].Valid {
+				action = database.ChangeCreate
+			}
+
+			err = recordConfigChange(ctx, tx, key, action, sql.NullString{String: config[key], Valid: true})
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// SeedDefaultConfig records the default value of each config key that is not
+// set yet, leaving existing values untouched.
+func SeedDefaultConfig(s *state.State) error {
+	keys := make([]string, 0, len(database.DefaultConfig))
+	for key := range database.DefaultConfig {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		for _, key := range keys {
+			exists, err := database.ConfigItemExists(ctx, tx, key)
+			if err != nil {
+				return err
+			}
+
+			if exists {
+				continue
+			}
+
+			_, err = database.CreateConfigItem(ctx, tx, database.ConfigItem{Key: key, Value: database.DefaultConfig[key]})
+			if err != nil {
+				return fmt.Errorf("Failed to record config item %q: %w", key, err)
+			}
+
+			err = recordConfigHistory(ctx, tx, key, sql.NullString{}, sql.NullString{String: database.DefaultConfig[key], Valid: true})
+			if err != nil {
+				return err
+			}
+
+			err = recordConfigChange(ctx, tx, key, database.ChangeCreate, sql.NullString{String: database.DefaultConfig[key], Valid: true})
+			if err != nil {
+				return err
+			}
 		}
 
 		return nil
 	})
 }
 
+// UpdateConfig updates a ConfigItem in the database
+func UpdateConfig(s *state.State, key string, value string) error {
+	return transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		return updateConfig(ctx, tx, key, value)
+	})
+}
+
+// CompareAndSwapConfig sets a config key to value only if its stored value
+// is expected, or only if it is not stored when expected is nil. Scheduled
+// values of the key are not compared, the write supersedes those that are
+// due. The check and the write happen in the same transaction, a mismatch is
+// a 409 Conflict.
+func CompareAndSwapConfig(s *state.State, key string, expected *string, value string) error {
+	return transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		current, err := database.GetConfigItem(ctx, tx, key)
+		if err != nil && !api.StatusErrorCheck(err, http.StatusNotFound) {
+			return err
+		}
+
+		exists := err == nil
+		if expected == nil && exists {
+			return api.StatusErrorf(http.StatusConflict, "Config key %q is already set", key)
+		}
+
+		if expected != nil && !exists {
+			return api.StatusErrorf(http.StatusConflict, "Config key %q is not set", key)
+		}
+
+		if expected != nil && current.Value != *expected {
+			return api.StatusErrorf(http.StatusConflict, "Config key %q does not have the expected value", key)
+		}
+
+		return updateConfig(ctx, tx, key, value)
+	})
+}
+
+// updateConfig creates or updates a ConfigItem within the given transaction.
+// The value is validated first, see database.ConfigValidators. It supersedes
+// the scheduled values of the key that are due.
+func updateConfig(ctx context.Context, tx *sql.Tx, key string, value string) error {
+	err := database.ValidateConfigValue(key, value)
+	if err != nil {
+		return err
+	}
+
+	err = supersedeScheduledConfig(ctx, tx, key)
+	if err != nil {
+		return err
+	}
+
+	configItem := database.ConfigItem{Key: key, Value: value}
+
+	action := database.ChangeUpdate
+	oldValue := sql.NullString{}
+	current, err := database.GetConfigItem(ctx, tx, key)
+	if err == nil {
+		oldValue = sql.NullString{String: current.Value, Valid: true}
+		err = database.UpdateConfigItem(ctx, tx, key, configItem)
+	} else if api.StatusErrorCheck(err, http.StatusNotFound) {
+		action = database.ChangeCreate
+		_, err = database.CreateConfigItem(ctx, tx, configItem)
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to record config item: %w", err)
+	}
+
+	err = recordConfigHistory(ctx, tx, key, oldValue, sql.NullString{String: value, Valid: true})
+	if err != nil {
+		return err
+	}
+
+	return recordConfigChange(ctx, tx, key, action, sql.NullString{String: value, Valid: true})
+}
+
 // DeleteConfig deletes a ConfigItem from the database
 func DeleteConfig(s *state.State, key string) error {
-	return s.Database.Transaction(s.Context, func(ctx context.Context, tx *sql.Tx) error {
-		return database.DeleteConfigItem(ctx, tx, key)
+	return transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		return deleteConfig(ctx, tx, key)
+	})
+}
+
+// deleteConfig deletes a ConfigItem within the given transaction, along
+// with the scheduled values of the key that are due.
+func deleteConfig(ctx context.Context, tx *sql.Tx, key string) error {
+	current, err := database.GetConfigItem(ctx, tx, key)
+	if err != nil {
+		return err
+	}
+
+	err = supersedeScheduledConfig(ctx, tx, key)
+	if err != nil {
+		return err
+	}
+
+	err = database.DeleteConfigItem(ctx, tx, key)
+	if err != nil {
+		return err
+	}
+
+	err = recordConfigHistory(ctx, tx, key, sql.NullString{String: current.Value, Valid: true}, sql.NullString{})
+	if err != nil {
+		return err
+	}
+
+	return recordConfigChange(ctx, tx, key, database.ChangeDelete, sql.NullString{})
+}
+
+// DiffConfig returns the changes that importing the given config with
+// ImportConfig would make to the database, without applying anything.
+func DiffConfig(s *state.State, config map[string]string) (types.ConfigDiff, error) {
+	var current map[string]string
+
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		var err error
+		current, err = snapshotConfig(ctx, tx)
+		return err
+	})
+	if err != nil {
+		return types.ConfigDiff{}, err
+	}
+
+	return diffConfig(current, withoutTerraformKeys(config)), nil
+}
+
+// ImportConfig replaces the config with the given one in a single
+// transaction and returns the changes made. Keys missing from the given
+// config are deleted. Terraform states and locks are left alone, whether
+// they are in the given config or not.
+func ImportConfig(s *state.State, config map[string]string) (types.ConfigDiff, error) {
+	var diff types.ConfigDiff
+
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		var err error
+		diff, err = applyConfig(ctx, tx, config)
+		return err
 	})
+	if err != nil {
+		return types.ConfigDiff{}, err
+	}
+
+	added, changed := configDiffKeys(diff)
+
+	// Only announce the import once it is committed.
+	logger.Info("Imported config", logger.Ctx{"added": added, "changed": changed, "removed": diff.Removed})
+
+	return diff, nil
+}
+
+// applyConfig replaces the config with the given one within the given
+// transaction and returns the changes made, as imports and snapshot
+// restores do. Terraform states and locks are left alone. Each change is
+// recorded in the config history and the change feed like any other write.
+func applyConfig(ctx context.Context, tx *sql.Tx, config map[string]string) (types.ConfigDiff, error) {
+	current, err := snapshotConfig(ctx, tx)
+	if err != nil {
+		return types.ConfigDiff{}, err
+	}
+
+	config = withoutTerraformKeys(config)
+	diff := diffConfig(current, config)
+
+	added, changed := configDiffKeys(diff)
+	for _, key := range append(added, changed...) {
+		err = updateConfig(ctx, tx, key, config[key])
+		if err != nil {
+			return types.ConfigDiff{}, fmt.Errorf("Failed to set config key %q: %w", key, err)
+		}
+	}
+
+	for _, key := range diff.Removed {
+		err = deleteConfig(ctx, tx, key)
+		if err != nil {
+			return types.ConfigDiff{}, fmt.Errorf("Failed to delete config key %q: %w", key, err)
+		}
+	}
+
+	return diff, nil
+}
+
+// withoutTerraformKeys returns a copy of config without Terraform states
+// and locks.
+func withoutTerraformKeys(config map[string]string) map[string]string {
+	filtered := make(map[string]string, len(config))
+	for key, value := range config {
+		if !isTerraformKey(key) {
+			filtered[key] = value
+		}
+	}
+
+	return filtered
+}
+
+// configDiffKeys returns the sorted keys added and changed by a diff.
+func configDiffKeys(diff types.ConfigDiff) ([]string, []string) {
+	added := make([]string, 0, len(diff.Added))
+	for key := range diff.Added {
+		added = append(added, key)
+	}
+
+	changed := make([]string, 0, len(diff.Changed))
+	for key := range diff.Changed {
+		changed = append(changed, key)
+	}
+
+	sort.Strings(added)
+	sort.Strings(changed)
+
+	return added, changed
+}
+
+// diffConfig compares the current config against the desired config.
+// Keys missing from the desired config are reported as removed.
+func diffConfig(current map[string]string, desired map[string]string) types.ConfigDiff {
+	diff := types.ConfigDiff{
+		Added:   make(map[string]string),
+		Changed: make(map[string]types.ConfigChange),
+		Removed: make([]string, 0),
+	}
+
+	for key, value := range desired {
+		old, ok := current[key]
+		if !ok {
+			diff.Added[key] = value
+		} else if old != value {
+			diff.Changed[key] = types.ConfigChange{Old: old, New: value}
+		}
+	}
+
+	for key := range current {
+		_, ok := desired[key]
+		if !ok {
+			diff.Removed = append(diff.Removed, key)
+		}
+	}
+
+	sort.Strings(diff.Removed)
+
+	return diff
 }
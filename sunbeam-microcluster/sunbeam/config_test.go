@@ -0,0 +1,380 @@
+package sunbeam
+
+import (
+	"context"
+	"database/sql"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/canonical/lxd/shared/api"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/api/types"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/database"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/internal/testutil"
+)
+
+func TestSeedDefaultConfig(t *testing.T) {
+	s := testutil.NewState(t)
+
+	err := CreateConfig(s, "nodes.offline-threshold", "10m")
+	if err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+
+	// Bootstrapping again must not fail nor overwrite anything.
+	for i := 0; i < 2; i++ {
+		err = SeedDefaultConfig(s)
+		if err != nil {
+			t.Fatalf("Failed to seed default config: %v", err)
+		}
+	}
+
+	for key, value := range database.DefaultConfig {
+		if key == "nodes.offline-threshold" {
+			value = "10m"
+		}
+
+		got, err := GetConfig(s, key)
+		if err != nil {
+			t.Fatalf("Failed to get config key %q: %v", key, err)
+		}
+
+		if got != value {
+			t.Errorf("Config key %q is %q after seeding, expected %q", key, got, value)
+		}
+	}
+}
+
+func TestSetConfigBatchRollback(t *testing.T) {
+	s := testutil.NewState(t)
+
+	err := CreateConfig(s, "batch.a", "1")
+	if err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+
+	// Make the write of the last key of the batch conflict.
+	err = transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `
+CREATE TRIGGER conflict BEFORE INSERT ON config WHEN NEW.key = 'batch.c'
+BEGIN
+  SELECT RAISE(ABORT, 'conflict');
+END`)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Failed to create trigger: %v", err)
+	}
+
+	err = SetConfigBatch(s, map[string]string{"batch.a": "2", "batch.b": "2", "batch.c": "2"})
+	if err == nil {
+		t.Fatal("Expected a conflicting batch to fail")
+	}
+
+	config, err := GetConfigBatch(s, []string{"batch.a", "batch.b", "batch.c"})
+	if err != nil {
+		t.Fatalf("Failed to get config batch: %v", err)
+	}
+
+	if len(config) != 1 || config["batch.a"] != "1" {
+		t.Fatalf("Config after a failed batch is %v, expected only batch.a set to 1", config)
+	}
+
+	err = SetConfigBatch(s, map[string]string{"batch.a": "2", "batch.b": "2"})
+	if err != nil {
+		t.Fatalf("Failed to set config batch: %v", err)
+	}
+
+	config, err = GetConfigBatch(s, []string{"batch.a", "batch.b", "batch.c"})
+	if err != nil {
+		t.Fatalf("Failed to get config batch: %v", err)
+	}
+
+	if len(config) != 2 || config["batch.a"] != "2" || config["batch.b"] != "2" {
+		t.Fatalf("Config after a batch is %v, expected batch.a and batch.b set to 2", config)
+	}
+}
+
+func TestConfigValidation(t *testing.T) {
+	s := testutil.NewState(t)
+
+	err := CreateConfig(s, "manifest.retention", "abc")
+	if !api.StatusErrorCheck(err, http.StatusBadRequest) {
+		t.Fatalf("Expected a non numeric retention to be rejected with 400, got %v", err)
+	}
+
+	err = CreateConfig(s, "nodes.offline-threshold", "30s")
+	if err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+
+	err = UpdateConfig(s, "nodes.offline-threshold", "soon")
+	if !api.StatusErrorCheck(err, http.StatusBadRequest) {
+		t.Fatalf("Expected an invalid duration to be rejected with 400, got %v", err)
+	}
+
+	value, err := GetConfig(s, "nodes.offline-threshold")
+	if err != nil {
+		t.Fatalf("Failed to get config: %v", err)
+	}
+
+	if value != "30s" {
+		t.Errorf("Config key holds %q after a rejected update, expected %q", value, "30s")
+	}
+}
+
+func TestDeleteConfig(t *testing.T) {
+	s := testutil.NewState(t)
+
+	err := CreateConfig(s, "delete.key", "1")
+	if err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+
+	err = DeleteConfig(s, "delete.key")
+	if err != nil {
+		t.Fatalf("Failed to delete config: %v", err)
+	}
+
+	_, err = GetConfig(s, "delete.key")
+	if !api.StatusErrorCheck(err, http.StatusNotFound) {
+		t.Errorf("Expected a deleted key to be gone, got %v", err)
+	}
+
+	err = DeleteConfig(s, "delete.key")
+	if !api.StatusErrorCheck(err, http.StatusNotFound) {
+		t.Errorf("Expected deleting a missing key to fail with 404, got %v", err)
+	}
+
+	// Setting the key again creates it afresh.
+	err = CreateConfig(s, "delete.key", "2")
+	if err != nil {
+		t.Fatalf("Failed to create config again: %v", err)
+	}
+
+	history, err := GetConfigHistory(s, "delete.key")
+	if err != nil {
+		t.Fatalf("Failed to get config history: %v", err)
+	}
+
+	if len(history) != 3 || history[2].OldValue != nil {
+		t.Errorf("Config history is %+v, expected the key to be created after its deletion", history)
+	}
+}
+
+func TestCompareAndSwapConfig(t *testing.T) {
+	s := testutil.NewState(t)
+
+	unlocked, locked := "unlocked", "locked by node1"
+
+	err := CompareAndSwapConfig(s, "lock", nil, unlocked)
+	if err != nil {
+		t.Fatalf("Failed to create config key if absent: %v", err)
+	}
+
+	err = CompareAndSwapConfig(s, "lock", nil, locked)
+	if !api.StatusErrorCheck(err, http.StatusConflict) {
+		t.Fatalf("Expected creating a key already set to fail with 409, got %v", err)
+	}
+
+	err = CompareAndSwapConfig(s, "lock", &unlocked, locked)
+	if err != nil {
+		t.Fatalf("Failed to swap config value: %v", err)
+	}
+
+	// A second taker still expects the lock free.
+	err = CompareAndSwapConfig(s, "lock", &unlocked, "locked by node2")
+	if !api.StatusErrorCheck(err, http.StatusConflict) {
+		t.Fatalf("Expected swapping a stale value to fail with 409, got %v", err)
+	}
+
+	err = CompareAndSwapConfig(s, "unset", &unlocked, locked)
+	if !api.StatusErrorCheck(err, http.StatusConflict) {
+		t.Fatalf("Expected swapping a key not set to fail with 409, got %v", err)
+	}
+
+	value, err := GetConfig(s, "lock")
+	if err != nil {
+		t.Fatalf("Failed to get config: %v", err)
+	}
+
+	if value != locked {
+		t.Errorf("Config key is %q after the swaps, expected %q", value, locked)
+	}
+}
+
+func TestCompareAndSwapConfigScheduled(t *testing.T) {
+	s := testutil.NewState(t)
+
+	unlocked, scheduled := "unlocked", "locked by node9"
+
+	err := UpdateConfig(s, "lock", unlocked)
+	if err != nil {
+		t.Fatalf("Failed to set config: %v", err)
+	}
+
+	addScheduledConfig(t, s, "lock", scheduled, time.Now().Add(-time.Minute))
+
+	// The due scheduled value is effective, the stored one is compared.
+	err = CompareAndSwapConfig(s, "lock", &scheduled, "locked by node1")
+	if !api.StatusErrorCheck(err, http.StatusConflict) {
+		t.Fatalf("Expected swapping the scheduled value to fail with 409, got %v", err)
+	}
+
+	err = CompareAndSwapConfig(s, "lock", &unlocked, "locked by node1")
+	if err != nil {
+		t.Fatalf("Failed to swap the stored config value: %v", err)
+	}
+
+	err = PromoteScheduledConfig(s)
+	if err != nil {
+		t.Fatalf("Failed to promote scheduled config: %v", err)
+	}
+
+	assertConfigValue(t, s, "lock", "locked by node1")
+
+	// A key only set by a due scheduled value is not stored yet.
+	addScheduledConfig(t, s, "pending", scheduled, time.Now().Add(-time.Minute))
+
+	err = CompareAndSwapConfig(s, "pending", nil, unlocked)
+	if err != nil {
+		t.Fatalf("Failed to create a config key only scheduled: %v", err)
+	}
+
+	assertConfigValue(t, s, "pending", unlocked)
+}
+
+func TestGetConfigOrDefault(t *testing.T) {
+	s := testutil.NewState(t)
+
+	err := UpdateConfig(s, "region", "RegionOne")
+	if err != nil {
+		t.Fatalf("Failed to set config: %v", err)
+	}
+
+	tests := []struct {
+		key      string
+		expected types.DefaultedConfig
+	}{
+		{key: "region", expected: types.DefaultedConfig{Key: "region", Value: "RegionOne"}},
+		{key: "unset", expected: types.DefaultedConfig{Key: "unset", Value: "fallback", IsDefault: true}},
+	}
+
+	for _, test := range tests {
+		config, err := GetConfigOrDefault(s, test.key, "fallback")
+		if err != nil {
+			t.Fatalf("Failed to get config %q with a default: %v", test.key, err)
+		}
+
+		if config != test.expected {
+			t.Errorf("Got config %q as %+v, expected %+v", test.key, config, test.expected)
+		}
+	}
+
+	_, err = GetConfig(s, "unset")
+	if !api.StatusErrorCheck(err, http.StatusNotFound) {
+		t.Errorf("Expected the default not to be stored, got %v", err)
+	}
+}
+
+func TestGetConfigItemKeys(t *testing.T) {
+	s := testutil.NewState(t)
+
+	for _, key := range []string{"zone", "ceph.osds", "ceph_pool", "ceph", "region"} {
+		err := UpdateConfig(s, key, "value of "+key)
+		if err != nil {
+			t.Fatalf("Failed to set config key %q: %v", key, err)
+		}
+	}
+
+	ceph, underscore, missing := "ceph", "ceph_", "nova"
+
+	tests := []struct {
+		prefix *string
+		keys   []string
+	}{
+		{prefix: nil, keys: []string{"ceph", "ceph.osds", "ceph_pool", "region", "zone"}},
+		{prefix: &ceph, keys: []string{"ceph", "ceph.osds", "ceph_pool"}},
+		{prefix: &underscore, keys: []string{"ceph_pool"}},
+		{prefix: &missing, keys: []string{}},
+	}
+
+	for _, test := range tests {
+		keys, err := GetConfigItemKeys(s, test.prefix)
+		if err != nil {
+			t.Fatalf("Failed to list config keys: %v", err)
+		}
+
+		prefix := "none"
+		if test.prefix != nil {
+			prefix = strconv.Quote(*test.prefix)
+		}
+
+		if !slices.Equal(keys, test.keys) {
+			t.Errorf("Config keys with prefix %s are %v, expected %v", prefix, keys, test.keys)
+		}
+	}
+}
+
+func TestImportConfig(t *testing.T) {
+	s := testutil.NewState(t)
+
+	for key, value := range map[string]string{"region": "RegionOne", "extra": "1", "tfstate-openstack": "state1", "tflock-openstack": "lock1"} {
+		err := UpdateConfig(s, key, value)
+		if err != nil {
+			t.Fatalf("Failed to set config key %q: %v", key, err)
+		}
+	}
+
+	config := map[string]string{"region": "RegionTwo", "zone": "az1", "tfstate-openstack": "state2"}
+
+	preview, err := DiffConfig(s, config)
+	if err != nil {
+		t.Fatalf("Failed to diff config: %v", err)
+	}
+
+	diff, err := ImportConfig(s, config)
+	if err != nil {
+		t.Fatalf("Failed to import config: %v", err)
+	}
+
+	// Terraform states and locks are neither added, changed nor removed.
+	for name, d := range map[string]types.ConfigDiff{"Diffing": preview, "Importing": diff} {
+		if !maps.Equal(d.Added, map[string]string{"zone": "az1"}) || len(d.Changed) != 1 || !slices.Equal(d.Removed, []string{"extra"}) {
+			t.Errorf("%s changed %+v, expected zone added, region changed and extra removed", name, d)
+		}
+
+		change, ok := d.Changed["region"]
+		if !ok || change.Old != "RegionOne" || change.New != "RegionTwo" {
+			t.Errorf("%s changed region by %+v, expected it from RegionOne to RegionTwo", name, change)
+		}
+	}
+
+	imported, err := GetConfigBatch(s, []string{"region", "zone", "extra", "tfstate-openstack", "tflock-openstack"})
+	if err != nil {
+		t.Fatalf("Failed to get config batch: %v", err)
+	}
+
+	expected := map[string]string{"region": "RegionTwo", "zone": "az1", "tfstate-openstack": "state1", "tflock-openstack": "lock1"}
+	if !maps.Equal(imported, expected) {
+		t.Errorf("Config after importing is %v, expected %v", imported, expected)
+	}
+
+	_, err = ImportConfig(s, map[string]string{"api.access-log": "maybe"})
+	if !api.StatusErrorCheck(err, http.StatusBadRequest) {
+		t.Errorf("Expected importing an invalid value to fail with 400, got %v", err)
+	}
+
+	imported, err = GetConfigBatch(s, []string{"region", "zone"})
+	if err != nil {
+		t.Fatalf("Failed to get config batch: %v", err)
+	}
+
+	if len(imported) != 2 {
+		t.Errorf("Config after a failed import is %v, expected it unchanged", imported)
+	}
+}
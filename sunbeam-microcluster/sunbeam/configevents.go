@@ -0,0 +1,23 @@
+package sunbeam
+
+import (
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/api/types"
+)
+
+// ConfigEvent returns the config change described by an event, along with
+// the value committed by the change, unset if the key was deleted. Events
+// on other entities and changes of Terraform states and locks are not
+// streamed to subscribers, false is returned for those.
+func ConfigEvent(event Event) (types.ConfigEvent, bool) {
+	if event.Entity != "config" || isTerraformKey(event.Key) {
+		return types.ConfigEvent{}, false
+	}
+
+	return types.ConfigEvent{
+		Seq:       event.Seq,
+		Key:       event.Key,
+		Action:    event.Action,
+		Value:     event.Value,
+		Timestamp: event.Timestamp,
+	}, true
+}
@@ -0,0 +1,111 @@
+package sunbeam
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/canonical/lxd/shared/api"
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/api/types"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/database"
+)
+
+// configHistoryRetentionKey is the config key holding the number of days
+// config changes are kept in the history.
+const configHistoryRetentionKey = "config.history-retention-days"
+
+// defaultConfigHistoryRetention applies when no retention is configured.
+const defaultConfigHistoryRetention = 90
+
+// recordConfigHistory records a change of the value of a config key within
+// the given transaction. Terraform states and locks are not config, and
+// would only fill the history with copies of large blobs, so their changes
+// are not recorded.
+func recordConfigHistory(ctx context.Context, tx *sql.Tx, key string, oldValue sql.NullString, newValue sql.NullString) error {
+	if isTerraformKey(key) {
+		return nil
+	}
+
+	_, err := database.CreateConfigHistoryEntry(ctx, tx, key, oldValue, newValue)
+	return err
+}
+
+// GetConfigHistory returns the changes of a config key, oldest first.
+func GetConfigHistory(s *state.State, key string) ([]types.ConfigHistoryEntry, error) {
+	history := []types.ConfigHistoryEntry{}
+
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		records, err := database.GetConfigHistory(ctx, tx, key)
+		if err != nil {
+			return err
+		}
+
+		for _, record := range records {
+			entry := types.ConfigHistoryEntry{Key: record.Key, ChangedAt: record.ChangedAt}
+			if record.OldValue.Valid {
+				entry.OldValue = &record.OldValue.String
+			}
+
+			if record.NewValue.Valid {
+				entry.NewValue = &record.NewValue.String
+			}
+
+			history = append(history, entry)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return history, nil
+}
+
+// TrimConfigHistory deletes the config changes older than the given number
+// of days, in batches, and returns the number deleted. Node config changes
+// share the retention of global ones.
+func TrimConfigHistory(s *state.State, days int) (int64, error) {
+	if days <= 0 {
+		return 0, api.StatusErrorf(http.StatusBadRequest, "Config history retention must be a positive number of days")
+	}
+
+	before := time.Now().AddDate(0, 0, -days)
+
+	deleted, err := deleteInBatches(s, func(ctx context.Context, tx *sql.Tx) (int64, error) {
+		return database.DeleteConfigHistoryBefore(ctx, tx, before, compactBatchSize)
+	})
+	if err != nil {
+		return deleted, err
+	}
+
+	nodeDeleted, err := deleteInBatches(s, func(ctx context.Context, tx *sql.Tx) (int64, error) {
+		return database.DeleteNodeConfigHistoryBefore(ctx, tx, before, compactBatchSize)
+	})
+
+	return deleted + nodeDeleted, err
+}
+
+// configHistoryRetention returns the configured config history retention,
+// in days.
+func configHistoryRetention(s *state.State) (int, error) {
+	value, err := GetConfig(s, configHistoryRetentionKey)
+	if err != nil {
+		if api.StatusErrorCheck(err, http.StatusNotFound) {
+			return defaultConfigHistoryRetention, nil
+		}
+
+		return 0, err
+	}
+
+	days, err := strconv.Atoi(value)
+	if err != nil || days <= 0 {
+		return 0, api.StatusErrorf(http.StatusBadRequest, "Invalid %q value %q", configHistoryRetentionKey, value)
+	}
+
+	return days, nil
+}
@@ -0,0 +1,94 @@
+package sunbeam
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/internal/testutil"
+)
+
+// historyValue returns the value of a config history entry, or "<unset>".
+func historyValue(value *string) string {
+	if value == nil {
+		return "<unset>"
+	}
+
+	return *value
+}
+
+func TestConfigHistory(t *testing.T) {
+	s := testutil.NewState(t)
+
+	err := CreateConfig(s, "history.key", "1")
+	if err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+
+	err = UpdateConfig(s, "history.key", "2")
+	if err != nil {
+		t.Fatalf("Failed to update config: %v", err)
+	}
+
+	err = DeleteConfig(s, "history.key")
+	if err != nil {
+		t.Fatalf("Failed to delete config: %v", err)
+	}
+
+	history, err := GetConfigHistory(s, "history.key")
+	if err != nil {
+		t.Fatalf("Failed to get config history: %v", err)
+	}
+
+	expected := [][2]string{{"<unset>", "1"}, {"1", "2"}, {"2", "<unset>"}}
+	if len(history) != len(expected) {
+		t.Fatalf("Config history has %d entries, expected %d", len(history), len(expected))
+	}
+
+	for i, entry := range history {
+		change := [2]string{historyValue(entry.OldValue), historyValue(entry.NewValue)}
+		if change != expected[i] {
+			t.Errorf("Config history entry %d changed the value from %q to %q, expected %q to %q", i, change[0], change[1], expected[i][0], expected[i][1])
+		}
+	}
+}
+
+func TestTrimConfigHistory(t *testing.T) {
+	s := testutil.NewState(t)
+
+	for _, key := range []string{"history.old", "history.new"} {
+		err := CreateConfig(s, key, "1")
+		if err != nil {
+			t.Fatalf("Failed to create config: %v", err)
+		}
+	}
+
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, "UPDATE config_history SET changed_at = ? WHERE key = ?", time.Now().UTC().AddDate(0, 0, -3), "history.old")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Failed to backdate config history: %v", err)
+	}
+
+	deleted, err := TrimConfigHistory(s, 2)
+	if err != nil {
+		t.Fatalf("Failed to trim config history: %v", err)
+	}
+
+	if deleted != 1 {
+		t.Errorf("Trimming deleted %d entries, expected 1", deleted)
+	}
+
+	for key, count := range map[string]int{"history.old": 0, "history.new": 1} {
+		history, err := GetConfigHistory(s, key)
+		if err != nil {
+			t.Fatalf("Failed to get config history: %v", err)
+		}
+
+		if len(history) != count {
+			t.Errorf("Config history of %q has %d entries after trimming, expected %d", key, len(history), count)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package sunbeam
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"github.com/canonical/lxd/shared/api"
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/api/types"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/database"
+)
+
+// SetConfigParent declares the parent key a config key inherits its value
+// from when unset. Declarations creating an inheritance cycle are rejected.
+func SetConfigParent(s *state.State, key string, parent string) error {
+	if parent == "" {
+		return api.StatusErrorf(http.StatusBadRequest, "Parent must not be empty")
+	}
+
+	return transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		parents, err := database.GetConfigParents(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		// Walk up from the new parent, reaching the key means a cycle.
+		visited := map[string]bool{}
+		for current := parent; current != ""; current = parents[current] {
+			if current == key {
+				return api.StatusErrorf(http.StatusBadRequest, "Setting %q as parent of %q would create an inheritance cycle", parent, key)
+			}
+
+			if visited[current] {
+				break
+			}
+
+			visited[current] = true
+		}
+
+		err = database.SetConfigParent(ctx, tx, key, parent)
+		if err != nil {
+			return err
+		}
+
+		return recordChange(ctx, tx, "config_parents", key, database.ChangeUpdate)
+	})
+}
+
+// DeleteConfigParent removes the declared parent of a config key.
+func DeleteConfigParent(s *state.State, key string) error {
+	return transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		err := database.DeleteConfigParent(ctx, tx, key)
+		if err != nil {
+			return err
+		}
+
+		return recordChange(ctx, tx, "config_parents", key, database.ChangeDelete)
+	})
+}
+
+// GetEffectiveConfig returns the value of a config key as GetConfig does,
+// falling back to its declared parents when it is unset, along with the key
+// the value came from. If node is set, the value is rendered as a template
+// for that node.
+func GetEffectiveConfig(s *state.State, key string, node string) (types.EffectiveConfig, error) {
+	effective := types.EffectiveConfig{Key: key, Node: node}
+
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		var record *database.Node
+		var roles []string
+		if node != "" {
+			var err error
+			record, err = database.GetNode(ctx, tx, node)
+			if err != nil {
+				return err
+			}
+
+			roles, err = database.GetNodeRolesByNodeID(ctx, tx, record.ID)
+			if err != nil {
+				return err
+			}
+		}
+
+		parents, err := database.GetConfigParents(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		visited := map[string]bool{}
+		for current := key; current != ""; current = parents[current] {
+			if visited[current] {
+				return fmt.Errorf("Inheritance cycle detected resolving config %q at %q", key, current)
+			}
+
+			visited[current] = true
+
+			value, err := effectiveConfigValue(ctx, tx, current)
+			if err == nil {
+				effective.Value = value
+				effective.Source = current
+
+				if record != nil {
+					effective.Value, err = renderConfigTemplate(value, *record, roles)
+				}
+
+				return err
+			}
+
+			if !api.StatusErrorCheck(err, http.StatusNotFound) {
+				return err
+			}
+		}
+
+		return api.StatusErrorf(http.StatusNotFound, "ConfigItem not found")
+	})
+
+	return effective, err
+}
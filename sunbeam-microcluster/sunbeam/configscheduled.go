@@ -0,0 +1,139 @@
+package sunbeam
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/canonical/lxd/shared/logger"
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/api/types"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/database"
+)
+
+// ScheduleConfig records a config value that takes effect at the given time.
+// A time that is not in the future updates the config immediately.
+func ScheduleConfig(s *state.State, key string, value string, effectiveAt time.Time) error {
+	if !effectiveAt.After(time.Now()) {
+		return UpdateConfig(s, key, value)
+	}
+
+	// Invalid values are rejected now rather than when they take effect.
+	err := database.ValidateConfigValue(key, value)
+	if err != nil {
+		return err
+	}
+
+	return transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		_, err = database.CreateScheduledConfigItem(ctx, tx, key, value, effectiveAt)
+		return err
+	})
+}
+
+// ListScheduledConfig returns the config changes that have not been promoted yet.
+func ListScheduledConfig(s *state.State) ([]types.ScheduledConfig, error) {
+	scheduled := make([]types.ScheduledConfig, 0)
+
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		records, err := database.GetScheduledConfigItems(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		for _, record := range records {
+			scheduled = append(scheduled, types.ScheduledConfig{
+				ID:          record.ID,
+				Key:         record.Key,
+				Value:       record.Value,
+				EffectiveAt: record.EffectiveAt,
+			})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return scheduled, nil
+}
+
+// PromoteScheduledConfig writes the scheduled config values that have become
+// effective to the config table. Of the values of a key that are due, the
+// one taking effect last wins. It is run from the heartbeat hook on the
+// leader.
+func PromoteScheduledConfig(s *state.State) error {
+	var promoted []database.ScheduledConfigItem
+
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		due, err := database.GetDueScheduledConfigItems(ctx, tx, time.Now())
+		if err != nil {
+			return err
+		}
+
+		// Due values come oldest first, keep the last one of each key.
+		latest := map[string]database.ScheduledConfigItem{}
+		keys := []string{}
+		for _, item := range due {
+			_, ok := latest[item.Key]
+			if !ok {
+				keys = append(keys, item.Key)
+			}
+
+			latest[item.Key] = item
+		}
+
+		promoted = make([]database.ScheduledConfigItem, 0, len(keys))
+		for _, key := range keys {
+			// Writing the value drops the due values of the key.
+			err = updateConfig(ctx, tx, key, latest[key].Value)
+			if err != nil {
+				return err
+			}
+
+			promoted = append(promoted, latest[key])
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, item := range promoted {
+		logger.Info("Promoted scheduled config change", logger.Ctx{"key": item.Key, "effective_at": item.EffectiveAt})
+	}
+
+	return nil
+}
+
+// supersedeScheduledConfig drops the scheduled values of a config key that
+// are due, as a direct write of the key supersedes them. Values scheduled to
+// take effect later are kept.
+func supersedeScheduledConfig(ctx context.Context, tx *sql.Tx, key string) error {
+	return database.DeleteDueScheduledConfigItemsByKey(ctx, tx, key, time.Now())
+}
+
+// effectiveConfigValue returns the value of a config key as of now, taking
+// scheduled changes that are due but not yet promoted into account. Every
+// read of the value of a key resolves it this way. Direct writes supersede
+// the due scheduled values, so those still around took effect after the key
+// was last written.
+func effectiveConfigValue(ctx context.Context, tx *sql.Tx, key string) (string, error) {
+	due, err := database.GetDueScheduledConfigItemsByKey(ctx, tx, key, time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	if len(due) > 0 {
+		return due[len(due)-1].Value, nil
+	}
+
+	record, err := database.GetConfigItem(ctx, tx, key)
+	if err != nil {
+		return "", err
+	}
+
+	return record.Value, nil
+}
@@ -0,0 +1,119 @@
+package sunbeam
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/database"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/internal/testutil"
+)
+
+// addScheduledConfig records a scheduled config value taking effect at the
+// given time, which unlike ScheduleConfig may be in the past.
+func addScheduledConfig(t *testing.T, s *state.State, key string, value string, effectiveAt time.Time) {
+	t.Helper()
+
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		_, err := database.CreateScheduledConfigItem(ctx, tx, key, value, effectiveAt)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Failed to schedule config key %q: %v", key, err)
+	}
+}
+
+// assertConfigValue fails the test unless both GetConfig and
+// GetEffectiveConfig resolve the key to the expected value.
+func assertConfigValue(t *testing.T, s *state.State, key string, expected string) {
+	t.Helper()
+
+	value, err := GetConfig(s, key)
+	if err != nil {
+		t.Fatalf("Failed to get config key %q: %v", key, err)
+	}
+
+	effective, err := GetEffectiveConfig(s, key, "")
+	if err != nil {
+		t.Fatalf("Failed to get effective config key %q: %v", key, err)
+	}
+
+	if value != expected || effective.Value != expected {
+		t.Errorf("Config key %q is %q, effectively %q, expected %q", key, value, effective.Value, expected)
+	}
+}
+
+func TestScheduledConfigSuperseded(t *testing.T) {
+	s := testutil.NewState(t)
+
+	err := UpdateConfig(s, "region", "RegionOne")
+	if err != nil {
+		t.Fatalf("Failed to set config: %v", err)
+	}
+
+	addScheduledConfig(t, s, "region", "RegionTwo", time.Now().Add(-time.Minute))
+	addScheduledConfig(t, s, "region", "RegionFour", time.Now().Add(time.Hour))
+
+	// A due value takes effect before it is promoted.
+	assertConfigValue(t, s, "region", "RegionTwo")
+
+	err = UpdateConfig(s, "region", "RegionThree")
+	if err != nil {
+		t.Fatalf("Failed to set config: %v", err)
+	}
+
+	assertConfigValue(t, s, "region", "RegionThree")
+
+	err = PromoteScheduledConfig(s)
+	if err != nil {
+		t.Fatalf("Failed to promote scheduled config: %v", err)
+	}
+
+	assertConfigValue(t, s, "region", "RegionThree")
+
+	scheduled, err := ListScheduledConfig(s)
+	if err != nil {
+		t.Fatalf("Failed to list scheduled config: %v", err)
+	}
+
+	if len(scheduled) != 1 || scheduled[0].Value != "RegionFour" {
+		t.Errorf("Scheduled config is %+v, expected only the value not yet due", scheduled)
+	}
+}
+
+func TestPromoteScheduledConfig(t *testing.T) {
+	s := testutil.NewState(t)
+
+	addScheduledConfig(t, s, "zone", "az1", time.Now().Add(-2*time.Minute))
+	addScheduledConfig(t, s, "zone", "az2", time.Now().Add(-time.Minute))
+
+	assertConfigValue(t, s, "zone", "az2")
+
+	err := PromoteScheduledConfig(s)
+	if err != nil {
+		t.Fatalf("Failed to promote scheduled config: %v", err)
+	}
+
+	assertConfigValue(t, s, "zone", "az2")
+
+	scheduled, err := ListScheduledConfig(s)
+	if err != nil {
+		t.Fatalf("Failed to list scheduled config: %v", err)
+	}
+
+	if len(scheduled) != 0 {
+		t.Errorf("Scheduled config is %+v after promotion, expected none", scheduled)
+	}
+
+	history, err := GetConfigHistory(s, "zone")
+	if err != nil {
+		t.Fatalf("Failed to get config history: %v", err)
+	}
+
+	if len(history) != 1 {
+		t.Errorf("Config history is %+v, expected the latest due value written once", history)
+	}
+}
@@ -0,0 +1,86 @@
+package sunbeam
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"github.com/canonical/lxd/shared/api"
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/database"
+)
+
+// maxSearchPatternLength bounds the length of a config search pattern.
+const maxSearchPatternLength = 256
+
+// SearchConfig returns the config key/value pairs whose key matches the
+// given glob pattern. "*" matches any sequence of characters, including
+// dots, and "?" matches a single character; everything else matches
+// literally. The pattern must match the whole key. Terraform states and
+// locks are never returned.
+func SearchConfig(s *state.State, pattern string) (map[string]string, error) {
+	if pattern == "" {
+		return nil, api.StatusErrorf(http.StatusBadRequest, "Search pattern must not be empty")
+	}
+
+	if len(pattern) > maxSearchPatternLength {
+		return nil, api.StatusErrorf(http.StatusBadRequest, "Search pattern is longer than %d characters", maxSearchPatternLength)
+	}
+
+	matches := make(map[string]string)
+
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		records, err := database.GetConfigItems(ctx, tx)
+		if err != nil {
+			return fmt.Errorf("Failed to fetch config items: %w", err)
+		}
+
+		for _, record := range records {
+			if !isTerraformKey(record.Key) && globMatch(pattern, record.Key) {
+				matches[record.Key] = record.Value
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return matches, nil
+}
+
+// globMatch reports whether name matches the glob pattern. Only the last
+// "*" seen is ever backtracked to, so matching takes at most
+// len(pattern)*len(name) steps whatever the pattern.
+func globMatch(pattern string, name string) bool {
+	p, n := 0, 0
+	star, next := -1, 0
+
+	for n < len(name) {
+		switch {
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == name[n]):
+			p++
+			n++
+		case p < len(pattern) && pattern[p] == '*':
+			star = p
+			next = n
+			p++
+		case star >= 0:
+			// Let the last "*" absorb one more character and retry.
+			next++
+			p = star + 1
+			n = next
+		default:
+			return false
+		}
+	}
+
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+
+	return p == len(pattern)
+}
@@ -0,0 +1,173 @@
+package sunbeam
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/canonical/lxd/shared/api"
+	"github.com/canonical/lxd/shared/logger"
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/api/types"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/database"
+)
+
+// CreateConfigSnapshot stores a copy of every config key/value pair under
+// the given name. Parents, scheduled changes and Terraform states and locks
+// are not part of snapshots.
+func CreateConfigSnapshot(s *state.State, name string) (types.ConfigSnapshot, error) {
+	if name == "" {
+		return types.ConfigSnapshot{}, api.StatusErrorf(http.StatusBadRequest, "Snapshot name must not be empty")
+	}
+
+	var snapshot types.ConfigSnapshot
+
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		config, err := snapshotConfig(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		data, err := json.Marshal(config)
+		if err != nil {
+			return fmt.Errorf("Failed to encode config snapshot %q: %w", name, err)
+		}
+
+		_, err = database.CreateConfigSnapshot(ctx, tx, name, string(data))
+		if err != nil {
+			return err
+		}
+
+		record, err := database.GetConfigSnapshot(ctx, tx, name)
+		if err != nil {
+			return err
+		}
+
+		snapshot, err = configSnapshotFromRecord(*record)
+		return err
+	})
+	if err != nil {
+		return types.ConfigSnapshot{}, err
+	}
+
+	return snapshot, nil
+}
+
+// ListConfigSnapshots returns the config snapshots, sorted by name.
+func ListConfigSnapshots(s *state.State) ([]types.ConfigSnapshot, error) {
+	snapshots := []types.ConfigSnapshot{}
+
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		records, err := database.GetConfigSnapshots(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		for _, record := range records {
+			snapshot, err := configSnapshotFromRecord(record)
+			if err != nil {
+				return err
+			}
+
+			snapshots = append(snapshots, snapshot)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return snapshots, nil
+}
+
+// RestoreConfigSnapshot replaces the config with the contents of the named
+// snapshot in a single transaction and returns the changes made. Keys set
+// since the snapshot was taken are deleted. Terraform states and locks are
+// left alone, including those in snapshots taken before they were left out.
+// Each change is recorded in the config history and the change feed like any
+// other write.
+func RestoreConfigSnapshot(s *state.State, name string) (types.ConfigDiff, error) {
+	var diff types.ConfigDiff
+
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		record, err := database.GetConfigSnapshot(ctx, tx, name)
+		if err != nil {
+			return err
+		}
+
+		snapshot, err := configSnapshotFromRecord(*record)
+		if err != nil {
+			return err
+		}
+
+		diff, err = applyConfig(ctx, tx, snapshot.Config)
+		return err
+	})
+	if err != nil {
+		return types.ConfigDiff{}, err
+	}
+
+	added, changed := configDiffKeys(diff)
+
+	// Only announce the restore once it is committed.
+	logger.Info("Restored config snapshot", logger.Ctx{"name": name, "added": added, "changed": changed, "removed": diff.Removed})
+
+	return diff, nil
+}
+
+// DeleteConfigSnapshot deletes the config snapshot with the given name.
+func DeleteConfigSnapshot(s *state.State, name string) error {
+	return transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		return database.DeleteConfigSnapshot(ctx, tx, name)
+	})
+}
+
+// currentConfig returns every config key/value pair as stored, without
+// resolving parents.
+func currentConfig(ctx context.Context, tx *sql.Tx) (map[string]string, error) {
+	records, err := database.GetConfigItems(ctx, tx)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to fetch config items: %w", err)
+	}
+
+	config := make(map[string]string, len(records))
+	for _, record := range records {
+		config[record.Key] = record.Value
+	}
+
+	return config, nil
+}
+
+// snapshotConfig returns the config key/value pairs snapshots cover, every
+// one except Terraform states and locks.
+func snapshotConfig(ctx context.Context, tx *sql.Tx) (map[string]string, error) {
+	config, err := currentConfig(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	for key := range config {
+		if isTerraformKey(key) {
+			delete(config, key)
+		}
+	}
+
+	return config, nil
+}
+
+// configSnapshotFromRecord converts a stored config snapshot to its API
+// type.
+func configSnapshotFromRecord(record database.ConfigSnapshot) (types.ConfigSnapshot, error) {
+	snapshot := types.ConfigSnapshot{Name: record.Name, CreatedAt: record.CreatedAt}
+
+	err := json.Unmarshal([]byte(record.Config), &snapshot.Config)
+	if err != nil {
+		return types.ConfigSnapshot{}, fmt.Errorf("Failed to decode config snapshot %q: %w", record.Name, err)
+	}
+
+	return snapshot, nil
+}
@@ -0,0 +1,86 @@
+package sunbeam
+
+import (
+	"maps"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/canonical/lxd/shared/api"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/internal/testutil"
+)
+
+func TestConfigSnapshotRestore(t *testing.T) {
+	s := testutil.NewState(t)
+
+	config := map[string]string{"region": "RegionOne", "zone": "az1", "tfstate-openstack": "state1"}
+	for key, value := range config {
+		err := UpdateConfig(s, key, value)
+		if err != nil {
+			t.Fatalf("Failed to set config key %q: %v", key, err)
+		}
+	}
+
+	snapshot, err := CreateConfigSnapshot(s, "before")
+	if err != nil {
+		t.Fatalf("Failed to create config snapshot: %v", err)
+	}
+
+	expected := map[string]string{"region": "RegionOne", "zone": "az1"}
+	if snapshot.Name != "before" || !maps.Equal(snapshot.Config, expected) {
+		t.Fatalf("Config snapshot is %q holding %v, expected %q holding %v", snapshot.Name, snapshot.Config, "before", expected)
+	}
+
+	_, err = CreateConfigSnapshot(s, "before")
+	if !api.StatusErrorCheck(err, http.StatusConflict) {
+		t.Errorf("Expected a snapshot of an existing name to fail with 409, got %v", err)
+	}
+
+	_, err = CreateConfigSnapshot(s, "")
+	if !api.StatusErrorCheck(err, http.StatusBadRequest) {
+		t.Errorf("Expected a snapshot without a name to be rejected with 400, got %v", err)
+	}
+
+	for key, value := range map[string]string{"region": "RegionTwo", "extra": "1", "tfstate-openstack": "state2"} {
+		err = UpdateConfig(s, key, value)
+		if err != nil {
+			t.Fatalf("Failed to set config key %q: %v", key, err)
+		}
+	}
+
+	err = DeleteConfig(s, "zone")
+	if err != nil {
+		t.Fatalf("Failed to delete config: %v", err)
+	}
+
+	diff, err := RestoreConfigSnapshot(s, "before")
+	if err != nil {
+		t.Fatalf("Failed to restore config snapshot: %v", err)
+	}
+
+	if !maps.Equal(diff.Added, map[string]string{"zone": "az1"}) || len(diff.Changed) != 1 || !slices.Equal(diff.Removed, []string{"extra"}) {
+		t.Errorf("Restoring changed %+v, expected zone added back, region changed and extra removed", diff)
+	}
+
+	change, ok := diff.Changed["region"]
+	if !ok || change.Old != "RegionTwo" || change.New != "RegionOne" {
+		t.Errorf("Restoring changed region by %+v, expected it back from RegionTwo to RegionOne", change)
+	}
+
+	restored, err := GetConfigBatch(s, []string{"region", "zone", "extra", "tfstate-openstack"})
+	if err != nil {
+		t.Fatalf("Failed to get config batch: %v", err)
+	}
+
+	// Terraform states are left as they are.
+	expected["tfstate-openstack"] = "state2"
+	if !maps.Equal(restored, expected) {
+		t.Errorf("Config after restoring is %v, expected %v", restored, expected)
+	}
+
+	_, err = RestoreConfigSnapshot(s, "missing")
+	if !api.StatusErrorCheck(err, http.StatusNotFound) {
+		t.Errorf("Expected restoring a missing snapshot to fail with 404, got %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+package sunbeam
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/canonical/lxd/shared/api"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/database"
+)
+
+// renderConfigTemplate interpolates the node references of a config value.
+// References take the form ${node.<attribute>}, or ${node.label.<key>} for a
+// label of the node metadata, "$${" produces a literal "${". A reference to
+// an unknown attribute or to a label the node does not have is an error.
+func renderConfigTemplate(value string, node database.Node, roles []string) (string, error) {
+	var out strings.Builder
+
+	for {
+		start := strings.Index(value, "${")
+		if start < 0 {
+			out.WriteString(value)
+			return out.String(), nil
+		}
+
+		if start > 0 && value[start-1] == '$' {
+			out.WriteString(value[:start-1])
+			out.WriteString("${")
+			value = value[start+2:]
+			continue
+		}
+
+		end := strings.Index(value[start:], "}")
+		if end < 0 {
+			return "", api.StatusErrorf(http.StatusBadRequest, "Unterminated reference in config value at %q", value[start:])
+		}
+
+		ref := value[start+2 : start+end]
+		resolved, err := resolveNodeReference(ref, node, roles)
+		if err != nil {
+			return "", err
+		}
+
+		out.WriteString(value[:start])
+		out.WriteString(resolved)
+		value = value[start+end+1:]
+	}
+}
+
+// resolveNodeReference returns the value of a node attribute reference.
+// Labels are the top level keys of the node metadata holding a string.
+func resolveNodeReference(ref string, node database.Node, roles []string) (string, error) {
+	key, ok := strings.CutPrefix(ref, "node.label.")
+	if ok {
+		label, ok := nodeMetadata(node)[key].(string)
+		if !ok {
+			return "", api.StatusErrorf(http.StatusBadRequest, "Undefined reference ${%s} for node %q, the node has no %q label", ref, node.Name, key)
+		}
+
+		return label, nil
+	}
+
+	switch ref {
+	case "node.name":
+		return node.Name, nil
+	case "node.member":
+		return node.Member, nil
+	case "node.system_id":
+		return node.SystemID, nil
+	case "node.machine_id":
+		return strconv.Itoa(node.MachineID), nil
+	case "node.owner":
+		return node.Owner, nil
+	case "node.role":
+		return strings.Join(roles, ","), nil
+	}
+
+	return "", api.StatusErrorf(http.StatusBadRequest, "Undefined reference ${%s} for node %q", ref, node.Name)
+}
@@ -0,0 +1,81 @@
+package sunbeam
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/canonical/lxd/shared/api"
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/api/types"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/database"
+)
+
+// configCoercion checks a config value is of a type with the validator of
+// that type, then converts it.
+type configCoercion struct {
+	validate database.ConfigValidator
+	convert  func(value string) any
+}
+
+// configCoercions maps the types config values can be read as to how
+// values are coerced to them.
+var configCoercions = map[string]configCoercion{
+	"bool": {
+		validate: database.ValidateBool,
+		convert:  func(value string) any { return value == "true" },
+	},
+	"duration": {
+		validate: database.ValidateDuration,
+		convert: func(value string) any {
+			d, _ := time.ParseDuration(value)
+			return d.Seconds()
+		},
+	},
+	"float": {
+		validate: database.ValidateFloat,
+		convert: func(value string) any {
+			f, _ := strconv.ParseFloat(value, 64)
+			return f
+		},
+	},
+	"int": {
+		validate: database.ValidateInt,
+		convert: func(value string) any {
+			n, _ := strconv.ParseInt(value, 10, 64)
+			return n
+		},
+	},
+}
+
+// GetTypedConfig returns the currently effective value of a config key
+// coerced to the given type, one of "bool", "duration", "float" or "int".
+// Durations are returned in seconds.
+func GetTypedConfig(s *state.State, key string, valueType string) (types.TypedConfig, error) {
+	coercion, ok := configCoercions[valueType]
+	if !ok {
+		valueTypes := make([]string, 0, len(configCoercions))
+		for t := range configCoercions {
+			valueTypes = append(valueTypes, t)
+		}
+
+		slices.Sort(valueTypes)
+
+		return types.TypedConfig{}, api.StatusErrorf(http.StatusBadRequest, "Invalid type %q, expected one of %s", valueType, strings.Join(valueTypes, ", "))
+	}
+
+	value, err := GetConfig(s, key)
+	if err != nil {
+		return types.TypedConfig{}, err
+	}
+
+	err = coercion.validate(value)
+	if err != nil {
+		return types.TypedConfig{}, api.StatusErrorf(http.StatusBadRequest, "Value %q of config key %q is not a %s: %v", value, key, valueType, err)
+	}
+
+	return types.TypedConfig{Key: key, Type: valueType, Value: coercion.convert(value)}, nil
+}
@@ -0,0 +1,70 @@
+package sunbeam
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/canonical/lxd/shared/api"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/internal/testutil"
+)
+
+func TestGetTypedConfig(t *testing.T) {
+	s := testutil.NewState(t)
+
+	config := map[string]string{
+		"test.enabled":  "true",
+		"test.interval": "1m30s",
+		"test.ratio":    "0.75",
+		"test.count":    "42",
+		"test.name":     "RegionOne",
+	}
+
+	for key, value := range config {
+		err := UpdateConfig(s, key, value)
+		if err != nil {
+			t.Fatalf("Failed to set config key %q: %v", key, err)
+		}
+	}
+
+	tests := []struct {
+		key       string
+		valueType string
+		value     any
+	}{
+		{key: "test.enabled", valueType: "bool", value: true},
+		{key: "test.interval", valueType: "duration", value: 90.0},
+		{key: "test.ratio", valueType: "float", value: 0.75},
+		{key: "test.count", valueType: "int", value: int64(42)},
+		{key: "test.count", valueType: "float", value: 42.0},
+	}
+
+	for _, test := range tests {
+		typed, err := GetTypedConfig(s, test.key, test.valueType)
+		if err != nil {
+			t.Fatalf("Failed to get %q as %s: %v", test.key, test.valueType, err)
+		}
+
+		if typed.Key != test.key || typed.Type != test.valueType || typed.Value != test.value {
+			t.Errorf("Got %q as %s %v (%T), expected %v (%T)", test.key, test.valueType, typed.Value, typed.Value, test.value, test.value)
+		}
+	}
+
+	failures := []struct {
+		key       string
+		valueType string
+		status    int
+	}{
+		{key: "test.name", valueType: "int", status: http.StatusBadRequest},
+		{key: "test.ratio", valueType: "bool", status: http.StatusBadRequest},
+		{key: "test.count", valueType: "string", status: http.StatusBadRequest},
+		{key: "test.unset", valueType: "int", status: http.StatusNotFound},
+	}
+
+	for _, failure := range failures {
+		_, err := GetTypedConfig(s, failure.key, failure.valueType)
+		if !api.StatusErrorCheck(err, failure.status) {
+			t.Errorf("Expected getting %q as %s to fail with %d, got %v", failure.key, failure.valueType, failure.status, err)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package sunbeam
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/canonical/lxd/shared/api"
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/api/types"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/database"
+)
+
+// nodesSoftDeleteKey is the config key holding whether deleted nodes are
+// kept as tombstones rather than removed.
+const nodesSoftDeleteKey = "nodes.soft-delete"
+
+// nodesTombstoneRetentionKey is the config key holding the number of days
+// soft deleted nodes are kept by compaction.
+const nodesTombstoneRetentionKey = "nodes.tombstone-retention-days"
+
+// defaultNodesTombstoneRetention applies when no retention is configured.
+const defaultNodesTombstoneRetention = 90
+
+// softDeleteNode keeps a tombstone of the node with the given name if soft
+// delete is enabled, before the node is deleted within the same transaction.
+// It returns whether a tombstone was kept.
+func softDeleteNode(ctx context.Context, tx *sql.Tx, name string) (bool, error) {
+	value, err := effectiveConfigValue(ctx, tx, nodesSoftDeleteKey)
+	if err != nil && !api.StatusErrorCheck(err, http.StatusNotFound) {
+		return false, err
+	}
+
+	if value != "true" {
+		return false, nil
+	}
+
+	record, err := database.GetNode(ctx, tx, name)
+	if err != nil {
+		return false, err
+	}
+
+	roles, err := database.GetNodeRolesByNodeID(ctx, tx, record.ID)
+	if err != nil {
+		return false, err
+	}
+
+	node, err := json.Marshal(nodeFromRecord(*record, roles))
+	if err != nil {
+		return false, fmt.Errorf("Failed to encode node %q: %w", name, err)
+	}
+
+	err = database.CreateDeletedNode(ctx, tx, name, string(node), time.Now())
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// ListDeletedNodes returns the soft deleted nodes holding all the given
+// roles and labels, filtered by owner if provided. A name may be listed
+// several times if nodes of that name were deleted more than once.
+func ListDeletedNodes(s *state.State, roles []string, owner *string, labels []database.NodeLabel) (types.Nodes, error) {
+	nodes := types.Nodes{}
+
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		records, err := database.GetDeletedNodes(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		for _, record := range records {
+			var node types.Node
+			err = json.Unmarshal([]byte(record.Node), &node)
+			if err != nil {
+				return fmt.Errorf("Failed to decode deleted node %q: %w", record.Name, err)
+			}
+
+			if owner != nil && node.Owner != *owner {
+				continue
+			}
+
+			if !hasAllRoles(node.Role, roles) || !hasAllLabels(node.Metadata, labels) {
+				continue
+			}
+
+			deletedAt := record.DeletedAt
+			node.DeletedAt = &deletedAt
+			nodes = append(nodes, node)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return nodes, nil
+}
+
+// hasAllRoles returns whether held contains each of the wanted roles.
+func hasAllRoles(held []string, wanted []string) bool {
+	for _, role := range wanted {
+		if !slices.Contains(held, role) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// hasAllLabels returns whether metadata holds each of the wanted labels, as
+// a string value.
+func hasAllLabels(metadata map[string]any, labels []database.NodeLabel) bool {
+	for _, label := range labels {
+		value, ok := metadata[label.Key].(string)
+		if !ok || value != label.Value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// PurgeDeletedNodes permanently deletes the nodes soft deleted before the
+// given time and returns how many were deleted.
+func PurgeDeletedNodes(s *state.State, before time.Time) (int64, error) {
+	return deleteInBatches(s, func(ctx context.Context, tx *sql.Tx) (int64, error) {
+		return database.DeleteDeletedNodesBefore(ctx, tx, before, compactBatchSize)
+	})
+}
+
+// nodesTombstoneRetention returns the number of days soft deleted nodes are
+// kept, as set in the config or the default.
+func nodesTombstoneRetention(s *state.State) (int, error) {
+	value, err := GetConfig(s, nodesTombstoneRetentionKey)
+	if err != nil {
+		if api.StatusErrorCheck(err, http.StatusNotFound) {
+			return defaultNodesTombstoneRetention, nil
+		}
+
+		return 0, err
+	}
+
+	days, err := strconv.Atoi(value)
+	if err != nil || days <= 0 {
+		return 0, api.StatusErrorf(http.StatusBadRequest, "Invalid %q value %q", nodesTombstoneRetentionKey, value)
+	}
+
+	return days, nil
+}
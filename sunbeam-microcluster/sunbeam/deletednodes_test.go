@@ -0,0 +1,90 @@
+package sunbeam
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/api/types"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/internal/testutil"
+)
+
+// deletedNodeRoles returns the roles of each of the given deleted nodes, in
+// order.
+func deletedNodeRoles(nodes types.Nodes) [][]string {
+	roles := make([][]string, 0, len(nodes))
+	for _, node := range nodes {
+		roles = append(roles, node.Role)
+	}
+
+	return roles
+}
+
+func TestSoftDeleteNode(t *testing.T) {
+	s := testutil.NewState(t)
+	addTestNodes(t, s, map[string][]string{"node1": {"compute"}}, "node1", "node2", "node3")
+
+	err := DeleteNode(s, "node3")
+	if err != nil {
+		t.Fatalf("Failed to delete node: %v", err)
+	}
+
+	err = UpdateConfig(s, "nodes.soft-delete", "true")
+	if err != nil {
+		t.Fatalf("Failed to enable soft delete: %v", err)
+	}
+
+	err = DeleteNode(s, "node1")
+	if err != nil {
+		t.Fatalf("Failed to delete node: %v", err)
+	}
+
+	names := nodeNames(t, s)
+	if !slices.Equal(names, []string{"node2"}) {
+		t.Errorf("Nodes are %v after deleting node1, expected only node2", names)
+	}
+
+	deleted, err := ListDeletedNodes(s, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to list deleted nodes: %v", err)
+	}
+
+	if len(deleted) != 1 || deleted[0].Name != "node1" || deleted[0].DeletedAt == nil || !slices.Equal(deleted[0].Role, []string{"compute"}) {
+		t.Fatalf("Deleted nodes are %+v, expected node1 alone, with its roles and deletion time", deleted)
+	}
+
+	// The name of a soft deleted node is free for a new node.
+	addTestNodes(t, s, map[string][]string{"node1": {"storage"}}, "node1")
+
+	err = DeleteNode(s, "node1")
+	if err != nil {
+		t.Fatalf("Failed to delete the new node1: %v", err)
+	}
+
+	deleted, err = ListDeletedNodes(s, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to list deleted nodes: %v", err)
+	}
+
+	if !slices.EqualFunc(deletedNodeRoles(deleted), [][]string{{"compute"}, {"storage"}}, slices.Equal[[]string]) {
+		t.Fatalf("Deleted nodes have roles %v, expected both nodes named node1 kept", deletedNodeRoles(deleted))
+	}
+
+	purged, err := PurgeDeletedNodes(s, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to purge deleted nodes: %v", err)
+	}
+
+	if purged != 0 {
+		t.Errorf("Purged %d nodes deleted before an hour ago, expected none", purged)
+	}
+
+	purged, err = PurgeDeletedNodes(s, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to purge deleted nodes: %v", err)
+	}
+
+	if purged != 2 {
+		t.Errorf("Purged %d nodes deleted until an hour from now, expected 2", purged)
+	}
+}
@@ -0,0 +1,115 @@
+package sunbeam
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/canonical/go-dqlite/client"
+	"github.com/canonical/go-dqlite/driver"
+	"github.com/canonical/lxd/shared"
+	"github.com/canonical/microcluster/state"
+)
+
+// dqliteDialTimeout bounds the time spent connecting to a dqlite node.
+const dqliteDialTimeout = 10 * time.Second
+
+// openDqlite opens a database handle of its own on the dqlite database of
+// the cluster, next to the one MicroCluster manages. It is meant for
+// statements that cannot run within a transaction, as MicroCluster only runs
+// transactions. The caller closes the handle.
+func openDqlite(ctx context.Context, s *state.State) (*sql.DB, error) {
+	store := client.NewInmemNodeStore()
+	err := store.Set(ctx, []client.NodeInfo{{Address: s.Address().URL.Host}})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to set up the dqlite node store: %w", err)
+	}
+
+	dial := func(ctx context.Context, address string) (net.Conn, error) {
+		return dialDqlite(ctx, s, address)
+	}
+
+	dqliteDriver, err := driver.New(store, driver.WithDialFunc(dial))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create the dqlite driver: %w", err)
+	}
+
+	connector, err := dqliteDriver.OpenConnector(filepath.Base(s.OS.DatabasePath()))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open the database: %w", err)
+	}
+
+	return sql.OpenDB(connector), nil
+}
+
+// dialDqlite connects to the dqlite node at the given address through the
+// internal database endpoint of its member, as MicroCluster does.
+func dialDqlite(ctx context.Context, s *state.State, address string) (net.Conn, error) {
+	clusterCert, err := s.ClusterCert().PublicKeyX509()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse the cluster certificate: %w", err)
+	}
+
+	// Trust the cluster certificate to authenticate members.
+	clusterCert.IsCA = true
+	clusterCert.KeyUsage = x509.KeyUsageCertSign
+
+	config := shared.InitTLSConfig()
+	config.Certificates = []tls.Certificate{s.ServerCert().KeyPair()}
+	config.RootCAs = x509.NewCertPool()
+	config.RootCAs.AddCert(clusterCert)
+	if len(clusterCert.DNSNames) > 0 {
+		config.ServerName = clusterCert.DNSNames[0]
+	}
+
+	dialer := &tls.Dialer{NetDialer: &net.Dialer{Timeout: dqliteDialTimeout}, Config: config}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to connect to %q: %w", address, err)
+	}
+
+	err = upgradeDqlite(ctx, conn, address)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// upgradeDqlite asks the member at the other end of conn to hand the
+// connection over to dqlite.
+func upgradeDqlite(ctx context.Context, conn net.Conn, address string) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("https://%s/cluster/internal/database", address), nil)
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("Upgrade", "dqlite")
+	request.Header.Set("X-Dqlite-Version", "1")
+
+	err = request.Write(conn)
+	if err != nil {
+		return fmt.Errorf("Failed to send the dqlite upgrade request to %q: %w", address, err)
+	}
+
+	response, err := http.ReadResponse(bufio.NewReader(conn), request)
+	if err != nil {
+		return fmt.Errorf("Failed to read the dqlite upgrade response of %q: %w", address, err)
+	}
+
+	_ = response.Body.Close()
+
+	if response.StatusCode != http.StatusSwitchingProtocols || response.Header.Get("Upgrade") != "dqlite" {
+		return fmt.Errorf("Failed to upgrade the connection to %q to dqlite: %s", address, response.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,141 @@
+package sunbeam
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Event describes a committed mutation, or a cluster event raised by a hook.
+type Event struct {
+	// Seq is the change feed sequence of the mutation, 0 for hook events.
+	Seq    int64
+	Entity string
+	Key    string
+	Action string
+	// Value is the value a config key was set to by the mutation, nil for
+	// deletions and other entities.
+	Value     *string
+	Timestamp time.Time
+}
+
+// SubscriberMode sets what happens when a subscriber's buffer is full.
+type SubscriberMode int
+
+const (
+	// SubscriberDrop discards events that don't fit in the subscriber's
+	// buffer, the publisher never waits. Dropped events are counted.
+	SubscriberDrop SubscriberMode = iota
+
+	// SubscriberBlock makes the publisher wait until the subscriber has room
+	// or the subscription is closed, slowing down the write path.
+	SubscriberBlock
+)
+
+// Events is the event bus the write paths and hooks publish to.
+var Events = NewEventBus()
+
+// EventBus fans out published events to its subscribers.
+type EventBus struct {
+	mu          sync.RWMutex
+	subscribers map[*Subscription]struct{}
+}
+
+// Subscription receives the events published on a bus.
+type Subscription struct {
+	// C delivers the events. It is closed when the subscription is closed.
+	C <-chan Event
+
+	bus       *EventBus
+	ch        chan Event
+	mode      SubscriberMode
+	done      chan struct{}
+	closeOnce sync.Once
+	dropped   atomic.Uint64
+}
+
+// NewEventBus returns an event bus without subscribers.
+func NewEventBus() *EventBus {
+	return &EventBus{subscribers: map[*Subscription]struct{}{}}
+}
+
+// Subscribe registers a subscriber with a buffer of the given size.
+func (b *EventBus) Subscribe(buffer int, mode SubscriberMode) *Subscription {
+	ch := make(chan Event, buffer)
+	sub := &Subscription{
+		C:    ch,
+		bus:  b,
+		ch:   ch,
+		mode: mode,
+		done: make(chan struct{}),
+	}
+
+	b.mu.Lock()
+	b.subscribers[sub] = struct{}{}
+	b.mu.Unlock()
+
+	return sub
+}
+
+// Publish delivers an event to every subscriber according to its mode.
+func (b *EventBus) Publish(event Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for sub := range b.subscribers {
+		sub.deliver(event)
+	}
+}
+
+// Close unregisters the subscription and closes its channel.
+func (sub *Subscription) Close() {
+	sub.closeOnce.Do(func() {
+		close(sub.done)
+
+		// Wait for in-flight publishes to finish before closing the channel.
+		sub.bus.mu.Lock()
+		delete(sub.bus.subscribers, sub)
+		sub.bus.mu.Unlock()
+
+		close(sub.ch)
+	})
+}
+
+// Dropped returns the number of events discarded because the buffer was full.
+func (sub *Subscription) Dropped() uint64 {
+	return sub.dropped.Load()
+}
+
+// deliver sends an event to the subscriber.
+func (sub *Subscription) deliver(event Event) {
+	if sub.mode == SubscriberBlock {
+		select {
+		case sub.ch <- event:
+		case <-sub.done:
+		}
+
+		return
+	}
+
+	select {
+	case sub.ch <- event:
+	default:
+		sub.dropped.Add(1)
+	}
+}
+
+// pendingEventsKey is the context key holding the events of a transaction
+// until it commits.
+type pendingEventsKey struct{}
+
+// queueEvent queues an event to be published once the transaction running
+// with ctx commits.
+func queueEvent(ctx context.Context, event Event) {
+	pending, ok := ctx.Value(pendingEventsKey{}).(*[]Event)
+	if !ok {
+		return
+	}
+
+	*pending = append(*pending, event)
+}
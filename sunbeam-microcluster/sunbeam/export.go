@@ -0,0 +1,112 @@
+package sunbeam
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"sort"
+
+	"github.com/canonical/microcluster/cluster"
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/api/types"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/database"
+)
+
+const (
+	// ansibleUngrouped is the group of nodes without any role.
+	ansibleUngrouped = "ungrouped"
+	// ansibleCordoned is the group of nodes under maintenance.
+	ansibleCordoned = "cordoned"
+	// ansibleOffline is the group of nodes that stopped heartbeating.
+	ansibleOffline = "offline"
+)
+
+// ExportNodesAnsible renders the nodes as an Ansible dynamic inventory.
+//
+// Every role is a group holding the nodes with that role, so a node with
+// several roles is in several groups. Nodes without a role are in the
+// "ungrouped" group. Cordoned nodes are also in the "cordoned" group, and
+// offline nodes in the "offline" group, so playbooks can exclude them with
+// "!cordoned:!offline". All groups are children of
+// "all". Nodes that are cluster members get their member address as
+// ansible_host, and every node has its sunbeam attributes as host variables.
+func ExportNodesAnsible(s *state.State) (types.AnsibleInventory, error) {
+	groups := map[string][]string{}
+	meta := types.AnsibleMeta{HostVars: map[string]types.AnsibleHostVars{}}
+
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		records, err := database.GetNodes(ctx, tx)
+		if err != nil {
+			return fmt.Errorf("Failed to fetch nodes: %w", err)
+		}
+
+		members, err := cluster.GetInternalClusterMembers(ctx, tx)
+		if err != nil {
+			return fmt.Errorf("Failed to fetch cluster members: %w", err)
+		}
+
+		addresses := make(map[string]string, len(members))
+		for _, member := range members {
+			host, _, err := net.SplitHostPort(member.Address)
+			if err != nil {
+				host = member.Address
+			}
+
+			addresses[member.Name] = host
+		}
+
+		allRoles, err := database.GetNodeRoles(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		for _, node := range records {
+			roles := nodeRoles(allRoles[node.ID])
+
+			if len(roles) == 0 {
+				groups[ansibleUngrouped] = append(groups[ansibleUngrouped], node.Name)
+			}
+
+			for _, role := range roles {
+				groups[role] = append(groups[role], node.Name)
+			}
+
+			if node.Cordoned {
+				groups[ansibleCordoned] = append(groups[ansibleCordoned], node.Name)
+			}
+
+			if node.Status == database.NodeStatusOffline {
+				groups[ansibleOffline] = append(groups[ansibleOffline], node.Name)
+			}
+
+			meta.HostVars[node.Name] = types.AnsibleHostVars{
+				AnsibleHost: addresses[node.Name],
+				Roles:       roles,
+				MachineID:   node.MachineID,
+				SystemID:    node.SystemID,
+				Owner:       node.Owner,
+				Cordoned:    node.Cordoned,
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	inventory := types.AnsibleInventory{"_meta": meta}
+
+	children := make([]string, 0, len(groups))
+	for name, hosts := range groups {
+		inventory[name] = types.AnsibleGroup{Hosts: hosts}
+		children = append(children, name)
+	}
+
+	sort.Strings(children)
+	inventory["all"] = types.AnsibleGroup{Children: children}
+
+	return inventory, nil
+}
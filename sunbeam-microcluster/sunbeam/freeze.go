@@ -0,0 +1,74 @@
+package sunbeam
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/canonical/lxd/shared/api"
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/api/types"
+)
+
+// clusterFreezeKey is the config key holding the cluster freeze state. While
+// frozen, no member can join or be removed from the cluster.
+const clusterFreezeKey = "cluster.freeze"
+
+// GetClusterFreeze returns the cluster freeze state
+func GetClusterFreeze(s *state.State) (types.ClusterFreeze, error) {
+	var freeze types.ClusterFreeze
+
+	value, err := GetConfig(s, clusterFreezeKey)
+	if err != nil {
+		if api.StatusErrorCheck(err, http.StatusNotFound) {
+			return freeze, nil
+		}
+
+		return freeze, err
+	}
+
+	err = json.Unmarshal([]byte(value), &freeze)
+	if err != nil {
+		return freeze, fmt.Errorf("Invalid %q value: %w", clusterFreezeKey, err)
+	}
+
+	return freeze, nil
+}
+
+// SetClusterFreeze freezes or unfreezes cluster membership
+func SetClusterFreeze(s *state.State, freeze types.ClusterFreeze) error {
+	if !freeze.Frozen {
+		freeze.Reason = ""
+	}
+
+	value, err := json.Marshal(freeze)
+	if err != nil {
+		return fmt.Errorf("Failed to marshal freeze state: %w", err)
+	}
+
+	return transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		return updateConfig(ctx, tx, clusterFreezeKey, string(value))
+	})
+}
+
+// VerifyNotFrozen rejects a membership change of the named member while the
+// cluster is frozen. The action describes the change for the error message.
+func VerifyNotFrozen(s *state.State, action string, name string) error {
+	freeze, err := GetClusterFreeze(s)
+	if err != nil {
+		return err
+	}
+
+	if !freeze.Frozen {
+		return nil
+	}
+
+	if freeze.Reason != "" {
+		return api.StatusErrorf(http.StatusConflict, "Cannot %s %q, cluster membership is frozen: %s", action, name, freeze.Reason)
+	}
+
+	return api.StatusErrorf(http.StatusConflict, "Cannot %s %q, cluster membership is frozen", action, name)
+}
@@ -0,0 +1,78 @@
+package sunbeam
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+
+	dqliteClient "github.com/canonical/go-dqlite/client"
+	"github.com/canonical/lxd/shared/api"
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/api/types"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/database"
+)
+
+// GetHealth reports whether the local member can serve reads and reach the
+// dqlite leader, along with its dqlite role and whether the voters have an
+// online majority. A member that cannot do either is unavailable.
+func GetHealth(s *state.State) (types.Health, error) {
+	health := types.Health{Name: s.Name()}
+
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		var err error
+		health.SchemaVersion, err = database.GetSchemaExtensionsVersion(ctx, tx)
+		return err
+	})
+	if err != nil {
+		return health, api.StatusErrorf(http.StatusServiceUnavailable, "Database cannot serve reads: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(s.Context, leaderTimeout)
+	defer cancel()
+
+	client, err := s.Database.Leader(ctx)
+	if err != nil {
+		return health, api.StatusErrorf(http.StatusServiceUnavailable, "Failed to connect to the dqlite leader: %v", err)
+	}
+
+	defer func() { _ = client.Close() }()
+
+	leader, err := client.Leader(ctx)
+	if err != nil {
+		return health, api.StatusErrorf(http.StatusServiceUnavailable, "Failed to get the dqlite leader: %v", err)
+	}
+
+	health.Leader = leader.Address
+
+	members, err := clusterMembers(ctx, s, client)
+	if err != nil {
+		return health, api.StatusErrorf(http.StatusServiceUnavailable, "Failed to get the cluster members: %v", err)
+	}
+
+	health.Role, health.Quorum = memberQuorum(members, s.Address().URL.Host)
+
+	return health, nil
+}
+
+// memberQuorum returns the dqlite role of the member at the given address,
+// empty if it is not a member, and whether a majority of the voters is
+// online.
+func memberQuorum(members []clusterMember, address string) (string, bool) {
+	role := ""
+	voters, onlineVoters := 0, 0
+	for _, member := range members {
+		if member.Address == address {
+			role = member.Role.String()
+		}
+
+		if member.Role == dqliteClient.Voter {
+			voters++
+			if member.Online {
+				onlineVoters++
+			}
+		}
+	}
+
+	return role, onlineVoters*2 > voters
+}
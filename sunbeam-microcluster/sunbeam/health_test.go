@@ -0,0 +1,53 @@
+package sunbeam
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	dqliteClient "github.com/canonical/go-dqlite/client"
+	"github.com/canonical/lxd/shared/api"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/internal/testutil"
+)
+
+func TestMemberQuorum(t *testing.T) {
+	tests := []struct {
+		name    string
+		online  []bool
+		role    string
+		quorum  bool
+		address string
+	}{
+		{name: "healthy", online: []bool{true, true, true}, role: "voter", quorum: true, address: "10.0.0.1:7000"},
+		{name: "one voter offline", online: []bool{true, false, true}, role: "voter", quorum: true, address: "10.0.0.1:7000"},
+		{name: "degraded", online: []bool{true, false, false}, role: "voter", quorum: false, address: "10.0.0.1:7000"},
+		{name: "stand-by", online: []bool{true, true, true}, role: "stand-by", quorum: true, address: "10.0.0.4:7000"},
+		{name: "not a member", online: []bool{true, true, true}, role: "", quorum: true, address: "10.0.0.9:7000"},
+	}
+
+	for _, test := range tests {
+		members := []clusterMember{
+			{Name: "member1", Address: "10.0.0.1:7000", Role: dqliteClient.Voter, Online: test.online[0]},
+			{Name: "member2", Address: "10.0.0.2:7000", Role: dqliteClient.Voter, Online: test.online[1]},
+			{Name: "member3", Address: "10.0.0.3:7000", Role: dqliteClient.Voter, Online: test.online[2]},
+			{Name: "member4", Address: "10.0.0.4:7000", Role: dqliteClient.StandBy, Online: false},
+		}
+
+		role, quorum := memberQuorum(members, test.address)
+		if role != test.role || quorum != test.quorum {
+			t.Errorf("%s: member has role %q with quorum %v, expected %q with %v", test.name, role, quorum, test.role, test.quorum)
+		}
+	}
+}
+
+func TestGetHealthDatabaseUnavailable(t *testing.T) {
+	s := testutil.NewState(t)
+
+	setFailingTransactions(t, s, errors.New("database is unreachable"))
+
+	_, err := GetHealth(s)
+	if !api.StatusErrorCheck(err, http.StatusServiceUnavailable) {
+		t.Fatalf("Expected a member that cannot serve reads to be unhealthy with 503, got %v", err)
+	}
+}
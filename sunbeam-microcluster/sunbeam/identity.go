@@ -0,0 +1,172 @@
+package sunbeam
+
+import (
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+
+	"gopkg.in/yaml.v2"
+)
+
+// clientAuth holds the CA bundle client certificates are verified against
+// and the mapping of certificate subjects to identities, along with the files
+// they were loaded from so they can be reloaded.
+var clientAuth struct {
+	mu             sync.RWMutex
+	caFile         string
+	identitiesFile string
+	required       bool
+	roots          *x509.CertPool
+	identities     map[string]string
+}
+
+// LoadClientAuth configures client certificate identities. caFile is a PEM
+// bundle of the CAs trusted to issue client certificates. identitiesFile is
+// an optional YAML mapping of certificate subjects, either the full
+// distinguished name or the common name, to identities. Without a CA bundle
+// client certificates do not map to identities, and any configuration loaded
+// before is dropped. If required is set, mutating requests must present a
+// certificate issued by one of the CAs.
+func LoadClientAuth(caFile string, identitiesFile string, required bool) error {
+	var roots *x509.CertPool
+	var identities map[string]string
+	if caFile == "" {
+		if identitiesFile != "" {
+			return fmt.Errorf("A client CA bundle is required to map certificate identities")
+		}
+
+		if required {
+			return fmt.Errorf("A client CA bundle is required to require client certificates")
+		}
+	} else {
+		var err error
+		roots, identities, err = readClientAuth(caFile, identitiesFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	clientAuth.mu.Lock()
+	defer clientAuth.mu.Unlock()
+
+	clientAuth.caFile = caFile
+	clientAuth.identitiesFile = identitiesFile
+	clientAuth.required = required
+	clientAuth.roots = roots
+	clientAuth.identities = identities
+
+	return nil
+}
+
+// ReloadClientAuth reads the client CA bundle and identities again from the
+// files they were loaded from. The current configuration is kept if either
+// cannot be read.
+func ReloadClientAuth() error {
+	clientAuth.mu.RLock()
+	caFile := clientAuth.caFile
+	identitiesFile := clientAuth.identitiesFile
+	clientAuth.mu.RUnlock()
+
+	if caFile == "" {
+		return nil
+	}
+
+	roots, identities, err := readClientAuth(caFile, identitiesFile)
+	if err != nil {
+		return err
+	}
+
+	clientAuth.mu.Lock()
+	defer clientAuth.mu.Unlock()
+
+	clientAuth.roots = roots
+	clientAuth.identities = identities
+
+	return nil
+}
+
+// ClientCertRequired returns whether mutating requests must present a client
+// certificate issued by the client CA.
+func ClientCertRequired() bool {
+	clientAuth.mu.RLock()
+	defer clientAuth.mu.RUnlock()
+
+	return clientAuth.required
+}
+
+// ClientCAs returns the pool of CAs trusted to issue client certificates,
+// nil if no client CA bundle is configured.
+func ClientCAs() *x509.CertPool {
+	clientAuth.mu.RLock()
+	defer clientAuth.mu.RUnlock()
+
+	return clientAuth.roots
+}
+
+// readClientAuth reads the client CA bundle and the optional identities file.
+func readClientAuth(caFile string, identitiesFile string) (*x509.CertPool, map[string]string, error) {
+	bundle, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to read client CA bundle: %w", err)
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(bundle) {
+		return nil, nil, fmt.Errorf("No certificates found in client CA bundle %q", caFile)
+	}
+
+	identities := make(map[string]string)
+	if identitiesFile != "" {
+		data, err := os.ReadFile(identitiesFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Failed to read client identities: %w", err)
+		}
+
+		err = yaml.Unmarshal(data, &identities)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Failed to parse client identities %q: %w", identitiesFile, err)
+		}
+	}
+
+	return roots, identities, nil
+}
+
+// CertificateIdentity returns the identity of the caller presenting the
+// given certificate chain. The leaf must verify against the client CA
+// bundle; its subject is then mapped to an identity, or used as is when
+// unmapped. ok is false when no identity can be derived, in which case
+// callers fall back to their other means of identification.
+func CertificateIdentity(chain []*x509.Certificate) (identity string, ok bool) {
+	clientAuth.mu.RLock()
+	defer clientAuth.mu.RUnlock()
+
+	if clientAuth.roots == nil || len(chain) == 0 {
+		return "", false
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	leaf := chain[0]
+	_, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         clientAuth.roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		return "", false
+	}
+
+	subject := leaf.Subject.String()
+	for _, key := range []string{subject, leaf.Subject.CommonName} {
+		identity, ok := clientAuth.identities[key]
+		if ok {
+			return identity, true
+		}
+	}
+
+	return subject, true
+}
@@ -0,0 +1,122 @@
+package sunbeam
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/internal/testutil"
+)
+
+// loadTestClientCA loads a new CA as the client CA, requiring client
+// certificates on mutating requests if required is set, and returns the path
+// of its bundle along with a function issuing client certificates from it.
+// The client CA is unloaded when the test ends.
+func loadTestClientCA(t *testing.T, required bool) (string, func(commonName string) tls.Certificate) {
+	t.Helper()
+
+	caFile, issue := testutil.NewClientCA(t)
+
+	err := LoadClientAuth(caFile, "", required)
+	if err != nil {
+		t.Fatalf("Failed to load client CA bundle: %v", err)
+	}
+
+	t.Cleanup(func() { _ = LoadClientAuth("", "", false) })
+
+	return caFile, issue
+}
+
+func TestCertificateIdentity(t *testing.T) {
+	caFile, issue := loadTestClientCA(t, true)
+
+	identitiesFile := filepath.Join(t.TempDir(), "identities.yaml")
+	err := os.WriteFile(identitiesFile, []byte("operator: admin\n"), 0600)
+	if err != nil {
+		t.Fatalf("Failed to write client identities: %v", err)
+	}
+
+	err = LoadClientAuth(caFile, identitiesFile, true)
+	if err != nil {
+		t.Fatalf("Failed to load client CA bundle: %v", err)
+	}
+
+	if !ClientCertRequired() {
+		t.Fatalf("Expected client certificates to be required")
+	}
+
+	_, issueUntrusted := testutil.NewClientCA(t)
+
+	tests := []struct {
+		name     string
+		cert     *x509.Certificate
+		identity string
+		ok       bool
+	}{
+		{name: "a mapped certificate", cert: issue("operator").Leaf, identity: "admin", ok: true},
+		{name: "an unmapped certificate", cert: issue("tool").Leaf, identity: "CN=tool", ok: true},
+		{name: "a certificate of another CA", cert: issueUntrusted("operator").Leaf, identity: "", ok: false},
+	}
+
+	for _, test := range tests {
+		identity, ok := CertificateIdentity([]*x509.Certificate{test.cert})
+		if identity != test.identity || ok != test.ok {
+			t.Errorf("Identity of %s is %q (%v), expected %q (%v)", test.name, identity, ok, test.identity, test.ok)
+		}
+	}
+}
+
+func TestReloadClientAuth(t *testing.T) {
+	caFile, issue := loadTestClientCA(t, true)
+	cert := issue("operator").Leaf
+
+	// Replace the bundle with that of another CA, as an operator rotating
+	// the client CA would, before reloading it.
+	otherFile, issueOther := testutil.NewClientCA(t)
+	bundle, err := os.ReadFile(otherFile)
+	if err != nil {
+		t.Fatalf("Failed to read client CA bundle: %v", err)
+	}
+
+	err = os.WriteFile(caFile, bundle, 0600)
+	if err != nil {
+		t.Fatalf("Failed to write client CA bundle: %v", err)
+	}
+
+	_, ok := CertificateIdentity([]*x509.Certificate{cert})
+	if !ok {
+		t.Fatalf("Expected the loaded CA to be used until reloaded")
+	}
+
+	err = ReloadClientAuth()
+	if err != nil {
+		t.Fatalf("Failed to reload client CA bundle: %v", err)
+	}
+
+	_, ok = CertificateIdentity([]*x509.Certificate{cert})
+	if ok {
+		t.Errorf("Expected a certificate of the rotated CA to be rejected after reload")
+	}
+
+	_, ok = CertificateIdentity([]*x509.Certificate{issueOther("operator").Leaf})
+	if !ok {
+		t.Errorf("Expected a certificate of the new CA to be accepted after reload")
+	}
+
+	err = os.WriteFile(caFile, []byte("not a certificate"), 0600)
+	if err != nil {
+		t.Fatalf("Failed to write client CA bundle: %v", err)
+	}
+
+	err = ReloadClientAuth()
+	if err == nil {
+		t.Fatalf("Expected reloading an invalid bundle to fail")
+	}
+
+	_, ok = CertificateIdentity([]*x509.Certificate{issueOther("operator").Leaf})
+	if !ok {
+		t.Errorf("Expected the previous CA to be kept when reloading fails")
+	}
+}
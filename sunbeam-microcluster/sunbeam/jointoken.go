@@ -0,0 +1,206 @@
+package sunbeam
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/canonical/lxd/shared/api"
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/api/types"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/database"
+)
+
+// defaultJoinTokenTTL is the lifetime of a join token issued without one.
+const defaultJoinTokenTTL = 24 * time.Hour
+
+// maxJoinTokenBatch bounds the number of join tokens issued in one batch.
+const maxJoinTokenBatch = 1000
+
+// IssueJoinToken creates a single-use join token, optionally bound to a
+// system_id. Only the token hash is stored, the token itself is returned once.
+func IssueJoinToken(s *state.State, systemID string, ttl time.Duration) (types.JoinToken, error) {
+	tokens, err := IssueJoinTokens(s, []string{systemID}, ttl)
+	if err != nil {
+		return types.JoinToken{}, err
+	}
+
+	return tokens[0], nil
+}
+
+// IssueJoinTokenBatch issues count unbound join tokens plus one token bound
+// to each of the given system_ids, in a single transaction.
+func IssueJoinTokenBatch(s *state.State, count int, systemIDs []string, ttl time.Duration) ([]types.JoinToken, error) {
+	if count < 0 {
+		return nil, api.StatusErrorf(http.StatusBadRequest, "Token count must not be negative")
+	}
+
+	if count+len(systemIDs) > maxJoinTokenBatch {
+		return nil, api.StatusErrorf(http.StatusBadRequest, "Cannot issue more than %d join tokens at once", maxJoinTokenBatch)
+	}
+
+	return IssueJoinTokens(s, append(make([]string, count), systemIDs...), ttl)
+}
+
+// IssueJoinTokens creates one single-use join token per given system_id in
+// a single transaction, an empty system_id issues an unbound token. Each
+// token expires and is used independently of the others. System IDs a token
+// is bound to are checked against the attestation allowlist on issue.
+func IssueJoinTokens(s *state.State, systemIDs []string, ttl time.Duration) ([]types.JoinToken, error) {
+	if ttl < 0 {
+		return nil, api.StatusErrorf(http.StatusBadRequest, "Token lifetime must not be negative")
+	}
+
+	if len(systemIDs) == 0 {
+		return nil, api.StatusErrorf(http.StatusBadRequest, "No join tokens requested")
+	}
+
+	if len(systemIDs) > maxJoinTokenBatch {
+		return nil, api.StatusErrorf(http.StatusBadRequest, "Cannot issue more than %d join tokens at once", maxJoinTokenBatch)
+	}
+
+	if ttl == 0 {
+		ttl = defaultJoinTokenTTL
+	}
+
+	expiresAt := time.Now().UTC().Add(ttl)
+	joinTokens := make([]types.JoinToken, 0, len(systemIDs))
+	for _, systemID := range systemIDs {
+		token, err := generateJoinToken()
+		if err != nil {
+			return nil, err
+		}
+
+		joinTokens = append(joinTokens, types.JoinToken{Token: token, SystemID: systemID, ExpiresAt: expiresAt})
+	}
+
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		for _, joinToken := range joinTokens {
+			if joinToken.SystemID != "" {
+				err := verifyNodeSystemID(ctx, tx, "", joinToken.SystemID)
+				if err != nil {
+					return err
+				}
+			}
+
+			_, err := database.CreateJoinToken(ctx, tx, joinTokenHash(joinToken.Token), joinToken.SystemID, joinToken.ExpiresAt)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return joinTokens, nil
+}
+
+// RegisterNode creates a node on behalf of the node itself. The node proves
+// it was expected by presenting a join token, which must be unused, unexpired
+// and, if bound to a system_id, presented with that system_id. The system_id
+// is checked against the attestation allowlist by this member, in the same
+// transaction that consumes the token and records it on the node. A node
+// presenting a schema version other than the cluster's is rejected and keeps
+// its token, so that it can register again once its snap is aligned.
+func RegisterNode(s *state.State, token string, name string, systemID string, schemaVersion int, role []string, hardware types.NodeHardware) error {
+	if name == "" {
+		return api.StatusErrorf(http.StatusBadRequest, "Node name must not be empty")
+	}
+
+	role = nodeRoles(role)
+	err := validateNodeHardware(hardware)
+	if err != nil {
+		return err
+	}
+
+	return transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		joinToken, err := database.GetJoinTokenByHash(ctx, tx, joinTokenHash(token))
+		if err != nil {
+			if api.StatusErrorCheck(err, http.StatusNotFound) {
+				return api.StatusErrorf(http.StatusForbidden, "Invalid join token")
+			}
+
+			return err
+		}
+
+		if joinToken.UsedAt.Valid {
+			return api.StatusErrorf(http.StatusForbidden, "Join token has already been used")
+		}
+
+		if time.Now().After(joinToken.ExpiresAt) {
+			return api.StatusErrorf(http.StatusForbidden, "Join token has expired")
+		}
+
+		if joinToken.SystemID != "" && joinToken.SystemID != systemID {
+			return api.StatusErrorf(http.StatusForbidden, "Join token is bound to a different system_id")
+		}
+
+		err = verifySchemaVersion(ctx, tx, schemaVersion)
+		if err != nil {
+			return err
+		}
+
+		err = verifyNodeSystemID(ctx, tx, name, systemID)
+		if err != nil {
+			return err
+		}
+
+		err = database.ValidateNodeRoles(ctx, tx, role)
+		if err != nil {
+			return err
+		}
+
+		id, err := createNode(ctx, tx, database.Node{
+			Member:    s.Name(),
+			Name:      name,
+			Role:      database.LegacyRole(role),
+			MachineID: -1,
+			SystemID:  systemID,
+			CPUCount:  hardware.CPUCount,
+			MemoryMB:  hardware.MemoryMB,
+			DiskGB:    hardware.DiskGB,
+			Status:    database.NodeStatusUnknown,
+		})
+		if err != nil {
+			return fmt.Errorf("Failed to record node: %w", err)
+		}
+
+		err = database.SetNodeRoles(ctx, tx, int(id), role)
+		if err != nil {
+			return err
+		}
+
+		err = database.MarkJoinTokenUsed(ctx, tx, joinToken.ID, name)
+		if err != nil {
+			return err
+		}
+
+		return recordChange(ctx, tx, "nodes", name, database.ChangeCreate)
+	})
+}
+
+// generateJoinToken returns a random hex encoded token.
+func generateJoinToken() (string, error) {
+	buf := make([]byte, 32)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", fmt.Errorf("Failed to generate join token: %w", err)
+	}
+
+	return hex.EncodeToString(buf), nil
+}
+
+// joinTokenHash returns the hex encoded SHA-256 hash of a join token.
+func joinTokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
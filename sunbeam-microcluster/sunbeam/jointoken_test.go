@@ -0,0 +1,97 @@
+package sunbeam
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/canonical/lxd/shared/api"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/api/types"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/database"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/internal/testutil"
+)
+
+func TestRegisterNode(t *testing.T) {
+	s := testutil.NewState(t)
+	schemaVersion := len(database.SchemaExtensions)
+
+	joinToken, err := IssueJoinToken(s, "sys-1", time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to issue join token: %v", err)
+	}
+
+	err = RegisterNode(s, "invalid", "node1", "sys-1", schemaVersion, []string{"compute"}, types.NodeHardware{})
+	if !api.StatusErrorCheck(err, http.StatusForbidden) {
+		t.Fatalf("Expected an invalid token to be rejected with 403, got %v", err)
+	}
+
+	err = RegisterNode(s, joinToken.Token, "node1", "sys-2", schemaVersion, []string{"compute"}, types.NodeHardware{})
+	if !api.StatusErrorCheck(err, http.StatusForbidden) {
+		t.Fatalf("Expected a token bound to another system_id to be rejected with 403, got %v", err)
+	}
+
+	err = RegisterNode(s, joinToken.Token, "node1", "sys-1", schemaVersion, []string{"compute"}, types.NodeHardware{})
+	if err != nil {
+		t.Fatalf("Failed to register node: %v", err)
+	}
+
+	node, err := GetNode(s, "node1")
+	if err != nil {
+		t.Fatalf("Failed to get registered node: %v", err)
+	}
+
+	if node.SystemID != "sys-1" || len(node.Role) != 1 || node.Role[0] != "compute" {
+		t.Fatalf("Registered node has system_id %q and roles %v, expected %q and [compute]", node.SystemID, node.Role, "sys-1")
+	}
+
+	err = RegisterNode(s, joinToken.Token, "node2", "sys-1", schemaVersion, []string{"compute"}, types.NodeHardware{})
+	if !api.StatusErrorCheck(err, http.StatusForbidden) {
+		t.Fatalf("Expected a used token to be rejected with 403, got %v", err)
+	}
+}
+
+func TestRegisterNodeExpiredToken(t *testing.T) {
+	s := testutil.NewState(t)
+
+	joinToken, err := IssueJoinToken(s, "", time.Nanosecond)
+	if err != nil {
+		t.Fatalf("Failed to issue join token: %v", err)
+	}
+
+	time.Sleep(time.Millisecond)
+
+	err = RegisterNode(s, joinToken.Token, "node1", "sys-1", len(database.SchemaExtensions), nil, types.NodeHardware{})
+	if !api.StatusErrorCheck(err, http.StatusForbidden) {
+		t.Fatalf("Expected an expired token to be rejected with 403, got %v", err)
+	}
+}
+
+func TestRegisterNodeSchemaSkew(t *testing.T) {
+	s := testutil.NewState(t)
+	schemaVersion := len(database.SchemaExtensions)
+
+	joinToken, err := IssueJoinToken(s, "", time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to issue join token: %v", err)
+	}
+
+	for _, skewed := range []int{schemaVersion - 1, schemaVersion + 1} {
+		err = RegisterNode(s, joinToken.Token, "node1", "sys-1", skewed, nil, types.NodeHardware{})
+		if !api.StatusErrorCheck(err, http.StatusConflict) || !strings.Contains(err.Error(), "align the snap versions") {
+			t.Fatalf("Expected a node at schema version %d to be rejected with 409, got %v", skewed, err)
+		}
+	}
+
+	_, err = GetNode(s, "node1")
+	if !api.StatusErrorCheck(err, http.StatusNotFound) {
+		t.Fatalf("Expected a rejected node not to be recorded, got %v", err)
+	}
+
+	// The token is kept for the node to register once aligned.
+	err = RegisterNode(s, joinToken.Token, "node1", "sys-1", schemaVersion, nil, types.NodeHardware{})
+	if err != nil {
+		t.Fatalf("Failed to register node with an aligned schema: %v", err)
+	}
+}
@@ -0,0 +1,142 @@
+package sunbeam
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/api/types"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/database"
+)
+
+// jsonlPageSize is the number of rows a JSON Lines export reads in each
+// transaction.
+const jsonlPageSize = 500
+
+// jsonlWriter writes one JSON document per line to a writer that may be
+// flushed, such as an HTTP response.
+type jsonlWriter struct {
+	encoder *json.Encoder
+	flusher http.Flusher
+}
+
+// newJSONLWriter returns a jsonlWriter writing to w.
+func newJSONLWriter(w io.Writer) *jsonlWriter {
+	flusher, _ := w.(http.Flusher)
+
+	return &jsonlWriter{encoder: json.NewEncoder(w), flusher: flusher}
+}
+
+// Write encodes v on its own line.
+func (j *jsonlWriter) Write(v any) error {
+	return j.encoder.Encode(v)
+}
+
+// Flush sends the lines written so far to the client.
+func (j *jsonlWriter) Flush() {
+	if j.flusher != nil {
+		j.flusher.Flush()
+	}
+}
+
+// ExportAuditJSONL streams the audit entries matching the filter to w as
+// JSON Lines. Entries are read in pages, each in its own short transaction,
+// and written once the transaction is over, so that a slow client never
+// holds a transaction open. The export is not a snapshot: entries recorded
+// while it runs may be included.
+func ExportAuditJSONL(s *state.State, w io.Writer, filter database.AuditEntryFilter) error {
+	out := newJSONLWriter(w)
+
+	// A filter without a limit exports every matching entry.
+	remaining := filter.Limit
+	for {
+		page := filter
+		page.Limit = jsonlPageSize
+		if remaining > 0 && remaining < page.Limit {
+			page.Limit = remaining
+		}
+
+		var entries []database.AuditEntry
+		err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+			var err error
+			entries, err = database.GetAuditEntries(ctx, tx, page)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, e := range entries {
+			err = out.Write(types.AuditEntry{
+				ID:        e.ID,
+				Timestamp: e.Timestamp,
+				Actor:     e.Actor,
+				Action:    e.Action,
+				Target:    e.Target,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		out.Flush()
+
+		if len(entries) < page.Limit {
+			return nil
+		}
+
+		if remaining > 0 {
+			remaining -= len(entries)
+			if remaining == 0 {
+				return nil
+			}
+		}
+
+		filter.After = entries[len(entries)-1].ID
+	}
+}
+
+// ExportChangesJSONL streams the changes recorded after the given sequence
+// to w as JSON Lines. Changes are read in pages, each in its own short
+// transaction, and written once the transaction is over. Changes recorded
+// while the export runs may be included.
+func ExportChangesJSONL(s *state.State, w io.Writer, since int64) error {
+	out := newJSONLWriter(w)
+
+	for {
+		var changes []database.Change
+		err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+			var err error
+			changes, err = database.GetChangesSince(ctx, tx, since, jsonlPageSize)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, c := range changes {
+			err = out.Write(types.Change{
+				Seq:       c.Seq,
+				Entity:    c.Entity,
+				Key:       c.Key,
+				Action:    c.Action,
+				Timestamp: c.ChangedAt,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		out.Flush()
+
+		if len(changes) < jsonlPageSize {
+			return nil
+		}
+
+		since = changes[len(changes)-1].Seq
+	}
+}
@@ -2,31 +2,50 @@ package sunbeam
 
 import (
 	"context"
+	"crypto/subtle"
 	"database/sql"
 	"fmt"
+	"net/http"
+	"time"
 
+	"github.com/canonical/lxd/shared/api"
 	"github.com/canonical/microcluster/state"
 
 	"github.com/canonical/snap-openstack/sunbeam-microcluster/api/types"
 	"github.com/canonical/snap-openstack/sunbeam-microcluster/database"
 )
 
-// ListJujuUsers returns the jujuusers from the database
+// defaultJujuUserTokenTTL is the lifetime of a rotated juju user token
+// issued without one.
+const defaultJujuUserTokenTTL = 30 * 24 * time.Hour
+
+// ListJujuUsers returns the jujuusers from the database. The token of a
+// juju user whose token has expired is left out.
 func ListJujuUsers(s *state.State) (types.JujuUsers, error) {
 	users := types.JujuUsers{}
 
 	// Get the juju users from the database.
-	err := s.Database.Transaction(s.Context, func(ctx context.Context, tx *sql.Tx) error {
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
 		records, err := database.GetJujuUsers(ctx, tx)
 		if err != nil {
 			return fmt.Errorf("Failed to fetch juju user: %w", err)
 		}
 
 		for _, user := range records {
-			users = append(users, types.JujuUser{
-				Username: user.Username,
-				Token:    user.Token,
-			})
+			jujuUser := types.JujuUser{
+				Username:  user.Username,
+				ExpiresAt: jujuUserExpiry(user),
+				Expired:   jujuUserExpired(user),
+			}
+
+			if !jujuUser.Expired {
+				jujuUser.Token, err = jujuUserToken(ctx, tx, user)
+				if err != nil {
+					return err
+				}
+			}
+
+			users = append(users, jujuUser)
 		}
 
 		return nil
@@ -38,17 +57,28 @@ func ListJujuUsers(s *state.State) (types.JujuUsers, error) {
 	return users, nil
 }
 
-// GetJujuUser returns a JujuUser with the given name
+// GetJujuUser returns a JujuUser with the given name. A juju user whose
+// token has expired is gone until its token is rotated.
 func GetJujuUser(s *state.State, name string) (types.JujuUser, error) {
 	jujuUser := types.JujuUser{}
-	err := s.Database.Transaction(s.Context, func(ctx context.Context, tx *sql.Tx) error {
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
 		record, err := database.GetJujuUser(ctx, tx, name)
 		if err != nil {
 			return err
 		}
 
+		if jujuUserExpired(*record) {
+			return api.StatusErrorf(http.StatusGone, "Token of juju user %q has expired", name)
+		}
+
+		token, err := jujuUserToken(ctx, tx, *record)
+		if err != nil {
+			return err
+		}
+
 		jujuUser.Username = record.Username
-		jujuUser.Token = record.Token
+		jujuUser.Token = token
+		jujuUser.ExpiresAt = jujuUserExpiry(*record)
 
 		return nil
 	})
@@ -58,14 +88,19 @@ func GetJujuUser(s *state.State, name string) (types.JujuUser, error) {
 
 // AddJujuUser adds a Jujuuser to the database
 func AddJujuUser(s *state.State, name string, token string) error {
+	stored, err := database.EncryptSecret(token)
+	if err != nil {
+		return err
+	}
+
 	// Add juju user to the database.
-	err := s.Database.Transaction(s.Context, func(ctx context.Context, tx *sql.Tx) error {
-		_, err := database.CreateJujuUser(ctx, tx, database.JujuUser{Username: name, Token: token})
+	err = transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		_, err := database.CreateJujuUser(ctx, tx, database.JujuUser{Username: name, Token: stored})
 		if err != nil {
 			return fmt.Errorf("Failed to record juju user: %w", err)
 		}
 
-		return nil
+		return recordChange(ctx, tx, "jujuuser", name, database.ChangeCreate)
 	})
 	if err != nil {
 		return err
@@ -77,13 +112,13 @@ func AddJujuUser(s *state.State, name string, token string) error {
 // DeleteJujuUser deletes the juju user record from the database
 func DeleteJujuUser(s *state.State, name string) error {
 	// Delete juju user from the database.
-	err := s.Database.Transaction(s.Context, func(ctx context.Context, tx *sql.Tx) error {
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
 		err := database.DeleteJujuUser(ctx, tx, name)
 		if err != nil {
 			return fmt.Errorf("Failed to delete juju user: %w", err)
 		}
 
-		return nil
+		return recordChange(ctx, tx, "jujuuser", name, database.ChangeDelete)
 	})
 	if err != nil {
 		return err
@@ -91,3 +126,121 @@ func DeleteJujuUser(s *state.State, name string) error {
 
 	return nil
 }
+
+// RotateJujuUserToken replaces the token of a juju user with the given one,
+// or a generated one if empty, valid for ttl. The new token is returned.
+func RotateJujuUserToken(s *state.State, name string, token string, ttl time.Duration) (types.JujuUser, error) {
+	if ttl < 0 {
+		return types.JujuUser{}, api.StatusErrorf(http.StatusBadRequest, "Token lifetime must not be negative")
+	}
+
+	if ttl == 0 {
+		ttl = defaultJujuUserTokenTTL
+	}
+
+	if token == "" {
+		var err error
+		token, err = generateJoinToken()
+		if err != nil {
+			return types.JujuUser{}, err
+		}
+	}
+
+	stored, err := database.EncryptSecret(token)
+	if err != nil {
+		return types.JujuUser{}, err
+	}
+
+	expiresAt := time.Now().UTC().Add(ttl)
+	err = transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		user, err := database.GetJujuUser(ctx, tx, name)
+		if err != nil {
+			return err
+		}
+
+		user.Token = stored
+		user.ExpiresAt = sql.NullTime{Time: expiresAt, Valid: true}
+		err = database.UpdateJujuUser(ctx, tx, name, *user)
+		if err != nil {
+			return fmt.Errorf("Failed to rotate juju user token: %w", err)
+		}
+
+		return recordChange(ctx, tx, "jujuuser", name, database.ChangeUpdate)
+	})
+	if err != nil {
+		return types.JujuUser{}, err
+	}
+
+	return types.JujuUser{Username: name, Token: token, ExpiresAt: &expiresAt}, nil
+}
+
+// ValidateJujuUserToken checks that token is the current, unexpired token of
+// the given juju user.
+func ValidateJujuUserToken(s *state.State, name string, token string) error {
+	return transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		user, err := database.GetJujuUser(ctx, tx, name)
+		if err != nil {
+			if api.StatusErrorCheck(err, http.StatusNotFound) {
+				return api.StatusErrorf(http.StatusForbidden, "Invalid juju user token")
+			}
+
+			return err
+		}
+
+		current, err := jujuUserToken(ctx, tx, *user)
+		if err != nil {
+			return err
+		}
+
+		if subtle.ConstantTimeCompare([]byte(current), []byte(token)) != 1 {
+			return api.StatusErrorf(http.StatusForbidden, "Invalid juju user token")
+		}
+
+		if jujuUserExpired(*user) {
+			return api.StatusErrorf(http.StatusForbidden, "Juju user token has expired")
+		}
+
+		return nil
+	})
+}
+
+// jujuUserExpiry returns when the token of a juju user expires, nil if never.
+func jujuUserExpiry(user database.JujuUser) *time.Time {
+	if !user.ExpiresAt.Valid {
+		return nil
+	}
+
+	expiresAt := user.ExpiresAt.Time
+
+	return &expiresAt
+}
+
+// jujuUserExpired returns whether the token of a juju user has expired.
+func jujuUserExpired(user database.JujuUser) bool {
+	return user.ExpiresAt.Valid && !time.Now().Before(user.ExpiresAt.Time)
+}
+
+// jujuUserToken returns the decrypted token of a juju user. Tokens stored in
+// plaintext before a secrets key was configured are encrypted in place.
+func jujuUserToken(ctx context.Context, tx *sql.Tx, user database.JujuUser) (string, error) {
+	token, legacy, err := database.DecryptSecret(user.Token)
+	if err != nil {
+		return "", fmt.Errorf("Failed to read token of juju user %q: %w", user.Username, err)
+	}
+
+	if !legacy {
+		return token, nil
+	}
+
+	user.Token, err = database.EncryptSecret(token)
+	if err != nil {
+		return "", err
+	}
+
+	err = database.UpdateJujuUser(ctx, tx, user.Username, user)
+	if err != nil {
+		return "", fmt.Errorf("Failed to encrypt token of juju user %q: %w", user.Username, err)
+	}
+
+	return token, nil
+}
@@ -0,0 +1,88 @@
+package sunbeam
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/canonical/lxd/shared/api"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/internal/testutil"
+)
+
+func TestRotateJujuUserToken(t *testing.T) {
+	s := testutil.NewState(t)
+
+	err := AddJujuUser(s, "alice", "old-token")
+	if err != nil {
+		t.Fatalf("Failed to add juju user: %v", err)
+	}
+
+	err = ValidateJujuUserToken(s, "alice", "old-token")
+	if err != nil {
+		t.Fatalf("Failed to validate token: %v", err)
+	}
+
+	rotated, err := RotateJujuUserToken(s, "alice", "", time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to rotate token: %v", err)
+	}
+
+	if rotated.Token == "" || rotated.Token == "old-token" || rotated.ExpiresAt == nil {
+		t.Fatalf("Rotated token %q expiring at %v, expected a new token with an expiry", rotated.Token, rotated.ExpiresAt)
+	}
+
+	err = ValidateJujuUserToken(s, "alice", "old-token")
+	if !api.StatusErrorCheck(err, http.StatusForbidden) {
+		t.Errorf("Expected the previous token to be rejected with 403, got %v", err)
+	}
+
+	err = ValidateJujuUserToken(s, "alice", rotated.Token)
+	if err != nil {
+		t.Errorf("Failed to validate rotated token: %v", err)
+	}
+
+	user, err := GetJujuUser(s, "alice")
+	if err != nil {
+		t.Fatalf("Failed to get juju user: %v", err)
+	}
+
+	if user.Token != rotated.Token || user.ExpiresAt == nil || !user.ExpiresAt.Equal(*rotated.ExpiresAt) {
+		t.Errorf("Juju user has token %q expiring at %v, expected %q expiring at %v", user.Token, user.ExpiresAt, rotated.Token, rotated.ExpiresAt)
+	}
+}
+
+func TestExpiredJujuUserToken(t *testing.T) {
+	s := testutil.NewState(t)
+
+	err := AddJujuUser(s, "alice", "old-token")
+	if err != nil {
+		t.Fatalf("Failed to add juju user: %v", err)
+	}
+
+	_, err = RotateJujuUserToken(s, "alice", "short-lived", time.Nanosecond)
+	if err != nil {
+		t.Fatalf("Failed to rotate token: %v", err)
+	}
+
+	time.Sleep(time.Millisecond)
+
+	err = ValidateJujuUserToken(s, "alice", "short-lived")
+	if !api.StatusErrorCheck(err, http.StatusForbidden) {
+		t.Errorf("Expected an expired token to be rejected with 403, got %v", err)
+	}
+
+	_, err = GetJujuUser(s, "alice")
+	if !api.StatusErrorCheck(err, http.StatusGone) {
+		t.Errorf("Expected getting a juju user with an expired token to fail with 410, got %v", err)
+	}
+
+	users, err := ListJujuUsers(s)
+	if err != nil {
+		t.Fatalf("Failed to list juju users: %v", err)
+	}
+
+	if len(users) != 1 || !users[0].Expired || users[0].Token != "" {
+		t.Errorf("Listed juju users %+v, expected alice expired without a token", users)
+	}
+}
@@ -0,0 +1,65 @@
+package sunbeam
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/canonical/lxd/shared/api"
+	"github.com/canonical/microcluster/state"
+)
+
+// leaderTimeout bounds the time spent finding the dqlite leader.
+const leaderTimeout = 30 * time.Second
+
+// IsLeader returns whether the local member is the dqlite leader.
+func IsLeader(s *state.State) (bool, error) {
+	leader, err := leaderAddress(s)
+	if err != nil {
+		return false, err
+	}
+
+	return leader == s.Address().URL.Host, nil
+}
+
+// requireLeader returns an error naming the leader if the local member is not
+// the dqlite leader.
+func requireLeader(s *state.State) error {
+	leader, err := leaderAddress(s)
+	if err != nil {
+		return err
+	}
+
+	if leader != s.Address().URL.Host {
+		return api.StatusErrorf(http.StatusConflict, "This operation must run on the dqlite leader at %q", leader)
+	}
+
+	return nil
+}
+
+// leaderAddress returns the address of the dqlite leader.
+func leaderAddress(s *state.State) (string, error) {
+	ctx, cancel := context.WithTimeout(s.Context, leaderTimeout)
+	defer cancel()
+
+	return databaseBackend(s).LeaderAddress(ctx)
+}
+
+// dqliteLeaderAddress returns the address of the leader of the dqlite
+// database MicroCluster manages.
+func dqliteLeaderAddress(ctx context.Context, s *state.State) (string, error) {
+	client, err := s.Database.Leader(ctx)
+	if err != nil {
+		return "", fmt.Errorf("Failed to connect to the dqlite leader: %w", err)
+	}
+
+	defer func() { _ = client.Close() }()
+
+	info, err := client.Leader(ctx)
+	if err != nil {
+		return "", fmt.Errorf("Failed to get the dqlite leader: %w", err)
+	}
+
+	return info.Address, nil
+}
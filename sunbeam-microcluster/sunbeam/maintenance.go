@@ -0,0 +1,209 @@
+package sunbeam
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/canonical/lxd/shared/api"
+	"github.com/canonical/lxd/shared/logger"
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/api/types"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/database"
+)
+
+// ListMaintenance returns the maintenance windows. Only the upcoming windows
+// are returned unless all is set.
+func ListMaintenance(s *state.State, all bool) (types.MaintenanceWindows, error) {
+	windows := types.MaintenanceWindows{}
+
+	var filter *string
+	if !all {
+		scheduled := database.MaintenanceScheduled
+		filter = &scheduled
+	}
+
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		records, err := database.GetMaintenanceWindows(ctx, tx, filter)
+		if err != nil {
+			return err
+		}
+
+		for _, record := range records {
+			windows = append(windows, maintenanceWindowFromRecord(record))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return windows, nil
+}
+
+// ScheduleMaintenance schedules a reboot or drain of a node at the given time.
+func ScheduleMaintenance(s *state.State, node string, action string, startAt time.Time) (types.MaintenanceWindow, error) {
+	window := types.MaintenanceWindow{}
+
+	if action != database.MaintenanceReboot && action != database.MaintenanceDrain {
+		return window, api.StatusErrorf(http.StatusBadRequest, "Invalid maintenance action %q, expected %q or %q", action, database.MaintenanceReboot, database.MaintenanceDrain)
+	}
+
+	if startAt.IsZero() {
+		return window, api.StatusErrorf(http.StatusBadRequest, "Maintenance start time must be set")
+	}
+
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		_, err := database.GetNode(ctx, tx, node)
+		if err != nil {
+			return err
+		}
+
+		id, err := database.CreateMaintenanceWindow(ctx, tx, node, action, startAt)
+		if err != nil {
+			return err
+		}
+
+		record, err := database.GetMaintenanceWindow(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		window = maintenanceWindowFromRecord(*record)
+
+		return recordChange(ctx, tx, "maintenance_windows", strconv.FormatInt(id, 10), database.ChangeCreate)
+	})
+
+	return window, err
+}
+
+// CancelMaintenance removes a maintenance window that has not started yet.
+func CancelMaintenance(s *state.State, id int64) error {
+	return transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		window, err := database.GetMaintenanceWindow(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		if window.State != database.MaintenanceScheduled {
+			return api.StatusErrorf(http.StatusConflict, "Maintenance window %d is already %s", id, window.State)
+		}
+
+		err = database.DeleteMaintenanceWindow(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		return recordChange(ctx, tx, "maintenance_windows", strconv.FormatInt(id, 10), database.ChangeDelete)
+	})
+}
+
+// CompleteMaintenance marks a started maintenance window as completed and
+// uncordons its node. It is called by the external automation carrying out
+// the maintenance once it is done.
+func CompleteMaintenance(s *state.State, id int64) error {
+	return transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		window, err := database.GetMaintenanceWindow(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		if window.State != database.MaintenanceStarted {
+			return api.StatusErrorf(http.StatusConflict, "Maintenance window %d is %s, not %s", id, window.State, database.MaintenanceStarted)
+		}
+
+		err = database.UpdateMaintenanceWindowState(ctx, tx, id, database.MaintenanceCompleted)
+		if err != nil {
+			return err
+		}
+
+		err = setNodeCordoned(ctx, tx, window.Node, false)
+		if err != nil {
+			return err
+		}
+
+		return recordChange(ctx, tx, "maintenance_windows", strconv.FormatInt(id, 10), database.ChangeUpdate)
+	})
+}
+
+// StartDueMaintenance starts the maintenance windows whose start time has
+// passed: the node is cordoned and the window marked as started, recording
+// the intent for external automation to act on. Nothing is done to the
+// machine itself. It is meant to run from the OnHeartbeat hook, so only on
+// the dqlite leader.
+func StartDueMaintenance(s *state.State) error {
+	var started []database.MaintenanceWindow
+
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		due, err := database.GetDueMaintenanceWindows(ctx, tx, time.Now())
+		if err != nil {
+			return err
+		}
+
+		for _, window := range due {
+			err = setNodeCordoned(ctx, tx, window.Node, true)
+			if err != nil {
+				return err
+			}
+
+			err = database.UpdateMaintenanceWindowState(ctx, tx, window.ID, database.MaintenanceStarted)
+			if err != nil {
+				return err
+			}
+
+			err = recordChange(ctx, tx, "maintenance_windows", strconv.FormatInt(window.ID, 10), database.ChangeUpdate)
+			if err != nil {
+				return err
+			}
+		}
+
+		started = due
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, window := range started {
+		logger.Info("Started scheduled maintenance", logger.Ctx{"id": window.ID, "node": window.Node, "action": window.Action})
+	}
+
+	return nil
+}
+
+// setNodeCordoned sets the cordoned flag of a node within the given transaction.
+func setNodeCordoned(ctx context.Context, tx *sql.Tx, name string, cordoned bool) error {
+	node, err := database.GetNode(ctx, tx, name)
+	if err != nil {
+		return err
+	}
+
+	if node.Cordoned == cordoned {
+		return nil
+	}
+
+	node.Cordoned = cordoned
+	err = updateNode(ctx, tx, name, *node)
+	if err != nil {
+		return fmt.Errorf("Failed to update node %q: %w", name, err)
+	}
+
+	return recordChange(ctx, tx, "nodes", name, database.ChangeUpdate)
+}
+
+// maintenanceWindowFromRecord converts a database maintenance window to its API type.
+func maintenanceWindowFromRecord(record database.MaintenanceWindow) types.MaintenanceWindow {
+	return types.MaintenanceWindow{
+		ID:      record.ID,
+		Node:    record.Node,
+		Action:  record.Action,
+		StartAt: record.StartAt,
+		State:   record.State,
+	}
+}
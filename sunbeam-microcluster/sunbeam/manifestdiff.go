@@ -0,0 +1,101 @@
+package sunbeam
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+
+	"github.com/canonical/lxd/shared/api"
+	"github.com/canonical/microcluster/state"
+	"gopkg.in/yaml.v2"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/api/types"
+)
+
+// DiffManifests compares the data of two stored manifests key by key and
+// returns the keys the second adds, removes and changes relative to the
+// first. Keys are given as paths such as "deployment.nodes[0].name".
+func DiffManifests(s *state.State, from string, to string) (types.ManifestDiff, error) {
+	documents := make([]map[string]any, 0, 2)
+	for _, manifestid := range []string{from, to} {
+		manifest, err := GetManifest(s, manifestid)
+		if err != nil {
+			return types.ManifestDiff{}, err
+		}
+
+		var document any
+		err = yaml.Unmarshal([]byte(manifest.Data), &document)
+		if err != nil {
+			return types.ManifestDiff{}, api.StatusErrorf(http.StatusBadRequest, "Failed to parse manifest %q: %v", manifest.ManifestID, err)
+		}
+
+		leaves := map[string]any{}
+		flattenManifest("", document, leaves)
+		documents = append(documents, leaves)
+	}
+
+	diff := types.ManifestDiff{
+		From:    from,
+		To:      to,
+		Added:   map[string]any{},
+		Changed: map[string]types.ManifestChange{},
+		Removed: []string{},
+	}
+
+	old, updated := documents[0], documents[1]
+	for path, value := range updated {
+		previous, ok := old[path]
+		if !ok {
+			diff.Added[path] = value
+		} else if !reflect.DeepEqual(previous, value) {
+			diff.Changed[path] = types.ManifestChange{Old: previous, New: value}
+		}
+	}
+
+	for path := range old {
+		_, ok := updated[path]
+		if !ok {
+			diff.Removed = append(diff.Removed, path)
+		}
+	}
+
+	sort.Strings(diff.Removed)
+
+	return diff, nil
+}
+
+// flattenManifest adds the scalar values of a decoded manifest document to
+// leaves, keyed by their path under prefix. Empty maps and lists are kept as
+// values so that adding or removing them shows in a diff.
+func flattenManifest(prefix string, value any, leaves map[string]any) {
+	switch v := value.(type) {
+	case map[any]any:
+		if len(v) == 0 {
+			leaves[prefix] = map[string]any{}
+			return
+		}
+
+		for key, child := range v {
+			path := fmt.Sprint(key)
+			if prefix != "" {
+				path = prefix + "." + path
+			}
+
+			flattenManifest(path, child, leaves)
+		}
+
+	case []any:
+		if len(v) == 0 {
+			leaves[prefix] = []any{}
+			return
+		}
+
+		for i, child := range v {
+			flattenManifest(fmt.Sprintf("%s[%d]", prefix, i), child, leaves)
+		}
+
+	default:
+		leaves[prefix] = v
+	}
+}
@@ -0,0 +1,88 @@
+package sunbeam
+
+import (
+	"net/http"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/canonical/lxd/shared/api"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/api/types"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/internal/testutil"
+)
+
+func TestDiffManifests(t *testing.T) {
+	s := testutil.NewState(t)
+
+	documents := map[string]string{
+		"before": `
+core:
+  config:
+    region: RegionOne
+    proxy:
+      enabled: false
+software:
+  charms:
+    keystone-k8s:
+      channel: 2023.2/stable
+    glance-k8s:
+      channel: 2023.2/stable
+`,
+		"after": `
+core:
+  config:
+    region: RegionOne
+    proxy:
+      enabled: true
+    external_network: {}
+software:
+  charms:
+    keystone-k8s:
+      channel: 2024.1/stable
+    nova-k8s:
+      channel: 2024.1/stable
+`,
+	}
+
+	for id, data := range documents {
+		_, err := AddManifest(s, id, data)
+		if err != nil {
+			t.Fatalf("Failed to add manifest %q: %v", id, err)
+		}
+	}
+
+	diff, err := DiffManifests(s, "before", "after")
+	if err != nil {
+		t.Fatalf("Failed to diff manifests: %v", err)
+	}
+
+	added := map[string]any{
+		"core.config.external_network":     map[string]any{},
+		"software.charms.nova-k8s.channel": "2024.1/stable",
+	}
+
+	changed := map[string]types.ManifestChange{
+		"core.config.proxy.enabled":            {Old: false, New: true},
+		"software.charms.keystone-k8s.channel": {Old: "2023.2/stable", New: "2024.1/stable"},
+	}
+
+	removed := []string{"software.charms.glance-k8s.channel"}
+
+	if !reflect.DeepEqual(diff.Added, added) {
+		t.Errorf("Diff adds %v, expected %v", diff.Added, added)
+	}
+
+	if !reflect.DeepEqual(diff.Changed, changed) {
+		t.Errorf("Diff changes %v, expected %v", diff.Changed, changed)
+	}
+
+	if !slices.Equal(diff.Removed, removed) {
+		t.Errorf("Diff removes %v, expected %v", diff.Removed, removed)
+	}
+
+	_, err = DiffManifests(s, "before", "missing")
+	if !api.StatusErrorCheck(err, http.StatusNotFound) {
+		t.Errorf("Expected diffing a missing manifest to fail with 404, got %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package sunbeam
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+
+	"github.com/canonical/lxd/shared/api"
+	"github.com/canonical/microcluster/state"
+	"gopkg.in/yaml.v2"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/api/types"
+)
+
+// manifestNodes is the part of a manifest declaring the expected nodes:
+//
+//	deployment:
+//	  nodes:
+//	  - name: node-1
+//	    role: [control, compute]
+type manifestNodes struct {
+	Deployment struct {
+		Nodes []struct {
+			Name string   `yaml:"name"`
+			Role []string `yaml:"role"`
+		} `yaml:"nodes"`
+	} `yaml:"deployment"`
+}
+
+// ValidateManifestNodes compares the nodes declared in a manifest against
+// the recorded nodes and reports the missing, extra and mismatched ones.
+func ValidateManifestNodes(s *state.State, manifestid string) (types.ManifestNodesValidation, error) {
+	validation := types.ManifestNodesValidation{
+		Missing:    make([]string, 0),
+		Extra:      make([]string, 0),
+		Mismatched: make([]types.NodeRoleMismatch, 0),
+	}
+
+	manifest, err := GetManifest(s, manifestid)
+	if err != nil {
+		return validation, err
+	}
+
+	validation.ManifestID = manifest.ManifestID
+
+	var declared manifestNodes
+	err = yaml.Unmarshal([]byte(manifest.Data), &declared)
+	if err != nil {
+		return validation, api.StatusErrorf(http.StatusBadRequest, "Failed to parse manifest %q: %v", manifest.ManifestID, err)
+	}
+
+	if declared.Deployment.Nodes == nil {
+		return validation, api.StatusErrorf(http.StatusBadRequest, "Manifest %q does not declare any nodes", manifest.ManifestID)
+	}
+
+	nodes, err := ListNodes(s, nil, nil, nil)
+	if err != nil {
+		return validation, fmt.Errorf("Failed to fetch nodes: %w", err)
+	}
+
+	actual := make(map[string][]string, len(nodes))
+	for _, node := range nodes {
+		actual[node.Name] = node.Role
+	}
+
+	expected := make(map[string]bool, len(declared.Deployment.Nodes))
+	for _, node := range declared.Deployment.Nodes {
+		expected[node.Name] = true
+
+		role, ok := actual[node.Name]
+		if !ok {
+			validation.Missing = append(validation.Missing, node.Name)
+			continue
+		}
+
+		expectedRole := slices.Clone(node.Role)
+		sort.Strings(expectedRole)
+		if !slices.Equal(expectedRole, role) {
+			validation.Mismatched = append(validation.Mismatched, types.NodeRoleMismatch{Name: node.Name, Expected: expectedRole, Actual: role})
+		}
+	}
+
+	for _, node := range nodes {
+		if !expected[node.Name] {
+			validation.Extra = append(validation.Extra, node.Name)
+		}
+	}
+
+	sort.Strings(validation.Missing)
+	sort.Strings(validation.Extra)
+	sort.Slice(validation.Mismatched, func(i, j int) bool { return validation.Mismatched[i].Name < validation.Mismatched[j].Name })
+
+	return validation, nil
+}
@@ -2,31 +2,60 @@ package sunbeam
 
 import (
 	"context"
+	"crypto/rand"
 	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/canonical/lxd/shared/api"
+	"github.com/canonical/lxd/shared/logger"
 	"github.com/canonical/microcluster/state"
 
 	"github.com/canonical/snap-openstack/sunbeam-microcluster/api/types"
 	"github.com/canonical/snap-openstack/sunbeam-microcluster/database"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/version"
 )
 
+// MaxManifestSize is the size in bytes of the largest manifest data
+// accepted, so that manifests do not bloat the replicated database.
+const MaxManifestSize = 4 * 1024 * 1024
+
+// manifestRetentionKey is the config key holding the number of most recently
+// applied manifests kept by garbage collection.
+const manifestRetentionKey = "manifest.retention"
+
 // ListManifests return all the manifests
 func ListManifests(s *state.State) (types.Manifests, error) {
 	manifests := types.Manifests{}
 
 	// Get the manifests from the database.
-	err := s.Database.Transaction(s.Context, func(ctx context.Context, tx *sql.Tx) error {
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
 		records, err := database.GetManifestItems(ctx, tx)
 		if err != nil {
 			return fmt.Errorf("Failed to fetch manifests: %w", err)
 		}
 
+		sortManifestItems(records)
+
 		for _, manifest := range records {
+			data, err := manifest.Content()
+			if err != nil {
+				return err
+			}
+
 			manifests = append(manifests, types.Manifest{
-				ManifestID:  manifest.ManifestID,
-				AppliedDate: manifest.AppliedDate,
-				Data:        manifest.Data,
+				ManifestID:       manifest.ManifestID,
+				AppliedDate:      manifestAppliedDate(manifest),
+				Data:             data,
+				AppliedByVersion: manifest.AppliedByVersion,
+				Checksum:         manifest.Checksum,
 			})
 		}
 
@@ -39,11 +68,129 @@ func ListManifests(s *state.State) (types.Manifests, error) {
 	return manifests, nil
 }
 
+// ListManifestsInRange returns at most limit manifests applied strictly
+// between after and before, oldest first, without their data. A nil bound
+// leaves the range open on that side.
+func ListManifestsInRange(s *state.State, after *time.Time, before *time.Time, limit int) (types.ManifestSummaries, error) {
+	manifests := types.ManifestSummaries{}
+
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		records, err := database.GetManifestsInRange(ctx, tx, after, before, limit)
+		if err != nil {
+			return err
+		}
+
+		for _, manifest := range records {
+			manifests = append(manifests, types.ManifestSummary{
+				ManifestID:       manifest.ManifestID,
+				AppliedDate:      manifestAppliedDate(manifest),
+				AppliedByVersion: manifest.AppliedByVersion,
+			})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return manifests, nil
+}
+
+// ListManifestsPage returns at most limit manifests applied after the one
+// the cursor points at, oldest first, along with the cursor of the next page.
+// An empty cursor starts from the first manifest. If contains is not empty,
+// only manifests whose id contains it are returned. The data of the manifests
+// is only returned with includeData.
+func ListManifestsPage(s *state.State, cursor string, contains string, includeData bool, limit int) (types.ManifestsPage, error) {
+	page := types.ManifestsPage{Manifests: types.Manifests{}}
+
+	afterAppliedAt, afterID, err := decodeManifestCursor(cursor)
+	if err != nil {
+		return page, err
+	}
+
+	err = transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		// Fetch one more manifest than asked for to know if there is a next page.
+		records, err := database.GetManifestsPage(ctx, tx, afterAppliedAt, afterID, contains, includeData, limit+1)
+		if err != nil {
+			return err
+		}
+
+		if len(records) > limit {
+			records = records[:limit]
+			last := records[len(records)-1]
+			page.NextCursor = encodeManifestCursor(last.AppliedAt, last.ID)
+		}
+
+		for _, manifest := range records {
+			var data string
+			if includeData {
+				data, err = manifest.Content()
+				if err != nil {
+					return err
+				}
+			}
+
+			page.Manifests = append(page.Manifests, types.Manifest{
+				ManifestID:       manifest.ManifestID,
+				AppliedDate:      manifestAppliedDate(manifest),
+				Data:             data,
+				AppliedByVersion: manifest.AppliedByVersion,
+			})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return types.ManifestsPage{}, err
+	}
+
+	return page, nil
+}
+
+// encodeManifestCursor returns an opaque cursor pointing at the manifest
+// with the given applied time and ID. Manifests inserted later sort after
+// it, so the cursor stays valid.
+func encodeManifestCursor(appliedAt int64, id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%d", appliedAt, id)))
+}
+
+// decodeManifestCursor returns the applied time and ID of the manifest the
+// cursor points at. An empty cursor points before the first manifest.
+func decodeManifestCursor(cursor string) (int64, int, error) {
+	if cursor == "" {
+		return math.MinInt64, 0, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, 0, api.StatusErrorf(http.StatusBadRequest, "Invalid manifest cursor %q", cursor)
+	}
+
+	appliedAtStr, idStr, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return 0, 0, api.StatusErrorf(http.StatusBadRequest, "Invalid manifest cursor %q", cursor)
+	}
+
+	appliedAt, err := strconv.ParseInt(appliedAtStr, 10, 64)
+	if err != nil {
+		return 0, 0, api.StatusErrorf(http.StatusBadRequest, "Invalid manifest cursor %q", cursor)
+	}
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return 0, 0, api.StatusErrorf(http.StatusBadRequest, "Invalid manifest cursor %q", cursor)
+	}
+
+	return appliedAt, id, nil
+}
+
 // GetManifest returns a Manifest with the given id
 func GetManifest(s *state.State, manifestid string) (types.Manifest, error) {
 	manifest := types.Manifest{}
 
-	err := s.Database.Transaction(s.Context, func(ctx context.Context, tx *sql.Tx) error {
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
 		var record *database.ManifestItem
 		var err error
 		// If manifest id is latest, retrieve the latest inserted record.
@@ -56,44 +203,196 @@ func GetManifest(s *state.State, manifestid string) (types.Manifest, error) {
 			return err
 		}
 
-		manifest.ManifestID = record.ManifestID
-		manifest.AppliedDate = record.AppliedDate
-		manifest.Data = record.Data
+		manifest, err = manifestFromRecord(*record)
 
-		return nil
+		return err
 	})
 
 	return manifest, err
 }
 
-// AddManifest adds a manifest to the database
-func AddManifest(s *state.State, manifestid string, data string) error {
+// AddManifest adds a manifest to the database and returns it. A random
+// manifest id is generated if none is given.
+func AddManifest(s *state.State, manifestid string, data string) (types.Manifest, error) {
+	manifest := types.Manifest{}
+
+	manifestid, err := manifestIDOrGenerated(manifestid)
+	if err != nil {
+		return manifest, err
+	}
+
 	// Add manifest to the database.
-	err := s.Database.Transaction(s.Context, func(ctx context.Context, tx *sql.Tx) error {
-		_, err := database.CreateManifestItem(ctx, tx, database.ManifestItem{ManifestID: manifestid, Data: data})
+	err = transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		err := addManifest(ctx, tx, manifestid, data)
+		if err != nil {
+			return err
+		}
+
+		record, err := database.GetManifestItem(ctx, tx, manifestid)
 		if err != nil {
-			return fmt.Errorf("Failed to record manifest: %w", err)
+			return err
 		}
 
-		return nil
+		manifest, err = manifestFromRecord(*record)
+
+		return err
 	})
+	if err != nil {
+		return types.Manifest{}, err
+	}
+
+	return manifest, nil
+}
+
+// manifestIDOrGenerated returns the given manifest id, or a random hex
+// encoded one if it is empty.
+func manifestIDOrGenerated(manifestid string) (string, error) {
+	if manifestid != "" {
+		return manifestid, nil
+	}
+
+	buf := make([]byte, 16)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", fmt.Errorf("Failed to generate manifest id: %w", err)
+	}
+
+	return hex.EncodeToString(buf), nil
+}
+
+// addManifest records a manifest within the given transaction, as applied
+// by the running version of the daemon. Data larger than MaxManifestSize is
+// rejected. The manifest is read back and rejected if its data does not
+// match what was written.
+func addManifest(ctx context.Context, tx *sql.Tx, manifestid string, data string) error {
+	return addManifestItem(ctx, tx, database.ManifestItem{ManifestID: manifestid, Data: data})
+}
+
+// addManifestItem records a manifest record as addManifest does, keeping
+// the fields of the record other than its version.
+func addManifestItem(ctx context.Context, tx *sql.Tx, item database.ManifestItem) error {
+	manifestid, data := item.ManifestID, item.Data
+	if len(data) > MaxManifestSize {
+		return api.StatusErrorf(http.StatusRequestEntityTooLarge, "Manifest data is %d bytes, more than the %d bytes allowed", len(data), MaxManifestSize)
+	}
+
+	item.AppliedByVersion = version.Version
+	_, err := database.CreateManifestItem(ctx, tx, item)
+	if err != nil {
+		return fmt.Errorf("Failed to record manifest: %w", err)
+	}
+
+	record, err := database.GetManifestItem(ctx, tx, manifestid)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	verification := verifyManifest(*record)
+	if !verification.Valid || record.Checksum != database.ManifestChecksum(data) {
+		logger.Error("Manifest does not match the data written", logger.Ctx{"manifestid": manifestid, "checksum": record.Checksum, "actual": verification.Actual})
+		return fmt.Errorf("Manifest %q does not match the data written", manifestid)
+	}
+
+	return recordChange(ctx, tx, "manifest", manifestid, database.ChangeCreate)
+}
+
+// RollbackManifest re-applies the data of a stored manifest as a new
+// manifest with a random id, recording the manifest it was rolled back
+// from. Manifests whose data does not match their checksum are rejected.
+func RollbackManifest(s *state.State, manifestid string) (types.Manifest, error) {
+	manifest := types.Manifest{}
+
+	newid, err := manifestIDOrGenerated("")
+	if err != nil {
+		return manifest, err
+	}
+
+	err = transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		source, err := database.GetManifestItem(ctx, tx, manifestid)
+		if err != nil {
+			return err
+		}
+
+		verification := verifyManifest(*source)
+		if !verification.Valid {
+			return api.StatusErrorf(http.StatusBadRequest, "Manifest %q does not match its checksum, refusing to roll back to it", manifestid)
+		}
+
+		data, err := source.Content()
+		if err != nil {
+			return err
+		}
+
+		err = addManifestItem(ctx, tx, database.ManifestItem{ManifestID: newid, Data: data, RolledBackFrom: source.ManifestID})
+		if err != nil {
+			return err
+		}
+
+		record, err := database.GetManifestItem(ctx, tx, newid)
+		if err != nil {
+			return err
+		}
+
+		manifest, err = manifestFromRecord(*record)
+
+		return err
+	})
+	if err != nil {
+		return types.Manifest{}, err
+	}
+
+	return manifest, nil
+}
+
+// AddManifestDeduplicated adds a manifest to the database unless a manifest
+// with identical content already exists, in which case the existing manifest
+// is returned instead of creating a duplicate. A random manifest id is
+// generated if none is given.
+func AddManifestDeduplicated(s *state.State, manifestid string, data string) (types.Manifest, error) {
+	manifest := types.Manifest{}
+	checksum := database.ManifestChecksum(data)
+
+	manifestid, err := manifestIDOrGenerated(manifestid)
+	if err != nil {
+		return manifest, err
+	}
+
+	err = transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		record, err := database.GetManifestItemByChecksum(ctx, tx, checksum)
+		if err != nil && !api.StatusErrorCheck(err, http.StatusNotFound) {
+			return err
+		}
+
+		if record == nil {
+			err = addManifest(ctx, tx, manifestid, data)
+			if err != nil {
+				return err
+			}
+
+			record, err = database.GetManifestItem(ctx, tx, manifestid)
+			if err != nil {
+				return err
+			}
+		}
+
+		manifest, err = manifestFromRecord(*record)
+
+		return err
+	})
+
+	return manifest, err
 }
 
 // DeleteManifest deletes a manifest from database
 func DeleteManifest(s *state.State, manifestid string) error {
 	// Delete manifest from the database.
-	err := s.Database.Transaction(s.Context, func(ctx context.Context, tx *sql.Tx) error {
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
 		err := database.DeleteManifestItem(ctx, tx, manifestid)
 		if err != nil {
 			return fmt.Errorf("Failed to delete manifest: %w", err)
 		}
 
-		return nil
+		return recordChange(ctx, tx, "manifest", manifestid, database.ChangeDelete)
 	})
 	if err != nil {
 		return err
@@ -101,3 +400,117 @@ func DeleteManifest(s *state.State, manifestid string) error {
 
 	return nil
 }
+
+// GarbageCollectManifests deletes all manifests but the most recently
+// applied ones, as many as the manifest.retention config key holds, and
+// those still recorded as the last applied manifest of a node. Nothing is
+// deleted while the key is unset or not a positive number. It runs as a
+// maintenance task, which only the dqlite leader runs.
+func GarbageCollectManifests(s *state.State) error {
+	value, err := GetConfig(s, manifestRetentionKey)
+	if err != nil {
+		if api.StatusErrorCheck(err, http.StatusNotFound) {
+			return nil
+		}
+
+		return err
+	}
+
+	keep, err := strconv.Atoi(value)
+	if err != nil || keep <= 0 {
+		logger.Warn("Skipping manifest garbage collection, invalid retention", logger.Ctx{"key": manifestRetentionKey, "value": value})
+		return nil
+	}
+
+	var removed int64
+	err = transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		removed, err = database.DeleteManifestsExceptLatest(ctx, tx, keep)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to delete old manifests: %w", err)
+	}
+
+	if removed > 0 {
+		logger.Info("Deleted old manifests", logger.Ctx{"removed": removed, "retention": keep})
+	}
+
+	return nil
+}
+
+// VerifyManifest recomputes the checksum of a manifest's data and reports
+// whether it matches the checksum recorded on write.
+func VerifyManifest(s *state.State, manifestid string) (types.ManifestVerification, error) {
+	var verification types.ManifestVerification
+
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		record, err := database.GetManifestItem(ctx, tx, manifestid)
+		if err != nil {
+			return err
+		}
+
+		verification = verifyManifest(*record)
+
+		return nil
+	})
+
+	return verification, err
+}
+
+// verifyManifest recomputes the checksum of a manifest record. Data that
+// can no longer be decompressed is reported as not matching.
+func verifyManifest(record database.ManifestItem) types.ManifestVerification {
+	verification := types.ManifestVerification{ManifestID: record.ManifestID, Checksum: record.Checksum}
+
+	data, err := record.Content()
+	if err != nil {
+		logger.Warn("Failed to read manifest data", logger.Ctx{"manifestid": record.ManifestID, "err": err})
+		return verification
+	}
+
+	verification.Actual = database.ManifestChecksum(data)
+	verification.Valid = verification.Actual == record.Checksum
+
+	return verification
+}
+
+// sortManifestItems sorts manifests by the time they were applied, oldest
+// first. Manifests applied within the same nanosecond keep their insertion
+// order.
+func sortManifestItems(records []database.ManifestItem) {
+	sort.SliceStable(records, func(i, j int) bool {
+		if records[i].AppliedAt != records[j].AppliedAt {
+			return records[i].AppliedAt < records[j].AppliedAt
+		}
+
+		return records[i].ID < records[j].ID
+	})
+}
+
+// manifestFromRecord returns the manifest held by a record, with its data
+// decompressed.
+func manifestFromRecord(record database.ManifestItem) (types.Manifest, error) {
+	data, err := record.Content()
+	if err != nil {
+		return types.Manifest{}, err
+	}
+
+	return types.Manifest{
+		ManifestID:       record.ManifestID,
+		AppliedDate:      manifestAppliedDate(record),
+		Data:             data,
+		AppliedByVersion: record.AppliedByVersion,
+		Checksum:         record.Checksum,
+		RolledBackFrom:   record.RolledBackFrom,
+	}, nil
+}
+
+// manifestAppliedDate returns when a manifest was applied, with nanosecond
+// precision when known.
+func manifestAppliedDate(manifest database.ManifestItem) string {
+	if manifest.AppliedAt == 0 {
+		return manifest.AppliedDate
+	}
+
+	return time.Unix(0, manifest.AppliedAt).UTC().Format(time.RFC3339Nano)
+}
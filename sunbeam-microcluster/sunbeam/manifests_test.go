@@ -0,0 +1,458 @@
+package sunbeam
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/canonical/lxd/shared/api"
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/api/types"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/database"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/internal/testutil"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/version"
+)
+
+// addTestManifests adds a manifest with each of the given ids, in order.
+func addTestManifests(t *testing.T, s *state.State, ids ...string) {
+	t.Helper()
+
+	for _, id := range ids {
+		_, err := AddManifest(s, id, "data of "+id)
+		if err != nil {
+			t.Fatalf("Failed to add manifest %q: %v", id, err)
+		}
+	}
+}
+
+func TestManifestAppliedDatePrecision(t *testing.T) {
+	s := testutil.NewState(t)
+	addTestManifests(t, s, "m1", "m2")
+
+	manifests, err := ListManifests(s)
+	if err != nil {
+		t.Fatalf("Failed to list manifests: %v", err)
+	}
+
+	if len(manifests) != 2 || manifests[0].ManifestID != "m1" || manifests[1].ManifestID != "m2" {
+		t.Fatalf("Listed manifests %+v, expected m1 then m2", manifests)
+	}
+
+	first, err := time.Parse(time.RFC3339Nano, manifests[0].AppliedDate)
+	if err != nil {
+		t.Fatalf("Failed to parse applied date: %v", err)
+	}
+
+	second, err := time.Parse(time.RFC3339Nano, manifests[1].AppliedDate)
+	if err != nil {
+		t.Fatalf("Failed to parse applied date: %v", err)
+	}
+
+	if !first.Before(second) {
+		t.Errorf("Manifests applied back to back at %v and %v, expected distinct ordered dates", first, second)
+	}
+}
+
+// manifestIDs returns the ids of the given manifests.
+func manifestIDs(manifests types.ManifestSummaries) []string {
+	ids := make([]string, 0, len(manifests))
+	for _, manifest := range manifests {
+		ids = append(ids, manifest.ManifestID)
+	}
+
+	return ids
+}
+
+func TestListManifestsInRange(t *testing.T) {
+	s := testutil.NewState(t)
+	addTestManifests(t, s, "m1", "m2", "m3")
+
+	all, err := ListManifestsInRange(s, nil, nil, 10)
+	if err != nil {
+		t.Fatalf("Failed to list manifests: %v", err)
+	}
+
+	dates := make([]time.Time, 0, len(all))
+	for _, manifest := range all {
+		date, err := time.Parse(time.RFC3339Nano, manifest.AppliedDate)
+		if err != nil {
+			t.Fatalf("Failed to parse applied date: %v", err)
+		}
+
+		dates = append(dates, date)
+	}
+
+	if len(dates) != 3 {
+		t.Fatalf("Listed %d manifests without bounds, expected 3", len(dates))
+	}
+
+	tests := []struct {
+		name   string
+		after  *time.Time
+		before *time.Time
+		limit  int
+		ids    []string
+	}{
+		{name: "neither", ids: []string{"m1", "m2", "m3"}, limit: 10},
+		{name: "after only", after: &dates[0], ids: []string{"m2", "m3"}, limit: 10},
+		{name: "before only", before: &dates[2], ids: []string{"m1", "m2"}, limit: 10},
+		{name: "both", after: &dates[0], before: &dates[2], ids: []string{"m2"}, limit: 10},
+		{name: "limit", ids: []string{"m1", "m2"}, limit: 2},
+	}
+
+	for _, test := range tests {
+		manifests, err := ListManifestsInRange(s, test.after, test.before, test.limit)
+		if err != nil {
+			t.Fatalf("Failed to list manifests: %v", err)
+		}
+
+		ids := manifestIDs(manifests)
+		if !slices.Equal(ids, test.ids) {
+			t.Errorf("Listed manifests %v with %s, expected %v", ids, test.name, test.ids)
+		}
+	}
+}
+
+func TestVerifyManifest(t *testing.T) {
+	s := testutil.NewState(t)
+	addTestManifests(t, s, "intact", "corrupted")
+
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, "UPDATE manifest SET data = ?, compressed = 0 WHERE manifest_id = ?", "truncated", "corrupted")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Failed to corrupt manifest: %v", err)
+	}
+
+	for id, valid := range map[string]bool{"intact": true, "corrupted": false} {
+		verification, err := VerifyManifest(s, id)
+		if err != nil {
+			t.Fatalf("Failed to verify manifest %q: %v", id, err)
+		}
+
+		if verification.Checksum != database.ManifestChecksum("data of "+id) {
+			t.Errorf("Manifest %q has checksum %q recorded, expected the checksum of its data as written", id, verification.Checksum)
+		}
+
+		if verification.Valid != valid {
+			t.Errorf("Manifest %q verified as valid %v with actual checksum %q, expected %v", id, verification.Valid, verification.Actual, valid)
+		}
+	}
+}
+
+func TestGarbageCollectManifests(t *testing.T) {
+	s := testutil.NewState(t)
+	addTestManifests(t, s, "m1", "m2", "m3", "m4", "m5")
+
+	// Nothing is collected while the retention is unset.
+	err := GarbageCollectManifests(s)
+	if err != nil {
+		t.Fatalf("Failed to collect manifests: %v", err)
+	}
+
+	err = CreateConfig(s, manifestRetentionKey, "2")
+	if err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+
+	// Manifests nodes last applied are kept whatever their age.
+	addTestNodes(t, s, nil, "node1")
+
+	err = SetNodeAppliedManifest(s, "node1", "m1")
+	if err != nil {
+		t.Fatalf("Failed to record applied manifest: %v", err)
+	}
+
+	err = GarbageCollectManifests(s)
+	if err != nil {
+		t.Fatalf("Failed to collect manifests: %v", err)
+	}
+
+	all, err := ListManifestsInRange(s, nil, nil, 10)
+	if err != nil {
+		t.Fatalf("Failed to list manifests: %v", err)
+	}
+
+	ids := manifestIDs(all)
+	if !slices.Equal(ids, []string{"m1", "m4", "m5"}) {
+		t.Errorf("Collecting manifests kept %v, expected the latest two and the one node1 applied", ids)
+	}
+}
+
+// manifestPageIDs returns the ids of the manifests of the given page.
+func manifestPageIDs(page types.ManifestsPage) []string {
+	ids := make([]string, 0, len(page.Manifests))
+	for _, manifest := range page.Manifests {
+		ids = append(ids, manifest.ManifestID)
+	}
+
+	return ids
+}
+
+func TestListManifestsPage(t *testing.T) {
+	s := testutil.NewState(t)
+	addTestManifests(t, s, "m1", "m2", "m3", "m4")
+
+	page, err := ListManifestsPage(s, "", "", false, 4)
+	if err != nil {
+		t.Fatalf("Failed to list manifests: %v", err)
+	}
+
+	if !slices.Equal(manifestPageIDs(page), []string{"m1", "m2", "m3", "m4"}) || page.NextCursor != "" {
+		t.Fatalf("Page of exactly every manifest holds %v with next cursor %q, expected all without a cursor", manifestPageIDs(page), page.NextCursor)
+	}
+
+	page, err = ListManifestsPage(s, "", "", false, 2)
+	if err != nil {
+		t.Fatalf("Failed to list manifests: %v", err)
+	}
+
+	if !slices.Equal(manifestPageIDs(page), []string{"m1", "m2"}) || page.NextCursor == "" {
+		t.Fatalf("First page holds %v with next cursor %q, expected m1 and m2 with a cursor", manifestPageIDs(page), page.NextCursor)
+	}
+
+	if page.Manifests[0].Data != "" {
+		t.Errorf("Expected the data of listed manifests to be left out unless asked for")
+	}
+
+	// Manifests added meanwhile sort after the cursor.
+	addTestManifests(t, s, "m5")
+
+	var ids []string
+	pages := 1
+	for cursor := page.NextCursor; cursor != ""; cursor = page.NextCursor {
+		page, err = ListManifestsPage(s, cursor, "", true, 2)
+		if err != nil {
+			t.Fatalf("Failed to list manifests: %v", err)
+		}
+
+		pages++
+		ids = append(ids, manifestPageIDs(page)...)
+		for _, manifest := range page.Manifests {
+			if manifest.Data != "data of "+manifest.ManifestID {
+				t.Errorf("Listed manifest %q with data %q, expected its data included", manifest.ManifestID, manifest.Data)
+			}
+		}
+	}
+
+	if !slices.Equal(ids, []string{"m3", "m4", "m5"}) || pages != 3 {
+		t.Errorf("Following the cursor listed %v over %d pages, expected m3, m4 and m5 over 3", ids, pages)
+	}
+
+	_, err = ListManifestsPage(s, "not a cursor", "", false, 2)
+	if !api.StatusErrorCheck(err, http.StatusBadRequest) {
+		t.Errorf("Expected an invalid cursor to be rejected with 400, got %v", err)
+	}
+}
+
+func TestListManifestsPageContains(t *testing.T) {
+	s := testutil.NewState(t)
+	addTestManifests(t, s, "control-1", "compute-1", "control-2", "100%")
+
+	tests := []struct {
+		contains string
+		ids      []string
+	}{
+		{contains: "", ids: []string{"control-1", "compute-1", "control-2", "100%"}},
+		{contains: "control", ids: []string{"control-1", "control-2"}},
+		{contains: "-1", ids: []string{"control-1", "compute-1"}},
+		{contains: "%", ids: []string{"100%"}},
+		{contains: "storage", ids: []string{}},
+	}
+
+	for _, test := range tests {
+		page, err := ListManifestsPage(s, "", test.contains, false, 10)
+		if err != nil {
+			t.Fatalf("Failed to search manifests for %q: %v", test.contains, err)
+		}
+
+		if !slices.Equal(manifestPageIDs(page), test.ids) {
+			t.Errorf("Manifests containing %q are %v, expected %v", test.contains, manifestPageIDs(page), test.ids)
+		}
+	}
+}
+
+func TestManifestAppliedByVersion(t *testing.T) {
+	s := testutil.NewState(t)
+	addTestManifests(t, s, "current")
+
+	// A manifest stored before the version was recorded.
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, "INSERT INTO manifest (manifest_id, data) VALUES (?, ?)", "legacy", "data of legacy")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Failed to add legacy manifest: %v", err)
+	}
+
+	for id, expected := range map[string]string{"current": version.Version, "legacy": "unknown"} {
+		manifest, err := GetManifest(s, id)
+		if err != nil {
+			t.Fatalf("Failed to get manifest %q: %v", id, err)
+		}
+
+		if manifest.AppliedByVersion != expected {
+			t.Errorf("Manifest %q was applied by version %q, expected %q", id, manifest.AppliedByVersion, expected)
+		}
+	}
+
+	manifests, err := ListManifests(s)
+	if err != nil {
+		t.Fatalf("Failed to list manifests: %v", err)
+	}
+
+	// The legacy manifest has no applied time recorded and sorts first.
+	if len(manifests) != 2 || manifests[1].ManifestID != "current" || manifests[1].AppliedByVersion != version.Version {
+		t.Errorf("Listed manifests %+v, expected the current one applied by version %q", manifests, version.Version)
+	}
+}
+
+func TestAddManifest(t *testing.T) {
+	s := testutil.NewState(t)
+
+	manifest, err := AddManifest(s, "m1", "data of m1")
+	if err != nil {
+		t.Fatalf("Failed to add manifest: %v", err)
+	}
+
+	if manifest.ManifestID != "m1" || manifest.Data != "data of m1" || manifest.Checksum != database.ManifestChecksum("data of m1") || manifest.AppliedDate == "" {
+		t.Errorf("Added manifest %+v, expected m1 with its data, checksum and applied date", manifest)
+	}
+
+	generated, err := AddManifest(s, "", "data of a generated id")
+	if err != nil {
+		t.Fatalf("Failed to add manifest without an id: %v", err)
+	}
+
+	if len(generated.ManifestID) != 32 {
+		t.Errorf("Generated manifest id %q, expected 32 hex digits", generated.ManifestID)
+	}
+
+	_, err = AddManifest(s, "m1", "other data")
+	if !api.StatusErrorCheck(err, http.StatusConflict) {
+		t.Errorf("Expected adding a manifest id already stored to fail with 409, got %v", err)
+	}
+
+	_, err = AddManifest(s, "large", strings.Repeat("x", MaxManifestSize+1))
+	if !api.StatusErrorCheck(err, http.StatusRequestEntityTooLarge) {
+		t.Errorf("Expected data beyond the maximum size to be rejected with 413, got %v", err)
+	}
+
+	_, err = GetManifest(s, "large")
+	if !api.StatusErrorCheck(err, http.StatusNotFound) {
+		t.Errorf("Expected a rejected manifest not to be stored, got %v", err)
+	}
+}
+
+func TestGetManifest(t *testing.T) {
+	s := testutil.NewState(t)
+
+	documents := map[string]string{
+		// Too short for compression to pay off, stored as is.
+		"short": "core: {}",
+		// Repetitive enough to be stored compressed.
+		"long": strings.Repeat("software:\n  charms:\n    keystone-k8s:\n      channel: 2024.1/stable\n", 200) + "trailing newline kept\n\n",
+	}
+
+	for id, data := range documents {
+		_, err := AddManifest(s, id, data)
+		if err != nil {
+			t.Fatalf("Failed to add manifest %q: %v", id, err)
+		}
+	}
+
+	var compressed map[string]bool
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		compressed = map[string]bool{}
+		for id := range documents {
+			record, err := database.GetManifestItem(ctx, tx, id)
+			if err != nil {
+				return err
+			}
+
+			compressed[id] = record.Compressed
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to get manifest records: %v", err)
+	}
+
+	if compressed["short"] || !compressed["long"] {
+		t.Fatalf("Manifests stored compressed %v, expected only the long one", compressed)
+	}
+
+	for id, data := range documents {
+		manifest, err := GetManifest(s, id)
+		if err != nil {
+			t.Fatalf("Failed to get manifest %q: %v", id, err)
+		}
+
+		if manifest.Data != data {
+			t.Errorf("Manifest %q has data %q, expected exactly %q", id, manifest.Data, data)
+		}
+
+		if manifest.ManifestID != id || manifest.Checksum != database.ManifestChecksum(data) || manifest.AppliedByVersion != version.Version || manifest.AppliedDate == "" {
+			t.Errorf("Got manifest %q as %+v, expected its id, checksum, version and applied date", id, manifest)
+		}
+	}
+
+	_, err = GetManifest(s, "missing")
+	if !api.StatusErrorCheck(err, http.StatusNotFound) {
+		t.Errorf("Expected a missing manifest to be reported with 404, got %v", err)
+	}
+}
+
+func TestRollbackManifest(t *testing.T) {
+	s := testutil.NewState(t)
+	addTestManifests(t, s, "m1", "m2", "corrupted")
+
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, "UPDATE manifest SET data = ?, compressed = 0 WHERE manifest_id = ?", "truncated", "corrupted")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Failed to corrupt manifest: %v", err)
+	}
+
+	source, err := GetManifest(s, "m1")
+	if err != nil {
+		t.Fatalf("Failed to get manifest: %v", err)
+	}
+
+	rollback, err := RollbackManifest(s, "m1")
+	if err != nil {
+		t.Fatalf("Failed to roll back manifest: %v", err)
+	}
+
+	if rollback.ManifestID == "m1" || rollback.RolledBackFrom != "m1" || rollback.Data != source.Data || rollback.Checksum != source.Checksum {
+		t.Errorf("Rollback created %+v, expected a new manifest rolled back from m1 with its data", rollback)
+	}
+
+	manifests, err := ListManifests(s)
+	if err != nil {
+		t.Fatalf("Failed to list manifests: %v", err)
+	}
+
+	latest := manifests[len(manifests)-1]
+	if len(manifests) != 4 || latest.ManifestID != rollback.ManifestID || latest.AppliedDate == source.AppliedDate {
+		t.Errorf("Manifests after the rollback are %+v, expected the rollback added last with a new applied date", manifests)
+	}
+
+	_, err = RollbackManifest(s, "missing")
+	if !api.StatusErrorCheck(err, http.StatusNotFound) {
+		t.Errorf("Expected rolling back to a missing manifest to fail with 404, got %v", err)
+	}
+
+	_, err = RollbackManifest(s, "corrupted")
+	if !api.StatusErrorCheck(err, http.StatusBadRequest) {
+		t.Errorf("Expected rolling back to a manifest not matching its checksum to fail with 400, got %v", err)
+	}
+}
@@ -0,0 +1,212 @@
+package sunbeam
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+
+	"github.com/canonical/lxd/shared/logger"
+	"github.com/canonical/microcluster/cluster"
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/database"
+)
+
+// RegisterNewMembers records a node for every cluster member that has none
+// yet, so the node inventory reflects the cluster as soon as a member joins.
+// The hook is not told which member joined, members that already have a node
+// are left untouched. The node is recorded without roles, machine id or
+// system id, adding the node through the API later fills them in.
+func RegisterNewMembers(s *state.State) error {
+	var registered []string
+
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		registered = nil
+
+		members, err := cluster.GetInternalClusterMembers(ctx, tx)
+		if err != nil {
+			return fmt.Errorf("Failed to fetch cluster members: %w", err)
+		}
+
+		for _, member := range members {
+			exists, err := database.NodeExists(ctx, tx, member.Name)
+			if err != nil {
+				return err
+			}
+
+			if exists {
+				continue
+			}
+
+			_, err = createNode(ctx, tx, database.Node{
+				Member:    member.Name,
+				Name:      member.Name,
+				MachineID: -1,
+				Status:    database.NodeStatusUnknown,
+			})
+			if err != nil {
+				return fmt.Errorf("Failed to record node %q: %w", member.Name, err)
+			}
+
+			err = recordChange(ctx, tx, "nodes", member.Name, database.ChangeCreate)
+			if err != nil {
+				return err
+			}
+
+			registered = append(registered, member.Name)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, name := range registered {
+		logger.Info("Registered node of new cluster member", logger.Ctx{"name": name})
+	}
+
+	return nil
+}
+
+// unregisteredNode returns whether a node was only recorded on joining the
+// cluster and has not been added through the API yet.
+func unregisteredNode(node database.Node, roles []string) bool {
+	return len(roles) == 0 && node.MachineID == -1 && node.SystemID == ""
+}
+
+// HandOverMemberNodes records the nodes recorded through the named cluster
+// member as recorded through another member, as a member that nodes refer to
+// cannot be removed from the cluster. It is run before the member is
+// removed, its own node is then purged once it has left. Nothing is handed
+// over when no other member remains.
+func HandOverMemberNodes(s *state.State, name string) error {
+	var heir string
+	var moved int64
+
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		heir = ""
+		moved = 0
+
+		members, err := cluster.GetInternalClusterMembers(ctx, tx)
+		if err != nil {
+			return fmt.Errorf("Failed to fetch cluster members: %w", err)
+		}
+
+		for _, member := range members {
+			if member.Name != name {
+				heir = member.Name
+				break
+			}
+		}
+
+		if heir == "" {
+			return nil
+		}
+
+		moved, err = database.MoveNodesToMember(ctx, tx, name, heir)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if moved > 0 {
+		logger.Info("Handed nodes over to another cluster member", logger.Ctx{"member": name, "heir": heir, "nodes": moved})
+	}
+
+	return nil
+}
+
+// PurgeRemovedMembers deletes the node records of members that have left the
+// cluster. The removal hook is not told which member left, so a node is
+// considered removed when it has heartbeated as a cluster member before but
+// no member of its name remains. Role and maintenance rows of the node are
+// deleted along with it. Purging is idempotent, the hook may run on several
+// peers or be retried.
+func PurgeRemovedMembers(s *state.State) error {
+	var purged []string
+	var lostRoles []string
+
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		purged = nil
+		lostRoles = nil
+
+		members, err := cluster.GetInternalClusterMembers(ctx, tx)
+		if err != nil {
+			return fmt.Errorf("Failed to fetch cluster members: %w", err)
+		}
+
+		memberNames := make(map[string]bool, len(members))
+		for _, member := range members {
+			memberNames[member.Name] = true
+		}
+
+		nodes, err := database.GetNodes(ctx, tx)
+		if err != nil {
+			return fmt.Errorf("Failed to fetch nodes: %w", err)
+		}
+
+		roles, err := database.GetNodeRoles(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		for _, node := range nodes {
+			if !node.LastSeen.Valid || memberNames[node.Name] {
+				continue
+			}
+
+			err = database.DeleteNode(ctx, tx, node.Name)
+			if err != nil {
+				return fmt.Errorf("Failed to delete node %q: %w", node.Name, err)
+			}
+
+			err = recordChange(ctx, tx, "nodes", node.Name, database.ChangeDelete)
+			if err != nil {
+				return err
+			}
+
+			purged = append(purged, node.Name)
+		}
+
+		held := map[string]bool{}
+		for _, node := range nodes {
+			if slices.Contains(purged, node.Name) {
+				continue
+			}
+
+			for _, role := range roles[node.ID] {
+				held[role] = true
+			}
+		}
+
+		for _, node := range nodes {
+			if !slices.Contains(purged, node.Name) {
+				continue
+			}
+
+			for _, role := range roles[node.ID] {
+				if !held[role] && !slices.Contains(lostRoles, role) {
+					lostRoles = append(lostRoles, role)
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, name := range purged {
+		logger.Info("Purged node of removed cluster member", logger.Ctx{"name": name})
+	}
+
+	for _, role := range lostRoles {
+		logger.Warn("No node holds role anymore, the cluster may be degraded", logger.Ctx{"role": role})
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package sunbeam
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/api/types"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/internal/testutil"
+)
+
+// nodeNames returns the names of the nodes of the cluster.
+func nodeNames(t *testing.T, s *state.State) []string {
+	t.Helper()
+
+	nodes, err := ListNodes(s, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to list nodes: %v", err)
+	}
+
+	names := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		names = append(names, node.Name)
+	}
+
+	return names
+}
+
+func TestPurgeRemovedMembers(t *testing.T) {
+	s := testutil.NewState(t)
+	testutil.AddMember(t, s, "member2", "10.0.0.2:7000")
+
+	err := RegisterNewMembers(s)
+	if err != nil {
+		t.Fatalf("Failed to register new members: %v", err)
+	}
+
+	// Only nodes that have heartbeated as members are purged.
+	err = UpdateNodeStatus(s)
+	if err != nil {
+		t.Fatalf("Failed to update node status: %v", err)
+	}
+
+	// Nodes refer to the member they were recorded through, they are
+	// handed over before it is removed.
+	err = HandOverMemberNodes(s, "member2")
+	if err != nil {
+		t.Fatalf("Failed to hand over the nodes of member2: %v", err)
+	}
+
+	testutil.RemoveMember(t, s, "10.0.0.2:7000")
+
+	// The hook may be retried, purging again must succeed.
+	for i := 0; i < 2; i++ {
+		err = PurgeRemovedMembers(s)
+		if err != nil {
+			t.Fatalf("Failed to purge removed members: %v", err)
+		}
+	}
+
+	names := nodeNames(t, s)
+	if !slices.Equal(names, []string{testutil.MemberName}) {
+		t.Fatalf("Nodes after purging are %v, expected only %q", names, testutil.MemberName)
+	}
+}
+
+func TestRegisterNewMembers(t *testing.T) {
+	s := testutil.NewState(t)
+
+	err := AddNode(s, testutil.MemberName, []string{"control"}, 1, "sys-1", types.NodeHardware{}, false)
+	if err != nil {
+		t.Fatalf("Failed to add node: %v", err)
+	}
+
+	testutil.AddMember(t, s, "member2", "10.0.0.2:7000")
+	testutil.AddMember(t, s, "member3", "10.0.0.3:7000")
+
+	// The hook runs on every member, registering twice must not fail.
+	for i := 0; i < 2; i++ {
+		err = RegisterNewMembers(s)
+		if err != nil {
+			t.Fatalf("Failed to register new members: %v", err)
+		}
+	}
+
+	names := nodeNames(t, s)
+	expected := []string{testutil.MemberName, "member2", "member3"}
+	if !slices.Equal(names, expected) {
+		t.Fatalf("Nodes after registering are %v, expected %v", names, expected)
+	}
+
+	node, err := GetNode(s, "member2")
+	if err != nil {
+		t.Fatalf("Failed to get node: %v", err)
+	}
+
+	if node.MachineID != -1 || node.SystemID != "" || len(node.Role) != 0 {
+		t.Errorf("Registered node has machine id %d, system id %q and roles %v, expected none", node.MachineID, node.SystemID, node.Role)
+	}
+
+	node, err = GetNode(s, testutil.MemberName)
+	if err != nil {
+		t.Fatalf("Failed to get node: %v", err)
+	}
+
+	if node.MachineID != 1 || node.SystemID != "sys-1" || !slices.Equal(node.Role, []string{"control"}) {
+		t.Errorf("Existing node was changed to machine id %d, system id %q and roles %v", node.MachineID, node.SystemID, node.Role)
+	}
+}
@@ -0,0 +1,97 @@
+package sunbeam
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/canonical/microcluster/state"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/database"
+)
+
+var (
+	nodesByRoleGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "sunbeam_nodes",
+		Help: "Number of nodes holding each role.",
+	}, []string{"role"})
+
+	configKeysGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "sunbeam_config_keys",
+		Help: "Number of config keys set.",
+	})
+
+	manifestsGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "sunbeam_manifests",
+		Help: "Number of manifests recorded.",
+	})
+
+	heartbeatsCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "sunbeam_heartbeats_total",
+		Help: "Number of heartbeat hooks run on the dqlite leader, by result.",
+	}, []string{"result"})
+)
+
+func init() {
+	prometheus.MustRegister(nodesByRoleGauge, configKeysGauge, manifestsGauge, heartbeatsCounter)
+
+	// Both results are exported from the start, so rates can be computed
+	// before the first failure.
+	heartbeatsCounter.WithLabelValues("success")
+	heartbeatsCounter.WithLabelValues("failure")
+}
+
+// RecordHeartbeat counts the outcome of a heartbeat hook run.
+func RecordHeartbeat(err error) {
+	if err != nil {
+		heartbeatsCounter.WithLabelValues("failure").Inc()
+		return
+	}
+
+	heartbeatsCounter.WithLabelValues("success").Inc()
+}
+
+// UpdateMetrics refreshes the gauges from the database, it is meant to run
+// before the metrics are gathered.
+func UpdateMetrics(s *state.State) error {
+	var roles map[int][]string
+	var configKeys []string
+	var manifests int
+
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		var err error
+		roles, err = database.GetNodeRoles(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		configKeys, err = database.GetConfigItemKeys(ctx, tx, nil)
+		if err != nil {
+			return err
+		}
+
+		manifests, err = database.CountManifests(ctx, tx)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	counts := map[string]int{}
+	for _, nodeRoles := range roles {
+		for _, role := range nodeRoles {
+			counts[role]++
+		}
+	}
+
+	// Roles no node holds anymore are dropped rather than left stale.
+	nodesByRoleGauge.Reset()
+	for role, count := range counts {
+		nodesByRoleGauge.WithLabelValues(role).Set(float64(count))
+	}
+
+	configKeysGauge.Set(float64(len(configKeys)))
+	manifestsGauge.Set(float64(manifests))
+
+	return nil
+}
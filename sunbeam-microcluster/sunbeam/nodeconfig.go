@@ -0,0 +1,143 @@
+package sunbeam
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+
+	"github.com/canonical/lxd/shared/api"
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/api/types"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/database"
+)
+
+// GetNodeConfig returns the value of a config key for the given node, the
+// value the node overrides it with or else the global value.
+func GetNodeConfig(s *state.State, node string, key string) (string, error) {
+	var value string
+
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		_, err := database.GetNode(ctx, tx, node)
+		if err != nil {
+			return err
+		}
+
+		value, err = database.GetEffectiveConfig(ctx, tx, node, key)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return value, nil
+}
+
+// SetNodeConfig overrides a config key for the given node. The change is
+// recorded in the node config history. The override and its history are
+// removed along with the node.
+func SetNodeConfig(s *state.State, node string, key string, value string) error {
+	if key == "" {
+		return api.StatusErrorf(http.StatusBadRequest, "Config key must not be empty")
+	}
+
+	return transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		return setNodeConfig(ctx, tx, node, key, value)
+	})
+}
+
+// setNodeConfig overrides a config key for the given node within the given
+// transaction, and records the change in the node config history.
+func setNodeConfig(ctx context.Context, tx *sql.Tx, node string, key string, value string) error {
+	err := database.ValidateConfigValue(key, value)
+	if err != nil {
+		return err
+	}
+
+	action := database.ChangeUpdate
+	oldValue := sql.NullString{}
+	current, err := database.GetNodeConfigItem(ctx, tx, node, key)
+	if err == nil {
+		oldValue = sql.NullString{String: current, Valid: true}
+	} else if api.StatusErrorCheck(err, http.StatusNotFound) {
+		action = database.ChangeCreate
+	} else {
+		return err
+	}
+
+	err = database.SetNodeConfigItem(ctx, tx, node, key, value)
+	if err != nil {
+		return err
+	}
+
+	// Terraform states and locks are kept out of the history as they are
+	// out of the global config history.
+	if !isTerraformKey(key) {
+		_, err = database.CreateNodeConfigHistoryEntry(ctx, tx, node, key, oldValue, sql.NullString{String: value, Valid: true})
+		if err != nil {
+			return err
+		}
+	}
+
+	return recordChange(ctx, tx, "node_config", node+"/"+key, action)
+}
+
+// GetNodeConfigHistory returns the changes of the value the given node
+// overrides a config key with, oldest first.
+func GetNodeConfigHistory(s *state.State, node string, key string) ([]types.NodeConfigHistoryEntry, error) {
+	history := []types.NodeConfigHistoryEntry{}
+
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		_, err := database.GetNode(ctx, tx, node)
+		if err != nil {
+			return err
+		}
+
+		records, err := database.GetNodeConfigHistory(ctx, tx, node, key)
+		if err != nil {
+			return err
+		}
+
+		for _, record := range records {
+			entry := types.NodeConfigHistoryEntry{ID: record.ID, Node: record.Node, Key: record.Key, ChangedAt: record.ChangedAt}
+			if record.OldValue.Valid {
+				entry.OldValue = &record.OldValue.String
+			}
+
+			if record.NewValue.Valid {
+				entry.NewValue = &record.NewValue.String
+			}
+
+			history = append(history, entry)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return history, nil
+}
+
+// RevertNodeConfig sets the override of a config key for the given node back
+// to the value set by the node config history entry with the given ID. The
+// revert is recorded in the history as a change of its own.
+func RevertNodeConfig(s *state.State, node string, key string, id int64) error {
+	return transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		entry, err := database.GetNodeConfigHistoryEntry(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		if entry.Node != node || entry.Key != key {
+			return api.StatusErrorf(http.StatusNotFound, "No change %d of config %q of node %q", id, key, node)
+		}
+
+		if !entry.NewValue.Valid {
+			return api.StatusErrorf(http.StatusBadRequest, "Change %d of config %q of node %q set no value to revert to", id, key, node)
+		}
+
+		return setNodeConfig(ctx, tx, node, key, entry.NewValue.String)
+	})
+}
@@ -0,0 +1,164 @@
+package sunbeam
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"testing"
+
+	"github.com/canonical/lxd/shared/api"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/internal/testutil"
+)
+
+func TestNodeConfig(t *testing.T) {
+	s := testutil.NewState(t)
+	addTestNodes(t, s, nil, "node1", "node2")
+
+	err := UpdateConfig(s, "region", "RegionOne")
+	if err != nil {
+		t.Fatalf("Failed to set global config: %v", err)
+	}
+
+	err = SetNodeConfig(s, "node1", "region", "RegionTwo")
+	if err != nil {
+		t.Fatalf("Failed to set node config: %v", err)
+	}
+
+	tests := []struct {
+		node  string
+		key   string
+		value string
+	}{
+		{node: "node1", key: "region", value: "RegionTwo"},
+		{node: "node2", key: "region", value: "RegionOne"},
+	}
+
+	for _, test := range tests {
+		value, err := GetNodeConfig(s, test.node, test.key)
+		if err != nil {
+			t.Fatalf("Failed to get config %q of %q: %v", test.key, test.node, err)
+		}
+
+		if value != test.value {
+			t.Errorf("Config %q of %q is %q, expected %q", test.key, test.node, value, test.value)
+		}
+	}
+
+	_, err = GetNodeConfig(s, "node1", "unset")
+	if !api.StatusErrorCheck(err, http.StatusNotFound) {
+		t.Errorf("Expected a key set neither for the node nor globally to be missing, got %v", err)
+	}
+
+	err = SetNodeConfig(s, "unknown", "region", "RegionTwo")
+	if !api.StatusErrorCheck(err, http.StatusNotFound) {
+		t.Errorf("Expected overriding config of an unknown node to fail with 404, got %v", err)
+	}
+
+	err = DeleteNode(s, "node1")
+	if err != nil {
+		t.Fatalf("Failed to delete node: %v", err)
+	}
+
+	var overrides int
+	err = transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		return tx.QueryRowContext(ctx, "SELECT count(*) FROM node_config").Scan(&overrides)
+	})
+	if err != nil {
+		t.Fatalf("Failed to count node config: %v", err)
+	}
+
+	if overrides != 0 {
+		t.Errorf("Found %d node config overrides after deleting the node, expected them deleted with it", overrides)
+	}
+}
+
+func TestNodeConfigHistory(t *testing.T) {
+	s := testutil.NewState(t)
+	addTestNodes(t, s, nil, "node1", "node2")
+
+	for _, value := range []string{"RegionOne", "RegionTwo"} {
+		err := SetNodeConfig(s, "node1", "region", value)
+		if err != nil {
+			t.Fatalf("Failed to set node config: %v", err)
+		}
+	}
+
+	err := SetNodeConfig(s, "node2", "region", "RegionThree")
+	if err != nil {
+		t.Fatalf("Failed to set node config: %v", err)
+	}
+
+	history, err := GetNodeConfigHistory(s, "node1", "region")
+	if err != nil {
+		t.Fatalf("Failed to get node config history: %v", err)
+	}
+
+	expected := [][2]string{{"<unset>", "RegionOne"}, {"RegionOne", "RegionTwo"}}
+	if len(history) != len(expected) {
+		t.Fatalf("Node config history has %d entries, expected %d", len(history), len(expected))
+	}
+
+	for i, entry := range history {
+		change := [2]string{historyValue(entry.OldValue), historyValue(entry.NewValue)}
+		if change != expected[i] {
+			t.Errorf("Node config history entry %d changed the value from %q to %q, expected %q to %q", i, change[0], change[1], expected[i][0], expected[i][1])
+		}
+	}
+
+	err = RevertNodeConfig(s, "node1", "region", history[0].ID)
+	if err != nil {
+		t.Fatalf("Failed to revert node config: %v", err)
+	}
+
+	value, err := GetNodeConfig(s, "node1", "region")
+	if err != nil {
+		t.Fatalf("Failed to get node config: %v", err)
+	}
+
+	if value != "RegionOne" {
+		t.Errorf("Node config is %q after reverting, expected %q", value, "RegionOne")
+	}
+
+	history, err = GetNodeConfigHistory(s, "node1", "region")
+	if err != nil {
+		t.Fatalf("Failed to get node config history: %v", err)
+	}
+
+	if len(history) != 3 || historyValue(history[2].OldValue) != "RegionTwo" || historyValue(history[2].NewValue) != "RegionOne" {
+		t.Errorf("Expected the revert recorded as a change from %q to %q, got %d entries", "RegionTwo", "RegionOne", len(history))
+	}
+
+	// Changes of other nodes or keys cannot be reverted to.
+	other, err := GetNodeConfigHistory(s, "node2", "region")
+	if err != nil {
+		t.Fatalf("Failed to get node config history: %v", err)
+	}
+
+	err = RevertNodeConfig(s, "node1", "region", other[0].ID)
+	if !api.StatusErrorCheck(err, http.StatusNotFound) {
+		t.Errorf("Expected reverting to a change of another node to fail with 404, got %v", err)
+	}
+
+	err = RevertNodeConfig(s, "node1", "zone", history[0].ID)
+	if !api.StatusErrorCheck(err, http.StatusNotFound) {
+		t.Errorf("Expected reverting to a change of another key to fail with 404, got %v", err)
+	}
+
+	err = DeleteNode(s, "node1")
+	if err != nil {
+		t.Fatalf("Failed to delete node: %v", err)
+	}
+
+	var changes int
+	err = transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		return tx.QueryRowContext(ctx, "SELECT count(*) FROM node_config_history").Scan(&changes)
+	})
+	if err != nil {
+		t.Fatalf("Failed to count node config history: %v", err)
+	}
+
+	if changes != 1 {
+		t.Errorf("Found %d node config changes after deleting the node, expected only the one of the other node", changes)
+	}
+}
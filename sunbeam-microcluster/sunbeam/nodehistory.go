@@ -0,0 +1,202 @@
+package sunbeam
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/canonical/lxd/shared/api"
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/api/types"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/database"
+)
+
+// nodesHistoryKey is the config key holding whether node changes are
+// recorded in the history.
+const nodesHistoryKey = "nodes.history"
+
+// nodesHistoryRetentionKey is the config key holding the number of days
+// node changes are kept in the history.
+const nodesHistoryRetentionKey = "nodes.history-retention-days"
+
+// defaultNodesHistoryRetention applies when no retention is configured.
+const defaultNodesHistoryRetention = 90
+
+// nodeFieldChange is a change of a field of a node, as recorded in the
+// history.
+type nodeFieldChange struct {
+	Field    string
+	OldValue sql.NullString
+	NewValue sql.NullString
+}
+
+// nodeHistoryFields are the node fields whose changes are recorded, named
+// as in the API. Status and last seen time are heartbeat bookkeeping and
+// are left out. Renames are recorded by RenameNode.
+var nodeHistoryFields = []struct {
+	Name  string
+	Value func(node database.Node) string
+}{
+	{"member", func(node database.Node) string { return node.Member }},
+	{"machineid", func(node database.Node) string { return strconv.Itoa(node.MachineID) }},
+	{"systemid", func(node database.Node) string { return node.SystemID }},
+	{"owner", func(node database.Node) string { return node.Owner }},
+	{"cordoned", func(node database.Node) string { return strconv.FormatBool(node.Cordoned) }},
+	{"cpu_count", func(node database.Node) string { return strconv.Itoa(node.CPUCount) }},
+	{"memory_mb", func(node database.Node) string { return strconv.Itoa(node.MemoryMB) }},
+	{"disk_gb", func(node database.Node) string { return strconv.Itoa(node.DiskGB) }},
+	{"last_manifest_id", func(node database.Node) string { return node.LastManifestID }},
+	{"metadata", func(node database.Node) string { return node.Metadata }},
+}
+
+// nodeRecordChanges returns the changes of the recorded fields between two
+// versions of a node record.
+func nodeRecordChanges(old database.Node, updated database.Node) []nodeFieldChange {
+	changes := []nodeFieldChange{}
+	for _, field := range nodeHistoryFields {
+		oldValue, newValue := field.Value(old), field.Value(updated)
+		if oldValue != newValue {
+			changes = append(changes, nodeFieldChange{
+				Field:    field.Name,
+				OldValue: sql.NullString{String: oldValue, Valid: true},
+				NewValue: sql.NullString{String: newValue, Valid: true},
+			})
+		}
+	}
+
+	return changes
+}
+
+// nodeRolesChange returns the change of the roles of a node, nil if the
+// roles are the same. Roles are recorded comma separated.
+func nodeRolesChange(old []string, updated []string) *nodeFieldChange {
+	oldValue, newValue := strings.Join(old, ","), strings.Join(updated, ",")
+	if oldValue == newValue {
+		return nil
+	}
+
+	return &nodeFieldChange{
+		Field:    "role",
+		OldValue: sql.NullString{String: oldValue, Valid: true},
+		NewValue: sql.NullString{String: newValue, Valid: true},
+	}
+}
+
+// recordNodeHistory records changes of the node with the given name within
+// the given transaction, if node history is enabled.
+func recordNodeHistory(ctx context.Context, tx *sql.Tx, name string, changes ...nodeFieldChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	value, err := effectiveConfigValue(ctx, tx, nodesHistoryKey)
+	if err != nil && !api.StatusErrorCheck(err, http.StatusNotFound) {
+		return err
+	}
+
+	if value != "true" {
+		return nil
+	}
+
+	for _, change := range changes {
+		_, err = database.CreateNodeHistoryEntry(ctx, tx, name, change.Field, change.OldValue, change.NewValue)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setNodeRoles replaces the roles of a node and records the change in the
+// node history.
+func setNodeRoles(ctx context.Context, tx *sql.Tx, node database.Node, roles []string) error {
+	old, err := database.GetNodeRolesByNodeID(ctx, tx, node.ID)
+	if err != nil {
+		return err
+	}
+
+	err = database.SetNodeRoles(ctx, tx, node.ID, roles)
+	if err != nil {
+		return err
+	}
+
+	change := nodeRolesChange(old, roles)
+	if change == nil {
+		return nil
+	}
+
+	return recordNodeHistory(ctx, tx, node.Name, *change)
+}
+
+// GetNodeHistory returns the changes of the node with the given name,
+// oldest first. The changes of a deleted node are kept until trimmed, those
+// of a renamed node follow it to its new name.
+func GetNodeHistory(s *state.State, name string) ([]types.NodeHistoryEntry, error) {
+	history := []types.NodeHistoryEntry{}
+
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		records, err := database.GetNodeHistory(ctx, tx, name)
+		if err != nil {
+			return err
+		}
+
+		for _, record := range records {
+			entry := types.NodeHistoryEntry{Name: record.Name, Field: record.Field, ChangedAt: record.ChangedAt}
+			if record.OldValue.Valid {
+				entry.OldValue = &record.OldValue.String
+			}
+
+			if record.NewValue.Valid {
+				entry.NewValue = &record.NewValue.String
+			}
+
+			history = append(history, entry)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return history, nil
+}
+
+// TrimNodeHistory deletes the node changes older than the given number of
+// days, in batches, and returns the number deleted.
+func TrimNodeHistory(s *state.State, days int) (int64, error) {
+	if days <= 0 {
+		return 0, api.StatusErrorf(http.StatusBadRequest, "Node history retention must be a positive number of days")
+	}
+
+	before := time.Now().AddDate(0, 0, -days)
+
+	return deleteInBatches(s, func(ctx context.Context, tx *sql.Tx) (int64, error) {
+		return database.DeleteNodeHistoryBefore(ctx, tx, before, compactBatchSize)
+	})
+}
+
+// nodesHistoryRetention returns the configured node history retention, in
+// days.
+func nodesHistoryRetention(s *state.State) (int, error) {
+	value, err := GetConfig(s, nodesHistoryRetentionKey)
+	if err != nil {
+		if api.StatusErrorCheck(err, http.StatusNotFound) {
+			return defaultNodesHistoryRetention, nil
+		}
+
+		return 0, err
+	}
+
+	days, err := strconv.Atoi(value)
+	if err != nil || days <= 0 {
+		return 0, api.StatusErrorf(http.StatusBadRequest, "Invalid %q value %q", nodesHistoryRetentionKey, value)
+	}
+
+	return days, nil
+}
@@ -0,0 +1,122 @@
+package sunbeam
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/canonical/lxd/shared/api"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/api/types"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/internal/testutil"
+)
+
+// nodeHistoryChanges returns the history of a node as field, old value and
+// new value triples, unset values shown as "-".
+func nodeHistoryChanges(history []types.NodeHistoryEntry) [][3]string {
+	changes := [][3]string{}
+	for _, entry := range history {
+		change := [3]string{entry.Field, "-", "-"}
+		if entry.OldValue != nil {
+			change[1] = *entry.OldValue
+		}
+
+		if entry.NewValue != nil {
+			change[2] = *entry.NewValue
+		}
+
+		changes = append(changes, change)
+	}
+
+	return changes
+}
+
+func TestNodeHistory(t *testing.T) {
+	s := testutil.NewState(t)
+
+	addTestNodes(t, s, map[string][]string{"node1": {"control"}}, "node1")
+
+	err := UpdateNode(s, "node1", []string{"compute"}, -1, "")
+	if err != nil {
+		t.Fatalf("Failed to update node: %v", err)
+	}
+
+	history, err := GetNodeHistory(s, "node1")
+	if err != nil {
+		t.Fatalf("Failed to get node history: %v", err)
+	}
+
+	if len(history) != 0 {
+		t.Fatalf("Node history is %v while disabled, expected it empty", nodeHistoryChanges(history))
+	}
+
+	err = UpdateConfig(s, "nodes.history", "true")
+	if err != nil {
+		t.Fatalf("Failed to enable node history: %v", err)
+	}
+
+	err = UpdateNode(s, "node1", []string{"compute", "control"}, -1, "")
+	if err != nil {
+		t.Fatalf("Failed to update node: %v", err)
+	}
+
+	// Updating to the same roles records nothing.
+	err = UpdateNode(s, "node1", []string{"compute", "control"}, -1, "")
+	if err != nil {
+		t.Fatalf("Failed to update node: %v", err)
+	}
+
+	err = RenameNode(s, "node1", "node2")
+	if err != nil {
+		t.Fatalf("Failed to rename node: %v", err)
+	}
+
+	err = DeleteNode(s, "node2")
+	if err != nil {
+		t.Fatalf("Failed to delete node: %v", err)
+	}
+
+	history, err = GetNodeHistory(s, "node2")
+	if err != nil {
+		t.Fatalf("Failed to get node history: %v", err)
+	}
+
+	changes := nodeHistoryChanges(history)
+	expected := [][3]string{
+		{"role", "compute", "compute,control"},
+		{"name", "node1", "node2"},
+		{"deleted", "-", "hard"},
+	}
+
+	if len(changes) != len(expected) {
+		t.Fatalf("Node history is %v, expected %v", changes, expected)
+	}
+
+	for i := range expected {
+		if changes[i] != expected[i] || history[i].Name != "node2" {
+			t.Errorf("Node history entry %d is %v for %q, expected %v for node2", i, changes[i], history[i].Name, expected[i])
+		}
+	}
+
+	history, err = GetNodeHistory(s, "node1")
+	if err != nil {
+		t.Fatalf("Failed to get node history: %v", err)
+	}
+
+	if len(history) != 0 {
+		t.Errorf("History of the old node name is %v, expected it to follow the rename", nodeHistoryChanges(history))
+	}
+
+	removed, err := TrimNodeHistory(s, 1)
+	if err != nil {
+		t.Fatalf("Failed to trim node history: %v", err)
+	}
+
+	if removed != 0 {
+		t.Errorf("Trimmed %d recent node changes, expected none", removed)
+	}
+
+	_, err = TrimNodeHistory(s, 0)
+	if !api.StatusErrorCheck(err, http.StatusBadRequest) {
+		t.Errorf("Expected trimming with no retention to be rejected with 400, got %v", err)
+	}
+}
@@ -0,0 +1,149 @@
+package sunbeam
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/canonical/lxd/shared/api"
+	"github.com/canonical/lxd/shared/logger"
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/database"
+)
+
+// emptyNodeMetadata is the metadata of a node without any labels.
+const emptyNodeMetadata = "{}"
+
+// GetNodeMetadata returns the metadata of the node with the given name.
+func GetNodeMetadata(s *state.State, name string) (map[string]any, error) {
+	var metadata map[string]any
+
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		node, err := database.GetNode(ctx, tx, name)
+		if err != nil {
+			return err
+		}
+
+		metadata, err = decodeNodeMetadata(node.Metadata)
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return metadata, nil
+}
+
+// PatchNodeMetadata merges the given patch into the metadata of a node and
+// returns the result. Objects are merged recursively and keys set to null
+// are removed, as in a JSON merge patch.
+func PatchNodeMetadata(s *state.State, name string, patch map[string]any) (map[string]any, error) {
+	if patch == nil {
+		return nil, api.StatusErrorf(http.StatusBadRequest, "Node metadata must be a JSON object")
+	}
+
+	var metadata map[string]any
+
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		node, err := database.GetNode(ctx, tx, name)
+		if err != nil {
+			return err
+		}
+
+		metadata, err = decodeNodeMetadata(node.Metadata)
+		if err != nil {
+			return err
+		}
+
+		metadata = mergeNodeMetadata(metadata, patch)
+
+		node.Metadata = encodeNodeMetadata(metadata)
+		err = updateNode(ctx, tx, name, *node)
+		if err != nil {
+			return fmt.Errorf("Failed to update node metadata: %w", err)
+		}
+
+		return recordChange(ctx, tx, "nodes", name, database.ChangeUpdate)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return metadata, nil
+}
+
+// mergeNodeMetadata applies a JSON merge patch to metadata and returns it.
+func mergeNodeMetadata(metadata map[string]any, patch map[string]any) map[string]any {
+	if metadata == nil {
+		metadata = map[string]any{}
+	}
+
+	for key, value := range patch {
+		if value == nil {
+			delete(metadata, key)
+			continue
+		}
+
+		patchObject, ok := value.(map[string]any)
+		if !ok {
+			metadata[key] = value
+			continue
+		}
+
+		existing, _ := metadata[key].(map[string]any)
+		metadata[key] = mergeNodeMetadata(existing, patchObject)
+	}
+
+	return metadata
+}
+
+// decodeNodeMetadata decodes the metadata column of a node.
+func decodeNodeMetadata(value string) (map[string]any, error) {
+	metadata := map[string]any{}
+	if value == "" {
+		return metadata, nil
+	}
+
+	err := json.Unmarshal([]byte(value), &metadata)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decode node metadata: %w", err)
+	}
+
+	return metadata, nil
+}
+
+// encodeNodeMetadata encodes metadata for the metadata column of a node.
+func encodeNodeMetadata(metadata map[string]any) string {
+	if len(metadata) == 0 {
+		return emptyNodeMetadata
+	}
+
+	value, err := json.Marshal(metadata)
+	if err != nil {
+		// Metadata decoded from JSON always encodes back.
+		logger.Warn("Failed to encode node metadata", logger.Ctx{"err": err})
+		return emptyNodeMetadata
+	}
+
+	return string(value)
+}
+
+// nodeMetadata returns the metadata of a node record, or none if it cannot
+// be decoded.
+func nodeMetadata(node database.Node) map[string]any {
+	metadata, err := decodeNodeMetadata(node.Metadata)
+	if err != nil {
+		logger.Warn("Ignoring invalid node metadata", logger.Ctx{"node": node.Name, "err": err})
+		return nil
+	}
+
+	if len(metadata) == 0 {
+		return nil
+	}
+
+	return metadata
+}
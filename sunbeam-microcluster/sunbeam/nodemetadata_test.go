@@ -0,0 +1,90 @@
+package sunbeam
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/canonical/lxd/shared/api"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/database"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/internal/testutil"
+)
+
+func TestPatchNodeMetadata(t *testing.T) {
+	s := testutil.NewState(t)
+	addTestNodes(t, s, nil, "node1")
+
+	metadata, err := GetNodeMetadata(s, "node1")
+	if err != nil {
+		t.Fatalf("Failed to get node metadata: %v", err)
+	}
+
+	if len(metadata) != 0 {
+		t.Fatalf("New node has metadata %v, expected none", metadata)
+	}
+
+	patches := []struct {
+		patch    map[string]any
+		expected map[string]any
+	}{
+		{
+			patch:    map[string]any{"zone": "az1", "hardware": map[string]any{"gpu": true, "nics": 2.0}},
+			expected: map[string]any{"zone": "az1", "hardware": map[string]any{"gpu": true, "nics": 2.0}},
+		},
+		{
+			patch:    map[string]any{"rack": "r7", "hardware": map[string]any{"nics": 4.0}},
+			expected: map[string]any{"zone": "az1", "rack": "r7", "hardware": map[string]any{"gpu": true, "nics": 4.0}},
+		},
+		{
+			patch:    map[string]any{"zone": nil, "hardware": map[string]any{"gpu": nil}},
+			expected: map[string]any{"rack": "r7", "hardware": map[string]any{"nics": 4.0}},
+		},
+	}
+
+	for i, patch := range patches {
+		metadata, err := PatchNodeMetadata(s, "node1", patch.patch)
+		if err != nil {
+			t.Fatalf("Failed to apply metadata patch %d: %v", i+1, err)
+		}
+
+		stored, err := GetNodeMetadata(s, "node1")
+		if err != nil {
+			t.Fatalf("Failed to get node metadata: %v", err)
+		}
+
+		if !reflect.DeepEqual(metadata, patch.expected) || !reflect.DeepEqual(stored, patch.expected) {
+			t.Errorf("Metadata patch %d returned %v and stored %v, expected %v", i+1, metadata, stored, patch.expected)
+		}
+	}
+
+	_, err = PatchNodeMetadata(s, "node1", nil)
+	if !api.StatusErrorCheck(err, http.StatusBadRequest) {
+		t.Errorf("Expected a patch that is not an object to be rejected with 400, got %v", err)
+	}
+
+	_, err = PatchNodeMetadata(s, "unknown", map[string]any{"zone": "az1"})
+	if !api.StatusErrorCheck(err, http.StatusNotFound) {
+		t.Errorf("Expected patching the metadata of an unknown node to fail with 404, got %v", err)
+	}
+}
+
+func TestRenderConfigTemplateLabels(t *testing.T) {
+	node := database.Node{Name: "node1", Metadata: `{"zone": "az1", "hardware": {"gpu": true}}`}
+
+	value, err := renderConfigTemplate("availability-zone=${node.label.zone}", node, nil)
+	if err != nil {
+		t.Fatalf("Failed to render config template: %v", err)
+	}
+
+	if value != "availability-zone=az1" {
+		t.Errorf("Rendered config template as %q, expected the zone label interpolated", value)
+	}
+
+	for _, template := range []string{"${node.label.rack}", "${node.label.hardware}"} {
+		_, err = renderConfigTemplate(template, node, nil)
+		if !api.StatusErrorCheck(err, http.StatusBadRequest) {
+			t.Errorf("Expected %q to fail to render with 400 for a node without such a string label, got %v", template, err)
+		}
+	}
+}
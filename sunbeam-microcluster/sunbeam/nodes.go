@@ -3,38 +3,39 @@ package sunbeam
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
 	"fmt"
+	"net/http"
+	"slices"
 	"sort"
+	"strings"
+	"time"
 
+	"github.com/canonical/lxd/shared/api"
 	"github.com/canonical/microcluster/state"
 
 	"github.com/canonical/snap-openstack/sunbeam-microcluster/api/types"
 	"github.com/canonical/snap-openstack/sunbeam-microcluster/database"
 )
 
-// ListNodes return all the nodes, filterable by role (Optional)
-func ListNodes(s *state.State, roles []string) (types.Nodes, error) {
+// ListNodes return all the nodes, filterable by role, owner (Optional) and
+// metadata labels
+func ListNodes(s *state.State, roles []string, owner *string, labels []database.NodeLabel) (types.Nodes, error) {
 	nodes := types.Nodes{}
 
 	// Get the nodes from the database.
-	err := s.Database.Transaction(s.Context, func(ctx context.Context, tx *sql.Tx) error {
-		records, err := database.GetNodesFromRoles(ctx, tx, roles)
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		records, err := database.GetNodesFromRoles(ctx, tx, roles, owner, labels)
 		if err != nil {
 			return fmt.Errorf("Failed to fetch nodes: %w", err)
 		}
 
+		roles, err := database.GetNodeRoles(ctx, tx)
+		if err != nil {
+			return err
+		}
+
 		for _, node := range records {
-			nodeRole, err := roleFromStr(node.Role)
-			if err != nil {
-				return err
-			}
-			nodes = append(nodes, types.Node{
-				Name:      node.Name,
-				Role:      nodeRole,
-				MachineID: node.MachineID,
-				SystemID:  node.SystemID,
-			})
+			nodes = append(nodes, nodeFromRecord(node, roles[node.ID]))
 		}
 
 		return nil
@@ -46,23 +47,156 @@ func ListNodes(s *state.State, roles []string) (types.Nodes, error) {
 	return nodes, nil
 }
 
+// ValidateNodeRoles reports the nodes holding roles that are not allowed,
+// such as roles recorded before the allowed roles were enforced or changed.
+func ValidateNodeRoles(s *state.State) (types.NodeRoleValidation, error) {
+	validation := types.NodeRoleValidation{Invalid: map[string][]string{}}
+
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		var err error
+		validation.Allowed, err = database.GetAllowedNodeRoles(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		records, err := database.GetNodes(ctx, tx)
+		if err != nil {
+			return fmt.Errorf("Failed to fetch nodes: %w", err)
+		}
+
+		roles, err := database.GetNodeRoles(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		for _, node := range records {
+			for _, role := range roles[node.ID] {
+				if !slices.Contains(validation.Allowed, role) {
+					validation.Invalid[node.Name] = append(validation.Invalid[node.Name], role)
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return types.NodeRoleValidation{}, err
+	}
+
+	return validation, nil
+}
+
+// ListNodesPage returns at most limit nodes matching the given roles, owner
+// and labels, ordered by id and skipping the first offset matches. The offset of
+// the next page is set if there are more nodes.
+func ListNodesPage(s *state.State, roles []string, owner *string, labels []database.NodeLabel, limit int, offset int) (types.NodesPage, error) {
+	page := types.NodesPage{Nodes: types.Nodes{}}
+
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		// One more node than requested tells whether a next page exists.
+		records, err := database.GetNodesPage(ctx, tx, roles, owner, labels, limit+1, offset)
+		if err != nil {
+			return fmt.Errorf("Failed to fetch nodes: %w", err)
+		}
+
+		if len(records) > limit {
+			records = records[:limit]
+			next := offset + limit
+			page.NextOffset = &next
+		}
+
+		roles, err := database.GetNodeRoles(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		for _, node := range records {
+			page.Nodes = append(page.Nodes, nodeFromRecord(node, roles[node.ID]))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return types.NodesPage{}, err
+	}
+
+	return page, nil
+}
+
+// nodeFromRecord converts a database node and its roles to its API type.
+func nodeFromRecord(node database.Node, roles []string) types.Node {
+	return types.Node{
+		Name:           node.Name,
+		Role:           nodeRoles(roles),
+		MachineID:      node.MachineID,
+		SystemID:       node.SystemID,
+		Owner:          node.Owner,
+		Cordoned:       node.Cordoned,
+		LastManifestID: node.LastManifestID,
+		Status:         node.Status,
+		LastSeen:       lastSeen(node),
+		CreatedAt:      node.CreatedAt,
+		UpdatedAt:      node.UpdatedAt,
+		Metadata:       nodeMetadata(node),
+		NodeHardware: types.NodeHardware{
+			CPUCount: node.CPUCount,
+			MemoryMB: node.MemoryMB,
+			DiskGB:   node.DiskGB,
+		},
+	}
+}
+
 // GetNode returns a Node with the given name
 func GetNode(s *state.State, name string) (types.Node, error) {
 	node := types.Node{MachineID: -1}
-	err := s.Database.Transaction(s.Context, func(ctx context.Context, tx *sql.Tx) error {
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
 		record, err := database.GetNode(ctx, tx, name)
 		if err != nil {
 			return err
 		}
 
-		nodeRole, err := roleFromStr(record.Role)
+		roles, err := database.GetNodeRolesByNodeID(ctx, tx, record.ID)
 		if err != nil {
 			return err
 		}
+
 		node.Name = record.Name
-		node.Role = nodeRole
+		node.Role = nodeRoles(roles)
 		node.MachineID = record.MachineID
 		node.SystemID = record.SystemID
+		node.Owner = record.Owner
+		node.Cordoned = record.Cordoned
+		node.LastManifestID = record.LastManifestID
+		node.Status = record.Status
+		node.LastSeen = lastSeen(*record)
+		node.CreatedAt = record.CreatedAt
+		node.UpdatedAt = record.UpdatedAt
+		node.Metadata = nodeMetadata(*record)
+		node.CPUCount = record.CPUCount
+		node.MemoryMB = record.MemoryMB
+		node.DiskGB = record.DiskGB
+
+		return nil
+	})
+
+	return node, err
+}
+
+// GetNodeBySystemID returns the Node with the given MAAS system ID.
+func GetNodeBySystemID(s *state.State, systemID string) (types.Node, error) {
+	var node types.Node
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		record, err := database.GetNodeBySystemID(ctx, tx, systemID)
+		if err != nil {
+			return err
+		}
+
+		roles, err := database.GetNodeRolesByNodeID(ctx, tx, record.ID)
+		if err != nil {
+			return err
+		}
+
+		node = nodeFromRecord(*record, roles)
 
 		return nil
 	})
@@ -70,20 +204,93 @@ func GetNode(s *state.State, name string) (types.Node, error) {
 	return node, err
 }
 
-// AddNode adds a node to the database
-func AddNode(s *state.State, name string, role []string, machineid int, systemid string) error {
-	nodeRole, err := roleToStr(role)
+// AddNode adds a node to the database. With upsert, a node that already
+// exists is updated instead of rejected, keeping the fields not given: the
+// roles are only replaced if role is not nil, the machine id if not -1, the
+// system id if not empty and the hardware if not all zero.
+func AddNode(s *state.State, name string, role []string, machineid int, systemid string, hardware types.NodeHardware, upsert bool) error {
+	roleGiven := role != nil
+	role = nodeRoles(role)
+	err := validateNodeHardware(hardware)
 	if err != nil {
 		return err
 	}
+
 	// Add node to the database.
-	err = s.Database.Transaction(s.Context, func(ctx context.Context, tx *sql.Tx) error {
-		_, err := database.CreateNode(ctx, tx, database.Node{Member: s.Name(), Name: name, Role: nodeRole, MachineID: machineid, SystemID: systemid})
+	err = transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		err := database.ValidateNodeRoles(ctx, tx, role)
+		if err != nil {
+			return err
+		}
+
+		err = database.VerifyMachineIDFree(ctx, tx, machineid, name)
+		if err != nil {
+			return err
+		}
+
+		// A node recorded when its member joined the cluster is completed
+		// rather than rejected as a duplicate.
+		existing, err := database.GetNode(ctx, tx, name)
+		if err == nil {
+			roles, err := database.GetNodeRolesByNodeID(ctx, tx, existing.ID)
+			if err != nil {
+				return err
+			}
+
+			if !unregisteredNode(*existing, roles) {
+				if !upsert {
+					return api.StatusErrorf(http.StatusConflict, "This \"nodes\" entry already exists")
+				}
+
+				if !roleGiven {
+					role = nil
+				}
+
+				return updateNodeFields(ctx, tx, s.Name(), existing, role, machineid, systemid, hardware)
+			}
+
+			existing.Role = database.LegacyRole(role)
+			existing.MachineID = machineid
+			existing.SystemID = systemid
+			existing.CPUCount = hardware.CPUCount
+			existing.MemoryMB = hardware.MemoryMB
+			existing.DiskGB = hardware.DiskGB
+			err = updateNode(ctx, tx, name, *existing)
+			if err != nil {
+				return fmt.Errorf("Failed to record node: %w", err)
+			}
+
+			err = setNodeRoles(ctx, tx, *existing, role)
+			if err != nil {
+				return err
+			}
+
+			return recordChange(ctx, tx, "nodes", name, database.ChangeUpdate)
+		} else if !api.StatusErrorCheck(err, http.StatusNotFound) {
+			return err
+		}
+
+		id, err := createNode(ctx, tx, database.Node{
+			Member:    s.Name(),
+			Name:      name,
+			Role:      database.LegacyRole(role),
+			MachineID: machineid,
+			SystemID:  systemid,
+			CPUCount:  hardware.CPUCount,
+			MemoryMB:  hardware.MemoryMB,
+			DiskGB:    hardware.DiskGB,
+			Status:    database.NodeStatusUnknown,
+		})
 		if err != nil {
 			return fmt.Errorf("Failed to record node: %w", err)
 		}
 
-		return nil
+		err = database.SetNodeRoles(ctx, tx, int(id), role)
+		if err != nil {
+			return err
+		}
+
+		return recordChange(ctx, tx, "nodes", name, database.ChangeCreate)
 	})
 	if err != nil {
 		return err
@@ -92,53 +299,494 @@ func AddNode(s *state.State, name string, role []string, machineid int, systemid
 	return nil
 }
 
+// AddNodesBatch adds the given nodes in a single transaction. Nothing is
+// added if any node is invalid, listed twice, already exists or reuses a
+// machine ID.
+func AddNodesBatch(s *state.State, nodes types.Nodes) error {
+	if len(nodes) == 0 {
+		return api.StatusErrorf(http.StatusBadRequest, "No nodes to add")
+	}
+
+	records := make([]database.Node, 0, len(nodes))
+	roles := make([][]string, 0, len(nodes))
+	now := time.Now().UTC()
+	for _, node := range nodes {
+		if node.Name == "" {
+			return api.StatusErrorf(http.StatusBadRequest, "Node name must not be empty")
+		}
+
+		err := validateNodeHardware(node.NodeHardware)
+		if err != nil {
+			return fmt.Errorf("Invalid node %q: %w", node.Name, err)
+		}
+
+		role := nodeRoles(node.Role)
+		roles = append(roles, role)
+		records = append(records, database.Node{
+			Member:    s.Name(),
+			Name:      node.Name,
+			Role:      database.LegacyRole(role),
+			MachineID: node.MachineID,
+			SystemID:  node.SystemID,
+			CPUCount:  node.CPUCount,
+			MemoryMB:  node.MemoryMB,
+			DiskGB:    node.DiskGB,
+			Status:    database.NodeStatusUnknown,
+			CreatedAt: now,
+			UpdatedAt: now,
+			Metadata:  emptyNodeMetadata,
+		})
+	}
+
+	return transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		for i, role := range roles {
+			err := database.ValidateNodeRoles(ctx, tx, role)
+			if err != nil {
+				return fmt.Errorf("Invalid node %q: %w", records[i].Name, err)
+			}
+		}
+
+		ids, err := database.AddNodesBatch(ctx, tx, records)
+		if err != nil {
+			return err
+		}
+
+		for i, id := range ids {
+			err = database.SetNodeRoles(ctx, tx, int(id), roles[i])
+			if err != nil {
+				return err
+			}
+
+			err = recordChange(ctx, tx, "nodes", records[i].Name, database.ChangeCreate)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
 // UpdateNode updates a node record in the database
 func UpdateNode(s *state.State, name string, role []string, machineid int, systemid string) error {
-	nodeRole, err := roleToStr(role)
+	// Update node to the database.
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		node, err := database.GetNode(ctx, tx, name)
+		if err != nil {
+			return fmt.Errorf("Failed to retrieve node details: %w", err)
+		}
+
+		return updateNodeFields(ctx, tx, s.Name(), node, role, machineid, systemid, types.NodeHardware{})
+	})
 	if err != nil {
 		return err
 	}
-	// Update node to the database.
-	err = s.Database.Transaction(s.Context, func(ctx context.Context, tx *sql.Tx) error {
+
+	return nil
+}
+
+// updateNodeFields updates the given fields of a node and moves it to the
+// given member. The roles are only replaced if role is not nil, the machine
+// id if not -1, the system id if not empty and the hardware if not all zero.
+func updateNodeFields(ctx context.Context, tx *sql.Tx, member string, node *database.Node, role []string, machineid int, systemid string, hardware types.NodeHardware) error {
+	node.Member = member
+	if role != nil {
+		role = nodeRoles(role)
+		err := database.ValidateNodeRoles(ctx, tx, role)
+		if err != nil {
+			return err
+		}
+
+		node.Role = database.LegacyRole(role)
+
+		err = setNodeRoles(ctx, tx, *node, role)
+		if err != nil {
+			return err
+		}
+	}
+	if machineid != -1 {
+		err := database.VerifyMachineIDFree(ctx, tx, machineid, node.Name)
+		if err != nil {
+			return err
+		}
+
+		node.MachineID = machineid
+	}
+	if systemid != "" {
+		node.SystemID = systemid
+	}
+	if hardware != (types.NodeHardware{}) {
+		node.CPUCount = hardware.CPUCount
+		node.MemoryMB = hardware.MemoryMB
+		node.DiskGB = hardware.DiskGB
+	}
+
+	err := updateNode(ctx, tx, node.Name, *node)
+	if err != nil {
+		return fmt.Errorf("Failed to update record node: %w", err)
+	}
+
+	return recordChange(ctx, tx, "nodes", node.Name, database.ChangeUpdate)
+}
+
+// ClaimNode reserves a node to the given tenant. A node already reserved
+// to another tenant cannot be claimed.
+func ClaimNode(s *state.State, name string, owner string) error {
+	if owner == "" {
+		return api.StatusErrorf(http.StatusBadRequest, "Owner must not be empty")
+	}
+
+	return transaction(s, func(ctx context.Context, tx *sql.Tx) error {
 		node, err := database.GetNode(ctx, tx, name)
 		if err != nil {
-			return fmt.Errorf("Failed to retrieve node details: %w", err)
+			return err
+		}
+
+		if node.Owner == owner {
+			return nil
+		}
+
+		if node.Owner != "" {
+			return api.StatusErrorf(http.StatusConflict, "Node %q is already reserved by %q", name, node.Owner)
+		}
+
+		node.Owner = owner
+		err = updateNode(ctx, tx, name, *node)
+		if err != nil {
+			return fmt.Errorf("Failed to claim node: %w", err)
+		}
+
+		return recordChange(ctx, tx, "nodes", name, database.ChangeUpdate)
+	})
+}
+
+// RenameNode renames a node, keeping its roles, machine id, system id,
+// member and maintenance windows. The join tokens it registered with and its
+// history follow the new name, and the rename is recorded in the history.
+// Its status is reset until a cluster member of the new name heartbeats, so
+// the node is not mistaken for one whose member left.
+func RenameNode(s *state.State, name string, newName string) error {
+	if newName == "" {
+		return api.StatusErrorf(http.StatusBadRequest, "Node name must not be empty")
+	}
+
+	if newName == name {
+		return nil
+	}
+
+	return transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		node, err := database.GetNode(ctx, tx, name)
+		if err != nil {
+			return err
+		}
+
+		exists, err := database.NodeExists(ctx, tx, newName)
+		if err != nil {
+			return err
+		}
+
+		if exists {
+			return api.StatusErrorf(http.StatusConflict, "Node %q already exists", newName)
+		}
+
+		node.Name = newName
+		node.Status = database.NodeStatusUnknown
+		node.LastSeen = sql.NullTime{}
+		node.UpdatedAt = time.Now().UTC()
+		err = database.UpdateNode(ctx, tx, name, *node)
+		if err != nil {
+			return fmt.Errorf("Failed to rename node: %w", err)
+		}
+
+		err = database.RenameJoinTokenNode(ctx, tx, name, newName)
+		if err != nil {
+			return err
+		}
+
+		err = database.RenameNodeHistory(ctx, tx, name, newName)
+		if err != nil {
+			return err
+		}
+
+		err = recordNodeHistory(ctx, tx, newName, nodeFieldChange{
+			Field:    "name",
+			OldValue: sql.NullString{String: name, Valid: true},
+			NewValue: sql.NullString{String: newName, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+
+		err = recordChange(ctx, tx, "nodes", name, database.ChangeDelete)
+		if err != nil {
+			return err
+		}
+
+		return recordChange(ctx, tx, "nodes", newName, database.ChangeCreate)
+	})
+}
+
+// ReleaseNode clears the reservation on a node. If owner is provided, the
+// node must be reserved by that tenant.
+func ReleaseNode(s *state.State, name string, owner string) error {
+	return transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		node, err := database.GetNode(ctx, tx, name)
+		if err != nil {
+			return err
+		}
+
+		if node.Owner == "" {
+			return nil
+		}
+
+		if owner != "" && node.Owner != owner {
+			return api.StatusErrorf(http.StatusConflict, "Node %q is reserved by %q", name, node.Owner)
+		}
+
+		node.Owner = ""
+		err = updateNode(ctx, tx, name, *node)
+		if err != nil {
+			return fmt.Errorf("Failed to release node: %w", err)
+		}
+
+		return recordChange(ctx, tx, "nodes", name, database.ChangeUpdate)
+	})
+}
+
+// SetNodeHardware records the hardware facts of a node
+func SetNodeHardware(s *state.State, name string, hardware types.NodeHardware) error {
+	err := validateNodeHardware(hardware)
+	if err != nil {
+		return err
+	}
+
+	return transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		node, err := database.GetNode(ctx, tx, name)
+		if err != nil {
+			return err
+		}
+
+		node.CPUCount = hardware.CPUCount
+		node.MemoryMB = hardware.MemoryMB
+		node.DiskGB = hardware.DiskGB
+		err = updateNode(ctx, tx, name, *node)
+		if err != nil {
+			return fmt.Errorf("Failed to update node hardware: %w", err)
+		}
+
+		return recordChange(ctx, tx, "nodes", name, database.ChangeUpdate)
+	})
+}
+
+// SetNodeIdentity updates the machine and system ids of a node together, as
+// when it is re-enrolled. An id left unset keeps its current value. A
+// machine id used by another node is rejected with a conflict naming it.
+func SetNodeIdentity(s *state.State, name string, identity types.NodeIdentity) error {
+	if identity.MachineID == nil && identity.SystemID == nil {
+		return api.StatusErrorf(http.StatusBadRequest, "At least one of machineid and systemid must be given")
+	}
+
+	return transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		node, err := database.GetNode(ctx, tx, name)
+		if err != nil {
+			return err
+		}
+
+		if identity.MachineID != nil {
+			err = database.VerifyMachineIDFree(ctx, tx, *identity.MachineID, name)
+			if err != nil {
+				return err
+			}
+
+			node.MachineID = *identity.MachineID
+		}
+
+		if identity.SystemID != nil {
+			node.SystemID = *identity.SystemID
+		}
+
+		err = updateNode(ctx, tx, name, *node)
+		if err != nil {
+			return fmt.Errorf("Failed to update node identity: %w", err)
+		}
+
+		return recordChange(ctx, tx, "nodes", name, database.ChangeUpdate)
+	})
+}
+
+// SetNodeAppliedManifest records the manifest a node reports it has applied
+func SetNodeAppliedManifest(s *state.State, name string, manifestid string) error {
+	return transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		_, err := database.GetManifestItem(ctx, tx, manifestid)
+		if err != nil {
+			if api.StatusErrorCheck(err, http.StatusNotFound) {
+				return api.StatusErrorf(http.StatusBadRequest, "Manifest %q does not exist", manifestid)
+			}
+
+			return err
+		}
+
+		node, err := database.GetNode(ctx, tx, name)
+		if err != nil {
+			return err
+		}
+
+		node.LastManifestID = manifestid
+		err = updateNode(ctx, tx, name, *node)
+		if err != nil {
+			return fmt.Errorf("Failed to record applied manifest: %w", err)
+		}
+
+		return recordChange(ctx, tx, "nodes", name, database.ChangeUpdate)
+	})
+}
+
+// GetManifestSkew returns which manifest each node runs, compared to the
+// latest manifest
+func GetManifestSkew(s *state.State) (types.ManifestSkew, error) {
+	skew := types.ManifestSkew{Manifests: make(map[string][]string)}
+
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		latest, err := database.GetLatestManifestItem(ctx, tx)
+		if err != nil && !api.StatusErrorCheck(err, http.StatusNotFound) {
+			return err
 		}
 
-		if role == nil {
-			nodeRole = node.Role
+		if latest != nil {
+			skew.Latest = latest.ManifestID
 		}
-		if machineid == -1 {
-			machineid = node.MachineID
+
+		records, err := database.GetNodes(ctx, tx)
+		if err != nil {
+			return fmt.Errorf("Failed to fetch nodes: %w", err)
 		}
-		if systemid == "" {
-			systemid = node.SystemID
+
+		for _, node := range records {
+			skew.Manifests[node.LastManifestID] = append(skew.Manifests[node.LastManifestID], node.Name)
+			if node.LastManifestID != skew.Latest {
+				skew.Skewed = true
+			}
 		}
 
-		err = database.UpdateNode(ctx, tx, name, database.Node{Member: s.Name(), Name: name, Role: nodeRole, MachineID: machineid, SystemID: systemid})
+		return nil
+	})
+
+	return skew, err
+}
+
+// GetNodesCapacity returns the hardware totals across the cluster
+func GetNodesCapacity(s *state.State) (types.NodesCapacity, error) {
+	var capacity types.NodesCapacity
+
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		record, err := database.GetNodeCapacity(ctx, tx)
 		if err != nil {
-			return fmt.Errorf("Failed to update record node: %w", err)
+			return err
 		}
 
+		capacity.Nodes = record.Nodes
+		capacity.CPUCount = record.CPUCount
+		capacity.MemoryMB = record.MemoryMB
+		capacity.DiskGB = record.DiskGB
+
+		return nil
+	})
+
+	return capacity, err
+}
+
+// GetNodesSummary returns the number of nodes and of nodes holding each
+// role.
+func GetNodesSummary(s *state.State) (types.NodesSummary, error) {
+	var summary types.NodesSummary
+
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		record, err := database.GetNodeSummary(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		summary.Nodes = record.Nodes
+		summary.Roles = record.Roles
+
 		return nil
 	})
+
+	return summary, err
+}
+
+// createNode inserts a node record, stamping its creation time. Nodes are
+// created without metadata unless given some.
+func createNode(ctx context.Context, tx *sql.Tx, node database.Node) (int64, error) {
+	node.CreatedAt = time.Now().UTC()
+	node.UpdatedAt = node.CreatedAt
+	if node.Metadata == "" {
+		node.Metadata = emptyNodeMetadata
+	}
+
+	return database.CreateNode(ctx, tx, node)
+}
+
+// updateNode writes a node record, bumping its modification time, and
+// records the changed fields in the node history. Heartbeat bookkeeping
+// writes the record directly so updated_at and the history reflect changes
+// made by operators only, and so does RenameNode, which moves the history.
+func updateNode(ctx context.Context, tx *sql.Tx, name string, node database.Node) error {
+	old, err := database.GetNode(ctx, tx, name)
+	if err != nil {
+		return err
+	}
+
+	node.UpdatedAt = time.Now().UTC()
+
+	err = database.UpdateNode(ctx, tx, name, node)
 	if err != nil {
 		return err
 	}
 
+	return recordNodeHistory(ctx, tx, name, nodeRecordChanges(*old, node)...)
+}
+
+// validateNodeHardware rejects negative hardware facts
+func validateNodeHardware(hardware types.NodeHardware) error {
+	if hardware.CPUCount < 0 || hardware.MemoryMB < 0 || hardware.DiskGB < 0 {
+		return api.StatusErrorf(http.StatusBadRequest, "Hardware values must not be negative")
+	}
+
 	return nil
 }
 
 // DeleteNode deletes a node from database
 func DeleteNode(s *state.State, name string) error {
 	// Delete node from the database.
-	err := s.Database.Transaction(s.Context, func(ctx context.Context, tx *sql.Tx) error {
-		err := database.DeleteNode(ctx, tx, name)
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		kept, err := softDeleteNode(ctx, tx, name)
+		if err != nil {
+			return err
+		}
+
+		err = database.DeleteNodeConfigHistory(ctx, tx, name)
+		if err != nil {
+			return err
+		}
+
+		err = database.DeleteNode(ctx, tx, name)
 		if err != nil {
 			return fmt.Errorf("Failed to delete node: %w", err)
 		}
 
-		return nil
+		// The history records whether a tombstone of the node was kept.
+		deleted := nodeFieldChange{Field: "deleted", NewValue: sql.NullString{String: "hard", Valid: true}}
+		if kept {
+			deleted.NewValue.String = "soft"
+		}
+
+		err = recordNodeHistory(ctx, tx, name, deleted)
+		if err != nil {
+			return err
+		}
+
+		return recordChange(ctx, tx, "nodes", name, database.ChangeDelete)
 	})
 	if err != nil {
 		return err
@@ -147,23 +795,121 @@ func DeleteNode(s *state.State, name string) error {
 	return nil
 }
 
-// roleToStr converts a role slice to a string sorted
-func roleToStr(role []string) (string, error) {
-	sort.Strings(role)
-	roleJSON, err := json.Marshal(role)
+// nodeGroupFields are the node attributes nodes can be grouped by, along
+// with "label:<key>" for a label of the node metadata.
+var nodeGroupFields = []string{"member", "owner", "role", "status"}
+
+// nodeGroupLabelPrefix prefixes the label key of a field grouping nodes by a
+// label of their metadata.
+const nodeGroupLabelPrefix = "label:"
+
+// unsetLabelGroup is the group of the nodes without the label nodes are
+// grouped by.
+const unsetLabelGroup = "unset"
+
+// GroupNodes returns the nodes grouped by the given attribute. Counts are
+// computed in SQL where possible, roles hold several values per node and
+// labels are read from the node metadata, both are aggregated in memory.
+// Nodes without the label grouped by, or holding a value other than a
+// string under it, are in the "unset" group. If withNodes is set, the node
+// names of each group are returned as well.
+func GroupNodes(s *state.State, field string, withNodes bool) (types.NodeGroups, error) {
+	label, isLabel := strings.CutPrefix(field, nodeGroupLabelPrefix)
+	valid := isLabel && label != ""
+	for _, f := range nodeGroupFields {
+		if field == f {
+			valid = true
+			break
+		}
+	}
+
+	if !valid {
+		return nil, api.StatusErrorf(http.StatusBadRequest, "Invalid group by field %q, expected one of %s or %s<key>", field, strings.Join(nodeGroupFields, ", "), nodeGroupLabelPrefix)
+	}
+
+	groups := types.NodeGroups{}
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		if field != "role" && !isLabel && !withNodes {
+			counts, err := database.CountNodesGroupedBy(ctx, tx, field)
+			if err != nil {
+				return err
+			}
+
+			for _, c := range counts {
+				groups = append(groups, types.NodeGroup{Value: c.Value, Count: c.Count})
+			}
+
+			return nil
+		}
+
+		records, err := database.GetNodes(ctx, tx)
+		if err != nil {
+			return fmt.Errorf("Failed to fetch nodes: %w", err)
+		}
+
+		roles, err := database.GetNodeRoles(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		index := map[string]int{}
+		for _, node := range records {
+			var values []string
+			switch field {
+			case "member":
+				values = []string{node.Member}
+			case "owner":
+				values = []string{node.Owner}
+			case "status":
+				values = []string{node.Status}
+			case "role":
+				values = roles[node.ID]
+			default:
+				value, ok := nodeMetadata(node)[label].(string)
+				if !ok {
+					value = unsetLabelGroup
+				}
+
+				values = []string{value}
+			}
+
+			for _, value := range values {
+				i, ok := index[value]
+				if !ok {
+					i = len(groups)
+					index[value] = i
+					groups = append(groups, types.NodeGroup{Value: value})
+				}
+
+				groups[i].Count++
+				if withNodes {
+					groups[i].Nodes = append(groups[i].Nodes, node.Name)
+				}
+			}
+		}
+
+		sort.Slice(groups, func(i, j int) bool { return groups[i].Value < groups[j].Value })
+
+		return nil
+	})
 	if err != nil {
-		return "", fmt.Errorf("Failed to marshal role: %w", err)
+		return nil, err
 	}
-	return string(roleJSON), nil
+
+	return groups, nil
 }
 
-// roleFromStr converts a role string to a slice sorted
-func roleFromStr(roleStr string) ([]string, error) {
-	var role []string
-	err := json.Unmarshal([]byte(roleStr), &role)
-	if err != nil {
-		return nil, fmt.Errorf("Failed to unmarshal role: %w", err)
+// nodeRoles returns the given roles sorted and without duplicates, as an
+// empty slice rather than nil so nodes without roles render as [].
+func nodeRoles(role []string) []string {
+	roles := make([]string, 0, len(role))
+	for _, r := range role {
+		if r != "" && !slices.Contains(roles, r) {
+			roles = append(roles, r)
+		}
 	}
-	sort.Strings(role)
-	return role, nil
+
+	sort.Strings(roles)
+
+	return roles
 }
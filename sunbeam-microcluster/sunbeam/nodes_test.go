@@ -0,0 +1,592 @@
+package sunbeam
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/canonical/lxd/shared/api"
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/api/types"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/database"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/internal/testutil"
+)
+
+func TestUpdateNodeTimestamps(t *testing.T) {
+	s := testutil.NewState(t)
+
+	err := AddNode(s, "node1", []string{"compute"}, -1, "", types.NodeHardware{}, false)
+	if err != nil {
+		t.Fatalf("Failed to add node: %v", err)
+	}
+
+	added, err := GetNode(s, "node1")
+	if err != nil {
+		t.Fatalf("Failed to get node: %v", err)
+	}
+
+	if added.CreatedAt.IsZero() || !added.UpdatedAt.Equal(added.CreatedAt) {
+		t.Fatalf("New node has created_at %v and updated_at %v, expected both set and equal", added.CreatedAt, added.UpdatedAt)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+
+	err = UpdateNode(s, "node1", []string{"control"}, -1, "")
+	if err != nil {
+		t.Fatalf("Failed to update node: %v", err)
+	}
+
+	updated, err := GetNode(s, "node1")
+	if err != nil {
+		t.Fatalf("Failed to get node: %v", err)
+	}
+
+	if !updated.CreatedAt.Equal(added.CreatedAt) {
+		t.Errorf("Updating the roles changed created_at from %v to %v", added.CreatedAt, updated.CreatedAt)
+	}
+
+	if !updated.UpdatedAt.After(added.UpdatedAt) {
+		t.Errorf("Updating the roles did not bump updated_at past %v, got %v", added.UpdatedAt, updated.UpdatedAt)
+	}
+}
+
+// addTestNodes adds a node with each of the given names and roles, in order.
+func addTestNodes(t *testing.T, s *state.State, roles map[string][]string, names ...string) {
+	t.Helper()
+
+	for _, name := range names {
+		err := AddNode(s, name, roles[name], -1, "", types.NodeHardware{}, false)
+		if err != nil {
+			t.Fatalf("Failed to add node %q: %v", name, err)
+		}
+	}
+}
+
+func TestListNodesPage(t *testing.T) {
+	s := testutil.NewState(t)
+
+	page, err := ListNodesPage(s, nil, nil, nil, 2, 0)
+	if err != nil {
+		t.Fatalf("Failed to list nodes: %v", err)
+	}
+
+	if len(page.Nodes) != 0 || page.NextOffset != nil {
+		t.Fatalf("Listed %d nodes with next offset %v from an empty table, expected none", len(page.Nodes), page.NextOffset)
+	}
+
+	addTestNodes(t, s, nil, "node1", "node2", "node3", "node4", "node5")
+
+	tests := []struct {
+		offset int
+		names  []string
+		next   int
+	}{
+		{offset: 0, names: []string{"node1", "node2"}, next: 2},
+		{offset: 2, names: []string{"node3", "node4"}, next: 4},
+		{offset: 4, names: []string{"node5"}, next: -1},
+		{offset: 3, names: []string{"node4", "node5"}, next: -1},
+		{offset: 10, names: []string{}, next: -1},
+	}
+
+	for _, test := range tests {
+		page, err := ListNodesPage(s, nil, nil, nil, 2, test.offset)
+		if err != nil {
+			t.Fatalf("Failed to list nodes: %v", err)
+		}
+
+		names := make([]string, 0, len(page.Nodes))
+		for _, node := range page.Nodes {
+			names = append(names, node.Name)
+		}
+
+		next := -1
+		if page.NextOffset != nil {
+			next = *page.NextOffset
+		}
+
+		if !slices.Equal(names, test.names) || next != test.next {
+			t.Errorf("Page at offset %d holds %v with next offset %d, expected %v with %d", test.offset, names, next, test.names, test.next)
+		}
+	}
+}
+
+func TestListNodesByRole(t *testing.T) {
+	s := testutil.NewState(t)
+
+	roles := map[string][]string{
+		"node1": {"control", "storage"},
+		"node2": {"compute"},
+		"node3": {"storage"},
+	}
+
+	addTestNodes(t, s, roles, "node1", "node2", "node3")
+
+	tests := []struct {
+		roles []string
+		names []string
+	}{
+		{roles: []string{"storage"}, names: []string{"node1", "node3"}},
+		{roles: []string{"compute"}, names: []string{"node2"}},
+		{roles: []string{"unknown"}, names: []string{}},
+	}
+
+	for _, test := range tests {
+		nodes, err := ListNodes(s, test.roles, nil, nil)
+		if err != nil {
+			t.Fatalf("Failed to list nodes with roles %v: %v", test.roles, err)
+		}
+
+		names := make([]string, 0, len(nodes))
+		for _, node := range nodes {
+			names = append(names, node.Name)
+		}
+
+		if !slices.Equal(names, test.names) {
+			t.Errorf("Nodes with roles %v are %v, expected %v", test.roles, names, test.names)
+		}
+	}
+}
+
+func TestAddNodesBatch(t *testing.T) {
+	s := testutil.NewState(t)
+	addTestNodes(t, s, nil, "existing")
+
+	err := AddNodesBatch(s, types.Nodes{
+		{Name: "node1", Role: []string{"control"}, MachineID: 1, SystemID: "sys-1"},
+		{Name: "node2", Role: []string{"compute"}, MachineID: 2, SystemID: "sys-2"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to add nodes batch: %v", err)
+	}
+
+	node, err := GetNode(s, "node2")
+	if err != nil {
+		t.Fatalf("Failed to get node: %v", err)
+	}
+
+	if node.MachineID != 2 || node.SystemID != "sys-2" || !slices.Equal(node.Role, []string{"compute"}) {
+		t.Errorf("Batch added node2 with machine id %d, system id %q and roles %v", node.MachineID, node.SystemID, node.Role)
+	}
+
+	tests := []struct {
+		name      string
+		nodes     types.Nodes
+		status    int
+		offending string
+	}{
+		{
+			name:      "an existing name",
+			nodes:     types.Nodes{{Name: "node3", MachineID: -1}, {Name: "existing", MachineID: -1}},
+			status:    http.StatusConflict,
+			offending: "existing",
+		},
+		{
+			name:      "a repeated name",
+			nodes:     types.Nodes{{Name: "node3", MachineID: -1}, {Name: "node4", MachineID: -1}, {Name: "node4", MachineID: -1}},
+			status:    http.StatusBadRequest,
+			offending: "node4",
+		},
+		{
+			name:      "a taken machine id",
+			nodes:     types.Nodes{{Name: "node3", MachineID: -1}, {Name: "node4", MachineID: 1}},
+			status:    http.StatusConflict,
+			offending: "node1",
+		},
+	}
+
+	for _, test := range tests {
+		err := AddNodesBatch(s, test.nodes)
+		if !api.StatusErrorCheck(err, test.status) || !strings.Contains(err.Error(), fmt.Sprintf("%q", test.offending)) {
+			t.Errorf("Expected a batch with %s to fail with %d naming %q, got %v", test.name, test.status, test.offending, err)
+		}
+	}
+
+	names := nodeNames(t, s)
+	if !slices.Equal(names, []string{"existing", "node1", "node2"}) {
+		t.Errorf("Nodes after failed batches are %v, expected only those added before", names)
+	}
+}
+
+func TestRenameNode(t *testing.T) {
+	s := testutil.NewState(t)
+
+	err := AddNode(s, "old", []string{"control", "storage"}, 7, "sys-7", types.NodeHardware{CPUCount: 4}, false)
+	if err != nil {
+		t.Fatalf("Failed to add node: %v", err)
+	}
+
+	err = ClaimNode(s, "old", "tenant")
+	if err != nil {
+		t.Fatalf("Failed to claim node: %v", err)
+	}
+
+	addTestNodes(t, s, nil, "taken")
+
+	err = RenameNode(s, "old", "taken")
+	if !api.StatusErrorCheck(err, http.StatusConflict) {
+		t.Fatalf("Expected renaming to an existing name to fail with 409, got %v", err)
+	}
+
+	err = RenameNode(s, "old", "new")
+	if err != nil {
+		t.Fatalf("Failed to rename node: %v", err)
+	}
+
+	_, err = GetNode(s, "old")
+	if !api.StatusErrorCheck(err, http.StatusNotFound) {
+		t.Errorf("Expected the old name to be gone, got %v", err)
+	}
+
+	node, err := GetNode(s, "new")
+	if err != nil {
+		t.Fatalf("Failed to get renamed node: %v", err)
+	}
+
+	if !slices.Equal(node.Role, []string{"control", "storage"}) || node.MachineID != 7 || node.SystemID != "sys-7" || node.Owner != "tenant" || node.CPUCount != 4 {
+		t.Errorf("Renamed node is %+v, expected its roles, ids, owner and hardware kept", node)
+	}
+
+	machine, err := GetNodeBySystemID(s, "sys-7")
+	if err != nil {
+		t.Fatalf("Failed to get node by system id: %v", err)
+	}
+
+	if machine.Name != "new" {
+		t.Errorf("System id resolves to node %q, expected %q", machine.Name, "new")
+	}
+
+	nodes, err := ListNodes(s, []string{"storage"}, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to list nodes: %v", err)
+	}
+
+	if len(nodes) != 1 || nodes[0].Name != "new" {
+		t.Errorf("Storage nodes are %+v, expected only the renamed node", nodes)
+	}
+}
+
+func TestGetNodeBySystemID(t *testing.T) {
+	s := testutil.NewState(t)
+
+	err := AddNode(s, "maas", []string{"compute"}, 1, "abc123", types.NodeHardware{}, false)
+	if err != nil {
+		t.Fatalf("Failed to add node: %v", err)
+	}
+
+	addTestNodes(t, s, nil, "manual")
+
+	node, err := GetNodeBySystemID(s, "abc123")
+	if err != nil {
+		t.Fatalf("Failed to get node by system id: %v", err)
+	}
+
+	if node.Name != "maas" || node.MachineID != 1 || !slices.Equal(node.Role, []string{"compute"}) {
+		t.Errorf("System id resolves to %+v, expected the full maas node", node)
+	}
+
+	// The empty system id of the manual node is rejected rather than
+	// matched.
+	for systemID, status := range map[string]int{"": http.StatusBadRequest, "unknown": http.StatusNotFound} {
+		_, err = GetNodeBySystemID(s, systemID)
+		if !api.StatusErrorCheck(err, status) {
+			t.Errorf("Expected the lookup of system id %q to fail with %d, got %v", systemID, status, err)
+		}
+	}
+}
+
+func TestNodeRoleValidation(t *testing.T) {
+	s := testutil.NewState(t)
+
+	err := AddNode(s, "typo", []string{"controll"}, -1, "", types.NodeHardware{}, false)
+	if !api.StatusErrorCheck(err, http.StatusBadRequest) {
+		t.Fatalf("Expected an unknown role to be rejected with 400, got %v", err)
+	}
+
+	addTestNodes(t, s, map[string][]string{"node1": {"compute", "storage"}}, "node1")
+
+	err = UpdateNode(s, "node1", []string{"compute", "network"}, -1, "")
+	if !api.StatusErrorCheck(err, http.StatusBadRequest) {
+		t.Fatalf("Expected updating to an unknown role to be rejected with 400, got %v", err)
+	}
+
+	err = UpdateConfig(s, "roles.allowed", `["compute", "control", "network", "storage"]`)
+	if err != nil {
+		t.Fatalf("Failed to override the allowed roles: %v", err)
+	}
+
+	addTestNodes(t, s, map[string][]string{"node2": {"network"}}, "node2")
+
+	validation, err := ValidateNodeRoles(s)
+	if err != nil {
+		t.Fatalf("Failed to validate node roles: %v", err)
+	}
+
+	if len(validation.Invalid) != 0 {
+		t.Errorf("Nodes %v hold roles that are not allowed, expected none", validation.Invalid)
+	}
+
+	// Withdrawing a role leaves the nodes holding it to be reported.
+	err = UpdateConfig(s, "roles.allowed", `["compute", "storage"]`)
+	if err != nil {
+		t.Fatalf("Failed to override the allowed roles: %v", err)
+	}
+
+	validation, err = ValidateNodeRoles(s)
+	if err != nil {
+		t.Fatalf("Failed to validate node roles: %v", err)
+	}
+
+	if !slices.Equal(validation.Allowed, []string{"compute", "storage"}) || len(validation.Invalid) != 1 || !slices.Equal(validation.Invalid["node2"], []string{"network"}) {
+		t.Errorf("Role validation is %+v, expected node2 reported for network", validation)
+	}
+}
+
+func TestAddNodeUpsert(t *testing.T) {
+	s := testutil.NewState(t)
+
+	err := AddNode(s, "node1", []string{"compute"}, 5, "sys-5", types.NodeHardware{CPUCount: 8, MemoryMB: 16384}, false)
+	if err != nil {
+		t.Fatalf("Failed to add node: %v", err)
+	}
+
+	err = AddNode(s, "node1", []string{"control"}, -1, "", types.NodeHardware{}, false)
+	if !api.StatusErrorCheck(err, http.StatusConflict) {
+		t.Fatalf("Expected adding an existing node without upsert to fail with 409, got %v", err)
+	}
+
+	err = AddNode(s, "node1", []string{"control"}, -1, "", types.NodeHardware{}, true)
+	if err != nil {
+		t.Fatalf("Failed to upsert node: %v", err)
+	}
+
+	node, err := GetNode(s, "node1")
+	if err != nil {
+		t.Fatalf("Failed to get node: %v", err)
+	}
+
+	if !slices.Equal(node.Role, []string{"control"}) {
+		t.Errorf("Upserted node has roles %v, expected [control]", node.Role)
+	}
+
+	if node.MachineID != 5 || node.SystemID != "sys-5" || node.CPUCount != 8 || node.MemoryMB != 16384 {
+		t.Errorf("Upserted node is %+v, expected the machine id, system id and hardware not given kept", node)
+	}
+}
+
+func TestGetNodesSummary(t *testing.T) {
+	s := testutil.NewState(t)
+
+	summary, err := GetNodesSummary(s)
+	if err != nil {
+		t.Fatalf("Failed to summarize nodes: %v", err)
+	}
+
+	if summary.Nodes != 0 || len(summary.Roles) != 0 {
+		t.Fatalf("Summary of no nodes is %+v, expected no nodes nor roles", summary)
+	}
+
+	roles := map[string][]string{
+		"node1": {"control", "compute", "storage"},
+		"node2": {"compute", "storage"},
+		"node3": {"compute"},
+		"node4": {"storage"},
+	}
+
+	addTestNodes(t, s, roles, "node1", "node2", "node3", "node4", "node5")
+
+	summary, err = GetNodesSummary(s)
+	if err != nil {
+		t.Fatalf("Failed to summarize nodes: %v", err)
+	}
+
+	expected := map[string]int{"control": 1, "compute": 3, "storage": 3}
+	if summary.Nodes != 5 || !maps.Equal(summary.Roles, expected) {
+		t.Errorf("Summary is %+v, expected 5 nodes with roles %v", summary, expected)
+	}
+
+	err = UpdateConfig(s, "nodes.soft-delete", "true")
+	if err != nil {
+		t.Fatalf("Failed to enable soft delete: %v", err)
+	}
+
+	err = DeleteNode(s, "node4")
+	if err != nil {
+		t.Fatalf("Failed to delete node: %v", err)
+	}
+
+	summary, err = GetNodesSummary(s)
+	if err != nil {
+		t.Fatalf("Failed to summarize nodes: %v", err)
+	}
+
+	expected["storage"] = 2
+	if summary.Nodes != 4 || !maps.Equal(summary.Roles, expected) {
+		t.Errorf("Summary is %+v after deleting node4, expected 4 nodes with roles %v", summary, expected)
+	}
+}
+
+func TestListNodesByLabel(t *testing.T) {
+	s := testutil.NewState(t)
+
+	addTestNodes(t, s, nil, "node1", "node2", "node3", "node4")
+
+	metadata := map[string]map[string]any{
+		"node1": {"zone": "az1", "rack": "r1"},
+		"node2": {"zone": "az1", "rack": "r2"},
+		"node3": {"zone": "az2", "rack": "r1"},
+	}
+
+	for name, labels := range metadata {
+		_, err := PatchNodeMetadata(s, name, labels)
+		if err != nil {
+			t.Fatalf("Failed to set metadata of node %q: %v", name, err)
+		}
+	}
+
+	tests := []struct {
+		labels []database.NodeLabel
+		names  []string
+	}{
+		{labels: []database.NodeLabel{{Key: "zone", Value: "az1"}}, names: []string{"node1", "node2"}},
+		{labels: []database.NodeLabel{{Key: "zone", Value: "az1"}, {Key: "rack", Value: "r1"}}, names: []string{"node1"}},
+		{labels: []database.NodeLabel{{Key: "zone", Value: "az3"}}, names: []string{}},
+		{labels: []database.NodeLabel{{Key: "zone", Value: "az2"}, {Key: "rack", Value: "r2"}}, names: []string{}},
+	}
+
+	for _, test := range tests {
+		nodes, err := ListNodes(s, nil, nil, test.labels)
+		if err != nil {
+			t.Fatalf("Failed to list nodes with labels %v: %v", test.labels, err)
+		}
+
+		names := make([]string, 0, len(nodes))
+		for _, node := range nodes {
+			names = append(names, node.Name)
+		}
+
+		if !slices.Equal(names, test.names) {
+			t.Errorf("Nodes with labels %v are %v, expected %v", test.labels, names, test.names)
+		}
+	}
+}
+
+func TestSetNodeIdentity(t *testing.T) {
+	s := testutil.NewState(t)
+
+	err := AddNode(s, "node1", nil, 1, "system1", types.NodeHardware{}, false)
+	if err != nil {
+		t.Fatalf("Failed to add node: %v", err)
+	}
+
+	err = AddNode(s, "node2", nil, 2, "system2", types.NodeHardware{}, false)
+	if err != nil {
+		t.Fatalf("Failed to add node: %v", err)
+	}
+
+	machineID, systemID := 11, "system11"
+
+	err = SetNodeIdentity(s, "node1", types.NodeIdentity{MachineID: &machineID, SystemID: &systemID})
+	if err != nil {
+		t.Fatalf("Failed to set node identity: %v", err)
+	}
+
+	node, err := GetNode(s, "node1")
+	if err != nil {
+		t.Fatalf("Failed to get node: %v", err)
+	}
+
+	if node.MachineID != machineID || node.SystemID != systemID {
+		t.Errorf("Node identity is %d/%q, expected %d/%q", node.MachineID, node.SystemID, machineID, systemID)
+	}
+
+	// Omitting the system id keeps it.
+	machineID = 12
+	err = SetNodeIdentity(s, "node1", types.NodeIdentity{MachineID: &machineID})
+	if err != nil {
+		t.Fatalf("Failed to set node machine id: %v", err)
+	}
+
+	node, err = GetNode(s, "node1")
+	if err != nil {
+		t.Fatalf("Failed to get node: %v", err)
+	}
+
+	if node.MachineID != machineID || node.SystemID != systemID {
+		t.Errorf("Node identity is %d/%q, expected %d/%q", node.MachineID, node.SystemID, machineID, systemID)
+	}
+
+	conflicting, newSystemID := 2, "system13"
+	err = SetNodeIdentity(s, "node1", types.NodeIdentity{MachineID: &conflicting, SystemID: &newSystemID})
+	if !api.StatusErrorCheck(err, http.StatusConflict) || !strings.Contains(err.Error(), `"node2"`) {
+		t.Fatalf("Expected a machine id in use to fail with 409 naming node2, got %v", err)
+	}
+
+	node, err = GetNode(s, "node1")
+	if err != nil {
+		t.Fatalf("Failed to get node: %v", err)
+	}
+
+	if node.MachineID != machineID || node.SystemID != systemID {
+		t.Errorf("Node identity is %d/%q after a conflict, expected it unchanged", node.MachineID, node.SystemID)
+	}
+
+	err = SetNodeIdentity(s, "node1", types.NodeIdentity{})
+	if !api.StatusErrorCheck(err, http.StatusBadRequest) {
+		t.Errorf("Expected an empty identity to be rejected with 400, got %v", err)
+	}
+}
+
+func TestGroupNodesByLabel(t *testing.T) {
+	s := testutil.NewState(t)
+	addTestNodes(t, s, map[string][]string{"node1": {"compute", "storage"}, "node2": {"compute"}}, "node1", "node2", "node3", "node4")
+
+	for name, metadata := range map[string]map[string]any{
+		"node1": {"zone": "az1"},
+		"node2": {"zone": "az1"},
+		"node3": {"zone": "az2"},
+		"node4": {"zone": 2},
+	} {
+		_, err := PatchNodeMetadata(s, name, metadata)
+		if err != nil {
+			t.Fatalf("Failed to set metadata of %q: %v", name, err)
+		}
+	}
+
+	tests := []struct {
+		field     string
+		withNodes bool
+		groups    types.NodeGroups
+	}{
+		{field: "role", groups: types.NodeGroups{{Value: "compute", Count: 2}, {Value: "storage", Count: 1}}},
+		{field: "label:zone", groups: types.NodeGroups{{Value: "az1", Count: 2}, {Value: "az2", Count: 1}, {Value: "unset", Count: 1}}},
+		{field: "label:zone", withNodes: true, groups: types.NodeGroups{
+			{Value: "az1", Count: 2, Nodes: []string{"node1", "node2"}},
+			{Value: "az2", Count: 1, Nodes: []string{"node3"}},
+			{Value: "unset", Count: 1, Nodes: []string{"node4"}},
+		}},
+		{field: "label:rack", groups: types.NodeGroups{{Value: "unset", Count: 4}}},
+	}
+
+	for _, test := range tests {
+		groups, err := GroupNodes(s, test.field, test.withNodes)
+		if err != nil {
+			t.Fatalf("Failed to group nodes by %q: %v", test.field, err)
+		}
+
+		if fmt.Sprint(groups) != fmt.Sprint(test.groups) {
+			t.Errorf("Grouping nodes by %q gave %v, expected %v", test.field, groups, test.groups)
+		}
+	}
+
+	for _, field := range []string{"label:", "labels", "zone"} {
+		_, err := GroupNodes(s, field, false)
+		if !api.StatusErrorCheck(err, http.StatusBadRequest) {
+			t.Errorf("Expected grouping nodes by %q to fail with 400, got %v", field, err)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package sunbeam
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/canonical/lxd/shared/api"
+	"github.com/canonical/lxd/shared/logger"
+	"github.com/canonical/microcluster/state"
+)
+
+// apiRateLimitKey is the config key holding the number of API requests per
+// second allowed, for reads and writes each. Zero or unset is unlimited.
+const apiRateLimitKey = "api.rate_limit"
+
+// apiRateLimitRefresh is how long the rate read from apiRateLimitKey is used
+// before it is read again, so that requests do not each hit the database.
+const apiRateLimitRefresh = 10 * time.Second
+
+// apiRateLimit holds the configured rate and a token bucket for each class
+// of API requests, so that throttled writes do not block reads.
+var apiRateLimit struct {
+	mu        sync.Mutex
+	rate      int
+	refreshed time.Time
+	reads     tokenBucket
+	writes    tokenBucket
+}
+
+// tokenBucket is a token bucket refilled at a given rate per second, holding
+// at most one second worth of tokens.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take refills the bucket for the time elapsed since it was last used and
+// takes a token from it. If the bucket is empty, it returns how long until
+// a token is available.
+func (b *tokenBucket) take(rate int, now time.Time) (retryAfter time.Duration, ok bool) {
+	capacity := float64(rate)
+	if b.last.IsZero() {
+		b.tokens = capacity
+	} else {
+		b.tokens = math.Min(capacity, b.tokens+now.Sub(b.last).Seconds()*capacity)
+	}
+
+	b.last = now
+
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / capacity * float64(time.Second)), false
+	}
+
+	b.tokens--
+
+	return 0, true
+}
+
+// AllowAPIRequest takes a token for an API request from the bucket of reads
+// or writes. If the rate set by the api.rate_limit config key is exceeded, it
+// returns how long the caller should wait before retrying.
+func AllowAPIRequest(s *state.State, write bool) (retryAfter time.Duration, ok bool) {
+	rate := apiRequestRate(s)
+	if rate <= 0 {
+		return 0, true
+	}
+
+	apiRateLimit.mu.Lock()
+	defer apiRateLimit.mu.Unlock()
+
+	if write {
+		return apiRateLimit.writes.take(rate, time.Now())
+	}
+
+	return apiRateLimit.reads.take(rate, time.Now())
+}
+
+// apiRequestRate returns the rate set by the api.rate_limit config key,
+// reading it again once apiRateLimitRefresh has passed. The previous rate is
+// kept if the key cannot be read.
+func apiRequestRate(s *state.State) int {
+	apiRateLimit.mu.Lock()
+	if time.Since(apiRateLimit.refreshed) < apiRateLimitRefresh {
+		rate := apiRateLimit.rate
+		apiRateLimit.mu.Unlock()
+
+		return rate
+	}
+
+	// Concurrent requests keep using the previous rate while it is read.
+	apiRateLimit.refreshed = time.Now()
+	rate := apiRateLimit.rate
+	apiRateLimit.mu.Unlock()
+
+	value, err := GetConfig(s, apiRateLimitKey)
+	if err == nil {
+		rate, err = strconv.Atoi(value)
+		if err != nil {
+			logger.Warn("Ignoring invalid API rate limit", logger.Ctx{"key": apiRateLimitKey, "value": value})
+			rate = 0
+		}
+	} else if api.StatusErrorCheck(err, http.StatusNotFound) {
+		rate = 0
+	} else {
+		logger.Warn("Failed to read API rate limit", logger.Ctx{"key": apiRateLimitKey, "err": err})
+	}
+
+	apiRateLimit.mu.Lock()
+	defer apiRateLimit.mu.Unlock()
+
+	apiRateLimit.rate = rate
+
+	return rate
+}
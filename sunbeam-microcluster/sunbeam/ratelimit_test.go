@@ -0,0 +1,102 @@
+package sunbeam
+
+import (
+	"testing"
+	"time"
+
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/internal/testutil"
+)
+
+// setTestAPIRateLimit sets the api.rate_limit config key and forgets the
+// rate and buckets left by earlier requests, so that the key is read again.
+func setTestAPIRateLimit(t *testing.T, s *state.State, rate string) {
+	t.Helper()
+
+	err := UpdateConfig(s, apiRateLimitKey, rate)
+	if err != nil {
+		t.Fatalf("Failed to set API rate limit: %v", err)
+	}
+
+	reset := func() {
+		apiRateLimit.mu.Lock()
+		defer apiRateLimit.mu.Unlock()
+
+		apiRateLimit.rate = 0
+		apiRateLimit.refreshed = time.Time{}
+		apiRateLimit.reads = tokenBucket{}
+		apiRateLimit.writes = tokenBucket{}
+	}
+
+	reset()
+	t.Cleanup(reset)
+}
+
+func TestTokenBucket(t *testing.T) {
+	var bucket tokenBucket
+	now := time.Now()
+
+	for i := 0; i < 4; i++ {
+		_, ok := bucket.take(4, now)
+		if !ok {
+			t.Fatalf("Expected request %d of a burst of 4 to be allowed at 4 per second", i+1)
+		}
+	}
+
+	retryAfter, ok := bucket.take(4, now)
+	if ok || retryAfter != 250*time.Millisecond {
+		t.Fatalf("Expected the fifth request of the burst to wait 250ms, got %v (allowed %v)", retryAfter, ok)
+	}
+
+	_, ok = bucket.take(4, now.Add(250*time.Millisecond))
+	if !ok {
+		t.Fatalf("Expected a request to be allowed once a token is refilled")
+	}
+
+	// The bucket holds at most one second worth of tokens, however long it
+	// has been idle.
+	now = now.Add(time.Hour)
+	for i := 0; i < 4; i++ {
+		_, ok = bucket.take(4, now)
+		if !ok {
+			t.Fatalf("Expected request %d after an idle hour to be allowed", i+1)
+		}
+	}
+
+	_, ok = bucket.take(4, now)
+	if ok {
+		t.Fatalf("Expected an idle bucket not to hold more than a burst of 4")
+	}
+}
+
+func TestAllowAPIRequest(t *testing.T) {
+	s := testutil.NewState(t)
+	setTestAPIRateLimit(t, s, "2")
+
+	for i := 0; i < 2; i++ {
+		_, ok := AllowAPIRequest(s, true)
+		if !ok {
+			t.Fatalf("Expected write %d of a burst of 2 to be allowed", i+1)
+		}
+	}
+
+	retryAfter, ok := AllowAPIRequest(s, true)
+	if ok || retryAfter <= 0 {
+		t.Fatalf("Expected the third write of the burst to be rejected with a delay, got %v (allowed %v)", retryAfter, ok)
+	}
+
+	_, ok = AllowAPIRequest(s, false)
+	if !ok {
+		t.Fatalf("Expected reads not to be blocked by throttled writes")
+	}
+
+	setTestAPIRateLimit(t, s, "0")
+
+	for i := 0; i < 10; i++ {
+		_, ok = AllowAPIRequest(s, true)
+		if !ok {
+			t.Fatalf("Expected write %d to be allowed without a rate limit", i+1)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package sunbeam
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	dqliteClient "github.com/canonical/go-dqlite/client"
+	"github.com/canonical/lxd/shared/api"
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/api/types"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/database"
+)
+
+// roleMinimumsKey is the config key holding the minimum number of nodes
+// each role needs, as a JSON object of role name to count.
+const roleMinimumsKey = "roles.minimum"
+
+// CheckNodeRemoval reports whether removing a node would break dqlite
+// quorum or leave a role with fewer nodes than its configured minimum.
+func CheckNodeRemoval(s *state.State, name string) (types.NodeRemovalSafety, error) {
+	safety := types.NodeRemovalSafety{Name: name, Reasons: make([]string, 0), Warnings: make([]string, 0)}
+
+	node, err := GetNode(s, name)
+	if err != nil {
+		return safety, err
+	}
+
+	err = checkRoleMinimums(s, node, &safety)
+	if err != nil {
+		return safety, err
+	}
+
+	err = checkQuorum(s, node.Name, &safety)
+	if err != nil {
+		return safety, err
+	}
+
+	safety.Safe = len(safety.Reasons) == 0
+
+	return safety, nil
+}
+
+// checkRoleMinimums records a reason for each role of the node that would
+// fall below its configured minimum.
+func checkRoleMinimums(s *state.State, node types.Node, safety *types.NodeRemovalSafety) error {
+	value, err := GetConfig(s, roleMinimumsKey)
+	if err != nil {
+		if api.StatusErrorCheck(err, http.StatusNotFound) {
+			return nil
+		}
+
+		return err
+	}
+
+	minimums := map[string]int{}
+	err = json.Unmarshal([]byte(value), &minimums)
+	if err != nil {
+		return fmt.Errorf("Invalid %q value: %w", roleMinimumsKey, err)
+	}
+
+	for _, role := range node.Role {
+		minimum, ok := minimums[role]
+		if !ok {
+			continue
+		}
+
+		var nodes []database.Node
+		err = transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+			nodes, err = database.GetNodesByRole(ctx, tx, role)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("Failed to fetch nodes with role %q: %w", role, err)
+		}
+
+		if len(nodes)-1 < minimum {
+			safety.Reasons = append(safety.Reasons, fmt.Sprintf("Role %q would have %d nodes, below its minimum of %d", role, len(nodes)-1, minimum))
+		}
+	}
+
+	return nil
+}
+
+// checkQuorum records a reason if removing the cluster member of the given
+// name would leave the dqlite voters without an online majority.
+func checkQuorum(s *state.State, name string, safety *types.NodeRemovalSafety) error {
+	ctx, cancel := context.WithTimeout(s.Context, leaderTimeout)
+	defer cancel()
+
+	client, err := s.Database.Leader(ctx)
+	if err != nil {
+		return fmt.Errorf("Failed to connect to the dqlite leader: %w", err)
+	}
+
+	defer func() { _ = client.Close() }()
+
+	members, err := clusterMembers(ctx, s, client)
+	if err != nil {
+		return err
+	}
+
+	var target *clusterMember
+	voters, onlineVoters := 0, 0
+	for i, member := range members {
+		if member.Name == name {
+			target = &members[i]
+			continue
+		}
+
+		if member.Role == dqliteClient.Voter {
+			voters++
+			if member.Online {
+				onlineVoters++
+			}
+		}
+	}
+
+	if target == nil {
+		safety.Warnings = append(safety.Warnings, fmt.Sprintf("Node %q is not a cluster member", name))
+		return nil
+	}
+
+	if len(members) == 1 {
+		safety.Reasons = append(safety.Reasons, fmt.Sprintf("Node %q is the last cluster member", name))
+		return nil
+	}
+
+	if target.Role != dqliteClient.Voter {
+		return nil
+	}
+
+	leader, err := client.Leader(ctx)
+	if err != nil {
+		return fmt.Errorf("Failed to get the dqlite leader: %w", err)
+	}
+
+	if leader.ID == target.ID {
+		safety.Warnings = append(safety.Warnings, fmt.Sprintf("Node %q is the dqlite leader, leadership will move to another voter", name))
+	}
+
+	if voters == 0 {
+		safety.Reasons = append(safety.Reasons, fmt.Sprintf("Node %q is the only dqlite voter", name))
+	} else if onlineVoters <= voters/2 {
+		safety.Reasons = append(safety.Reasons, fmt.Sprintf("Only %d of the remaining %d dqlite voters are online, quorum would be lost", onlineVoters, voters))
+	}
+
+	return nil
+}
@@ -0,0 +1,116 @@
+package sunbeam
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/canonical/lxd/shared/logger"
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/database"
+)
+
+// MaintenanceTask is work the dqlite leader runs from its heartbeat, at most
+// once every Interval. The interval can be overridden with the
+// scheduler.<name>.interval config key, as a Go duration.
+type MaintenanceTask struct {
+	Name     string
+	Interval time.Duration
+	Run      func(s *state.State) error
+}
+
+// scheduledTask is a registered maintenance task and when it last succeeded.
+type scheduledTask struct {
+	MaintenanceTask
+	lastRun time.Time
+}
+
+// maintenanceTasks holds the registered maintenance tasks, in registration
+// order.
+var maintenanceTasks struct {
+	mu    sync.Mutex
+	tasks []*scheduledTask
+}
+
+// maintenanceIntervalKey returns the config key overriding the interval of
+// the named task.
+func maintenanceIntervalKey(name string) string {
+	return "scheduler." + name + ".interval"
+}
+
+// RegisterMaintenanceTask registers a task to run from the heartbeat of the
+// dqlite leader. It is meant to be called before the daemon starts.
+func RegisterMaintenanceTask(task MaintenanceTask) error {
+	if task.Name == "" || task.Run == nil || task.Interval <= 0 {
+		return fmt.Errorf("Maintenance tasks need a name, a function and a positive interval")
+	}
+
+	maintenanceTasks.mu.Lock()
+	defer maintenanceTasks.mu.Unlock()
+
+	for _, t := range maintenanceTasks.tasks {
+		if t.Name == task.Name {
+			return fmt.Errorf("Maintenance task %q is already registered", task.Name)
+		}
+	}
+
+	database.ConfigValidators[maintenanceIntervalKey(task.Name)] = database.ValidateDuration
+	maintenanceTasks.tasks = append(maintenanceTasks.tasks, &scheduledTask{MaintenanceTask: task})
+
+	return nil
+}
+
+// RunMaintenanceTasks runs the registered tasks whose interval has passed
+// since they last succeeded, in registration order. Nothing runs unless this
+// member is the dqlite leader. A failing task does not stop the others and
+// is tried again on the next heartbeat.
+func RunMaintenanceTasks(s *state.State) error {
+	leader, err := IsLeader(s)
+	if err != nil {
+		return err
+	}
+
+	if !leader {
+		return nil
+	}
+
+	overrides, err := GetConfigByPrefix(s, "scheduler.")
+	if err != nil {
+		return err
+	}
+
+	maintenanceTasks.mu.Lock()
+	defer maintenanceTasks.mu.Unlock()
+
+	var errs []error
+	for _, task := range maintenanceTasks.tasks {
+		interval := task.Interval
+		value, ok := overrides[maintenanceIntervalKey(task.Name)]
+		if ok {
+			override, err := time.ParseDuration(value)
+			if err == nil && override > 0 {
+				interval = override
+			} else {
+				logger.Warn("Ignoring invalid maintenance task interval", logger.Ctx{"task": task.Name, "value": value})
+			}
+		}
+
+		if time.Since(task.lastRun) < interval {
+			continue
+		}
+
+		start := time.Now()
+		err := task.Run(s)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Maintenance task %q failed: %w", task.Name, err))
+			continue
+		}
+
+		task.lastRun = start
+		logger.Debug("Ran maintenance task", logger.Ctx{"task": task.Name, "duration": time.Since(start)})
+	}
+
+	return errors.Join(errs...)
+}
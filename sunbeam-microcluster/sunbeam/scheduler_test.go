@@ -0,0 +1,116 @@
+package sunbeam
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/database"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/internal/testutil"
+)
+
+// setTestMaintenanceTasks registers the given tasks in place of any
+// registered ones, until the test ends.
+func setTestMaintenanceTasks(t *testing.T, tasks ...MaintenanceTask) {
+	t.Helper()
+
+	maintenanceTasks.mu.Lock()
+	registered := maintenanceTasks.tasks
+	maintenanceTasks.tasks = nil
+	maintenanceTasks.mu.Unlock()
+
+	t.Cleanup(func() {
+		maintenanceTasks.mu.Lock()
+		defer maintenanceTasks.mu.Unlock()
+
+		for _, task := range tasks {
+			delete(database.ConfigValidators, maintenanceIntervalKey(task.Name))
+		}
+
+		maintenanceTasks.tasks = registered
+	})
+
+	for _, task := range tasks {
+		err := RegisterMaintenanceTask(task)
+		if err != nil {
+			t.Fatalf("Failed to register maintenance task %q: %v", task.Name, err)
+		}
+	}
+}
+
+func TestRunMaintenanceTasks(t *testing.T) {
+	s := testutil.NewState(t)
+
+	runs := map[string]int{}
+	failing := true
+	setTestMaintenanceTasks(t,
+		MaintenanceTask{Name: "hourly", Interval: time.Hour, Run: func(_ *state.State) error {
+			runs["hourly"]++
+			return nil
+		}},
+		MaintenanceTask{Name: "flaky", Interval: time.Hour, Run: func(_ *state.State) error {
+			runs["flaky"]++
+			if failing {
+				return errors.New("not yet")
+			}
+
+			return nil
+		}},
+	)
+
+	err := RegisterMaintenanceTask(MaintenanceTask{Name: "hourly", Interval: time.Minute, Run: func(_ *state.State) error { return nil }})
+	if err == nil {
+		t.Error("Expected registering a task twice to fail")
+	}
+
+	testutil.StateBackend(t, s).Leader = "10.0.0.2:7000"
+
+	err = RunMaintenanceTasks(s)
+	if err != nil {
+		t.Fatalf("Failed to run maintenance tasks: %v", err)
+	}
+
+	if len(runs) != 0 {
+		t.Fatalf("Maintenance tasks ran %v times on a member that is not the leader, expected none", runs)
+	}
+
+	testutil.StateBackend(t, s).Leader = testutil.MemberAddress
+
+	err = RunMaintenanceTasks(s)
+	if err == nil {
+		t.Fatal("Expected the failing maintenance task to be reported")
+	}
+
+	failing = false
+
+	// The hourly task is not due again, the failed one is retried.
+	err = RunMaintenanceTasks(s)
+	if err != nil {
+		t.Fatalf("Failed to run maintenance tasks: %v", err)
+	}
+
+	if runs["hourly"] != 1 || runs["flaky"] != 2 {
+		t.Fatalf("Maintenance tasks ran %v times, expected hourly once and flaky twice", runs)
+	}
+
+	err = UpdateConfig(s, maintenanceIntervalKey("hourly"), "1ns")
+	if err != nil {
+		t.Fatalf("Failed to override maintenance task interval: %v", err)
+	}
+
+	err = UpdateConfig(s, maintenanceIntervalKey("flaky"), "soon")
+	if err == nil {
+		t.Error("Expected an invalid maintenance task interval to be rejected")
+	}
+
+	err = RunMaintenanceTasks(s)
+	if err != nil {
+		t.Fatalf("Failed to run maintenance tasks: %v", err)
+	}
+
+	if runs["hourly"] != 2 || runs["flaky"] != 2 {
+		t.Errorf("Maintenance tasks ran %v times, expected hourly twice at its overridden interval and flaky twice", runs)
+	}
+}
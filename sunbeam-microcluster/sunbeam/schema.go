@@ -0,0 +1,145 @@
+package sunbeam
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"github.com/canonical/lxd/shared/api"
+	"github.com/canonical/lxd/shared/logger"
+	"github.com/canonical/microcluster/cluster"
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/api/types"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/database"
+)
+
+// GetSchema returns the schema version of the database along with the
+// schema extensions applied and still pending. Extensions recorded by a
+// newer member than this one are reported by version only.
+func GetSchema(s *state.State) (types.Schema, error) {
+	schema := types.Schema{Applied: []string{}, Pending: []string{}}
+
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		var err error
+		schema.Version, err = database.GetSchemaExtensionsVersion(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		schema.InternalVersion, err = database.GetSchemaInternalVersion(ctx, tx)
+		return err
+	})
+	if err != nil {
+		return types.Schema{}, err
+	}
+
+	for i := range database.SchemaExtensions {
+		name := database.SchemaExtensionName(i)
+		if i < schema.Version {
+			schema.Applied = append(schema.Applied, name)
+		} else {
+			schema.Pending = append(schema.Pending, name)
+		}
+	}
+
+	return schema, nil
+}
+
+// MigrateSchema applies the schema extensions not yet applied to the
+// database and reports them. It only runs on the dqlite leader and does
+// nothing when the schema is up to date.
+func MigrateSchema(s *state.State) (types.SchemaMigration, error) {
+	migration := types.SchemaMigration{Applied: []types.AppliedMigration{}}
+
+	err := requireLeader(s)
+	if err != nil {
+		return migration, err
+	}
+
+	err = transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		version, err := database.GetSchemaExtensionsVersion(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		for i := version; i < len(database.SchemaExtensions); i++ {
+			entry, err := database.ApplySchemaExtension(ctx, tx, i)
+			if err != nil {
+				return err
+			}
+
+			migration.Applied = append(migration.Applied, types.AppliedMigration{
+				Version:   entry.Version,
+				Name:      entry.Name,
+				AppliedAt: entry.AppliedAt,
+			})
+		}
+
+		migration.Version = len(database.SchemaExtensions)
+		if version > migration.Version {
+			migration.Version = version
+		}
+
+		return nil
+	})
+	if err != nil {
+		return types.SchemaMigration{}, err
+	}
+
+	for _, applied := range migration.Applied {
+		logger.Info("Applied schema extension", logger.Ctx{"version": applied.Version, "name": applied.Name})
+	}
+
+	return migration, nil
+}
+
+// VerifySchemaCompatible rejects a member joining a cluster whose members
+// run a different number of schema extensions than it does. Members still
+// pending, such as this one while joining, are not compared.
+func VerifySchemaCompatible(s *state.State) error {
+	var versions []uint64
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		var err error
+		_, versions, err = cluster.GetClusterMemberSchemaVersions(ctx, tx)
+		if err != nil {
+			return fmt.Errorf("Failed to fetch cluster member schema versions: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	local := uint64(len(database.SchemaExtensions))
+	for _, version := range versions {
+		if version != local {
+			return api.StatusErrorf(http.StatusConflict, "Cannot join, cluster members are at schema version %d but this member is at %d, align the snap versions of all members before joining", version, local)
+		}
+	}
+
+	return nil
+}
+
+// verifySchemaVersion rejects a node registering with a different number of
+// schema extensions than the cluster runs, before its join token is
+// consumed. Nodes not presenting their schema version are not checked here,
+// VerifySchemaCompatible still checks them when they join.
+func verifySchemaVersion(ctx context.Context, tx *sql.Tx, version int) error {
+	if version == 0 {
+		return nil
+	}
+
+	current, err := database.GetSchemaExtensionsVersion(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	if version != current {
+		return api.StatusErrorf(http.StatusConflict, "Cannot join, the cluster is at schema version %d but this node is at %d, align the snap versions of all members before joining", current, version)
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package sunbeam
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"testing"
+
+	"github.com/canonical/lxd/shared/api"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/database"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/internal/testutil"
+)
+
+// microclusterSchemaVersion is the number of schema updates MicroCluster
+// applies on its own.
+const microclusterSchemaVersion = 2
+
+func TestGetSchema(t *testing.T) {
+	s := testutil.NewState(t)
+
+	schema, err := GetSchema(s)
+	if err != nil {
+		t.Fatalf("Failed to get schema: %v", err)
+	}
+
+	if schema.Version != len(database.SchemaExtensions) || schema.InternalVersion != microclusterSchemaVersion {
+		t.Errorf("Schema is at version %d with internal version %d, expected %d and %d", schema.Version, schema.InternalVersion, len(database.SchemaExtensions), microclusterSchemaVersion)
+	}
+
+	if len(schema.Applied) != len(database.SchemaExtensions) || len(schema.Pending) != 0 {
+		t.Fatalf("Schema has %d extensions applied and %d pending, expected all %d applied", len(schema.Applied), len(schema.Pending), len(database.SchemaExtensions))
+	}
+
+	for i, name := range schema.Applied {
+		if name != database.SchemaExtensionName(i) {
+			t.Errorf("Applied schema extension %d is %q, expected %q", i+1, name, database.SchemaExtensionName(i))
+		}
+	}
+}
+
+func TestVerifySchemaCompatible(t *testing.T) {
+	s := testutil.NewState(t)
+	testutil.AddMember(t, s, "member2", "10.0.0.2:7000")
+
+	err := VerifySchemaCompatible(s)
+	if err != nil {
+		t.Fatalf("Expected joining members at the same schema version to be accepted, got %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		role    string
+		version int
+		valid   bool
+	}{
+		{name: "behind", role: "voter", version: len(database.SchemaExtensions) - 1, valid: false},
+		{name: "ahead", role: "voter", version: len(database.SchemaExtensions) + 1, valid: false},
+		{name: "pending", role: "pending", version: len(database.SchemaExtensions) + 1, valid: true},
+	}
+
+	for _, test := range tests {
+		err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+			_, err := tx.ExecContext(ctx, "UPDATE internal_cluster_members SET schema_external = ?, role = ? WHERE name = ?", test.version, test.role, "member2")
+			return err
+		})
+		if err != nil {
+			t.Fatalf("Failed to update cluster member: %v", err)
+		}
+
+		err = VerifySchemaCompatible(s)
+		if test.valid && err != nil {
+			t.Errorf("Expected joining with a %s member to be accepted, got %v", test.name, err)
+		} else if !test.valid && !api.StatusErrorCheck(err, http.StatusConflict) {
+			t.Errorf("Expected joining with a member %s to fail with 409, got %v", test.name, err)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package sunbeam
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/canonical/lxd/shared/api"
+	"github.com/canonical/lxd/shared/logger"
+	"github.com/canonical/microcluster/cluster"
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/database"
+)
+
+// nodeOfflineThresholdKey is the config key holding the time, as a Go
+// duration, after which a node that has not heartbeated is marked offline.
+const nodeOfflineThresholdKey = "nodes.offline-threshold"
+
+// defaultNodeOfflineThreshold applies when no threshold is configured.
+const defaultNodeOfflineThreshold = memberOfflineAfter
+
+// UpdateNodeStatus refreshes the status of the nodes from the heartbeats of
+// the cluster members of the same name. Nodes whose member heartbeated
+// within the threshold are marked online, nodes not seen for longer are
+// marked offline. It is run from the heartbeat hook on the leader.
+func UpdateNodeStatus(s *state.State) error {
+	threshold, err := nodeOfflineThreshold(s)
+	if err != nil {
+		return err
+	}
+
+	type statusChange struct {
+		name   string
+		status string
+	}
+
+	var changed []statusChange
+
+	err = transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		members, err := cluster.GetInternalClusterMembers(ctx, tx)
+		if err != nil {
+			return fmt.Errorf("Failed to fetch cluster members: %w", err)
+		}
+
+		heartbeats := make(map[string]time.Time, len(members))
+		for _, member := range members {
+			heartbeats[member.Name] = member.Heartbeat
+		}
+
+		nodes, err := database.GetNodes(ctx, tx)
+		if err != nil {
+			return fmt.Errorf("Failed to fetch nodes: %w", err)
+		}
+
+		for _, node := range nodes {
+			status := node.Status
+			lastSeen := node.LastSeen
+
+			heartbeat := heartbeats[node.Name]
+			if !heartbeat.IsZero() && time.Since(heartbeat) < threshold {
+				status = database.NodeStatusOnline
+				lastSeen = sql.NullTime{Time: heartbeat.UTC(), Valid: true}
+			} else if lastSeen.Valid && time.Since(lastSeen.Time) >= threshold {
+				status = database.NodeStatusOffline
+			}
+
+			if status == node.Status && lastSeen == node.LastSeen {
+				continue
+			}
+
+			statusChanged := status != node.Status
+			node.Status = status
+			node.LastSeen = lastSeen
+
+			err = database.UpdateNode(ctx, tx, node.Name, node)
+			if err != nil {
+				return fmt.Errorf("Failed to update node %q status: %w", node.Name, err)
+			}
+
+			// Only status flips are worth a change, last_seen moves on
+			// every heartbeat.
+			if statusChanged {
+				err = recordChange(ctx, tx, "nodes", node.Name, database.ChangeUpdate)
+				if err != nil {
+					return err
+				}
+
+				changed = append(changed, statusChange{name: node.Name, status: status})
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, change := range changed {
+		logger.Info("Node status changed", logger.Ctx{"name": change.name, "status": change.status})
+	}
+
+	return nil
+}
+
+// ResetNodeStatus marks every node's status unknown, as the statuses
+// recorded before the daemon restarted cannot be trusted. The next heartbeat
+// round establishes them again. Last seen times are kept, so nodes not seen
+// for longer than the threshold are still marked offline.
+func ResetNodeStatus(s *state.State) error {
+	var reset []string
+
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		reset = nil
+
+		nodes, err := database.GetNodes(ctx, tx)
+		if err != nil {
+			return fmt.Errorf("Failed to fetch nodes: %w", err)
+		}
+
+		for _, node := range nodes {
+			if node.Status == database.NodeStatusUnknown {
+				continue
+			}
+
+			node.Status = database.NodeStatusUnknown
+			err = database.UpdateNode(ctx, tx, node.Name, node)
+			if err != nil {
+				return fmt.Errorf("Failed to update node %q status: %w", node.Name, err)
+			}
+
+			err = recordChange(ctx, tx, "nodes", node.Name, database.ChangeUpdate)
+			if err != nil {
+				return err
+			}
+
+			reset = append(reset, node.Name)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(reset) > 0 {
+		logger.Info("Reset node statuses on start", logger.Ctx{"nodes": reset})
+	}
+
+	return nil
+}
+
+// nodeOfflineThreshold returns the configured node offline threshold.
+func nodeOfflineThreshold(s *state.State) (time.Duration, error) {
+	value, err := GetConfig(s, nodeOfflineThresholdKey)
+	if err != nil {
+		if api.StatusErrorCheck(err, http.StatusNotFound) {
+			return defaultNodeOfflineThreshold, nil
+		}
+
+		return 0, err
+	}
+
+	threshold, err := time.ParseDuration(value)
+	if err != nil || threshold <= 0 {
+		return 0, fmt.Errorf("Invalid %q value %q, expected a positive duration", nodeOfflineThresholdKey, value)
+	}
+
+	return threshold, nil
+}
+
+// lastSeen returns the last heartbeat of a node, nil if never seen.
+func lastSeen(node database.Node) *time.Time {
+	if !node.LastSeen.Valid {
+		return nil
+	}
+
+	t := node.LastSeen.Time
+	return &t
+}
@@ -0,0 +1,60 @@
+package sunbeam
+
+import (
+	"testing"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/internal/testutil"
+)
+
+func TestResetNodeStatus(t *testing.T) {
+	s := testutil.NewState(t)
+	addTestNodes(t, s, nil, testutil.MemberName)
+
+	err := UpdateNodeStatus(s)
+	if err != nil {
+		t.Fatalf("Failed to update node statuses: %v", err)
+	}
+
+	node, err := GetNode(s, testutil.MemberName)
+	if err != nil {
+		t.Fatalf("Failed to get node: %v", err)
+	}
+
+	if node.Status != "online" || node.LastSeen == nil {
+		t.Fatalf("Node of a heartbeating member has status %q and last seen %v, expected online and seen", node.Status, node.LastSeen)
+	}
+
+	lastSeen := *node.LastSeen
+
+	err = ResetNodeStatus(s)
+	if err != nil {
+		t.Fatalf("Failed to reset node statuses: %v", err)
+	}
+
+	node, err = GetNode(s, testutil.MemberName)
+	if err != nil {
+		t.Fatalf("Failed to get node: %v", err)
+	}
+
+	if node.Status != "unknown" {
+		t.Errorf("Node has status %q after the reset, expected unknown", node.Status)
+	}
+
+	if node.LastSeen == nil || !node.LastSeen.Equal(lastSeen) {
+		t.Errorf("Node was last seen %v after the reset, expected %v kept", node.LastSeen, lastSeen)
+	}
+
+	err = UpdateNodeStatus(s)
+	if err != nil {
+		t.Fatalf("Failed to update node statuses: %v", err)
+	}
+
+	node, err = GetNode(s, testutil.MemberName)
+	if err != nil {
+		t.Fatalf("Failed to get node: %v", err)
+	}
+
+	if node.Status != "online" {
+		t.Errorf("Node has status %q after the next heartbeat round, expected online", node.Status)
+	}
+}
@@ -14,6 +14,12 @@ import (
 const tfstatePrefix = "tfstate-"
 const tflockPrefix = "tflock-"
 
+// isTerraformKey returns whether a config key holds a Terraform state or
+// lock. These are blobs served through the Terraform endpoints only.
+func isTerraformKey(key string) bool {
+	return strings.HasPrefix(key, tfstatePrefix) || strings.HasPrefix(key, tflockPrefix)
+}
+
 // GetTerraformStates returns the list of terraform states from the database
 func GetTerraformStates(s *state.State) ([]string, error) {
 	prefix := tfstatePrefix
@@ -0,0 +1,101 @@
+package sunbeam
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/api/types"
+)
+
+// topologyBuilder accumulates the vertices and edges of a topology graph,
+// adding each vertex once.
+type topologyBuilder struct {
+	topology types.Topology
+	seen     map[string]bool
+}
+
+// vertex adds a vertex of the given kind and name if not present yet and
+// returns its ID.
+func (b *topologyBuilder) vertex(kind string, name string, attributes map[string]string) string {
+	id := kind + ":" + name
+	if !b.seen[id] {
+		b.seen[id] = true
+		b.topology.Nodes = append(b.topology.Nodes, types.TopologyNode{ID: id, Kind: kind, Name: name, Attributes: attributes})
+	}
+
+	return id
+}
+
+// edge adds an edge between two vertices.
+func (b *topologyBuilder) edge(source string, target string, kind string) {
+	b.topology.Edges = append(b.topology.Edges, types.TopologyEdge{Source: source, Target: target, Kind: kind})
+}
+
+// GetTopology returns the nodes and cluster members as a graph. Nodes are
+// linked to their roles and owner, so that role peers and nodes of the
+// same tenant share a vertex, and to the cluster member of the same name.
+// Members are linked to their dqlite role, the voters making up the
+// "voter" vertex.
+func GetTopology(s *state.State) (types.Topology, error) {
+	b := topologyBuilder{
+		topology: types.Topology{Nodes: []types.TopologyNode{}, Edges: []types.TopologyEdge{}},
+		seen:     map[string]bool{},
+	}
+
+	ctx, cancel := context.WithTimeout(s.Context, leaderTimeout)
+	defer cancel()
+
+	client, err := s.Database.Leader(ctx)
+	if err != nil {
+		return b.topology, fmt.Errorf("Failed to connect to the dqlite leader: %w", err)
+	}
+
+	defer func() { _ = client.Close() }()
+
+	members, err := clusterMembers(ctx, s, client)
+	if err != nil {
+		return b.topology, err
+	}
+
+	memberIDs := map[string]string{}
+	for _, member := range members {
+		id := b.vertex("member", member.Name, map[string]string{
+			"address": member.Address,
+			"online":  strconv.FormatBool(member.Online),
+		})
+		memberIDs[member.Name] = id
+
+		b.edge(id, b.vertex("dqlite-role", member.Role.String(), nil), "dqlite-role")
+	}
+
+	nodes, err := ListNodes(s, nil, nil, nil)
+	if err != nil {
+		return b.topology, err
+	}
+
+	for _, node := range nodes {
+		id := b.vertex("node", node.Name, map[string]string{
+			"systemid":  node.SystemID,
+			"machineid": strconv.Itoa(node.MachineID),
+			"cordoned":  strconv.FormatBool(node.Cordoned),
+		})
+
+		for _, role := range node.Role {
+			b.edge(id, b.vertex("role", role, nil), "role")
+		}
+
+		if node.Owner != "" {
+			b.edge(id, b.vertex("owner", node.Owner, nil), "owner")
+		}
+
+		memberID, ok := memberIDs[node.Name]
+		if ok {
+			b.edge(id, memberID, "member")
+		}
+	}
+
+	return b.topology, nil
+}
@@ -0,0 +1,199 @@
+package sunbeam
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/canonical/lxd/lxd/db/query"
+	"github.com/canonical/lxd/shared/api"
+	"github.com/canonical/lxd/shared/logger"
+	"github.com/canonical/microcluster/state"
+)
+
+// diskFullMessages are the error messages sqlite and dqlite report when the
+// storage backing the database is full.
+var diskFullMessages = []string{
+	"database or disk is full",
+	"no space left on device",
+	"SQLITE_FULL",
+}
+
+// maxTransactionRetryDelay bounds the delay between two attempts of a
+// transaction failing on write contention.
+const maxTransactionRetryDelay = 5 * time.Second
+
+// transactionRetry holds how many times a transaction failing on write
+// contention is attempted, and the delay before the first retry, doubled
+// for each further one.
+var transactionRetry = struct {
+	attempts  int
+	baseDelay time.Duration
+}{
+	attempts:  5,
+	baseDelay: 50 * time.Millisecond,
+}
+
+// transactionTimeout bounds how long a transaction may take, retries
+// included, zero if unbounded.
+var transactionTimeout = 30 * time.Second
+
+// SetTransactionTimeout sets how long a transaction may take, retries
+// included, before it is abandoned. Zero lets transactions run unbounded.
+func SetTransactionTimeout(timeout time.Duration) error {
+	if timeout < 0 {
+		return fmt.Errorf("Transaction timeout must not be negative, got %s", timeout)
+	}
+
+	transactionTimeout = timeout
+
+	return nil
+}
+
+// SetTransactionRetry sets how many times a transaction failing on write
+// contention is attempted, and the delay before the first retry.
+func SetTransactionRetry(attempts int, baseDelay time.Duration) error {
+	if attempts < 1 {
+		return fmt.Errorf("Transaction attempts must be at least 1, got %d", attempts)
+	}
+
+	if baseDelay < 0 {
+		return fmt.Errorf("Transaction retry delay must not be negative, got %s", baseDelay)
+	}
+
+	transactionRetry.attempts = attempts
+	transactionRetry.baseDelay = baseDelay
+
+	return nil
+}
+
+// transaction runs f in a database transaction, translating storage errors
+// into errors the API can report meaningfully. Events queued by f are
+// published once the transaction commits. Transactions failing because the
+// database is busy are retried with exponential backoff, as many times as
+// configured, and reported with 503 if they still fail. Other errors such
+// as constraint violations are returned straight away. Transactions still
+// running once the configured timeout has passed are abandoned.
+func transaction(s *state.State, f func(context.Context, *sql.Tx) error) error {
+	return transactionWithTimeout(s, transactionTimeout, f)
+}
+
+// bulkTransaction runs f like transaction, without the transaction timeout.
+// It is meant for operations on the whole database that must happen in a
+// single transaction, such as exporting or importing the cluster, and may
+// legitimately take longer than requests do. Streaming exports and
+// compaction run one page or batch per transaction instead.
+func bulkTransaction(s *state.State, f func(context.Context, *sql.Tx) error) error {
+	return transactionWithTimeout(s, 0, f)
+}
+
+// transactionWithTimeout runs f as described for transaction, abandoning it
+// after the given timeout, zero if unbounded.
+func transactionWithTimeout(s *state.State, timeout time.Duration, f func(context.Context, *sql.Tx) error) error {
+	var pending []Event
+	ctx := context.WithValue(s.Context, pendingEventsKey{}, &pending)
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	err := retryTransaction(ctx, func() error {
+		return databaseBackend(s).Transaction(ctx, func(ctx context.Context, tx *sql.Tx) error {
+			// Drop the events of a previous attempt if the transaction is retried.
+			pending = pending[:0]
+
+			return f(ctx, tx)
+		})
+	})
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			logger.Warn("Database transaction timed out", logger.Ctx{"timeout": timeout, "err": err})
+			return api.StatusErrorf(http.StatusServiceUnavailable, "Database transaction timed out after %s: %v", timeout, err)
+		}
+
+		if isDiskFull(err) {
+			return diskFullError(s, err)
+		}
+
+		if query.IsRetriableError(err) {
+			return api.StatusErrorf(http.StatusServiceUnavailable, "Database is busy, retry later: %v", err)
+		}
+
+		return err
+	}
+
+	for _, event := range pending {
+		Events.Publish(event)
+	}
+
+	return nil
+}
+
+// retryTransaction calls run until it succeeds, fails with an error that is
+// not caused by write contention, or has been attempted as many times as
+// configured. The delay between attempts doubles each time, with jitter so
+// that contending writers do not retry in lockstep.
+func retryTransaction(ctx context.Context, run func() error) error {
+	delay := transactionRetry.baseDelay
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = run()
+		if err == nil || !query.IsRetriableError(err) || attempt >= transactionRetry.attempts {
+			return err
+		}
+
+		// Wait between half and all of the delay.
+		wait := delay/2 + time.Duration(rand.Int64N(int64(delay/2)+1))
+		logger.Debug("Database is busy, retrying transaction", logger.Ctx{"attempt": attempt, "wait": wait, "err": err})
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+
+		delay = min(delay*2, maxTransactionRetryDelay)
+	}
+}
+
+// isDiskFull returns whether err was caused by the database storage being full.
+func isDiskFull(err error) bool {
+	if errors.Is(err, syscall.ENOSPC) {
+		return true
+	}
+
+	for _, msg := range diskFullMessages {
+		if strings.Contains(err.Error(), msg) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// diskFullError logs and returns a 507 error naming the state directory and
+// the space left on it.
+func diskFullError(s *state.State, err error) error {
+	stateDir := s.OS.StateDir
+
+	var stat syscall.Statfs_t
+	statErr := syscall.Statfs(stateDir, &stat)
+	if statErr != nil {
+		logger.Error("Database storage is full", logger.Ctx{"path": stateDir, "err": err})
+		return api.StatusErrorf(http.StatusInsufficientStorage, "Database storage is full in %q: %v", stateDir, err)
+	}
+
+	free := stat.Bavail * uint64(stat.Bsize)
+	logger.Error("Database storage is full", logger.Ctx{"path": stateDir, "free": free, "err": err})
+
+	return api.StatusErrorf(http.StatusInsufficientStorage, "Database storage is full in %q (%d bytes free): %v", stateDir, free, err)
+}
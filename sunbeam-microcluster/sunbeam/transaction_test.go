@@ -0,0 +1,206 @@
+package sunbeam
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/canonical/go-dqlite/driver"
+	"github.com/canonical/lxd/shared/api"
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/database"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/internal/testutil"
+)
+
+// failingBackend is a database backend whose transactions fail with err.
+type failingBackend struct {
+	database.Backend
+	err error
+}
+
+// Transaction returns the error of the backend without running f.
+func (b failingBackend) Transaction(_ context.Context, _ func(context.Context, *sql.Tx) error) error {
+	return b.err
+}
+
+// setFailingTransactions makes the transactions of a state returned by
+// testutil.NewState fail with err.
+func setFailingTransactions(t *testing.T, s *state.State, err error) {
+	t.Helper()
+
+	s.Context = database.WithBackend(s.Context, failingBackend{Backend: testutil.StateBackend(t, s), err: err})
+}
+
+// setTestTransactionRetry sets the transaction retry for the duration of the
+// test.
+func setTestTransactionRetry(t *testing.T, attempts int, baseDelay time.Duration) {
+	t.Helper()
+
+	retry := transactionRetry
+	t.Cleanup(func() { transactionRetry = retry })
+
+	err := SetTransactionRetry(attempts, baseDelay)
+	if err != nil {
+		t.Fatalf("Failed to set transaction retry: %v", err)
+	}
+}
+
+func TestTransactionRetriesBusy(t *testing.T) {
+	s := testutil.NewState(t)
+	setTestTransactionRetry(t, 3, time.Millisecond)
+
+	sub := Events.Subscribe(10, SubscriberDrop)
+	defer sub.Close()
+
+	attempts := 0
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		attempts++
+		queueEvent(ctx, Event{Entity: "test", Key: strconv.Itoa(attempts)})
+
+		if attempts < 3 {
+			return &driver.Error{Code: driver.ErrBusy, Message: "database is locked"}
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected the transaction to succeed once the database is no longer busy, got %v", err)
+	}
+
+	if attempts != 3 {
+		t.Fatalf("Transaction ran %d times, expected 2 busy attempts and a successful one", attempts)
+	}
+
+	event := <-sub.C
+	if event.Key != "3" {
+		t.Errorf("Published the event of attempt %s, expected only that of the committed attempt", event.Key)
+	}
+
+	select {
+	case event := <-sub.C:
+		t.Errorf("Published the event of attempt %s, expected the events of failed attempts dropped", event.Key)
+	default:
+	}
+
+	// A database busy on every attempt is given up on once the attempts
+	// are exhausted.
+	attempts = 0
+	err = transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		attempts++
+
+		return &driver.Error{Code: driver.ErrBusy, Message: "database is locked"}
+	})
+	if !api.StatusErrorCheck(err, http.StatusServiceUnavailable) {
+		t.Errorf("Expected a database busy on every attempt to be reported with 503, got %v", err)
+	}
+
+	if attempts != 3 {
+		t.Errorf("Transaction ran %d times, expected the 3 configured attempts", attempts)
+	}
+}
+
+func TestSetTransactionRetry(t *testing.T) {
+	setTestTransactionRetry(t, 5, 50*time.Millisecond)
+
+	tests := []struct {
+		attempts  int
+		baseDelay time.Duration
+		valid     bool
+	}{
+		{attempts: 1, baseDelay: 0, valid: true},
+		{attempts: 10, baseDelay: time.Second, valid: true},
+		{attempts: 0, baseDelay: time.Second, valid: false},
+		{attempts: 3, baseDelay: -time.Second, valid: false},
+	}
+
+	for _, test := range tests {
+		err := SetTransactionRetry(test.attempts, test.baseDelay)
+		if test.valid && err != nil {
+			t.Errorf("Failed to set %d attempts with a %s delay: %v", test.attempts, test.baseDelay, err)
+		} else if !test.valid && err == nil {
+			t.Errorf("Expected %d attempts with a %s delay to be rejected", test.attempts, test.baseDelay)
+		}
+	}
+}
+
+func TestTransactionDoesNotRetryConstraints(t *testing.T) {
+	s := testutil.NewState(t)
+	setTestTransactionRetry(t, 3, time.Millisecond)
+
+	constraint := errors.New("UNIQUE constraint failed: nodes.name")
+
+	attempts := 0
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		attempts++
+
+		return constraint
+	})
+	if !errors.Is(err, constraint) {
+		t.Fatalf("Expected the constraint violation to be returned, got %v", err)
+	}
+
+	if attempts != 1 {
+		t.Errorf("Transaction ran %d times, expected a constraint violation not to be retried", attempts)
+	}
+
+	// The backend failing on every attempt is given up on as well.
+	setFailingTransactions(t, s, &driver.Error{Code: driver.ErrBusy, Message: "database is locked"})
+
+	err = transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		return nil
+	})
+	if !api.StatusErrorCheck(err, http.StatusServiceUnavailable) {
+		t.Errorf("Expected a database busy on every attempt to be reported with 503, got %v", err)
+	}
+}
+
+func TestTransactionTimeout(t *testing.T) {
+	s := testutil.NewState(t)
+
+	timeout := transactionTimeout
+	t.Cleanup(func() { transactionTimeout = timeout })
+
+	err := SetTransactionTimeout(-time.Second)
+	if err == nil {
+		t.Error("Expected a negative transaction timeout to be rejected")
+	}
+
+	err = SetTransactionTimeout(50 * time.Millisecond)
+	if err != nil {
+		t.Fatalf("Failed to set transaction timeout: %v", err)
+	}
+
+	// A stuck transaction is abandoned.
+	start := time.Now()
+	err = transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		<-ctx.Done()
+
+		return ctx.Err()
+	})
+	if !api.StatusErrorCheck(err, http.StatusServiceUnavailable) || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("Expected a stuck transaction to time out with 503, got %v", err)
+	}
+
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("Stuck transaction was abandoned after %s, expected about 50ms", time.Since(start))
+	}
+
+	// Bulk operations are not bounded.
+	err = bulkTransaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+			return nil
+		}
+	})
+	if err != nil {
+		t.Errorf("Expected a bulk transaction to outlast the transaction timeout, got %v", err)
+	}
+}
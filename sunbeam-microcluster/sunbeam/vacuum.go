@@ -0,0 +1,96 @@
+package sunbeam
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/canonical/lxd/shared/api"
+	"github.com/canonical/lxd/shared/logger"
+	"github.com/canonical/microcluster/state"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/api/types"
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/database"
+)
+
+// minVacuumInterval is the least time between two vacuums, as a vacuum
+// locks the database while it runs.
+const minVacuumInterval = 10 * time.Minute
+
+// lastVacuum holds when the database was last vacuumed by this member. Its
+// lock is held during a vacuum so that vacuums never run concurrently.
+var lastVacuum struct {
+	sync.Mutex
+	at time.Time
+}
+
+// Vacuum checkpoints and vacuums the database to reclaim the space left by
+// deleted rows, and returns its size before and after. It only runs on the
+// dqlite leader, at most once every minVacuumInterval after a successful
+// vacuum. The vacuum itself runs on a database handle of its own, as it
+// cannot run within a transaction, and is not bound by the transaction
+// timeout.
+func Vacuum(s *state.State) (types.Vacuum, error) {
+	vacuum := types.Vacuum{}
+
+	err := requireLeader(s)
+	if err != nil {
+		return vacuum, err
+	}
+
+	lastVacuum.Lock()
+	defer lastVacuum.Unlock()
+
+	wait := minVacuumInterval - time.Since(lastVacuum.at)
+	if !lastVacuum.at.IsZero() && wait > 0 {
+		return vacuum, api.StatusErrorf(http.StatusTooManyRequests, "Database was vacuumed less than %s ago, retry in %s", minVacuumInterval, wait.Round(time.Second))
+	}
+
+	start := time.Now()
+
+	vacuum.SizeBefore, err = databaseSize(s)
+	if err != nil {
+		return types.Vacuum{}, err
+	}
+
+	db, err := databaseBackend(s).Open(s.Context)
+	if err != nil {
+		return types.Vacuum{}, err
+	}
+
+	defer func() { _ = db.Close() }()
+
+	err = database.Vacuum(s.Context, db)
+	if err != nil {
+		return types.Vacuum{}, err
+	}
+
+	lastVacuum.at = start
+
+	vacuum.SizeAfter, err = databaseSize(s)
+	if err != nil {
+		return types.Vacuum{}, err
+	}
+
+	duration := time.Since(start)
+	vacuum.Duration = duration.String()
+
+	logger.Info("Vacuumed database", logger.Ctx{"duration": duration, "size_before": vacuum.SizeBefore, "size_after": vacuum.SizeAfter})
+
+	return vacuum, nil
+}
+
+// databaseSize returns the size of the database in bytes, free pages
+// included.
+func databaseSize(s *state.State) (int64, error) {
+	var size int64
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		var err error
+		size, err = database.GetDatabaseSize(ctx, tx)
+		return err
+	})
+
+	return size, err
+}
@@ -0,0 +1,67 @@
+package sunbeam
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/canonical/lxd/shared/api"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/internal/testutil"
+)
+
+func TestVacuum(t *testing.T) {
+	s := testutil.NewState(t)
+
+	t.Cleanup(func() {
+		lastVacuum.Lock()
+		defer lastVacuum.Unlock()
+
+		lastVacuum.at = time.Time{}
+	})
+
+	config := make(map[string]string, 2000)
+	for i := 0; i < 2000; i++ {
+		config[fmt.Sprintf("vacuum.%d", i)] = strings.Repeat("x", 1024)
+	}
+
+	err := SetConfigBatch(s, config)
+	if err != nil {
+		t.Fatalf("Failed to set config: %v", err)
+	}
+
+	err = transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, "DELETE FROM config WHERE key LIKE 'vacuum.%'")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Failed to delete config: %v", err)
+	}
+
+	testutil.StateBackend(t, s).Leader = "10.0.0.2:7000"
+
+	_, err = Vacuum(s)
+	if !api.StatusErrorCheck(err, http.StatusConflict) {
+		t.Fatalf("Expected vacuuming on a member other than the leader to fail with 409, got %v", err)
+	}
+
+	testutil.StateBackend(t, s).Leader = testutil.MemberAddress
+
+	vacuum, err := Vacuum(s)
+	if err != nil {
+		t.Fatalf("Failed to vacuum database: %v", err)
+	}
+
+	if vacuum.SizeAfter >= vacuum.SizeBefore || vacuum.Duration == "" {
+		t.Errorf("Vacuum went from %d to %d bytes in %q, expected the space of the deleted rows reclaimed", vacuum.SizeBefore, vacuum.SizeAfter, vacuum.Duration)
+	}
+
+	_, err = Vacuum(s)
+	if !api.StatusErrorCheck(err, http.StatusTooManyRequests) {
+		t.Errorf("Expected vacuuming again straight away to fail with 429, got %v", err)
+	}
+}
@@ -0,0 +1,135 @@
+package sunbeam
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/canonical/lxd/shared/logger"
+	"github.com/canonical/microcluster/state"
+	"gopkg.in/yaml.v2"
+
+	"github.com/canonical/snap-openstack/sunbeam-microcluster/database"
+)
+
+// largeValueSize is the size in bytes above which a config value or
+// manifest is reported as unusually large.
+const largeValueSize = 64 * 1024
+
+// AllowedNodeRoles returns the roles nodes may hold, as configured with the
+// roles.allowed config key.
+func AllowedNodeRoles(s *state.State) ([]string, error) {
+	var allowed []string
+
+	err := transaction(s, func(ctx context.Context, tx *sql.Tx) error {
+		var err error
+		allowed, err = database.GetAllowedNodeRoles(ctx, tx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return allowed, nil
+}
+
+// NodeWarnings returns the non-fatal issues found in a node write, given the
+// roles nodes may hold.
+func NodeWarnings(allowed []string, role []string, machineid int, systemid string) []string {
+	warnings := []string{}
+
+	seen := map[string]bool{}
+	for _, r := range role {
+		if seen[r] {
+			warnings = append(warnings, fmt.Sprintf("Role %q is listed more than once", r))
+			continue
+		}
+
+		seen[r] = true
+		if !slices.Contains(allowed, r) {
+			warnings = append(warnings, fmt.Sprintf("Role %q is not one of %s", r, strings.Join(allowed, ", ")))
+		}
+	}
+
+	if role != nil && len(role) == 0 {
+		warnings = append(warnings, "Node has no roles")
+	}
+
+	if machineid < -1 {
+		warnings = append(warnings, fmt.Sprintf("Machine ID %d is negative", machineid))
+	}
+
+	if strings.TrimSpace(systemid) != systemid {
+		warnings = append(warnings, "System ID has leading or trailing whitespace")
+	}
+
+	return warnings
+}
+
+// ConfigWarnings returns the non-fatal issues found in a config write.
+func ConfigWarnings(key string, value string) []string {
+	warnings := []string{}
+
+	if strings.TrimSpace(key) != key {
+		warnings = append(warnings, "Config key has leading or trailing whitespace")
+	}
+
+	if value == "" {
+		warnings = append(warnings, fmt.Sprintf("Config value for %q is empty", key))
+	} else if !json.Valid([]byte(value)) {
+		warnings = append(warnings, fmt.Sprintf("Config value for %q is not valid JSON", key))
+	}
+
+	if len(value) > largeValueSize {
+		warnings = append(warnings, fmt.Sprintf("Config value for %q is unusually large (%d bytes)", key, len(value)))
+	}
+
+	return warnings
+}
+
+// ManifestWarnings returns the non-fatal issues found in a manifest write.
+func ManifestWarnings(data string) []string {
+	warnings := []string{}
+
+	if strings.TrimSpace(data) == "" {
+		warnings = append(warnings, "Manifest is empty")
+		return warnings
+	}
+
+	var manifest map[string]any
+	err := yaml.Unmarshal([]byte(data), &manifest)
+	if err != nil {
+		warnings = append(warnings, fmt.Sprintf("Manifest is not a valid YAML mapping: %v", err))
+	} else {
+		keys := make([]string, 0, len(manifest))
+		for key := range manifest {
+			keys = append(keys, key)
+		}
+
+		// Report the keys in a stable order.
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			if key != "deployment" && key != "software" {
+				warnings = append(warnings, fmt.Sprintf("Manifest has unknown top-level key %q", key))
+			}
+		}
+	}
+
+	if len(data) > largeValueSize {
+		warnings = append(warnings, fmt.Sprintf("Manifest is unusually large (%d bytes)", len(data)))
+	}
+
+	return warnings
+}
+
+// LogWarnings logs the warnings raised by a write that was committed.
+func LogWarnings(target string, warnings []string) {
+	for _, warning := range warnings {
+		logger.Warn(warning, logger.Ctx{"target": target})
+	}
+}